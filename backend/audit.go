@@ -0,0 +1,52 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/pkg/errors"
+)
+
+// AuditBase is the storage prefix of the audit records.
+const AuditBase = "audit/"
+
+// auditRecordKey returns the storage key of a new audit record of the given kind.
+// Keys of the same kind sort by creation time.
+func auditRecordKey(kind string, t time.Time) string {
+	return fmt.Sprintf("%s%s/%020d-%s", AuditBase, kind, t.UnixNano(), uuid.New().String())
+}
+
+// putAuditRecord stores the given audit record of the given kind.
+func putAuditRecord(ctx context.Context, s logical.Storage, kind string, t time.Time, record interface{}) error {
+	entry, err := logical.StorageEntryJSON(auditRecordKey(kind, t), record)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode audit record")
+	}
+	return s.Put(ctx, entry)
+}
+
+// listAuditRecords returns the audit records of the given kind, oldest first.
+func listAuditRecords(ctx context.Context, s logical.Storage, kind string) ([]*logical.StorageEntry, error) {
+	prefix := AuditBase + kind + "/"
+	keys, err := s.List(ctx, prefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list audit records")
+	}
+	sort.Strings(keys)
+
+	entries := make([]*logical.StorageEntry, 0, len(keys))
+	for _, key := range keys {
+		entry, err := s.Get(ctx, prefix+key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read audit record '%s'", key)
+		}
+		if entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
@@ -4,19 +4,45 @@ import (
 	"context"
 	"encoding/hex"
 	"sync"
+	"time"
 
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/encryptor"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
+	"github.com/bloxapp/key-vault/backend/store"
 	"github.com/bloxapp/key-vault/utils/encoder"
 )
 
+// Option configures the backend.
+type Option func(b *backend)
+
+// WithStorageEncryption enables the encryption of the accounts at rest.
+// The accounts are encrypted by a data key, the data key is encrypted by the given encryptor and password.
+func WithStorageEncryption(encryptor encryptor.Encryptor, password []byte) Option {
+	return func(b *backend) {
+		b.encryptor = encryptor
+		b.encryptionPassword = password
+	}
+}
+
+// WithClock sets the clock of the backend, time.Now by default.
+func WithClock(now func() time.Time) Option {
+	return func(b *backend) {
+		b.now = now
+	}
+}
+
 // Factory returns the backend factory
-func Factory(version string, logger *logrus.Logger) logical.Factory {
+func Factory(version string, logger *logrus.Logger, opts ...Option) logical.Factory {
 	return func(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
 		b := newBackend(version, logger)
+		for _, opt := range opts {
+			opt(b)
+		}
 		if err := b.Setup(ctx, conf); err != nil {
 			return nil, err
 		}
@@ -27,11 +53,16 @@ func Factory(version string, logger *logrus.Logger) logical.Factory {
 // newBackend returns the backend
 func newBackend(version string, logger *logrus.Logger) *backend {
 	b := &backend{
-		logger:      logger,
-		Version:     version,
-		signMapLock: &sync.Mutex{},
-		signLock:    make(map[string]*sync.Mutex),
-		encoder:     encoder.New(),
+		logger:       logger,
+		Version:      version,
+		signMapLock:  &sync.Mutex{},
+		signLock:     make(map[string]*sync.Mutex),
+		encoder:      encoder.New(),
+		dataKeyCache: &store.DataKeyCache{},
+		accountCache: store.NewAccountCache(),
+		configCache:  &configCache{},
+		rateLimiter:  newRateLimiter(),
+		now:          time.Now,
 	}
 	b.Backend = &framework.Backend{
 		Help: "",
@@ -39,18 +70,32 @@ func newBackend(version string, logger *logrus.Logger) *backend {
 			versionPaths(b),
 			storagePaths(b),
 			storageSlashingDataPaths(b),
+			storageWatermarksPaths(b),
+			storageEncryptionPaths(b),
+			storageMigrationsPaths(b),
+			storageImportPaths(b),
 			accountsPaths(b),
+			accountsDoppelgangerPaths(b),
+			accountsSharesPaths(b),
 			signsPaths(b),
 			signsVoluntaryExitPath(b),
+			accountsExitsPaths(b),
+			accountsExitEscrowPaths(b),
+			accountsValidatorIndexesPaths(b),
+			signCheckPaths(b),
 			configPaths(b),
+			logLevelPaths(b),
 		),
 		PathsSpecial: &logical.Paths{
 			SealWrapStorage: []string{
-				"wallet/",
+				store.SealWrapPrefix,
 			},
 		},
-		Secrets:     []*framework.Secret{},
-		BackendType: logical.TypeLogical,
+		Secrets:        []*framework.Secret{},
+		BackendType:    logical.TypeLogical,
+		Invalidate:     b.invalidate,
+		Clean:          b.clean,
+		InitializeFunc: b.initialize,
 	}
 	return b
 }
@@ -63,6 +108,34 @@ type backend struct {
 	signMapLock *sync.Mutex
 	signLock    map[string]*sync.Mutex
 	encoder     encoder.IEncoder
+
+	encryptor          encryptor.Encryptor
+	encryptionPassword []byte
+	dataKeyCache       *store.DataKeyCache
+
+	// The decoded accounts and config are cached per mount,
+	// the slashing data is always read from the storage.
+	accountCache *store.AccountCache
+	configCache  *configCache
+
+	migrationLock sync.Mutex
+	// importLock serializes the changes of the import sessions.
+	importLock sync.Mutex
+
+	rateLimiter *rateLimiter
+
+	now func() time.Time
+}
+
+// newStore returns the store of the given storage using the mount caches, encrypting the accounts if enabled.
+func (b *backend) newStore(ctx context.Context, s logical.Storage, network core.Network) *store.HashicorpVaultStore {
+	storage := store.NewHashicorpVaultStore(ctx, s, network)
+	storage.SetAccountCache(b.accountCache)
+	if b.encryptor != nil {
+		storage.SetEncryptor(b.encryptor, b.encryptionPassword)
+		storage.SetDataKeyCache(b.dataKeyCache)
+	}
+	return storage
 }
 
 // pathExistenceCheck checks if the given path exists
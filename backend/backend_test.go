@@ -9,6 +9,5 @@ import (
 
 func TestMain(t *testing.M) {
 	vault.InitCrypto()
-	t.Run()
-	os.Exit(0)
+	os.Exit(t.Run())
 }
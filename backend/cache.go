@@ -0,0 +1,89 @@
+package backend
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/bloxapp/key-vault/backend/store"
+)
+
+// configCache keeps the decoded mount config.
+type configCache struct {
+	mu     sync.RWMutex
+	config *Config
+}
+
+// get returns the cached config, nil if missing.
+func (c *configCache) get() *Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config
+}
+
+// set caches the given config.
+func (c *configCache) set(config *Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config = config
+}
+
+// load returns the cached config, or caches the one returned by the given function if missing.
+// Loads and updates hold the lock so that a config read before an update isn't cached after it.
+func (c *configCache) load(fn func() (*Config, error)) (*Config, error) {
+	if config := c.get(); config != nil {
+		return config, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.config != nil {
+		return c.config, nil
+	}
+	config, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	c.config = config
+	return config, nil
+}
+
+// update stores the config with the given function and drops the cached one once stored.
+func (c *configCache) update(fn func() error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := fn(); err != nil {
+		return err
+	}
+	c.config = nil
+	return nil
+}
+
+// invalidate drops the cached config.
+func (c *configCache) invalidate() {
+	c.set(nil)
+}
+
+// invalidate drops the caches depending on the given storage key.
+// It is called by Vault when a key is modified by another node, e.g. on performance standbys.
+func (b *backend) invalidate(ctx context.Context, key string) {
+	switch {
+	case key == ConfigPattern:
+		b.configCache.invalidate()
+	case key == store.DataKeyPath:
+		b.dataKeyCache.Invalidate()
+		b.accountCache.Invalidate()
+	case strings.HasPrefix(key, store.ImportSessionBase), strings.HasPrefix(key, store.ExitBase):
+		// Staged import chunks and exits are not cached.
+	case strings.HasPrefix(key, store.SealWrapPrefix):
+		b.accountCache.Invalidate()
+	}
+}
+
+// clean drops all the caches, it is called when the mount is unloaded.
+func (b *backend) clean(ctx context.Context) {
+	b.configCache.invalidate()
+	b.dataKeyCache.Invalidate()
+	b.accountCache.Invalidate()
+	b.rateLimiter.reset()
+}
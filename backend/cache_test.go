@@ -0,0 +1,166 @@
+package backend
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+// failingPutStorage fails the writes of the given key.
+type failingPutStorage struct {
+	logical.Storage
+	key string
+}
+
+func (s *failingPutStorage) Put(ctx context.Context, entry *logical.StorageEntry) error {
+	if entry.Key == s.key {
+		return errors.New("storage is down")
+	}
+	return s.Storage.Put(ctx, entry)
+}
+
+func TestMountCache(t *testing.T) {
+	setup := func(t *testing.T) (*backend, logical.Storage) {
+		lb, _ := getBackend(t)
+		req := logical.TestRequest(t, logical.CreateOperation, "accounts/sign")
+		setupBaseStorage(t, req)
+		require.NoError(t, setupStorageWithWalletAndAccounts(req.Storage))
+		return lb.(*backend), req.Storage
+	}
+	sign := func(t *testing.T, b *backend, storage logical.Storage) (*logical.Response, error) {
+		req := logical.TestRequest(t, logical.CreateOperation, "accounts/sign")
+		req.Storage = storage
+		req.Data = basicAggregationAndProofData()
+		return b.HandleRequest(context.Background(), req)
+	}
+	deleteAccounts := func(t *testing.T, storage logical.Storage) []string {
+		keys, err := storage.List(context.Background(), store.AccountBase)
+		require.NoError(t, err)
+		for _, key := range keys {
+			require.NoError(t, storage.Delete(context.Background(), store.AccountBase+key))
+		}
+		return keys
+	}
+
+	t.Run("accounts", func(t *testing.T) {
+		b, storage := setup(t)
+
+		res, err := sign(t, b, storage)
+		require.NoError(t, err)
+		require.NotEmpty(t, res.Data["signature"])
+		require.Equal(t, 1, b.accountCache.Len())
+
+		// the cached account is used
+		keys := deleteAccounts(t, storage)
+		res, err = sign(t, b, storage)
+		require.NoError(t, err)
+		require.NotEmpty(t, res.Data["signature"])
+
+		// until it is invalidated by Vault
+		b.InvalidateKey(context.Background(), store.AccountBase+keys[0])
+		require.Equal(t, 0, b.accountCache.Len())
+		res, err = sign(t, b, storage)
+		requireCodedError(t, res, err, errorex.CodeUnknownAccount, "failed to sign: account not found")
+	})
+
+	t.Run("config", func(t *testing.T) {
+		b, storage := setup(t)
+
+		_, err := sign(t, b, storage)
+		require.NoError(t, err)
+		require.NotNil(t, b.configCache.get())
+
+		// the cached config is used
+		require.NoError(t, storage.Delete(context.Background(), ConfigPattern))
+		_, err = sign(t, b, storage)
+		require.NoError(t, err)
+
+		// until it is invalidated by Vault
+		b.InvalidateKey(context.Background(), ConfigPattern)
+		res, err := sign(t, b, storage)
+		requireCodedError(t, res, err, errorex.CodeNotConfigured, "failed to get config: the plugin has not been configured yet")
+
+		// config writes refresh it
+		req := logical.TestRequest(t, logical.CreateOperation, "config")
+		req.Storage = storage
+		req.Data = map[string]interface{}{"network": "mainnet"}
+		_, err = b.HandleRequest(context.Background(), req)
+		require.NoError(t, err)
+
+		req = logical.TestRequest(t, logical.ReadOperation, "config")
+		req.Storage = storage
+		res, err = b.HandleRequest(context.Background(), req)
+		require.NoError(t, err)
+		require.EqualValues(t, "mainnet", res.Data["network"])
+	})
+
+	t.Run("failed config write", func(t *testing.T) {
+		b, storage := setup(t)
+
+		_, err := sign(t, b, storage)
+		require.NoError(t, err)
+
+		req := logical.TestRequest(t, logical.CreateOperation, "config")
+		req.Storage = &failingPutStorage{Storage: storage, key: ConfigPattern}
+		req.Data = map[string]interface{}{"network": "mainnet"}
+		res, err := b.HandleRequest(context.Background(), req)
+		requireCodedError(t, res, err, errorex.CodeStorageFailure, "failed to store config: storage is down")
+
+		// the stored config stays cached
+		config := b.configCache.get()
+		require.NotNil(t, config)
+		require.EqualValues(t, "prater", config.Network)
+	})
+
+	t.Run("storage update", func(t *testing.T) {
+		b, storage := setup(t)
+
+		_, err := sign(t, b, storage)
+		require.NoError(t, err)
+		require.Equal(t, 1, b.accountCache.Len())
+
+		inMemStore, _, err := baseInmemStorage()
+		require.NoError(t, err)
+		byts, err := json.Marshal(inMemStore)
+		require.NoError(t, err)
+		req := logical.TestRequest(t, logical.CreateOperation, "storage")
+		req.Storage = storage
+		req.Data = map[string]interface{}{"data": hex.EncodeToString(byts)}
+		_, err = b.HandleRequest(context.Background(), req)
+		require.NoError(t, err)
+		require.Equal(t, 0, b.accountCache.Len())
+	})
+
+	t.Run("slashing data is not cached", func(t *testing.T) {
+		b, storage := setup(t)
+		require.NoError(t, updateWithBasicHighestAtt(storage))
+
+		req := logical.TestRequest(t, logical.CreateOperation, "accounts/sign")
+		req.Storage = storage
+		req.Data = basicAttestationData()
+		_, err := b.HandleRequest(context.Background(), req)
+		require.NoError(t, err)
+
+		res, err := b.HandleRequest(context.Background(), req)
+		requireCodedError(t, res, err, errorex.CodeSlashable, "failed to sign: slashable attestation (HighestAttestationVote), not signing")
+	})
+
+	t.Run("clean", func(t *testing.T) {
+		b, storage := setup(t)
+
+		_, err := sign(t, b, storage)
+		require.NoError(t, err)
+
+		b.Cleanup(context.Background())
+		require.Equal(t, 0, b.accountCache.Len())
+		require.Nil(t, b.configCache.get())
+	})
+}
@@ -0,0 +1,87 @@
+package backend
+
+import (
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/pkg/errors"
+
+	"github.com/bloxapp/key-vault/keymanager/models"
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+// ErrClockBounds is returned when a sign request is too far from the current wall-clock slot,
+// usually because the clock of the client is broken.
+var ErrClockBounds = errors.New("request is out of the wall-clock bounds")
+
+// slotRange is the first and the last slot of a slot or an epoch of a sign request.
+type slotRange struct {
+	name        string
+	value       uint64
+	first, last phase0.Slot
+}
+
+// requestSlotRanges returns the slots and epochs of the given sign request to bound, none if it isn't bound to a slot.
+// The source epoch of attestations is not bound, it is not ahead of the target one but may be far behind.
+func requestSlotRanges(network core.Network, signReq *models.SignRequest) ([]slotRange, error) {
+	slot := func(slot phase0.Slot) slotRange {
+		return slotRange{name: "slot", value: uint64(slot), first: slot, last: slot}
+	}
+	epoch := func(epoch phase0.Epoch) slotRange {
+		first := phase0.Slot(uint64(epoch) * network.SlotsPerEpoch())
+		return slotRange{name: "epoch", value: uint64(epoch), first: first, last: first + phase0.Slot(network.SlotsPerEpoch()) - 1}
+	}
+
+	switch t := signReq.GetObject().(type) {
+	case *models.SignRequestBlock:
+		blockSlot, err := t.VersionedBeaconBlock.Slot()
+		if err != nil {
+			return nil, errorex.Wrap(errorex.CodeBadRequest, err, "could not get block slot")
+		}
+		return []slotRange{slot(blockSlot)}, nil
+	case *models.SignRequestBlindedBlock:
+		blockSlot, err := t.VersionedBlindedBeaconBlock.Slot()
+		if err != nil {
+			return nil, errorex.Wrap(errorex.CodeBadRequest, err, "could not get block slot")
+		}
+		return []slotRange{slot(blockSlot)}, nil
+	case *models.SignRequestAttestationData:
+		return []slotRange{slot(t.AttestationData.Slot), epoch(t.AttestationData.Target.Epoch)}, nil
+	case *models.SignRequestAggregateAttestationAndProof:
+		return []slotRange{slot(t.AggregateAttestationAndProof.Aggregate.Data.Slot)}, nil
+	case *models.SignRequestSlot:
+		return []slotRange{slot(t.Slot)}, nil
+	case *models.SignRequestEpoch:
+		return []slotRange{epoch(t.Epoch)}, nil
+	case *models.SignRequestSyncAggregatorSelectionData:
+		return []slotRange{slot(t.SyncAggregatorSelectionData.Slot)}, nil
+	case *models.SignRequestContributionAndProof:
+		return []slotRange{slot(t.ContributionAndProof.Contribution.Slot)}, nil
+	default:
+		// Sync committee messages only hold a block root, registrations a timestamp.
+		return nil, nil
+	}
+}
+
+// checkClockBounds refuses the sign requests whose slot or epoch is further ahead of, or behind,
+// the current wall-clock slot than allowed by the config.
+func (b *backend) checkClockBounds(config *Config, network core.Network, signReq *models.SignRequest) error {
+	if config.MaxSlotsAhead == 0 && config.MaxSlotsBehind == 0 {
+		return nil
+	}
+
+	ranges, err := requestSlotRanges(network, signReq)
+	if err != nil {
+		return err
+	}
+
+	current := network.EstimatedSlotAtTime(b.now().Unix())
+	for _, r := range ranges {
+		if config.MaxSlotsAhead > 0 && uint64(r.first) > uint64(current)+config.MaxSlotsAhead {
+			return errors.Wrapf(ErrClockBounds, "%s %d is more than %d slots ahead of the wall-clock slot %d", r.name, r.value, config.MaxSlotsAhead, current)
+		}
+		if config.MaxSlotsBehind > 0 && uint64(r.last)+config.MaxSlotsBehind < uint64(current) {
+			return errors.Wrapf(ErrClockBounds, "%s %d is more than %d slots behind the wall-clock slot %d", r.name, r.value, config.MaxSlotsBehind, current)
+		}
+	}
+	return nil
+}
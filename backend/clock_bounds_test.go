@@ -0,0 +1,134 @@
+package backend
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/keymanager/models"
+	"github.com/bloxapp/key-vault/utils/encoder"
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+// slotTime returns the wall-clock time of the given prater slot.
+func slotTime(slot phase0.Slot) time.Time {
+	network := core.PraterNetwork
+	return time.Unix(int64(network.MinGenesisTime()), 0).Add(time.Duration(slot) * network.SlotDurationSec())
+}
+
+func TestCheckClockBounds(t *testing.T) {
+	b := newBackend("test", nil)
+	b.now = func() time.Time {
+		return slotTime(3200)
+	}
+	config := &Config{MaxSlotsAhead: 4, MaxSlotsBehind: 64}
+	check := func(object models.ISignObject) error {
+		return b.checkClockBounds(config, core.PraterNetwork, &models.SignRequest{Object: object})
+	}
+	attestation := func(slot phase0.Slot, target phase0.Epoch) *models.SignRequestAttestationData {
+		return &models.SignRequestAttestationData{AttestationData: &phase0.AttestationData{
+			Slot:   slot,
+			Source: &phase0.Checkpoint{Epoch: 0},
+			Target: &phase0.Checkpoint{Epoch: target},
+		}}
+	}
+
+	tests := []struct {
+		name   string
+		object models.ISignObject
+		err    string
+	}{
+		{name: "attestation", object: attestation(3200, 100)},
+		{name: "attestation ahead", object: attestation(3205, 100), err: "slot 3205 is more than 4 slots ahead of the wall-clock slot 3200: request is out of the wall-clock bounds"},
+		{name: "attestation target ahead", object: attestation(3200, 101), err: "epoch 101 is more than 4 slots ahead of the wall-clock slot 3200: request is out of the wall-clock bounds"},
+		{name: "attestation behind", object: attestation(3135, 97), err: "slot 3135 is more than 64 slots behind the wall-clock slot 3200: request is out of the wall-clock bounds"},
+		{name: "randao", object: &models.SignRequestEpoch{Epoch: 98}},
+		{name: "randao behind", object: &models.SignRequestEpoch{Epoch: 97}, err: "epoch 97 is more than 64 slots behind the wall-clock slot 3200: request is out of the wall-clock bounds"},
+		{name: "selection proof ahead", object: &models.SignRequestSlot{Slot: 3300}, err: "slot 3300 is more than 4 slots ahead of the wall-clock slot 3200: request is out of the wall-clock bounds"},
+		{name: "sync selection data", object: &models.SignRequestSyncAggregatorSelectionData{SyncAggregatorSelectionData: &altair.SyncAggregatorSelectionData{Slot: 3204}}},
+		{name: "sync contribution behind", object: &models.SignRequestContributionAndProof{ContributionAndProof: &altair.ContributionAndProof{
+			Contribution: &altair.SyncCommitteeContribution{Slot: 10},
+		}}, err: "slot 10 is more than 64 slots behind the wall-clock slot 3200: request is out of the wall-clock bounds"},
+		{name: "sync committee message", object: &models.SignRequestSyncCommitteeMessage{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := check(test.object)
+			if len(test.err) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, test.err)
+			code, ok := signErrorCode(err)
+			require.True(t, ok)
+			require.Equal(t, errorex.CodePolicyViolation, code)
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		config = &Config{}
+		require.NoError(t, check(&models.SignRequestSlot{Slot: 1000000}))
+	})
+}
+
+func TestSignClockBounds(t *testing.T) {
+	b, _ := getBackend(t)
+	b.(*backend).now = func() time.Time {
+		return slotTime(3200)
+	}
+	defer func() {
+		b.(*backend).now = time.Now
+	}()
+
+	request := func(t *testing.T, storage logical.Storage, path string, slot phase0.Slot) (*logical.Response, error) {
+		req := logical.TestRequest(t, logical.CreateOperation, path)
+		req.Storage = storage
+		att := &phase0.AttestationData{
+			Slot:   slot,
+			Source: &phase0.Checkpoint{Epoch: phase0.Epoch(slot/32) - 1},
+			Target: &phase0.Checkpoint{Epoch: phase0.Epoch(slot / 32)},
+		}
+		req.Data = reqObject(att, _byteArray32("01000000f071c66c6561d0b939feb15f513a019d99a84bd85635221e3ad42dac"),
+			_byteArray("95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf"))
+		return b.HandleRequest(context.Background(), req)
+	}
+
+	req := logical.TestRequest(t, logical.CreateOperation, "accounts/sign")
+	setupBaseStorage(t, req, func(config *Config) {
+		config.MaxSlotsAhead = 32
+		config.MaxSlotsBehind = 32
+	})
+	// the config is cached per mount
+	b.InvalidateKey(context.Background(), ConfigPattern)
+	require.NoError(t, setupStorageWithWalletAndAccounts(req.Storage))
+
+	// a broken clock doesn't raise the watermarks
+	res, err := request(t, req.Storage, "accounts/sign", 10000)
+	requireCodedError(t, res, err, errorex.CodePolicyViolation, "failed to sign: slot 10000 is more than 32 slots ahead of the wall-clock slot 3200: request is out of the wall-clock bounds")
+
+	res, err = request(t, req.Storage, "accounts/sign/check", 10000)
+	require.NoError(t, err)
+	require.Equal(t, SignVerdictRefused, res.Data["verdict"])
+
+	res, err = request(t, req.Storage, "accounts/sign", 3200)
+	require.NoError(t, err)
+	require.NotEmpty(t, res.Data["signature"])
+
+	// randao reveals are bound as well
+	byts, err := encoder.New().Encode(&models.SignRequest{
+		PublicKey:       _byteArray("95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf"),
+		SignatureDomain: _byteArray32("02000000f071c66c6561d0b939feb15f513a019d99a84bd85635221e3ad42dac"),
+		Object:          &models.SignRequestEpoch{Epoch: 1},
+	})
+	require.NoError(t, err)
+	req.Data = map[string]interface{}{"sign_req": hex.EncodeToString(byts)}
+	res, err = b.HandleRequest(context.Background(), req)
+	requireCodedError(t, res, err, errorex.CodePolicyViolation, "failed to sign: epoch 1 is more than 32 slots behind the wall-clock slot 3200: request is out of the wall-clock bounds")
+}
@@ -0,0 +1,86 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bloxapp/eth2-key-manager/wallets/hd"
+	"github.com/bloxapp/eth2-key-manager/wallets/nd"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/pkg/errors"
+
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+// withCodedErrors converts the coded errors returned by the given callback
+// into responses with the matching HTTP status and machine-readable code.
+// Other errors are returned as is and end up as HTTP 500.
+func withCodedErrors(callback framework.OperationFunc) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		resp, err := callback(ctx, req, data)
+		if err == nil {
+			return resp, nil
+		}
+
+		codedErr, ok := errorex.AsCodedError(err)
+		if !ok {
+			return nil, err
+		}
+		reportRequestError(ctx, err)
+		// Keep the messages of the wrapping errors.
+		return errorex.NewCodedError(codedErr.Code, err.Error()).ToLogicalResponse()
+	}
+}
+
+// SlashableError is returned when the slashing protection refuses a sign request.
+// The signer doesn't expose typed errors, so the slashing checks are run by checkSignRequest before signing.
+type SlashableError struct {
+	msg string
+}
+
+// newSlashableError is the constructor of SlashableError.
+func newSlashableError(format string, args ...interface{}) *SlashableError {
+	return &SlashableError{msg: fmt.Sprintf(format, args...)}
+}
+
+// Error implements error interface.
+func (e *SlashableError) Error() string {
+	return e.msg
+}
+
+// signErrorCode returns the code of the given signer error.
+func signErrorCode(err error) (errorex.ErrorCode, bool) {
+	if codedErr, ok := errorex.AsCodedError(err); ok {
+		return codedErr.Code, true
+	}
+
+	var slashableErr *SlashableError
+	switch {
+	case errors.As(err, &slashableErr):
+		return errorex.CodeSlashable, true
+	case errors.Is(err, hd.ErrAccountNotFound), errors.Is(err, nd.ErrAccountNotFound):
+		return errorex.CodeUnknownAccount, true
+	case errors.Is(err, ErrFeeRecipientNotSet), errors.Is(err, ErrFeeRecipientDiffers), errors.Is(err, ErrDoppelgangerWindow),
+		errors.Is(err, ErrClockBounds), errors.Is(err, ErrKeyShareAccount), errors.Is(err, ErrValidatorIndexUnknown),
+		errors.Is(err, ErrValidatorIndexMismatch), errors.Is(err, ErrExitEpochAhead), errors.Is(err, ErrValidatorExited),
+		errors.Is(err, ErrExitEscrowKeyShare):
+		return errorex.CodePolicyViolation, true
+	case errors.Is(err, ErrRateLimited):
+		return errorex.CodeRateLimited, true
+	}
+	return "", false
+}
+
+// wrapSignError annotates the given signer error with its code, if known.
+func wrapSignError(err error) error {
+	return wrapCodedSignError(err, "failed to sign")
+}
+
+// wrapCodedSignError wraps the given signer error with the given message and its code, if known.
+func wrapCodedSignError(err error, message string) error {
+	if code, ok := signErrorCode(err); ok {
+		return errorex.Wrap(code, err, message)
+	}
+	return errors.Wrap(err, message)
+}
@@ -0,0 +1,88 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/bloxapp/eth2-key-manager/wallets/hd"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+// requireCodedError asserts that the given response is a coded error response.
+func requireCodedError(t *testing.T, res *logical.Response, err error, code errorex.ErrorCode, message string) {
+	require.NoError(t, err)
+	require.NotNil(t, res)
+	require.Equal(t, code.StatusCode(), res.Data[logical.HTTPStatusCode])
+
+	var body struct {
+		Data struct {
+			Code       errorex.ErrorCode `json:"code"`
+			Message    string            `json:"message"`
+			StatusCode int               `json:"status_code"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(res.Data[logical.HTTPRawBody].(string)), &body))
+	require.Equal(t, code, body.Data.Code)
+	require.Equal(t, message, body.Data.Message)
+	require.Equal(t, code.StatusCode(), body.Data.StatusCode)
+}
+
+func TestWithCodedErrors(t *testing.T) {
+	callback := func(err error) framework.OperationFunc {
+		return withCodedErrors(func(context.Context, *logical.Request, *framework.FieldData) (*logical.Response, error) {
+			return nil, err
+		})
+	}
+
+	t.Run("coded error", func(t *testing.T) {
+		res, err := callback(errors.Wrap(errorex.NewCodedError(errorex.CodeNotConfigured, "the plugin has not been configured yet"), "failed to get config"))(context.Background(), nil, nil)
+		requireCodedError(t, res, err, errorex.CodeNotConfigured, "failed to get config: the plugin has not been configured yet")
+	})
+
+	t.Run("other error", func(t *testing.T) {
+		res, err := callback(errors.New("unexpected"))(context.Background(), nil, nil)
+		require.EqualError(t, err, "unexpected")
+		require.Nil(t, res)
+	})
+}
+
+func TestSignErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code errorex.ErrorCode
+	}{
+		{err: hd.ErrAccountNotFound, code: errorex.CodeUnknownAccount},
+		{err: errors.Wrap(ErrFeeRecipientDiffers, "refused to sign"), code: errorex.CodePolicyViolation},
+		{err: errors.Wrap(fmt.Errorf("checked: %w", ErrValidatorExited), "refused to sign"), code: errorex.CodePolicyViolation},
+		{err: newSlashableError("slashable attestation (%s), not signing", "HighestAttestationVote"), code: errorex.CodeSlashable},
+		{err: errors.Wrap(newSlashableError("target epoch too far into the future"), "failed to check"), code: errorex.CodeSlashable},
+		{err: errorex.Wrap(errorex.CodeStorageFailure, errors.New("timeout"), "failed to open key vault"), code: errorex.CodeStorageFailure},
+		// the messages of the signer aren't matched
+		{err: errors.New("slashable attestation (HighestAttestationVote), not signing")},
+		{err: errors.New("unexpected")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			code, ok := signErrorCode(tt.err)
+			require.Equal(t, tt.code != "", ok)
+			require.Equal(t, tt.code, code)
+		})
+	}
+}
+
+func TestCodedErrorChain(t *testing.T) {
+	err := errors.Wrap(errorex.Wrap(errorex.CodeRateLimited, ErrRateLimited, "failed to sign"), "request failed")
+	require.EqualError(t, err, "request failed: failed to sign: "+ErrRateLimited.Error())
+	require.True(t, errors.Is(err, ErrRateLimited))
+
+	codedErr, ok := errorex.AsCodedError(err)
+	require.True(t, ok)
+	require.Equal(t, errorex.CodeRateLimited, codedErr.Code)
+}
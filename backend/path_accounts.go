@@ -3,6 +3,8 @@ package backend
 import (
 	"context"
 	"encoding/hex"
+	"strconv"
+	"time"
 
 	vault "github.com/bloxapp/eth2-key-manager"
 	"github.com/hashicorp/vault/sdk/framework"
@@ -10,6 +12,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/utils/errorex"
 )
 
 // Endpoints patterns
@@ -28,7 +31,7 @@ func accountsPaths(b *backend) []*framework.Path {
 			ExistenceCheck:  b.pathExistenceCheck,
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.ListOperation: &framework.PathOperation{
-					Callback: b.pathWalletAccountsList,
+					Callback: withCodedErrors(b.pathWalletAccountsList),
 				},
 			},
 		},
@@ -42,20 +45,30 @@ func (b *backend) pathWalletAccountsList(ctx context.Context, req *logical.Reque
 		return nil, errors.Wrap(err, "failed to get config")
 	}
 
-	storage := store.NewHashicorpVaultStore(ctx, req.Storage, config.Network)
+	storage := b.newStore(ctx, req.Storage, config.Network)
 	options := vault.KeyVaultOptions{}
 	options.SetStorage(storage)
 
 	portfolio, err := vault.OpenKeyVault(&options)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to open key vault")
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to open key vault")
 	}
 
 	wallet, err := portfolio.Wallet()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to retrieve wallet by name")
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to retrieve wallet by name")
 	}
 
+	shares, err := storage.ListKeyShares()
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to list key shares")
+	}
+	sharesByPubKey := make(map[string]*store.KeyShare, len(shares))
+	for _, share := range shares {
+		sharesByPubKey[share.SharePublicKey] = share
+	}
+
+	now := b.now()
 	var accounts []map[string]string
 	for _, a := range wallet.Accounts() {
 		accObj := map[string]string{
@@ -64,6 +77,22 @@ func (b *backend) pathWalletAccountsList(ctx context.Context, req *logical.Reque
 			"validationPubKey": hex.EncodeToString(a.ValidatorPublicKey()),
 			"withdrawalPubKey": hex.EncodeToString(a.WithdrawalPublicKey()),
 		}
+
+		// Pending doppelganger protection window
+		window, err := storage.DoppelgangerWindow(a.ValidatorPublicKey())
+		if err != nil {
+			return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read doppelganger window")
+		}
+		if window != nil && window.Pending(now) {
+			accObj["activationEpoch"] = strconv.FormatUint(uint64(window.ActivationEpoch), 10)
+			accObj["activationTime"] = window.ActivationTime.Format(time.RFC3339)
+		}
+
+		// Key share of a group public key
+		if share, ok := sharesByPubKey[accObj["validationPubKey"]]; ok {
+			accObj["groupPubKey"] = share.GroupPublicKey
+			accObj["shareIndex"] = strconv.FormatUint(share.Index, 10)
+		}
 		accounts = append(accounts, accObj)
 	}
 
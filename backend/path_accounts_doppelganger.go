@@ -0,0 +1,170 @@
+package backend
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/pkg/errors"
+
+	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/keymanager/models"
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+// Endpoints patterns
+const (
+	// DoppelgangerPattern is the path pattern for the doppelganger protection endpoint,
+	// the window of an account is released at DoppelgangerPattern/:public_key
+	DoppelgangerPattern = "accounts/doppelganger"
+)
+
+// doppelgangerAuditKind is the kind of the doppelganger window release audit records.
+const doppelgangerAuditKind = "doppelganger"
+
+// ErrDoppelgangerWindow is returned when an account in its doppelganger protection window is requested
+// to sign an attestation or a block.
+var ErrDoppelgangerWindow = errors.New("account is in its doppelganger protection window")
+
+// DoppelgangerAuditRecord is the audit record of a doppelganger window released by an operator.
+type DoppelgangerAuditRecord struct {
+	Time      time.Time                 `json:"time"`
+	PublicKey string                    `json:"public_key"`
+	EntityID  string                    `json:"entity_id,omitempty"`
+	Window    *store.DoppelgangerWindow `json:"window"`
+}
+
+func accountsDoppelgangerPaths(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern:         DoppelgangerPattern + "/" + framework.GenericNameRegex("public_key"),
+			HelpSynopsis:    "Release the doppelganger protection window of an account",
+			HelpDescription: `Let an imported account sign attestations and blocks before the end of its doppelganger protection window`,
+			Fields: map[string]*framework.FieldSchema{
+				"public_key": {
+					Type:        framework.TypeString,
+					Description: "Hex encoded public key of the account",
+				},
+			},
+			ExistenceCheck: b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathDoppelgangerRelease),
+				},
+			},
+		},
+	}
+}
+
+func (b *backend) pathDoppelgangerRelease(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// Load config
+	config, err := b.readConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get config")
+	}
+
+	publicKey := data.Get("public_key").(string)
+	pubKey, err := hex.DecodeString(publicKey)
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeBadRequest, err, "failed to decode public key")
+	}
+
+	// Expired windows are deleted as well, only pending ones are reported as released
+	released := false
+	err = b.lock(pubKey, func() error {
+		storage := b.newStore(ctx, req.Storage, config.Network)
+		if _, err := storage.AccountByPublicKey(pubKey); err != nil {
+			return wrapCodedSignError(err, "failed to release doppelganger window")
+		}
+
+		window, err := storage.DoppelgangerWindow(pubKey)
+		if err != nil {
+			return errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read doppelganger window")
+		}
+		if window == nil {
+			return nil
+		}
+
+		now := b.now().UTC()
+		if err := putAuditRecord(ctx, req.Storage, doppelgangerAuditKind, now, &DoppelgangerAuditRecord{
+			Time:      now,
+			PublicKey: publicKey,
+			EntityID:  req.EntityID,
+			Window:    window,
+		}); err != nil {
+			return errorex.Wrap(errorex.CodeStorageFailure, err, "failed to store audit record")
+		}
+		if err := storage.DeleteDoppelgangerWindow(pubKey); err != nil {
+			return errorex.Wrap(errorex.CodeStorageFailure, err, "failed to delete doppelganger window")
+		}
+		released = window.Pending(now)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"public_key": publicKey,
+			"released":   released,
+		},
+	}, nil
+}
+
+// doppelgangerEpochsOverride returns the doppelganger epochs of the given import request, nil to use the config.
+func doppelgangerEpochsOverride(data *framework.FieldData) (*uint64, error) {
+	value, ok := data.GetOk("doppelganger_epochs")
+	if !ok {
+		return nil, nil
+	}
+	epochs := value.(int)
+	if epochs < 0 {
+		return nil, errorex.NewErrBadRequest("invalid doppelganger epochs provided")
+	}
+	override := uint64(epochs)
+	return &override, nil
+}
+
+// saveDoppelgangerWindows starts the doppelganger protection window of the accounts added by the given import.
+func (b *backend) saveDoppelgangerWindows(storage *store.HashicorpVaultStore, report *store.ImportReport, epochs uint64) error {
+	if epochs == 0 || report.DryRun {
+		return nil
+	}
+
+	now := b.now()
+	for _, account := range report.Accounts {
+		if account.Status != store.ImportStatusAdded {
+			continue
+		}
+		pubKey, err := hex.DecodeString(account.PublicKey)
+		if err != nil {
+			return errors.Wrap(err, "failed to decode public key")
+		}
+		if err := storage.SaveDoppelgangerWindow(pubKey, store.NewDoppelgangerWindow(storage.Network(), now, epochs)); err != nil {
+			return errors.Wrapf(err, "failed to save doppelganger window of '%s'", account.PublicKey)
+		}
+	}
+	return nil
+}
+
+// checkDoppelgangerWindow refuses the attestations and blocks of the accounts in their doppelganger protection window.
+// The window is the one of the signing account, see signingKey, the imported share accounts sign for their group public key.
+func (b *backend) checkDoppelgangerWindow(storage *store.HashicorpVaultStore, signingPubKey []byte, signReq *models.SignRequest) error {
+	switch signReq.GetObject().(type) {
+	case *models.SignRequestBlock, *models.SignRequestBlindedBlock, *models.SignRequestAttestationData:
+	default:
+		return nil
+	}
+
+	window, err := storage.DoppelgangerWindow(signingPubKey)
+	if err != nil {
+		return errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read doppelganger window")
+	}
+	if window == nil || !window.Pending(b.now()) {
+		return nil
+	}
+	return errors.Wrapf(ErrDoppelgangerWindow, "refused to sign before epoch %d (%s)", window.ActivationEpoch, window.ActivationTime.Format(time.RFC3339))
+}
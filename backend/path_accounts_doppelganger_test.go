@@ -0,0 +1,186 @@
+package backend
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/stores/inmemory"
+	"github.com/bloxapp/eth2-key-manager/wallets/hd"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+func TestDoppelgangerWindow(t *testing.T) {
+	b, _ := getBackend(t)
+	now := time.Unix(int64(core.PraterNetwork.MinGenesisTime()), 0).Add(100 * 32 * 12 * time.Second)
+	b.(*backend).now = func() time.Time {
+		return now
+	}
+	defer func() {
+		b.(*backend).now = time.Now
+	}()
+
+	inMemStore, _, err := baseInmemStorage()
+	require.NoError(t, err)
+	byts, err := json.Marshal(inMemStore)
+	require.NoError(t, err)
+	pubKey := "95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf"
+
+	request := func(t *testing.T, storage logical.Storage, operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		req := logical.TestRequest(t, operation, path)
+		req.Storage = storage
+		req.Data = data
+		return b.HandleRequest(context.Background(), req)
+	}
+	importStorage := func(t *testing.T, data map[string]interface{}) logical.Storage {
+		req := logical.TestRequest(t, logical.CreateOperation, "storage")
+		setupBaseStorage(t, req, func(config *Config) {
+			config.DoppelgangerEpochs = 2
+		})
+		// the config is cached per mount
+		b.InvalidateKey(context.Background(), ConfigPattern)
+
+		if data == nil {
+			data = map[string]interface{}{}
+		}
+		if _, ok := data["data"]; !ok {
+			data["data"] = hex.EncodeToString(byts)
+		}
+		_, err := request(t, req.Storage, logical.CreateOperation, "storage", data)
+		require.NoError(t, err)
+		return req.Storage
+	}
+	requireSignable := func(t *testing.T, storage logical.Storage, signable bool) {
+		res, err := request(t, storage, logical.CreateOperation, "accounts/sign", basicAttestationData())
+		if signable {
+			require.NoError(t, err)
+			require.NotEmpty(t, res.Data["signature"])
+			return
+		}
+		requireCodedError(t, res, err, errorex.CodePolicyViolation, "failed to sign: refused to sign before epoch 103 (2021-03-24T00:59:12Z): account is in its doppelganger protection window")
+	}
+
+	t.Run("window of the configured epochs", func(t *testing.T) {
+		storage := importStorage(t, nil)
+		requireSignable(t, storage, false)
+
+		res, err := request(t, storage, logical.CreateOperation, "accounts/sign/check", basicAttestationData())
+		require.NoError(t, err)
+		require.Equal(t, SignVerdictRefused, res.Data["verdict"])
+
+		// other requests are signed
+		res, err = request(t, storage, logical.CreateOperation, "accounts/sign", basicAggregationAndProofData())
+		require.NoError(t, err)
+		require.NotEmpty(t, res.Data["signature"])
+
+		res, err = request(t, storage, logical.ListOperation, "accounts/", nil)
+		require.NoError(t, err)
+		account := res.Data["accounts"].([]map[string]string)[0]
+		require.Equal(t, "103", account["activationEpoch"])
+		require.Equal(t, "2021-03-24T00:59:12Z", account["activationTime"])
+
+		// the epoch of the import isn't counted
+		now = now.Add(2 * 32 * 12 * time.Second)
+		requireSignable(t, storage, false)
+
+		// the account signs from the activation epoch
+		now = now.Add(32 * 12 * time.Second)
+		defer func() {
+			now = now.Add(-3 * 32 * 12 * time.Second)
+		}()
+		requireSignable(t, storage, true)
+
+		res, err = request(t, storage, logical.ListOperation, "accounts/", nil)
+		require.NoError(t, err)
+		require.NotContains(t, res.Data["accounts"].([]map[string]string)[0], "activationEpoch")
+	})
+
+	t.Run("override", func(t *testing.T) {
+		storage := importStorage(t, map[string]interface{}{"doppelganger_epochs": 0})
+		requireSignable(t, storage, true)
+
+		res, err := request(t, storage, logical.CreateOperation, "storage", map[string]interface{}{
+			"data":                hex.EncodeToString(byts),
+			"doppelganger_epochs": -1,
+		})
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "invalid doppelganger epochs provided")
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		storage := importStorage(t, map[string]interface{}{"dry_run": true})
+		window, err := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork).DoppelgangerWindow(_byteArray(pubKey))
+		require.NoError(t, err)
+		require.Nil(t, window)
+	})
+
+	t.Run("key share", func(t *testing.T) {
+		sk, shares := testKeyShares(t, 2, 2)
+		groupPubKey := sk.GetPublicKey().Serialize()
+		shareStore := inmemory.NewInMemStore(core.PraterNetwork)
+		wallet := hd.NewWallet(&core.WalletContext{Storage: shareStore})
+		require.NoError(t, shareStore.SaveWallet(wallet))
+		index := 0
+		_, err := wallet.CreateValidatorAccountFromPrivateKey(shares[1].Serialize(), &index)
+		require.NoError(t, err)
+		shareByts, err := json.Marshal(shareStore)
+		require.NoError(t, err)
+
+		storage := importStorage(t, map[string]interface{}{"data": hex.EncodeToString(shareByts)})
+		_, err = request(t, storage, logical.CreateOperation, "accounts/shares/"+hex.EncodeToString(groupPubKey), map[string]interface{}{
+			"share_public_key": hex.EncodeToString(shares[1].GetPublicKey().Serialize()),
+			"index":            1,
+			"threshold":        2,
+			"peer_public_keys": map[string]interface{}{
+				"2": hex.EncodeToString(shares[2].GetPublicKey().Serialize()),
+			},
+		})
+		require.NoError(t, err)
+
+		// the group public key is signed by the share account, in its window
+		att := &phase0.AttestationData{
+			Slot:   3200,
+			Source: &phase0.Checkpoint{Epoch: 99},
+			Target: &phase0.Checkpoint{Epoch: 100},
+		}
+		domain := _byteArray32("01000000f071c66c6561d0b939feb15f513a019d99a84bd85635221e3ad42dac")
+		res, err := request(t, storage, logical.CreateOperation, "accounts/sign", reqObject(att, domain, groupPubKey))
+		requireCodedError(t, res, err, errorex.CodePolicyViolation, "failed to sign: refused to sign before epoch 103 (2021-03-24T00:59:12Z): account is in its doppelganger protection window")
+
+		res, err = request(t, storage, logical.CreateOperation, "accounts/sign/check", reqObject(att, domain, groupPubKey))
+		require.NoError(t, err)
+		require.Equal(t, SignVerdictRefused, res.Data["verdict"])
+	})
+
+	t.Run("release", func(t *testing.T) {
+		storage := importStorage(t, nil)
+		requireSignable(t, storage, false)
+
+		res, err := request(t, storage, logical.DeleteOperation, "accounts/doppelganger/"+pubKey, nil)
+		require.NoError(t, err)
+		require.True(t, res.Data["released"].(bool))
+		requireSignable(t, storage, true)
+
+		records, err := listAuditRecords(context.Background(), storage, doppelgangerAuditKind)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		var record DoppelgangerAuditRecord
+		require.NoError(t, records[0].DecodeJSON(&record))
+		require.Equal(t, pubKey, record.PublicKey)
+		require.EqualValues(t, 103, record.Window.ActivationEpoch)
+
+		res, err = request(t, storage, logical.DeleteOperation, "accounts/doppelganger/"+pubKey, nil)
+		require.NoError(t, err)
+		require.False(t, res.Data["released"].(bool))
+
+		res, err = request(t, storage, logical.DeleteOperation, "accounts/doppelganger/"+hex.EncodeToString(make([]byte, 48)), nil)
+		requireCodedError(t, res, err, errorex.CodeUnknownAccount, "failed to release doppelganger window: account not found")
+	})
+}
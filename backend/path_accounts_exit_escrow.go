@@ -0,0 +1,399 @@
+package backend
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/pkg/errors"
+
+	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/keymanager/models"
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+// Endpoints patterns
+const (
+	// ExitEscrowPattern is the path pattern for the escrowed voluntary exits endpoints,
+	// the exit of an account is retrieved at ExitEscrowPattern/:public_key and the retrievals are read
+	// at ExitEscrowRetrievalsPattern
+	ExitEscrowPattern = "accounts/exit-escrow"
+	// ExitEscrowRetrievalsPattern is the path pattern for the escrowed exit retrievals endpoint
+	ExitEscrowRetrievalsPattern = ExitEscrowPattern + "/retrievals"
+)
+
+// exitEscrowAuditKind is the kind of the escrowed exit retrieval audit records.
+const exitEscrowAuditKind = "exit-escrow"
+
+// domainVoluntaryExit is the signature domain type of the voluntary exits.
+var domainVoluntaryExit = phase0.DomainType{0x04, 0x00, 0x00, 0x00}
+
+// Exit escrow errors
+var (
+	// ErrExitEscrowApprovals is returned when exits are escrowed on a mount requiring exit approvals.
+	ErrExitEscrowApprovals = errors.New("exits can't be escrowed while exit approvals are required")
+	// ErrExitEscrowKeyShare is returned when the exit of a key share account is escrowed, its signature is partial.
+	ErrExitEscrowKeyShare = errors.New("key share accounts sign partial exits, they can't be escrowed")
+)
+
+// ExitRetrievalRecord is the audit record of a retrieval of escrowed exits.
+type ExitRetrievalRecord struct {
+	Time       time.Time `json:"time"`
+	EntityID   string    `json:"entity_id,omitempty"`
+	PublicKeys []string  `json:"public_keys"`
+}
+
+func accountsExitEscrowPaths(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern:         ExitEscrowPattern,
+			HelpSynopsis:    "Escrow voluntary exits",
+			HelpDescription: `Sign and store the voluntary exits of accounts at an epoch with the Capella exit domain, GET retrieves them`,
+			Fields: map[string]*framework.FieldSchema{
+				"epoch": {
+					Type:        framework.TypeInt,
+					Description: "Epoch of the voluntary exits",
+				},
+				"validator_indexes": {
+					Type:        framework.TypeMap,
+					Description: "Validator indexes by hex encoded public key",
+				},
+				"public_keys": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Hex encoded public keys of the accounts, all the accounts by default",
+				},
+			},
+			ExistenceCheck: b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathExitEscrowCreate),
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathExitEscrowRetrieve),
+				},
+			},
+		},
+		{
+			Pattern:         ExitEscrowPattern + "/",
+			HelpSynopsis:    "List escrowed voluntary exits",
+			HelpDescription: `List the public keys of the accounts with an escrowed voluntary exit`,
+			ExistenceCheck:  b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathExitEscrowList),
+				},
+			},
+		},
+		{
+			Pattern:         ExitEscrowRetrievalsPattern,
+			HelpSynopsis:    "Read escrowed exit retrievals",
+			HelpDescription: `Read the audit records of the retrievals of escrowed voluntary exits`,
+			ExistenceCheck:  b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathExitEscrowRetrievalsRead),
+				},
+			},
+		},
+		{
+			Pattern:         ExitEscrowPattern + "/(?P<public_key>[0-9a-fA-F]{96})",
+			HelpSynopsis:    "Retrieve an escrowed voluntary exit",
+			HelpDescription: `Retrieve the escrowed voluntary exit of an account, the retrieval is recorded`,
+			Fields: map[string]*framework.FieldSchema{
+				"public_key": {
+					Type:        framework.TypeString,
+					Description: "Hex encoded public key of the account",
+				},
+			},
+			ExistenceCheck: b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathEscrowedExitRetrieve),
+				},
+			},
+		},
+	}
+}
+
+// capellaForkVersion returns the Capella fork version of the given network.
+func capellaForkVersion(network core.Network) (phase0.Version, error) {
+	switch network {
+	case core.MainNetwork:
+		return phase0.Version{0x03, 0x00, 0x00, 0x00}, nil
+	case core.PraterNetwork:
+		return phase0.Version{0x03, 0x00, 0x10, 0x20}, nil
+	default:
+		return phase0.Version{}, errors.Errorf("no capella fork version for network '%s'", network)
+	}
+}
+
+// voluntaryExitDomain returns the signature domain of the voluntary exits of the given network.
+// The voluntary exits are signed with the Capella fork version from Deneb on (EIP-7044),
+// so that the escrowed exits stay valid in the later forks.
+func voluntaryExitDomain(network core.Network) (phase0.Domain, error) {
+	forkVersion, err := capellaForkVersion(network)
+	if err != nil {
+		return phase0.Domain{}, err
+	}
+	forkData := &phase0.ForkData{
+		CurrentVersion:        forkVersion,
+		GenesisValidatorsRoot: network.GenesisValidatorsRoot(),
+	}
+	forkDataRoot, err := forkData.HashTreeRoot()
+	if err != nil {
+		return phase0.Domain{}, errors.Wrap(err, "failed to compute fork data root")
+	}
+
+	var domain phase0.Domain
+	copy(domain[:], domainVoluntaryExit[:])
+	copy(domain[4:], forkDataRoot[:28])
+	return domain, nil
+}
+
+func (b *backend) pathExitEscrowCreate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// Load config
+	config, err := b.readConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get config")
+	}
+	if config.ExitApprovals > 0 {
+		return nil, errorex.Wrap(errorex.CodePolicyViolation, ErrExitEscrowApprovals, "failed to escrow exits")
+	}
+
+	epochValue, ok := data.GetOk("epoch")
+	if !ok || epochValue.(int) < 0 {
+		return nil, errorex.NewErrBadRequest("invalid epoch provided")
+	}
+	epoch := phase0.Epoch(epochValue.(int))
+	validatorIndexes, err := parseValidatorIndexes(data.Get("validator_indexes").(map[string]interface{}))
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeBadRequest, err, "invalid validator_indexes provided")
+	}
+
+	storage := b.newStore(ctx, req.Storage, config.Network)
+	if err := b.checkExitEpoch(storage.Network(), epoch); err != nil {
+		return nil, wrapCodedSignError(err, "failed to escrow exits")
+	}
+	domain, err := voluntaryExitDomain(storage.Network())
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeBadRequest, err, "failed to compute voluntary exit domain")
+	}
+	// The key share accounts are listed by their group public key, they are skipped.
+	pubKeys, err := slashingHistoryPublicKeys(storage, data.Get("public_keys").([]string))
+	if err != nil {
+		return nil, err
+	}
+
+	now := b.now().UTC()
+	escrowed := make([]*store.EscrowedExit, 0, len(pubKeys))
+	skipped := make(map[string]string)
+	for _, pubKey := range pubKeys {
+		pubKeyBytes, _ := hex.DecodeString(pubKey)
+		validatorIndex, provided := validatorIndexes[pubKey]
+
+		var sig []byte
+		if err := b.lock(pubKeyBytes, func() error {
+			// The given validator index is registered, a registered one is used otherwise.
+			if _, err := signingAccount(storage, pubKeyBytes); err != nil {
+				return err
+			}
+			share, err := storage.KeyShare(pubKeyBytes)
+			if err != nil {
+				return errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read key share")
+			}
+			if share != nil {
+				return ErrExitEscrowKeyShare
+			}
+			registered, found, err := storage.ValidatorIndex(pubKeyBytes)
+			if err != nil {
+				return errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read validator index")
+			}
+			switch {
+			case provided && !found:
+				if err := storage.SaveValidatorIndex(pubKeyBytes, validatorIndex); err != nil {
+					return err
+				}
+			case found && !provided:
+				validatorIndex = registered
+			case !found:
+				return ErrValidatorIndexUnknown
+			}
+
+			var sigErr error
+			sig, sigErr = b.signVoluntaryExit(ctx, req.Storage, config, &models.SignRequest{
+				PublicKey:       pubKeyBytes,
+				SignatureDomain: domain,
+				Object: &models.SignRequestVoluntaryExit{VoluntaryExit: &phase0.VoluntaryExit{
+					Epoch:          epoch,
+					ValidatorIndex: validatorIndex,
+				}},
+			})
+			return sigErr
+		}); err != nil {
+			// The accounts that can't exit are skipped, any other failure fails the escrow.
+			if code, ok := signErrorCode(err); ok && (code == errorex.CodeUnknownAccount || code == errorex.CodePolicyViolation) ||
+				errors.Cause(err) == store.ErrValidatorIndexTaken {
+				skipped[pubKey] = err.Error()
+				continue
+			}
+			return nil, wrapCodedSignError(err, fmt.Sprintf("failed to escrow exit of '%s'", pubKey))
+		}
+
+		exit := &store.EscrowedExit{
+			PublicKey:      pubKey,
+			ValidatorIndex: validatorIndex,
+			Epoch:          epoch,
+			Signature:      hex.EncodeToString(sig),
+			CreatedAt:      now,
+			CreatedBy:      req.EntityID,
+		}
+		if err := storage.SaveEscrowedExit(exit); err != nil {
+			return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to save escrowed exit")
+		}
+		escrowed = append(escrowed, exit)
+	}
+
+	// The signatures are only returned by the recorded retrievals.
+	exits := make([]map[string]interface{}, len(escrowed))
+	for i, exit := range escrowed {
+		exits[i] = map[string]interface{}{
+			"public_key":      exit.PublicKey,
+			"validator_index": exit.ValidatorIndex,
+			"epoch":           exit.Epoch,
+		}
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"escrowed": exits,
+			"skipped":  skipped,
+		},
+	}, nil
+}
+
+func (b *backend) pathExitEscrowList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	pubKeys, err := b.newStore(ctx, req.Storage, "").ListEscrowedExits()
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to list escrowed exits")
+	}
+	sort.Strings(pubKeys)
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"public_keys": pubKeys,
+		},
+	}, nil
+}
+
+// pathExitEscrowRetrieve returns the escrowed exits of the given public keys, all of them by default.
+func (b *backend) pathExitEscrowRetrieve(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	storage := b.newStore(ctx, req.Storage, "")
+
+	var pubKeys []string
+	if filter := data.Get("public_keys").([]string); len(filter) > 0 {
+		for _, pubKey := range filter {
+			pubKeys = append(pubKeys, strings.TrimPrefix(strings.ToLower(strings.TrimSpace(pubKey)), "0x"))
+		}
+	} else {
+		all, err := storage.ListEscrowedExits()
+		if err != nil {
+			return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to list escrowed exits")
+		}
+		pubKeys = all
+	}
+	sort.Strings(pubKeys)
+
+	exits, err := b.retrieveEscrowedExits(ctx, req, storage, pubKeys)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]map[string]interface{}, len(exits))
+	for i, exit := range exits {
+		ret[i] = escrowedExitMap(exit)
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"exits": ret,
+		},
+	}, nil
+}
+
+func (b *backend) pathEscrowedExitRetrieve(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	storage := b.newStore(ctx, req.Storage, "")
+	exits, err := b.retrieveEscrowedExits(ctx, req, storage, []string{strings.ToLower(data.Get("public_key").(string))})
+	if err != nil {
+		return nil, err
+	}
+	return &logical.Response{
+		Data: escrowedExitMap(exits[0]),
+	}, nil
+}
+
+// retrieveEscrowedExits reads the escrowed exits of the given public keys and records their retrieval.
+func (b *backend) retrieveEscrowedExits(ctx context.Context, req *logical.Request, storage *store.HashicorpVaultStore, pubKeys []string) ([]*store.EscrowedExit, error) {
+	exits := make([]*store.EscrowedExit, 0, len(pubKeys))
+	for _, pubKey := range pubKeys {
+		exit, err := storage.EscrowedExit(pubKey)
+		if err != nil {
+			if errors.Cause(err) == store.ErrEscrowedExitNotFound {
+				return nil, errorex.Wrap(errorex.CodeUnknownAccount, err, fmt.Sprintf("failed to retrieve exit of '%s'", pubKey))
+			}
+			return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read escrowed exit")
+		}
+		exits = append(exits, exit)
+	}
+	if len(exits) == 0 {
+		return exits, nil
+	}
+
+	record := &ExitRetrievalRecord{
+		Time:       b.now().UTC(),
+		EntityID:   req.EntityID,
+		PublicKeys: pubKeys,
+	}
+	if err := putAuditRecord(ctx, req.Storage, exitEscrowAuditKind, record.Time, record); err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to store audit record")
+	}
+	return exits, nil
+}
+
+func (b *backend) pathExitEscrowRetrievalsRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	entries, err := listAuditRecords(ctx, req.Storage, exitEscrowAuditKind)
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read audit records")
+	}
+
+	records := make([]*ExitRetrievalRecord, len(entries))
+	for i, entry := range entries {
+		records[i] = &ExitRetrievalRecord{}
+		if err := entry.DecodeJSON(records[i]); err != nil {
+			return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to decode audit record")
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"records": records,
+		},
+	}, nil
+}
+
+// escrowedExitMap returns the response data of the given escrowed exit, a signed voluntary exit.
+func escrowedExitMap(exit *store.EscrowedExit) map[string]interface{} {
+	return map[string]interface{}{
+		"public_key": exit.PublicKey,
+		"message": map[string]interface{}{
+			"epoch":           strconv.FormatUint(uint64(exit.Epoch), 10),
+			"validator_index": strconv.FormatUint(uint64(exit.ValidatorIndex), 10),
+		},
+		"signature":  "0x" + exit.Signature,
+		"created_at": exit.CreatedAt,
+		"created_by": exit.CreatedBy,
+	}
+}
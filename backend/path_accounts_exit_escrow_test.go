@@ -0,0 +1,154 @@
+package backend
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/signer"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+func TestVoluntaryExitDomain(t *testing.T) {
+	// The fork digests of Capella are 0xbba4da96 on mainnet and 0x628941ef on prater.
+	domain, err := voluntaryExitDomain(core.MainNetwork)
+	require.NoError(t, err)
+	require.Equal(t, "04000000bba4da96354c9f25476cf1bc69bf583a7f9e0af049305b62de676640", hex.EncodeToString(domain[:]))
+
+	domain, err = voluntaryExitDomain(core.PraterNetwork)
+	require.NoError(t, err)
+	require.Equal(t, "04000000628941ef21d1fe8c7134720add10bb91e3b02c007e0046d2472c6695", hex.EncodeToString(domain[:]))
+}
+
+func TestExitEscrow(t *testing.T) {
+	b, _ := getBackend(t)
+	pubKey := "95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf"
+
+	req := logical.TestRequest(t, logical.CreateOperation, "accounts/exit-escrow")
+	setupBaseStorage(t, req)
+	// the config is cached per mount
+	b.InvalidateKey(context.Background(), ConfigPattern)
+	require.NoError(t, setupStorageWithWalletAndAccounts(req.Storage))
+
+	storage := req.Storage
+	request := func(t *testing.T, operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		req := logical.TestRequest(t, operation, path)
+		req.Storage = storage
+		req.EntityID = "custodian"
+		req.Data = data
+		return b.HandleRequest(context.Background(), req)
+	}
+
+	t.Run("escrow", func(t *testing.T) {
+		res, err := request(t, logical.CreateOperation, "accounts/exit-escrow", map[string]interface{}{"validator_indexes": map[string]interface{}{}})
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "invalid epoch provided")
+
+		res, err = request(t, logical.CreateOperation, "accounts/exit-escrow", map[string]interface{}{
+			"epoch":             194048,
+			"validator_indexes": map[string]interface{}{"0x" + pubKey: "42"},
+		})
+		require.NoError(t, err)
+		escrowed := res.Data["escrowed"].([]map[string]interface{})
+		require.Len(t, escrowed, 1)
+		require.Equal(t, pubKey, escrowed[0]["public_key"])
+		require.NotContains(t, escrowed[0], "signature")
+		// the other accounts have no validator index
+		for key, reason := range res.Data["skipped"].(map[string]string) {
+			require.NotEqual(t, pubKey, key)
+			require.Equal(t, "no validator index registered for the account", reason)
+		}
+
+		res, err = request(t, logical.ListOperation, "accounts/exit-escrow/", nil)
+		require.NoError(t, err)
+		require.Equal(t, []string{pubKey}, res.Data["public_keys"])
+	})
+
+	t.Run("retrieve", func(t *testing.T) {
+		res, err := request(t, logical.ReadOperation, "accounts/exit-escrow/"+pubKey, nil)
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"epoch": "194048", "validator_index": "42"}, res.Data["message"])
+
+		// the exit is signed with the capella domain
+		require.NoError(t, core.InitBLS())
+		domain, err := voluntaryExitDomain(core.PraterNetwork)
+		require.NoError(t, err)
+		root, err := signer.ComputeETHSigningRoot(&phase0.VoluntaryExit{Epoch: 194048, ValidatorIndex: 42}, domain)
+		require.NoError(t, err)
+		var pk bls.PublicKey
+		require.NoError(t, pk.DeserializeHexStr(pubKey))
+		var sig bls.Sign
+		require.NoError(t, sig.DeserializeHexStr(strings.TrimPrefix(res.Data["signature"].(string), "0x")))
+		require.True(t, sig.VerifyByte(&pk, root[:]))
+
+		res, err = request(t, logical.ReadOperation, "accounts/exit-escrow", nil)
+		require.NoError(t, err)
+		require.Len(t, res.Data["exits"], 1)
+
+		res, err = request(t, logical.ReadOperation, "accounts/exit-escrow/"+strings.Repeat("ab", 48), nil)
+		requireCodedError(t, res, err, errorex.CodeUnknownAccount, "failed to retrieve exit of '"+strings.Repeat("ab", 48)+"': escrowed exit not found")
+
+		res, err = request(t, logical.ReadOperation, "accounts/exit-escrow/retrievals", nil)
+		require.NoError(t, err)
+		records := res.Data["records"].([]*ExitRetrievalRecord)
+		require.Len(t, records, 2)
+		for _, record := range records {
+			require.Equal(t, "custodian", record.EntityID)
+			require.Equal(t, []string{pubKey}, record.PublicKeys)
+		}
+	})
+
+	t.Run("key share", func(t *testing.T) {
+		sk, shares := testKeyShares(t, 2, 2)
+		groupPubKey := hex.EncodeToString(sk.GetPublicKey().Serialize())
+		wallet, err := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork).OpenWallet()
+		require.NoError(t, err)
+		index := 10
+		_, err = wallet.CreateValidatorAccountFromPrivateKey(shares[1].Serialize(), &index)
+		require.NoError(t, err)
+		_, err = request(t, logical.CreateOperation, "accounts/shares/"+groupPubKey, map[string]interface{}{
+			"share_public_key": hex.EncodeToString(shares[1].GetPublicKey().Serialize()),
+			"index":            1,
+			"threshold":        2,
+			"peer_public_keys": map[string]interface{}{
+				"2": hex.EncodeToString(shares[2].GetPublicKey().Serialize()),
+			},
+		})
+		require.NoError(t, err)
+
+		// the partial exit of the share account isn't escrowed
+		res, err := request(t, logical.CreateOperation, "accounts/exit-escrow", map[string]interface{}{
+			"epoch":             194048,
+			"public_keys":       groupPubKey,
+			"validator_indexes": map[string]interface{}{groupPubKey: "43"},
+		})
+		require.NoError(t, err)
+		require.Empty(t, res.Data["escrowed"])
+		require.Equal(t, map[string]string{groupPubKey: ErrExitEscrowKeyShare.Error()}, res.Data["skipped"])
+
+		res, err = request(t, logical.ListOperation, "accounts/exit-escrow/", nil)
+		require.NoError(t, err)
+		require.Equal(t, []string{pubKey}, res.Data["public_keys"])
+	})
+
+	t.Run("exit approvals", func(t *testing.T) {
+		setupBaseStorage(t, &logical.Request{Storage: storage}, func(config *Config) {
+			config.ExitApprovals = 1
+			config.ExitApprovalTTL = 3600
+		})
+		b.InvalidateKey(context.Background(), ConfigPattern)
+
+		res, err := request(t, logical.CreateOperation, "accounts/exit-escrow", map[string]interface{}{
+			"epoch":             194048,
+			"validator_indexes": map[string]interface{}{pubKey: 42},
+		})
+		requireCodedError(t, res, err, errorex.CodePolicyViolation, "failed to escrow exits: exits can't be escrowed while exit approvals are required")
+	})
+}
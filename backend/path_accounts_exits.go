@@ -0,0 +1,336 @@
+package backend
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/keymanager/models"
+	"github.com/bloxapp/key-vault/utils/errorex"
+	"github.com/bloxapp/key-vault/utils/sentry"
+)
+
+// Endpoints patterns
+const (
+	// ExitRequestsPattern is the path pattern for the voluntary exit requests endpoints,
+	// a request is read at ExitRequestsPattern/:exit_id, approved at ExitRequestsPattern/:exit_id/approve
+	// and rejected at ExitRequestsPattern/:exit_id/reject
+	ExitRequestsPattern = "accounts/exits"
+)
+
+// Exit request errors
+var (
+	// ErrExitRequestNotPending is returned when an approved, rejected or expired exit request is decided on.
+	ErrExitRequestNotPending = errors.New("exit request is not pending")
+	// ErrExitSelfApproval is returned when the requesting entity approves its own exit request.
+	ErrExitSelfApproval = errors.New("exit request can't be approved by the requesting entity")
+	// ErrExitAlreadyDecided is returned when an entity decides twice on the same exit request.
+	ErrExitAlreadyDecided = errors.New("exit request already decided by the entity")
+)
+
+func accountsExitsPaths(b *backend) []*framework.Path {
+	exitPattern := ExitRequestsPattern + "/" + framework.GenericNameRegex("exit_id")
+	exitIDField := &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "voluntary exit request ID",
+	}
+
+	return []*framework.Path{
+		{
+			Pattern:         ExitRequestsPattern + "/?",
+			HelpSynopsis:    "List voluntary exit requests",
+			HelpDescription: `List the voluntary exit requests with their approvals, rejection and expiry`,
+			ExistenceCheck:  b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathExitRequestsList),
+				},
+			},
+		},
+		{
+			Pattern:         exitPattern,
+			HelpSynopsis:    "Read a voluntary exit request",
+			HelpDescription: `Read a voluntary exit request, its signature is set once it is approved`,
+			Fields: map[string]*framework.FieldSchema{
+				"exit_id": exitIDField,
+			},
+			ExistenceCheck: b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathExitRequestRead),
+				},
+			},
+		},
+		{
+			Pattern:         exitPattern + "/approve",
+			HelpSynopsis:    "Approve a voluntary exit request",
+			HelpDescription: `Approve a voluntary exit request, the exit is signed with the last required approval`,
+			Fields: map[string]*framework.FieldSchema{
+				"exit_id": exitIDField,
+			},
+			ExistenceCheck: b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathExitRequestApprove),
+				},
+			},
+		},
+		{
+			Pattern:         exitPattern + "/reject",
+			HelpSynopsis:    "Reject a voluntary exit request",
+			HelpDescription: `Reject a voluntary exit request, it is never signed`,
+			Fields: map[string]*framework.FieldSchema{
+				"exit_id": exitIDField,
+				"reason": {
+					Type:        framework.TypeString,
+					Description: "Reason of the rejection",
+					Default:     "",
+				},
+			},
+			ExistenceCheck: b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathExitRequestReject),
+				},
+			},
+		},
+	}
+}
+
+// wrapExitRequestError returns the coded error of the given exit request error.
+func wrapExitRequestError(err error, message string) error {
+	switch errors.Cause(err) {
+	case store.ErrExitRequestNotFound:
+		return errorex.Wrap(errorex.CodeBadRequest, err, message)
+	case ErrExitRequestNotPending, ErrExitSelfApproval, ErrExitAlreadyDecided:
+		return errorex.Wrap(errorex.CodePolicyViolation, err, message)
+	default:
+		return errorex.Wrap(errorex.CodeStorageFailure, err, message)
+	}
+}
+
+// exitRequestMap returns the response data of the given exit request.
+func exitRequestMap(request *store.ExitRequest) map[string]interface{} {
+	ret := map[string]interface{}{
+		"exit_id":            request.ID,
+		"public_key":         request.PublicKey,
+		"status":             request.Status,
+		"requested_by":       request.RequestedBy,
+		"created_at":         request.CreatedAt,
+		"expires_at":         request.ExpiresAt,
+		"required_approvals": request.RequiredApprovals,
+		"approvals":          request.Approvals,
+	}
+	if request.Rejection != nil {
+		ret["rejection"] = request.Rejection
+	}
+	if request.ExpiredAt != nil {
+		ret["expired_at"] = *request.ExpiredAt
+	}
+	if len(request.Signature) > 0 {
+		ret["signature"] = request.Signature
+	}
+	return ret
+}
+
+// requestVoluntaryExit stores a pending request of the voluntary exit of the given sign request.
+// The account must exist, the exit is signed once approved. The request is answered with 202 and the exit_pending code,
+// so that the clients don't mistake it for a signature.
+func (b *backend) requestVoluntaryExit(ctx context.Context, req *logical.Request, config *Config, signReq *models.SignRequest, encodedReq string) (*logical.Response, error) {
+	if _, ok := signReq.GetObject().(*models.SignRequestVoluntaryExit); !ok {
+		return nil, errorex.NewErrBadRequest("failed to cast to sign request voluntary exit")
+	}
+
+	storage := b.newStore(ctx, req.Storage, config.Network)
+	if _, err := signingAccount(storage, signReq.GetPublicKey()); err != nil {
+		return nil, wrapSignError(err)
+	}
+	// The exit is checked again once approved, the wall-clock epoch may only move forward.
+	if err := b.checkVoluntaryExit(storage, signReq); err != nil {
+		return nil, wrapSignError(err)
+	}
+
+	request := store.NewExitRequest(
+		hex.EncodeToString(signReq.GetPublicKey()),
+		encodedReq,
+		req.EntityID,
+		b.now(),
+		time.Duration(config.ExitApprovalTTL)*time.Second,
+		config.ExitApprovals,
+	)
+	if err := storage.SaveExitRequest(request); err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to save exit request")
+	}
+	b.requestLogger(ctx).WithFields(logrus.Fields{
+		"exit_id":            request.ID,
+		"required_approvals": request.RequiredApprovals,
+	}).Info("voluntary exit requested")
+
+	data := exitRequestMap(request)
+	data["code"] = errorex.CodeExitPending
+	data["message"] = "voluntary exit is pending approval"
+	data["status_code"] = errorex.CodeExitPending.StatusCode()
+	return logical.RespondWithStatusCode(&logical.Response{
+		Data: data,
+	}, nil, errorex.CodeExitPending.StatusCode())
+}
+
+func (b *backend) pathExitRequestsList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	requests, err := b.newStore(ctx, req.Storage, "").ListExitRequests()
+	if err != nil {
+		return nil, wrapExitRequestError(err, "failed to list exit requests")
+	}
+
+	// The expiries are recorded by the next decision, they are only reported here.
+	now := b.now()
+	exitRequests := make([]map[string]interface{}, 0, len(requests))
+	for _, request := range requests {
+		request.Expire(now)
+		exitRequests = append(exitRequests, exitRequestMap(request))
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"exit_requests": exitRequests,
+		},
+	}, nil
+}
+
+func (b *backend) pathExitRequestRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	request, err := b.newStore(ctx, req.Storage, "").ExitRequest(data.Get("exit_id").(string))
+	if err != nil {
+		return nil, wrapExitRequestError(err, "failed to read exit request")
+	}
+
+	request.Expire(b.now())
+	return &logical.Response{
+		Data: exitRequestMap(request),
+	}, nil
+}
+
+func (b *backend) pathExitRequestApprove(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// Load config
+	config, err := b.readConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get config")
+	}
+
+	return b.decideExitRequest(ctx, req, config, data.Get("exit_id").(string), func(request *store.ExitRequest, decision *store.ExitDecision) error {
+		if decision.EntityID == request.RequestedBy {
+			return wrapExitRequestError(ErrExitSelfApproval, "failed to approve exit request")
+		}
+		request.Approvals = append(request.Approvals, decision)
+		if uint64(len(request.Approvals)) < request.RequiredApprovals {
+			return nil
+		}
+
+		// The last required approval releases the signature.
+		reqBytes, err := hex.DecodeString(request.SignRequest)
+		if err != nil {
+			return errorex.Wrap(errorex.CodeStorageFailure, err, "failed to decode exit sign request")
+		}
+		var signReq models.SignRequest
+		if err := b.encoder.Decode(reqBytes, &signReq); err != nil {
+			return errorex.Wrap(errorex.CodeStorageFailure, err, "failed to decode exit sign request")
+		}
+		sig, err := b.signVoluntaryExit(ctx, req.Storage, config, &signReq)
+		if err != nil {
+			return wrapSignError(err)
+		}
+		if err := b.recordSignedExit(b.newStore(ctx, req.Storage, config.Network), &signReq); err != nil {
+			return err
+		}
+		request.Status = store.ExitRequestApproved
+		request.Signature = hex.EncodeToString(sig)
+		return nil
+	})
+}
+
+func (b *backend) pathExitRequestReject(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// Load config
+	config, err := b.readConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get config")
+	}
+
+	reason := strings.TrimSpace(data.Get("reason").(string))
+	return b.decideExitRequest(ctx, req, config, data.Get("exit_id").(string), func(request *store.ExitRequest, decision *store.ExitDecision) error {
+		decision.Reason = reason
+		request.Rejection = decision
+		request.Status = store.ExitRequestRejected
+		return nil
+	})
+}
+
+// decideExitRequest runs the given decision of the requesting entity on the pending exit request and stores it.
+// The decisions of a request are serialized by the sign lock of its account, the expiry is recorded
+// by the first decision coming after it.
+func (b *backend) decideExitRequest(
+	ctx context.Context,
+	req *logical.Request,
+	config *Config,
+	id string,
+	decide func(request *store.ExitRequest, decision *store.ExitDecision) error,
+) (*logical.Response, error) {
+	if len(req.EntityID) == 0 {
+		return nil, errorex.NewErrBadRequest("deciding on an exit request requires a Vault entity")
+	}
+
+	storage := b.newStore(ctx, req.Storage, config.Network)
+	request, err := storage.ExitRequest(id)
+	if err != nil {
+		return nil, wrapExitRequestError(err, "failed to read exit request")
+	}
+	pubKey, err := hex.DecodeString(request.PublicKey)
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to decode exit request public key")
+	}
+
+	err = b.lock(pubKey, func() error {
+		// Read again under the lock, another decision may have been stored meanwhile.
+		request, err = storage.ExitRequest(id)
+		if err != nil {
+			return wrapExitRequestError(err, "failed to read exit request")
+		}
+
+		now := b.now()
+		if request.Expire(now) {
+			if err := storage.SaveExitRequest(request); err != nil {
+				return wrapExitRequestError(err, "failed to save exit request")
+			}
+		}
+		if request.Status != store.ExitRequestPending {
+			return wrapExitRequestError(errors.Wrapf(ErrExitRequestNotPending, "exit request is %s", request.Status), "failed to decide on exit request")
+		}
+		if request.Decided(req.EntityID) {
+			return wrapExitRequestError(ErrExitAlreadyDecided, "failed to decide on exit request")
+		}
+
+		if err := decide(request, &store.ExitDecision{EntityID: req.EntityID, Time: now.UTC()}); err != nil {
+			return err
+		}
+		if err := storage.SaveExitRequest(request); err != nil {
+			return wrapExitRequestError(err, "failed to save exit request")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.requestLogger(ctx).WithFields(logrus.Fields{
+		sentry.TagPublicKey: request.PublicKey,
+		"exit_id":           request.ID,
+		"status":            request.Status,
+		"approvals":         len(request.Approvals),
+	}).Info("exit request decided")
+
+	return &logical.Response{
+		Data: exitRequestMap(request),
+	}, nil
+}
@@ -0,0 +1,160 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+func TestExitRequests(t *testing.T) {
+	b, _ := getBackend(t)
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	b.(*backend).now = func() time.Time {
+		return now
+	}
+	defer func() {
+		b.(*backend).now = time.Now
+	}()
+
+	req := logical.TestRequest(t, logical.CreateOperation, "accounts/sign-voluntary-exit")
+	setupBaseStorage(t, req, func(config *Config) {
+		config.ExitApprovals = 2
+		config.ExitApprovalTTL = 3600
+	})
+	// the config is cached per mount
+	b.InvalidateKey(context.Background(), ConfigPattern)
+	require.NoError(t, setupStorageWithWalletAndAccounts(req.Storage))
+	setupValidatorIndex(t, req.Storage, "95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf", 1)
+
+	storage := req.Storage
+	request := func(t *testing.T, operation logical.Operation, path, entityID string, data map[string]interface{}) (*logical.Response, error) {
+		req := logical.TestRequest(t, operation, path)
+		req.Storage = storage
+		req.EntityID = entityID
+		req.Data = data
+		return b.HandleRequest(context.Background(), req)
+	}
+	requestExit := func(t *testing.T) string {
+		now = now.Add(time.Minute)
+		res, err := request(t, logical.CreateOperation, "accounts/sign-voluntary-exit", "requester", basicVoluntaryExitData(false))
+		require.NoError(t, err)
+		// the pending exit isn't answered as a signature
+		require.Equal(t, http.StatusAccepted, res.Data[logical.HTTPStatusCode])
+		var body struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(res.Data[logical.HTTPRawBody].(string)), &body))
+		require.EqualValues(t, errorex.CodeExitPending, body.Data["code"])
+		require.Equal(t, store.ExitRequestPending, body.Data["status"])
+		require.Equal(t, "requester", body.Data["requested_by"])
+		require.NotContains(t, body.Data, "signature")
+		return body.Data["exit_id"].(string)
+	}
+
+	t.Run("approve", func(t *testing.T) {
+		id := requestExit(t)
+		approve := "accounts/exits/" + id + "/approve"
+
+		res, err := request(t, logical.CreateOperation, approve, "requester", nil)
+		requireCodedError(t, res, err, errorex.CodePolicyViolation, "failed to approve exit request: exit request can't be approved by the requesting entity")
+
+		res, err = request(t, logical.CreateOperation, approve, "", nil)
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "deciding on an exit request requires a Vault entity")
+
+		res, err = request(t, logical.CreateOperation, approve, "approver-1", nil)
+		require.NoError(t, err)
+		require.Equal(t, store.ExitRequestPending, res.Data["status"])
+		require.Len(t, res.Data["approvals"], 1)
+		require.NotContains(t, res.Data, "signature")
+
+		res, err = request(t, logical.CreateOperation, approve, "approver-1", nil)
+		requireCodedError(t, res, err, errorex.CodePolicyViolation, "failed to decide on exit request: exit request already decided by the entity")
+
+		res, err = request(t, logical.CreateOperation, approve, "approver-2", nil)
+		require.NoError(t, err)
+		require.Equal(t, store.ExitRequestApproved, res.Data["status"])
+		require.NotEmpty(t, res.Data["signature"])
+
+		read, err := request(t, logical.ReadOperation, "accounts/exits/"+id, "requester", nil)
+		require.NoError(t, err)
+		require.Equal(t, res.Data["signature"], read.Data["signature"])
+
+		res, err = request(t, logical.CreateOperation, approve, "approver-3", nil)
+		requireCodedError(t, res, err, errorex.CodePolicyViolation, "failed to decide on exit request: exit request is approved: exit request is not pending")
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		id := requestExit(t)
+
+		res, err := request(t, logical.CreateOperation, "accounts/exits/"+id+"/reject", "approver-1", map[string]interface{}{"reason": "wrong validator"})
+		require.NoError(t, err)
+		require.Equal(t, store.ExitRequestRejected, res.Data["status"])
+		rejection := res.Data["rejection"].(*store.ExitDecision)
+		require.Equal(t, "approver-1", rejection.EntityID)
+		require.Equal(t, "wrong validator", rejection.Reason)
+
+		res, err = request(t, logical.CreateOperation, "accounts/exits/"+id+"/approve", "approver-2", nil)
+		requireCodedError(t, res, err, errorex.CodePolicyViolation, "failed to decide on exit request: exit request is rejected: exit request is not pending")
+	})
+
+	t.Run("expire", func(t *testing.T) {
+		id := requestExit(t)
+		now = now.Add(time.Hour)
+
+		res, err := request(t, logical.ReadOperation, "accounts/exits/"+id, "requester", nil)
+		require.NoError(t, err)
+		require.Equal(t, store.ExitRequestExpired, res.Data["status"])
+
+		res, err = request(t, logical.CreateOperation, "accounts/exits/"+id+"/approve", "approver-1", nil)
+		requireCodedError(t, res, err, errorex.CodePolicyViolation, "failed to decide on exit request: exit request is expired: exit request is not pending")
+
+		// the expiry is recorded
+		exit, err := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork).ExitRequest(id)
+		require.NoError(t, err)
+		require.Equal(t, store.ExitRequestExpired, exit.Status)
+		require.NotNil(t, exit.ExpiredAt)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		res, err := request(t, logical.ListOperation, "accounts/exits/", "requester", nil)
+		require.NoError(t, err)
+		requests := res.Data["exit_requests"].([]map[string]interface{})
+		require.Len(t, requests, 3)
+		require.Equal(t, store.ExitRequestApproved, requests[0]["status"])
+		require.Equal(t, store.ExitRequestRejected, requests[1]["status"])
+		require.Equal(t, store.ExitRequestExpired, requests[2]["status"])
+	})
+
+	t.Run("unknown exit request", func(t *testing.T) {
+		res, err := request(t, logical.CreateOperation, "accounts/exits/unknown/approve", "approver-1", nil)
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "failed to read exit request: exit request not found")
+	})
+
+	t.Run("config write keeps the exit approvals", func(t *testing.T) {
+		res, err := request(t, logical.UpdateOperation, "config", "", map[string]interface{}{
+			"doppelganger_epochs": 1,
+		})
+		require.NoError(t, err)
+		require.EqualValues(t, core.PraterNetwork, res.Data["network"])
+		require.EqualValues(t, 1, res.Data["doppelganger_epochs"])
+		require.EqualValues(t, 2, res.Data["exit_approvals"])
+		require.EqualValues(t, 3600, res.Data["exit_approval_ttl"])
+
+		// the omitted fields are kept, the given ones are replaced
+		res, err = request(t, logical.UpdateOperation, "config", "", map[string]interface{}{
+			"exit_approvals": 0,
+		})
+		require.NoError(t, err)
+		require.EqualValues(t, 1, res.Data["doppelganger_epochs"])
+		require.EqualValues(t, 0, res.Data["exit_approvals"])
+	})
+}
@@ -0,0 +1,543 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	slashingprotection "github.com/bloxapp/eth2-key-manager/slashing_protection"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/pkg/errors"
+
+	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/utils/errorex"
+	"github.com/bloxapp/key-vault/utils/threshold"
+)
+
+// Endpoints patterns
+const (
+	// KeySharesPattern is the path pattern for the key shares endpoints, a key share is registered
+	// at KeySharesPattern/:group_public_key and the partial signatures are combined at KeySharesCombinePattern.
+	KeySharesPattern = "accounts/shares"
+	// KeySharesCombinePattern is the path pattern for the partial signatures combine endpoint
+	KeySharesCombinePattern = KeySharesPattern + "/combine"
+)
+
+// ErrKeyShareAccount is returned when a key share account is requested to sign with its own public key,
+// the slashing data of the share is kept on the group public key.
+var ErrKeyShareAccount = errors.New("account is a key share, sign with the group public key")
+
+func accountsSharesPaths(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern:         KeySharesPattern + "/?",
+			HelpSynopsis:    "List key shares",
+			HelpDescription: `List the key shares of the group public keys signed by this mount`,
+			Fields:          map[string]*framework.FieldSchema{},
+			ExistenceCheck:  b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathKeySharesList),
+				},
+			},
+		},
+		{
+			Pattern:         KeySharesCombinePattern,
+			HelpSynopsis:    "Combine partial signatures",
+			HelpDescription: `Recover the signature of a group public key from the partial signatures of its key shares`,
+			Fields: map[string]*framework.FieldSchema{
+				"group_public_key": {
+					Type:        framework.TypeString,
+					Description: "Hex encoded group public key",
+				},
+				"signing_root": {
+					Type:        framework.TypeString,
+					Description: "Hex encoded signing root of the partial signatures",
+				},
+				"partial_signatures": {
+					Type:        framework.TypeMap,
+					Description: "Hex encoded partial signatures by share index",
+				},
+			},
+			ExistenceCheck: b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathKeySharesCombine),
+				},
+			},
+		},
+		{
+			Pattern:         KeySharesPattern + "/(?P<group_public_key>[0-9a-fA-F]{96})",
+			HelpSynopsis:    "Manage a key share",
+			HelpDescription: `Register the key share of a group public key, the share is an account of the wallet`,
+			Fields: map[string]*framework.FieldSchema{
+				"group_public_key": {
+					Type:        framework.TypeString,
+					Description: "Hex encoded group public key",
+				},
+				"share_public_key": {
+					Type:        framework.TypeString,
+					Description: "Hex encoded public key of the share account",
+				},
+				"index": {
+					Type:        framework.TypeInt,
+					Description: "Index of the share, starting at 1",
+				},
+				"threshold": {
+					Type:        framework.TypeInt,
+					Description: "Number of shares needed to sign for the group public key",
+				},
+				"peer_public_keys": {
+					Type:        framework.TypeMap,
+					Description: "Hex encoded public keys of the other shares by share index",
+				},
+			},
+			ExistenceCheck: b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathKeyShareWrite),
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathKeyShareWrite),
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathKeyShareRead),
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathKeyShareDelete),
+				},
+			},
+		},
+	}
+}
+
+func (b *backend) pathKeySharesList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// Load config
+	config, err := b.readConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get config")
+	}
+
+	shares, err := b.newStore(ctx, req.Storage, config.Network).ListKeyShares()
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to list key shares")
+	}
+
+	keyShares := make([]map[string]interface{}, 0, len(shares))
+	for _, share := range shares {
+		keyShares = append(keyShares, keyShareMap(share))
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"key_shares": keyShares,
+		},
+	}, nil
+}
+
+func (b *backend) pathKeyShareWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// Load config
+	config, err := b.readConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get config")
+	}
+
+	share, group, err := parseKeyShare(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := group.Verify(); err != nil {
+		return nil, errorex.Wrap(errorex.CodeBadRequest, err, "invalid key share")
+	}
+
+	pubKeys := [][]byte{group.PublicKey, group.SharePublicKeys[share.Index]}
+	sort.Slice(pubKeys, func(i, j int) bool {
+		return bytes.Compare(pubKeys[i], pubKeys[j]) < 0
+	})
+	err = b.lockAll(pubKeys, func() error {
+		storage := b.newStore(ctx, req.Storage, config.Network)
+		if _, err := storage.AccountByPublicKey(group.SharePublicKeys[share.Index]); err != nil {
+			return wrapCodedSignError(err, "failed to register key share")
+		}
+		if _, err := storage.AccountByPublicKey(group.PublicKey); err == nil {
+			return errorex.NewErrBadRequest("failed to register key share: the group public key is an account of the wallet")
+		}
+
+		shareGroup, err := storage.KeyShareGroup(group.SharePublicKeys[share.Index])
+		if err != nil {
+			return errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read key share")
+		}
+		if shareGroup != nil && !bytes.Equal(shareGroup, group.PublicKey) {
+			return errorex.NewErrBadRequest(fmt.Sprintf("failed to register key share: the account is a key share of '%s'", hex.EncodeToString(shareGroup)))
+		}
+
+		// The previous share account of the group signs with its own public key again.
+		existing, err := storage.KeyShare(group.PublicKey)
+		if err != nil {
+			return errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read key share")
+		}
+		if existing != nil && existing.SharePublicKey != share.SharePublicKey {
+			if err := storage.DeleteKeyShare(existing); err != nil {
+				return errorex.Wrap(errorex.CodeStorageFailure, err, "failed to delete key share")
+			}
+		}
+
+		if err := seedGroupSlashingData(storage, group.PublicKey, group.SharePublicKeys[share.Index]); err != nil {
+			return errorex.Wrap(errorex.CodeStorageFailure, err, "failed to save key share")
+		}
+		if err := storage.SaveKeyShare(share); err != nil {
+			return errorex.Wrap(errorex.CodeStorageFailure, err, "failed to save key share")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: keyShareMap(share),
+	}, nil
+}
+
+func (b *backend) pathKeyShareRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// Load config
+	config, err := b.readConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get config")
+	}
+
+	share, err := b.readKeyShare(b.newStore(ctx, req.Storage, config.Network), data.Get("group_public_key").(string))
+	if err != nil {
+		return nil, err
+	}
+	return &logical.Response{
+		Data: keyShareMap(share),
+	}, nil
+}
+
+func (b *backend) pathKeyShareDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// Load config
+	config, err := b.readConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get config")
+	}
+
+	groupPubKey, err := hex.DecodeString(data.Get("group_public_key").(string))
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeBadRequest, err, "failed to decode group public key")
+	}
+
+	// The share account signs with its own public key again once deleted, both keys are locked.
+	// The share is read again under the locks, it is retried if it was registered with another account meanwhile.
+	storage := b.newStore(ctx, req.Storage, config.Network)
+	for {
+		share, err := b.readKeyShare(storage, hex.EncodeToString(groupPubKey))
+		if err != nil {
+			return nil, err
+		}
+		sharePubKey, err := hex.DecodeString(share.SharePublicKey)
+		if err != nil {
+			return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to decode share public key")
+		}
+
+		pubKeys := [][]byte{groupPubKey, sharePubKey}
+		sort.Slice(pubKeys, func(i, j int) bool {
+			return bytes.Compare(pubKeys[i], pubKeys[j]) < 0
+		})
+		deleted := false
+		err = b.lockAll(pubKeys, func() error {
+			current, err := b.readKeyShare(storage, hex.EncodeToString(groupPubKey))
+			if err != nil || current.SharePublicKey != share.SharePublicKey {
+				return err
+			}
+			if err := storage.DeleteKeyShare(current); err != nil {
+				return errorex.Wrap(errorex.CodeStorageFailure, err, "failed to delete key share")
+			}
+			deleted = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if deleted {
+			return nil, nil
+		}
+	}
+}
+
+func (b *backend) pathKeySharesCombine(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// Load config
+	config, err := b.readConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get config")
+	}
+
+	share, err := b.readKeyShare(b.newStore(ctx, req.Storage, config.Network), data.Get("group_public_key").(string))
+	if err != nil {
+		return nil, err
+	}
+	group, err := keyShareGroup(share)
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "invalid key share")
+	}
+
+	rootBytes, err := hex.DecodeString(data.Get("signing_root").(string))
+	if err != nil || len(rootBytes) != len(phase0.Root{}) {
+		return nil, errorex.NewErrBadRequest("invalid signing root")
+	}
+	var signingRoot phase0.Root
+	copy(signingRoot[:], rootBytes)
+
+	input, _ := data.Get("partial_signatures").(map[string]interface{})
+	partials := make(map[uint64][]byte, len(input))
+	for key, value := range input {
+		index, err := strconv.ParseUint(key, 10, 64)
+		if err != nil || index == 0 {
+			return nil, errorex.NewErrBadRequest(fmt.Sprintf("invalid share index '%s'", key))
+		}
+		sigHex, _ := value.(string)
+		sig, err := hex.DecodeString(sigHex)
+		if err != nil {
+			return nil, errorex.NewErrBadRequest(fmt.Sprintf("invalid partial signature of share %d", index))
+		}
+		partials[index] = sig
+	}
+
+	sig, err := group.Combine(signingRoot, partials)
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeBadRequest, err, "failed to combine partial signatures")
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"signature": hex.EncodeToString(sig),
+		},
+	}, nil
+}
+
+// readKeyShare returns the key share of the given hex encoded group public key.
+func (b *backend) readKeyShare(storage *store.HashicorpVaultStore, groupPublicKey string) (*store.KeyShare, error) {
+	groupPubKey, err := hex.DecodeString(groupPublicKey)
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeBadRequest, err, "failed to decode group public key")
+	}
+	share, err := storage.KeyShare(groupPubKey)
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read key share")
+	}
+	if share == nil {
+		return nil, errorex.NewCodedError(errorex.CodeUnknownAccount, "key share not found")
+	}
+	return share, nil
+}
+
+// parseKeyShare parses & validates the key share of the given request and returns it with its group.
+func parseKeyShare(data *framework.FieldData) (*store.KeyShare, *threshold.Group, error) {
+	share := &store.KeyShare{
+		GroupPublicKey: data.Get("group_public_key").(string),
+		SharePublicKey: data.Get("share_public_key").(string),
+		PeerPublicKeys: make(map[uint64]string),
+	}
+
+	index, ok := data.GetOk("index")
+	if !ok || index.(int) <= 0 {
+		return nil, nil, errorex.NewErrBadRequest("invalid share index provided")
+	}
+	share.Index = uint64(index.(int))
+
+	thresholdValue, ok := data.GetOk("threshold")
+	if !ok || thresholdValue.(int) <= 0 {
+		return nil, nil, errorex.NewErrBadRequest("invalid threshold provided")
+	}
+	share.Threshold = uint64(thresholdValue.(int))
+
+	peers, _ := data.Get("peer_public_keys").(map[string]interface{})
+	for key, value := range peers {
+		peerIndex, err := strconv.ParseUint(key, 10, 64)
+		if err != nil || peerIndex == 0 || peerIndex == share.Index {
+			return nil, nil, errorex.NewErrBadRequest(fmt.Sprintf("invalid peer share index '%s'", key))
+		}
+		pubKey, _ := value.(string)
+		share.PeerPublicKeys[peerIndex] = pubKey
+	}
+
+	group, err := keyShareGroup(share)
+	if err != nil {
+		return nil, nil, errorex.NewErrBadRequest(err.Error())
+	}
+
+	// Normalize the hex encoding of the stored keys.
+	share.GroupPublicKey = hex.EncodeToString(group.PublicKey)
+	share.SharePublicKey = hex.EncodeToString(group.SharePublicKeys[share.Index])
+	for index := range share.PeerPublicKeys {
+		share.PeerPublicKeys[index] = hex.EncodeToString(group.SharePublicKeys[index])
+	}
+	return share, group, nil
+}
+
+// keyShareGroup returns the threshold group of the given key share.
+func keyShareGroup(share *store.KeyShare) (*threshold.Group, error) {
+	decode := func(name, value string) ([]byte, error) {
+		pubKey, err := hex.DecodeString(value)
+		if err != nil || len(pubKey) != BLSPubkeyLength {
+			return nil, errors.Errorf("invalid %s provided", name)
+		}
+		return pubKey, nil
+	}
+
+	groupPubKey, err := decode("group public key", share.GroupPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	group := &threshold.Group{
+		PublicKey:       groupPubKey,
+		Threshold:       share.Threshold,
+		SharePublicKeys: make(map[uint64][]byte, len(share.PeerPublicKeys)+1),
+	}
+	if group.SharePublicKeys[share.Index], err = decode("share public key", share.SharePublicKey); err != nil {
+		return nil, err
+	}
+	for index, value := range share.PeerPublicKeys {
+		if group.SharePublicKeys[index], err = decode(fmt.Sprintf("public key of peer share %d", index), value); err != nil {
+			return nil, err
+		}
+	}
+	return group, nil
+}
+
+// keyShareMap returns the response data of the given key share.
+func keyShareMap(share *store.KeyShare) map[string]interface{} {
+	peers := make(map[string]string, len(share.PeerPublicKeys))
+	for index, pubKey := range share.PeerPublicKeys {
+		peers[strconv.FormatUint(index, 10)] = pubKey
+	}
+	return map[string]interface{}{
+		"group_public_key": share.GroupPublicKey,
+		"share_public_key": share.SharePublicKey,
+		"index":            share.Index,
+		"threshold":        share.Threshold,
+		"peer_public_keys": peers,
+	}
+}
+
+// groupSlashingStore keeps the slashing data of a key share account on its group public key.
+type groupSlashingStore struct {
+	core.SlashingStore
+	groupPubKey []byte
+}
+
+// SaveHighestAttestation saves the highest attestation of the group.
+func (s *groupSlashingStore) SaveHighestAttestation(_ []byte, attestation *phase0.AttestationData) error {
+	return s.SlashingStore.SaveHighestAttestation(s.groupPubKey, attestation)
+}
+
+// RetrieveHighestAttestation retrieves the highest attestation of the group.
+func (s *groupSlashingStore) RetrieveHighestAttestation(_ []byte) (*phase0.AttestationData, bool, error) {
+	return s.SlashingStore.RetrieveHighestAttestation(s.groupPubKey)
+}
+
+// SaveHighestProposal saves the highest proposal of the group.
+func (s *groupSlashingStore) SaveHighestProposal(_ []byte, slot phase0.Slot) error {
+	return s.SlashingStore.SaveHighestProposal(s.groupPubKey, slot)
+}
+
+// RetrieveHighestProposal retrieves the highest proposal of the group.
+func (s *groupSlashingStore) RetrieveHighestProposal(_ []byte) (phase0.Slot, bool, error) {
+	return s.SlashingStore.RetrieveHighestProposal(s.groupPubKey)
+}
+
+// signingKey returns the public key of the account signing for the requested public key, and the slashing protector
+// of the requested key. The group public keys of the key shares are signed by the share account, the slashing data
+// staying on the group key, and the share accounts don't sign with their own public key.
+func signingKey(storage *store.HashicorpVaultStore, pubKey []byte) ([]byte, core.SlashingProtector, error) {
+	share, err := storage.KeyShare(pubKey)
+	if err != nil {
+		return nil, nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read key share")
+	}
+	if share != nil {
+		sharePubKey, err := hex.DecodeString(share.SharePublicKey)
+		if err != nil {
+			return nil, nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to decode share public key")
+		}
+		return sharePubKey, slashingprotection.NewNormalProtection(&groupSlashingStore{
+			SlashingStore: storage,
+			groupPubKey:   pubKey,
+		}), nil
+	}
+
+	groupPubKey, err := storage.KeyShareGroup(pubKey)
+	if err != nil {
+		return nil, nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read key share")
+	}
+	if groupPubKey != nil {
+		return nil, nil, errors.Wrapf(ErrKeyShareAccount, "group public key %s", hex.EncodeToString(groupPubKey))
+	}
+	return pubKey, slashingprotection.NewNormalProtection(storage), nil
+}
+
+// signingAccount returns the public key of the account signing for the given public key, the account must exist.
+func signingAccount(storage *store.HashicorpVaultStore, pubKey []byte) ([]byte, error) {
+	signingPubKey, _, err := signingKey(storage, pubKey)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := storage.AccountByPublicKey(signingPubKey); err != nil {
+		return nil, err
+	}
+	return signingPubKey, nil
+}
+
+// signatureData returns the response data of the given signature for the requested public key. The group public keys
+// are signed by their share account: the partial signature is answered along with the index and the public key of the
+// share, the client verifies it against the share and combines it with the partial signatures of the other shares.
+func signatureData(storage *store.HashicorpVaultStore, pubKey, sig []byte) (map[string]interface{}, error) {
+	data := map[string]interface{}{
+		"signature": hex.EncodeToString(sig),
+	}
+	share, err := storage.KeyShare(pubKey)
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read key share")
+	}
+	if share != nil {
+		data["share_index"] = share.Index
+		data["share_public_key"] = share.SharePublicKey
+	}
+	return data, nil
+}
+
+// seedGroupSlashingData copies the slashing data of the share account to the group public key when the group has
+// none yet, the slashing data of the validator being imported on the share account.
+func seedGroupSlashingData(storage *store.HashicorpVaultStore, groupPubKey, sharePubKey []byte) error {
+	if _, found, err := storage.RetrieveHighestAttestation(groupPubKey); err != nil {
+		return errors.Wrap(err, "failed to retrieve highest attestation")
+	} else if !found {
+		att, found, err := storage.RetrieveHighestAttestation(sharePubKey)
+		if err != nil {
+			return errors.Wrap(err, "failed to retrieve highest attestation")
+		}
+		if found {
+			if err := storage.SaveHighestAttestation(groupPubKey, att); err != nil {
+				return errors.Wrap(err, "failed to save highest attestation")
+			}
+		}
+	}
+
+	if _, found, err := storage.RetrieveHighestProposal(groupPubKey); err != nil {
+		return errors.Wrap(err, "failed to retrieve highest proposal")
+	} else if !found {
+		slot, found, err := storage.RetrieveHighestProposal(sharePubKey)
+		if err != nil {
+			return errors.Wrap(err, "failed to retrieve highest proposal")
+		}
+		if found {
+			if err := storage.SaveHighestProposal(groupPubKey, slot); err != nil {
+				return errors.Wrap(err, "failed to save highest proposal")
+			}
+		}
+	}
+	return nil
+}
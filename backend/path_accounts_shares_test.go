@@ -0,0 +1,199 @@
+package backend
+
+import (
+	"context"
+	"encoding/hex"
+	"strconv"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/signer"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+// testKeyShares splits a new key in n shares of the given threshold, indexed from 1.
+func testKeyShares(t *testing.T, threshold, n int) (*bls.SecretKey, map[uint64]*bls.SecretKey) {
+	require.NoError(t, core.InitBLS())
+
+	var sk bls.SecretKey
+	sk.SetByCSPRNG()
+	msk := sk.GetMasterSecretKey(threshold)
+
+	shares := make(map[uint64]*bls.SecretKey)
+	for index := 1; index <= n; index++ {
+		var id bls.ID
+		require.NoError(t, id.SetDecString(strconv.Itoa(index)))
+		var share bls.SecretKey
+		require.NoError(t, share.Set(msk, &id))
+		shares[uint64(index)] = &share
+	}
+	return &sk, shares
+}
+
+func TestKeyShares(t *testing.T) {
+	b, _ := getBackend(t)
+	sk, shares := testKeyShares(t, 2, 3)
+	groupPubKey := hex.EncodeToString(sk.GetPublicKey().Serialize())
+	sharePubKey := hex.EncodeToString(shares[1].GetPublicKey().Serialize())
+
+	req := logical.TestRequest(t, logical.CreateOperation, "accounts/sign")
+	setupBaseStorage(t, req)
+	// the config is cached per mount
+	b.InvalidateKey(context.Background(), ConfigPattern)
+	s, err := baseHashicorpStorage(context.Background(), req.Storage)
+	require.NoError(t, err)
+	wallet, err := s.OpenWallet()
+	require.NoError(t, err)
+	index := 1
+	account, err := wallet.CreateValidatorAccountFromPrivateKey(shares[1].Serialize(), &index)
+	require.NoError(t, err)
+	require.Equal(t, sharePubKey, hex.EncodeToString(account.ValidatorPublicKey()))
+	// the slashing data of the validator is imported on the share account
+	require.NoError(t, s.SaveHighestAttestation(account.ValidatorPublicKey(), &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: 76},
+		Target: &phase0.Checkpoint{Epoch: 77},
+	}))
+	require.NoError(t, s.SaveHighestProposal(account.ValidatorPublicKey(), 100))
+
+	storage := req.Storage
+	request := func(t *testing.T, operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		req := logical.TestRequest(t, operation, path)
+		req.Storage = storage
+		req.Data = data
+		return b.HandleRequest(context.Background(), req)
+	}
+	shareData := func(sharePubKey string) map[string]interface{} {
+		return map[string]interface{}{
+			"share_public_key": sharePubKey,
+			"index":            1,
+			"threshold":        2,
+			"peer_public_keys": map[string]interface{}{
+				"2": hex.EncodeToString(shares[2].GetPublicKey().Serialize()),
+				"3": hex.EncodeToString(shares[3].GetPublicKey().Serialize()),
+			},
+		}
+	}
+	att := &phase0.AttestationData{
+		Slot:            284115,
+		Index:           2,
+		BeaconBlockRoot: _byteArray32("7b5679277ca45ea74e1deebc9d3e8c0e7d6c570b3cfaf6884be144a81dac9a0e"),
+		Source:          &phase0.Checkpoint{Epoch: 77, Root: _byteArray32("7402fdc1ce16d449d637c34a172b349a12b2bae8d6d77e401006594d8057c33d")},
+		Target:          &phase0.Checkpoint{Epoch: 78, Root: _byteArray32("17959acc370274756fa5e9fdd7e7adf17204f49cc8457e49438c42c4883cbfb0")},
+	}
+	domain := _byteArray32("01000000f071c66c6561d0b939feb15f513a019d99a84bd85635221e3ad42dac")
+	signingRoot, err := signer.ComputeETHSigningRoot(att, domain)
+	require.NoError(t, err)
+
+	t.Run("register", func(t *testing.T) {
+		res, err := request(t, logical.CreateOperation, "accounts/shares/"+groupPubKey, shareData(hex.EncodeToString(shares[2].GetPublicKey().Serialize())))
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "invalid key share: share 2: shares don't match the group public key")
+
+		otherSK, others := testKeyShares(t, 2, 3)
+		data := shareData(hex.EncodeToString(others[1].GetPublicKey().Serialize()))
+		data["peer_public_keys"] = map[string]interface{}{"2": hex.EncodeToString(others[2].GetPublicKey().Serialize())}
+		res, err = request(t, logical.CreateOperation, "accounts/shares/"+hex.EncodeToString(otherSK.GetPublicKey().Serialize()), data)
+		requireCodedError(t, res, err, errorex.CodeUnknownAccount, "failed to register key share: account not found")
+
+		res, err = request(t, logical.CreateOperation, "accounts/shares/"+groupPubKey, shareData(sharePubKey))
+		require.NoError(t, err)
+		require.Equal(t, groupPubKey, res.Data["group_public_key"])
+		require.Equal(t, sharePubKey, res.Data["share_public_key"])
+
+		res, err = request(t, logical.ListOperation, "accounts/shares/", nil)
+		require.NoError(t, err)
+		require.Len(t, res.Data["key_shares"], 1)
+
+		res, err = request(t, logical.ListOperation, "accounts/", nil)
+		require.NoError(t, err)
+		accounts := res.Data["accounts"].([]map[string]string)
+		require.Len(t, accounts, 2)
+		for _, account := range accounts {
+			if account["validationPubKey"] == sharePubKey {
+				require.Equal(t, groupPubKey, account["groupPubKey"])
+				require.Equal(t, "1", account["shareIndex"])
+			} else {
+				require.NotContains(t, account, "groupPubKey")
+			}
+		}
+	})
+
+	t.Run("sign with the share", func(t *testing.T) {
+		res, err := request(t, logical.CreateOperation, "accounts/sign", reqObject(att, domain, sk.GetPublicKey().Serialize()))
+		require.NoError(t, err)
+		partial, err := hex.DecodeString(res.Data["signature"].(string))
+		require.NoError(t, err)
+		require.Equal(t, shares[1].SignByte(signingRoot[:]).Serialize(), partial)
+		// the partial signature is answered with its share
+		require.EqualValues(t, 1, res.Data["share_index"])
+		require.Equal(t, sharePubKey, res.Data["share_public_key"])
+
+		// the slashing data is kept on the group public key
+		highest, found, err := s.RetrieveHighestAttestation(sk.GetPublicKey().Serialize())
+		require.NoError(t, err)
+		require.True(t, found)
+		require.EqualValues(t, 78, highest.Target.Epoch)
+
+		doubleVote := *att
+		doubleVote.BeaconBlockRoot = _byteArray32("7b5679277ca45ea74e1deebc9d3e8c0e7d6c570b3cfaf6884be144a81dac9a0d")
+		res, err = request(t, logical.CreateOperation, "accounts/sign", reqObject(&doubleVote, domain, sk.GetPublicKey().Serialize()))
+		requireCodedError(t, res, err, errorex.CodeSlashable, "failed to sign: slashable attestation (HighestAttestationVote), not signing")
+
+		res, err = request(t, logical.CreateOperation, "accounts/sign/check", reqObject(&doubleVote, domain, sk.GetPublicKey().Serialize()))
+		require.NoError(t, err)
+		require.Equal(t, SignVerdictSlashable, res.Data["verdict"])
+
+		// the share account doesn't sign with its own public key
+		res, err = request(t, logical.CreateOperation, "accounts/sign", reqObject(att, domain, shares[1].GetPublicKey().Serialize()))
+		requireCodedError(t, res, err, errorex.CodePolicyViolation, "failed to sign: group public key "+groupPubKey+": account is a key share, sign with the group public key")
+
+		res, err = request(t, logical.CreateOperation, "accounts/shares/combine", map[string]interface{}{
+			"group_public_key": groupPubKey,
+			"signing_root":     hex.EncodeToString(signingRoot[:]),
+			"partial_signatures": map[string]interface{}{
+				"1": hex.EncodeToString(partial),
+				"3": hex.EncodeToString(shares[3].SignByte(signingRoot[:]).Serialize()),
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, hex.EncodeToString(sk.SignByte(signingRoot[:]).Serialize()), res.Data["signature"])
+
+		res, err = request(t, logical.CreateOperation, "accounts/shares/combine", map[string]interface{}{
+			"group_public_key":   groupPubKey,
+			"signing_root":       hex.EncodeToString(signingRoot[:]),
+			"partial_signatures": map[string]interface{}{"1": hex.EncodeToString(partial)},
+		})
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "failed to combine partial signatures: 1 partial signatures for a threshold of 2: not enough shares")
+
+		res, err = request(t, logical.CreateOperation, "accounts/shares/combine", map[string]interface{}{
+			"group_public_key": groupPubKey,
+			"signing_root":     hex.EncodeToString(signingRoot[:]),
+			"partial_signatures": map[string]interface{}{
+				"1": hex.EncodeToString(partial),
+				"4": hex.EncodeToString(shares[3].SignByte(signingRoot[:]).Serialize()),
+			},
+		})
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "failed to combine partial signatures: share 4: unknown share")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		res, err := request(t, logical.DeleteOperation, "accounts/shares/"+groupPubKey, nil)
+		require.NoError(t, err)
+		require.Nil(t, res)
+
+		res, err = request(t, logical.ReadOperation, "accounts/shares/"+groupPubKey, nil)
+		requireCodedError(t, res, err, errorex.CodeUnknownAccount, "key share not found")
+
+		res, err = request(t, logical.CreateOperation, "accounts/sign", reqObject(att, domain, sk.GetPublicKey().Serialize()))
+		requireCodedError(t, res, err, errorex.CodeUnknownAccount, "failed to sign: account not found")
+
+		// the share account signs with its own public key again
+		res, err = request(t, logical.CreateOperation, "accounts/sign", reqObject(att, domain, shares[1].GetPublicKey().Serialize()))
+		require.NoError(t, err)
+		require.NotContains(t, res.Data, "share_index")
+	})
+}
@@ -0,0 +1,159 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/pkg/errors"
+
+	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+// Endpoints patterns
+const (
+	// ValidatorIndexesPattern is the path pattern for the validator indexes endpoint
+	ValidatorIndexesPattern = "accounts/validator-indexes"
+)
+
+func accountsValidatorIndexesPaths(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern:         ValidatorIndexesPattern,
+			HelpSynopsis:    "Manage validator indexes",
+			HelpDescription: `Set the validator indexes of the accounts, the voluntary exits are only signed for their own validator index`,
+			Fields: map[string]*framework.FieldSchema{
+				"validator_indexes": {
+					Type:        framework.TypeMap,
+					Description: "Validator indexes by hex encoded public key",
+				},
+			},
+			ExistenceCheck: b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathValidatorIndexesWrite),
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathValidatorIndexesWrite),
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathValidatorIndexesRead),
+				},
+			},
+		},
+	}
+}
+
+// parseValidatorIndexes parses the validator indexes keyed by hex encoded public key.
+func parseValidatorIndexes(input map[string]interface{}) (map[string]phase0.ValidatorIndex, error) {
+	indexes := make(map[string]phase0.ValidatorIndex, len(input))
+	for key, value := range input {
+		pubKey := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(key)), "0x")
+		if pubKeyBytes, err := hex.DecodeString(pubKey); err != nil || len(pubKeyBytes) != BLSPubkeyLength {
+			return nil, errors.Errorf("invalid public key '%s'", key)
+		}
+		index, err := parseValidatorIndex(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid validator index of '%s'", key)
+		}
+		indexes[pubKey] = index
+	}
+	return indexes, nil
+}
+
+// parseValidatorIndex parses a validator index given as a JSON number or a decimal string.
+func parseValidatorIndex(value interface{}) (phase0.ValidatorIndex, error) {
+	var str string
+	switch v := value.(type) {
+	case string:
+		str = v
+	case json.Number:
+		str = v.String()
+	case int:
+		str = strconv.Itoa(v)
+	case float64:
+		str = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return 0, errors.Errorf("unexpected type %T", value)
+	}
+	index, err := strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return phase0.ValidatorIndex(index), nil
+}
+
+func (b *backend) pathValidatorIndexesWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// Load config
+	config, err := b.readConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get config")
+	}
+
+	indexes, err := parseValidatorIndexes(data.Get("validator_indexes").(map[string]interface{}))
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeBadRequest, err, "invalid validator_indexes provided")
+	}
+	if len(indexes) == 0 {
+		return nil, errorex.NewErrBadRequest("no validator_indexes provided")
+	}
+
+	storage := b.newStore(ctx, req.Storage, config.Network)
+	if err := b.saveValidatorIndexes(storage, indexes); err != nil {
+		return nil, err
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"validator_indexes": indexes,
+		},
+	}, nil
+}
+
+func (b *backend) pathValidatorIndexesRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	indexes, err := b.newStore(ctx, req.Storage, "").ListValidatorIndexes()
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to list validator indexes")
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"validator_indexes": indexes,
+		},
+	}, nil
+}
+
+// saveValidatorIndexes stores the given validator indexes by hex encoded public key, the accounts must exist.
+// The key share accounts are indexed by their group public key.
+func (b *backend) saveValidatorIndexes(storage *store.HashicorpVaultStore, indexes map[string]phase0.ValidatorIndex) error {
+	pubKeys := make([][]byte, 0, len(indexes))
+	for pubKey := range indexes {
+		pubKeyBytes, _ := hex.DecodeString(pubKey)
+		if _, err := signingAccount(storage, pubKeyBytes); err != nil {
+			return wrapCodedSignError(err, fmt.Sprintf("failed to set validator index of '%s'", pubKey))
+		}
+		pubKeys = append(pubKeys, pubKeyBytes)
+	}
+	sort.Slice(pubKeys, func(i, j int) bool {
+		return bytes.Compare(pubKeys[i], pubKeys[j]) < 0
+	})
+
+	return b.lockAll(pubKeys, func() error {
+		for _, pubKey := range pubKeys {
+			if err := storage.SaveValidatorIndex(pubKey, indexes[hex.EncodeToString(pubKey)]); err != nil {
+				if errors.Cause(err) == store.ErrValidatorIndexTaken {
+					return errorex.Wrap(errorex.CodeBadRequest, err, "failed to set validator index")
+				}
+				return errorex.Wrap(errorex.CodeStorageFailure, err, "failed to set validator index")
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,176 @@
+package backend
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/keymanager/models"
+	"github.com/bloxapp/key-vault/utils/encoder"
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+func voluntaryExitData(epoch phase0.Epoch, validatorIndex phase0.ValidatorIndex) map[string]interface{} {
+	req := &models.SignRequest{
+		PublicKey:       _byteArray("95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf"),
+		SignatureDomain: _byteArray32("01000000f071c66c6561d0b939feb15f513a019d99a84bd85635221e3ad42dac"),
+		Object: &models.SignRequestVoluntaryExit{VoluntaryExit: &phase0.VoluntaryExit{
+			Epoch:          epoch,
+			ValidatorIndex: validatorIndex,
+		}},
+	}
+
+	byts, _ := encoder.New().Encode(req)
+	return map[string]interface{}{
+		"sign_req": hex.EncodeToString(byts),
+	}
+}
+
+// setupValidatorIndex registers the validator index of the given hex encoded public key.
+func setupValidatorIndex(t *testing.T, storage logical.Storage, pubKey string, index phase0.ValidatorIndex) {
+	pubKeyBytes, err := hex.DecodeString(pubKey)
+	require.NoError(t, err)
+	require.NoError(t, store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork).SaveValidatorIndex(pubKeyBytes, index))
+}
+
+// exitedAttestationData returns an attestation at the first slot of epoch 8883.
+func exitedAttestationData() map[string]interface{} {
+	att := &phase0.AttestationData{
+		Slot:            8883 * 32,
+		Index:           2,
+		BeaconBlockRoot: _byteArray32("7b5679277ca45ea74e1deebc9d3e8c0e7d6c570b3cfaf6884be144a81dac9a0e"),
+		Source: &phase0.Checkpoint{
+			Epoch: 8882,
+			Root:  _byteArray32("7402fdc1ce16d449d637c34a172b349a12b2bae8d6d77e401006594d8057c33d"),
+		},
+		Target: &phase0.Checkpoint{
+			Epoch: 8883,
+			Root:  _byteArray32("17959acc370274756fa5e9fdd7e7adf17204f49cc8457e49438c42c4883cbfb0"),
+		},
+	}
+	return reqObject(
+		att,
+		_byteArray32("01000000f071c66c6561d0b939feb15f513a019d99a84bd85635221e3ad42dac"),
+		_byteArray("95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf"),
+	)
+}
+
+func TestValidatorIndexes(t *testing.T) {
+	b, _ := getBackend(t)
+	// the wall-clock epoch is 100
+	now := time.Unix(int64(core.PraterNetwork.MinGenesisTime()), 0).Add(100 * 32 * 12 * time.Second)
+	b.(*backend).now = func() time.Time {
+		return now
+	}
+	defer func() {
+		b.(*backend).now = time.Now
+	}()
+	pubKey := "95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf"
+
+	req := logical.TestRequest(t, logical.CreateOperation, "accounts/validator-indexes")
+	setupBaseStorage(t, req)
+	// the config is cached per mount
+	b.InvalidateKey(context.Background(), ConfigPattern)
+	require.NoError(t, setupStorageWithWalletAndAccounts(req.Storage))
+
+	storage := req.Storage
+	request := func(t *testing.T, operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		req := logical.TestRequest(t, operation, path)
+		req.Storage = storage
+		req.Data = data
+		return b.HandleRequest(context.Background(), req)
+	}
+
+	t.Run("set validator indexes", func(t *testing.T) {
+		res, err := request(t, logical.CreateOperation, "accounts/validator-indexes", map[string]interface{}{})
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "no validator_indexes provided")
+
+		res, err = request(t, logical.CreateOperation, "accounts/validator-indexes", map[string]interface{}{
+			"validator_indexes": map[string]interface{}{"0x" + pubKey: "abc"},
+		})
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "invalid validator_indexes provided: invalid validator index of '0x"+pubKey+"': strconv.ParseUint: parsing \"abc\": invalid syntax")
+
+		unknown := strings.Repeat("ab", 48)
+		res, err = request(t, logical.CreateOperation, "accounts/validator-indexes", map[string]interface{}{
+			"validator_indexes": map[string]interface{}{unknown: 7},
+		})
+		requireCodedError(t, res, err, errorex.CodeUnknownAccount, "failed to set validator index of '"+unknown+"': account not found")
+
+		_, err = request(t, logical.CreateOperation, "accounts/validator-indexes", map[string]interface{}{
+			"validator_indexes": map[string]interface{}{"0x" + pubKey: 42},
+		})
+		require.NoError(t, err)
+
+		res, err = request(t, logical.ReadOperation, "accounts/validator-indexes", nil)
+		require.NoError(t, err)
+		require.Equal(t, map[string]phase0.ValidatorIndex{pubKey: 42}, res.Data["validator_indexes"])
+	})
+
+	t.Run("voluntary exit checks", func(t *testing.T) {
+		res, err := request(t, logical.CreateOperation, "accounts/sign-voluntary-exit", voluntaryExitData(1, 1))
+		requireCodedError(t, res, err, errorex.CodePolicyViolation, "failed to sign: validator index 1, the account is 42: validator index doesn't belong to the account")
+
+		res, err = request(t, logical.CreateOperation, "accounts/sign-voluntary-exit", voluntaryExitData(101, 42))
+		requireCodedError(t, res, err, errorex.CodePolicyViolation, "failed to sign: epoch 101 is ahead of the wall-clock epoch 100: exit epoch is ahead of the wall-clock epoch")
+
+		// no exit is recorded for the refused ones
+		exit, err := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork).SignedExit(_byteArray(pubKey))
+		require.NoError(t, err)
+		require.Nil(t, exit)
+	})
+
+	t.Run("signing before the earliest exit epoch", func(t *testing.T) {
+		// the wall-clock epoch is the one of the attestation slot, 8878
+		clock := b.(*backend).now
+		b.(*backend).now = func() time.Time {
+			return time.Unix(int64(core.PraterNetwork.MinGenesisTime()), 0).Add(8878 * 32 * 12 * time.Second)
+		}
+		defer func() {
+			b.(*backend).now = clock
+		}()
+
+		// the exits are included from the wall-clock epoch at the earliest, even those of earlier epochs,
+		// so they take effect at epoch 8883 at the earliest
+		for _, epoch := range []phase0.Epoch{8874, 8873, 100} {
+			res, err := request(t, logical.CreateOperation, "accounts/sign-voluntary-exit", voluntaryExitData(epoch, 42))
+			require.NoError(t, err)
+			require.NotEmpty(t, res.Data["signature"])
+
+			res, err = request(t, logical.CreateOperation, "accounts/sign/check", basicAttestationData())
+			require.NoError(t, err)
+			require.Equal(t, SignVerdictSign, res.Data["verdict"])
+		}
+
+		// the first exit is kept, the later ones aren't included earlier
+		exit, err := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork).SignedExit(_byteArray(pubKey))
+		require.NoError(t, err)
+		require.EqualValues(t, 8874, exit.Epoch)
+	})
+
+	t.Run("signing after the exit epoch", func(t *testing.T) {
+		res, err := request(t, logical.CreateOperation, "accounts/sign", exitedAttestationData())
+		requireCodedError(t, res, err, errorex.CodePolicyViolation, "failed to sign: refused to sign slot 284256 from the earliest exit epoch 8883 of the exit at epoch 8874 included from epoch 8878: validator exited")
+
+		res, err = request(t, logical.CreateOperation, "accounts/sign/check", exitedAttestationData())
+		require.NoError(t, err)
+		require.Equal(t, SignVerdictRefused, res.Data["verdict"])
+
+		// the attestations before the exit epoch are still signed
+		res, err = request(t, logical.CreateOperation, "accounts/sign", basicAttestationData())
+		require.NoError(t, err)
+		require.NotEmpty(t, res.Data["signature"])
+
+		// other requests are signed
+		res, err = request(t, logical.CreateOperation, "accounts/sign", basicAggregationAndProofData())
+		require.NoError(t, err)
+		require.NotEmpty(t, res.Data["signature"])
+	})
+}
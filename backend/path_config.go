@@ -10,6 +10,10 @@ import (
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bloxapp/key-vault/utils/errorex"
+	"github.com/bloxapp/key-vault/utils/sentry"
 )
 
 var (
@@ -25,17 +29,34 @@ const (
 	ConfigPattern = "config"
 )
 
-// Config contains the configuration for each mount
+// Config contains the configuration for each mount.
+// DoppelgangerEpochs is the number of epochs during which the imported accounts don't sign attestations and blocks.
+// MaxSlotsAhead and MaxSlotsBehind bound the slots of the sign requests around the wall-clock slot, zero disables them.
+// RateLimits limit the sign requests per account and per Vault entity.
+// ExitApprovals is the number of distinct Vault entities approving a voluntary exit before it is signed,
+// within ExitApprovalTTL seconds, zero signs the exits right away.
 type Config struct {
-	Network       core.Network  `json:"network"`
-	FeeRecipients FeeRecipients `json:"fee_recipients"`
+	Network            core.Network  `json:"network"`
+	FeeRecipients      FeeRecipients `json:"fee_recipients"`
+	DoppelgangerEpochs uint64        `json:"doppelganger_epochs"`
+	MaxSlotsAhead      uint64        `json:"max_slots_ahead"`
+	MaxSlotsBehind     uint64        `json:"max_slots_behind"`
+	RateLimits         RateLimits    `json:"rate_limits"`
+	ExitApprovals      uint64        `json:"exit_approvals"`
+	ExitApprovalTTL    uint64        `json:"exit_approval_ttl"`
 }
 
 // Map returns a map representation of the FeeRecipients.
 func (c Config) Map() map[string]interface{} {
 	return map[string]interface{}{
-		"network":        c.Network,
-		"fee_recipients": c.FeeRecipients,
+		"network":             c.Network,
+		"fee_recipients":      c.FeeRecipients,
+		"doppelganger_epochs": c.DoppelgangerEpochs,
+		"max_slots_ahead":     c.MaxSlotsAhead,
+		"max_slots_behind":    c.MaxSlotsBehind,
+		"rate_limits":         c.RateLimits,
+		"exit_approvals":      c.ExitApprovals,
+		"exit_approval_ttl":   c.ExitApprovalTTL,
 	}
 }
 
@@ -45,13 +66,13 @@ func configPaths(b *backend) []*framework.Path {
 			Pattern: ConfigPattern,
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.CreateOperation: &framework.PathOperation{
-					Callback: b.pathWriteConfig,
+					Callback: withCodedErrors(b.pathWriteConfig),
 				},
 				logical.UpdateOperation: &framework.PathOperation{
-					Callback: b.pathWriteConfig,
+					Callback: withCodedErrors(b.pathWriteConfig),
 				},
 				logical.ReadOperation: &framework.PathOperation{
-					Callback: b.pathReadConfig,
+					Callback: withCodedErrors(b.pathReadConfig),
 				},
 			},
 			HelpSynopsis:    "Configure the Vault Ethereum plugin.",
@@ -71,31 +92,124 @@ func configPaths(b *backend) []*framework.Path {
 					Type:        framework.TypeMap,
 					Description: `Validator pubic keys and their associated fee recipient addresses.`,
 				},
+				"doppelganger_epochs": {
+					Type:        framework.TypeInt,
+					Description: `Number of epochs during which the imported accounts don't sign attestations and blocks.`,
+					Default:     0,
+				},
+				"max_slots_ahead": {
+					Type:        framework.TypeInt,
+					Description: `Maximum number of slots the sign requests are ahead of the wall-clock slot, 0 disables the bound.`,
+					Default:     0,
+				},
+				"max_slots_behind": {
+					Type:        framework.TypeInt,
+					Description: `Maximum number of slots the sign requests are behind the wall-clock slot, 0 disables the bound.`,
+					Default:     0,
+				},
+				"rate_limits": {
+					Type: framework.TypeMap,
+					Description: `Sign request rate limits, each one is a limit per slot or epoch:
+					public_key - limits of each account by object type
+					entity - limit of each Vault entity`,
+				},
+				"exit_approvals": {
+					Type:        framework.TypeInt,
+					Description: `Number of distinct Vault entities approving a voluntary exit before it is signed, 0 signs the exits right away.`,
+					Default:     0,
+				},
+				"exit_approval_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: `Time to approve a voluntary exit request before it expires.`,
+					Default:     "24h",
+				},
 			},
 		},
 	}
 }
 
 // pathWriteConfig is the write config path handler
+// The fields omitted by the request keep their stored value, the defaults apply to the first write.
 func (b *backend) pathWriteConfig(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	network := core.NetworkFromString(data.Get("network").(string))
-	if network == "" {
-		return nil, errors.New("invalid network provided")
+	stored, err := b.readConfig(ctx, req.Storage)
+	if codedErr, ok := errorex.AsCodedError(err); ok && codedErr.Code == errorex.CodeNotConfigured {
+		stored, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	configBundle := Config{
-		Network: network,
+	// The stored config is cached, its maps are replaced and never modified.
+	var configBundle Config
+	if stored != nil {
+		configBundle = *stored
+	}
+	given := func(key string) bool {
+		_, ok := data.GetOk(key)
+		return ok || stored == nil
+	}
+
+	if given("network") {
+		configBundle.Network = core.NetworkFromString(data.Get("network").(string))
+		if configBundle.Network == "" {
+			return nil, errorex.NewErrBadRequest("invalid network provided")
+		}
+	}
+
+	if given("doppelganger_epochs") {
+		doppelgangerEpochs := data.Get("doppelganger_epochs").(int)
+		if doppelgangerEpochs < 0 {
+			return nil, errorex.NewErrBadRequest("invalid doppelganger epochs provided")
+		}
+		configBundle.DoppelgangerEpochs = uint64(doppelgangerEpochs)
+	}
+
+	for key, value := range map[string]*uint64{
+		"max_slots_ahead":  &configBundle.MaxSlotsAhead,
+		"max_slots_behind": &configBundle.MaxSlotsBehind,
+	} {
+		if given(key) {
+			maxSlots := data.Get(key).(int)
+			if maxSlots < 0 {
+				return nil, errorex.NewErrBadRequest("invalid wall-clock bounds provided")
+			}
+			*value = uint64(maxSlots)
+		}
+	}
+
+	if given("exit_approvals") {
+		exitApprovals := data.Get("exit_approvals").(int)
+		if exitApprovals < 0 {
+			return nil, errorex.NewErrBadRequest("invalid exit approvals provided")
+		}
+		configBundle.ExitApprovals = uint64(exitApprovals)
+	}
+	if given("exit_approval_ttl") {
+		exitApprovalTTL := data.Get("exit_approval_ttl").(int)
+		if exitApprovalTTL <= 0 {
+			return nil, errorex.NewErrBadRequest("invalid exit approval ttl provided")
+		}
+		configBundle.ExitApprovalTTL = uint64(exitApprovalTTL)
 	}
 
 	// Parse and validate the fee recipients (if given.)
-	if data, ok := data.Get("fee_recipients").(map[string]interface{}); ok {
+	if data, ok := data.Get("fee_recipients").(map[string]interface{}); ok && given("fee_recipients") {
 		recipients, err := ParseFeeRecipients(data)
 		if err != nil {
-			return nil, err
+			return nil, errorex.NewErrBadRequest(err.Error())
 		}
 		configBundle.FeeRecipients = recipients
 	}
 
+	// Parse and validate the rate limits (if given.)
+	if data, ok := data.Get("rate_limits").(map[string]interface{}); ok && given("rate_limits") {
+		limits, err := ParseRateLimits(data)
+		if err != nil {
+			return nil, errorex.NewErrBadRequest(err.Error())
+		}
+		configBundle.RateLimits = limits
+	}
+
 	// Create storage entry
 	entry, err := logical.StorageEntryJSON("config", configBundle.Map())
 	if err != nil {
@@ -103,9 +217,21 @@ func (b *backend) pathWriteConfig(ctx context.Context, req *logical.Request, dat
 	}
 
 	// Store config
-	if err := req.Storage.Put(ctx, entry); err != nil {
-		return nil, err
+	if err := b.configCache.update(func() error {
+		return req.Storage.Put(ctx, entry)
+	}); err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to store config")
 	}
+	b.requestLogger(ctx).WithFields(logrus.Fields{
+		sentry.TagNetwork:     string(configBundle.Network),
+		"doppelganger_epochs": configBundle.DoppelgangerEpochs,
+		"max_slots_ahead":     configBundle.MaxSlotsAhead,
+		"max_slots_behind":    configBundle.MaxSlotsBehind,
+		"exit_approvals":      configBundle.ExitApprovals,
+		"fee_recipients":      len(configBundle.FeeRecipients),
+		"rate_limits":         len(configBundle.RateLimits.PublicKey),
+		"entity_rate_limit":   configBundle.RateLimits.Entity != nil,
+	}).Info("config updated")
 
 	// Return the secret
 	return &logical.Response{
@@ -130,22 +256,24 @@ func (b *backend) pathReadConfig(ctx context.Context, req *logical.Request, data
 }
 
 // readConfig returns the configuration for this PluginBackend.
+// The returned config is cached and must not be modified.
 func (b *backend) readConfig(ctx context.Context, s logical.Storage) (*Config, error) {
-	entry, err := s.Get(ctx, "config")
-	if err != nil {
-		return nil, err
-	}
-
-	if entry == nil {
-		return nil, errors.New("the plugin has not been configured yet")
-	}
+	return b.configCache.load(func() (*Config, error) {
+		entry, err := s.Get(ctx, "config")
+		if err != nil {
+			return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read config")
+		}
 
-	var result Config
-	if err := entry.DecodeJSON(&result); err != nil {
-		return nil, errors.Wrap(err, "error reading configuration")
-	}
+		if entry == nil {
+			return nil, errorex.NewCodedError(errorex.CodeNotConfigured, "the plugin has not been configured yet")
+		}
 
-	return &result, nil
+		var result Config
+		if err := entry.DecodeJSON(&result); err != nil {
+			return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "error reading configuration")
+		}
+		return &result, nil
+	})
 }
 
 // FeeRecipients is a map of validator public keys and their associated fee recipient addresses.
@@ -0,0 +1,81 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+// Endpoints patterns
+const (
+	// LogLevelPattern is the path pattern for the log level endpoint
+	LogLevelPattern = "log-level"
+)
+
+func logLevelPaths(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern:         LogLevelPattern,
+			HelpSynopsis:    "Manage the log level",
+			HelpDescription: `Read and set the log level of the plugin, the level is kept until the plugin restarts`,
+			Fields: map[string]*framework.FieldSchema{
+				"level": {
+					Type:        framework.TypeString,
+					Description: "Log level: trace, debug, info, warn, error, fatal or panic",
+				},
+			},
+			ExistenceCheck: b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathLogLevelRead),
+				},
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathLogLevelWrite),
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathLogLevelWrite),
+				},
+			},
+		},
+	}
+}
+
+func (b *backend) pathLogLevelRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"level": b.logger.GetLevel().String(),
+		},
+	}, nil
+}
+
+// pathLogLevelWrite sets the level of the plugin logger, shared by all the mounts of the plugin.
+func (b *backend) pathLogLevelWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	level, err := logrus.ParseLevel(data.Get("level").(string))
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeBadRequest, err, "invalid level provided")
+	}
+
+	previous := b.logger.GetLevel()
+	logger := b.requestLogger(ctx).WithFields(logrus.Fields{
+		"previous_level": previous.String(),
+		"level":          level.String(),
+	})
+	// The change is logged at the most verbose of the two levels.
+	if level >= previous {
+		b.logger.SetLevel(level)
+		logger.Info("log level updated")
+	} else {
+		logger.Info("log level updated")
+		b.logger.SetLevel(level)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"level": level.String(),
+		},
+	}, nil
+}
@@ -0,0 +1,61 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/helper/logging"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+func TestLogLevel(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.InfoLevel)
+	b, err := Factory("test", logger)(context.Background(), &logical.BackendConfig{
+		Logger:      logging.NewVaultLogger(hclog.Trace),
+		System:      &logical.StaticSystemView{},
+		StorageView: &logical.InmemStorage{},
+		BackendUUID: "test",
+	})
+	require.NoError(t, err)
+
+	request := func(t *testing.T, operation logical.Operation, data map[string]interface{}) (*logical.Response, error) {
+		req := logical.TestRequest(t, operation, "log-level")
+		req.EntityID = "admin"
+		req.Data = data
+		return b.HandleRequest(context.Background(), req)
+	}
+
+	res, err := request(t, logical.ReadOperation, nil)
+	require.NoError(t, err)
+	require.Equal(t, "info", res.Data["level"])
+
+	res, err = request(t, logical.CreateOperation, map[string]interface{}{"level": "loud"})
+	requireCodedError(t, res, err, errorex.CodeBadRequest, "invalid level provided: not a valid logrus Level: \"loud\"")
+
+	res, err = request(t, logical.CreateOperation, map[string]interface{}{"level": "debug"})
+	require.NoError(t, err)
+	require.Equal(t, "debug", res.Data["level"])
+	require.Equal(t, logrus.DebugLevel, logger.GetLevel())
+	entry := hook.LastEntry()
+	require.Equal(t, "log level updated", entry.Message)
+	require.Equal(t, "info", entry.Data["previous_level"])
+	require.Equal(t, "admin", entry.Data["entity_id"])
+
+	// raising the level is logged before it applies
+	hook.Reset()
+	_, err = request(t, logical.UpdateOperation, map[string]interface{}{"level": "error"})
+	require.NoError(t, err)
+	require.Equal(t, logrus.ErrorLevel, logger.GetLevel())
+	require.Equal(t, "log level updated", hook.LastEntry().Message)
+
+	res, err = request(t, logical.ReadOperation, nil)
+	require.NoError(t, err)
+	require.Equal(t, "error", res.Data["level"])
+}
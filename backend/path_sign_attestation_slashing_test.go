@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/bloxapp/key-vault/utils/encoder"
+	"github.com/bloxapp/key-vault/utils/errorex"
 
 	"github.com/bloxapp/key-vault/backend/store"
 	"github.com/bloxapp/key-vault/keymanager/models"
@@ -130,8 +131,7 @@ func TestAttestationSlashing(t *testing.T) {
 		// duplicated attestation
 		req.Data = basicAttestationData()
 		res, err = b.HandleRequest(context.Background(), req)
-		require.EqualError(t, err, "failed to sign: slashable attestation (HighestAttestationVote), not signing")
-		require.Nil(t, res)
+		requireCodedError(t, res, err, errorex.CodeSlashable, "failed to sign: slashable attestation (HighestAttestationVote), not signing")
 	})
 
 	t.Run("Sign double Attestation (different block root), should return error", func(t *testing.T) {
@@ -153,9 +153,7 @@ func TestAttestationSlashing(t *testing.T) {
 		data := basicAttestationDataWithOps(false, true, false, false, false)
 		req.Data = data
 		res, err = b.HandleRequest(context.Background(), req)
-		require.Error(t, err)
-		require.EqualError(t, err, "failed to sign: slashable attestation (HighestAttestationVote), not signing")
-		require.Nil(t, res)
+		requireCodedError(t, res, err, errorex.CodeSlashable, "failed to sign: slashable attestation (HighestAttestationVote), not signing")
 	})
 
 	t.Run("Sign double Attestation (different source root), should return error", func(t *testing.T) {
@@ -176,9 +174,7 @@ func TestAttestationSlashing(t *testing.T) {
 		// slashable attestation
 		req.Data = basicAttestationDataWithOps(false, false, true, false, false)
 		res, err = b.HandleRequest(context.Background(), req)
-		require.Error(t, err)
-		require.EqualError(t, err, "failed to sign: slashable attestation (HighestAttestationVote), not signing")
-		require.Nil(t, res)
+		requireCodedError(t, res, err, errorex.CodeSlashable, "failed to sign: slashable attestation (HighestAttestationVote), not signing")
 	})
 
 	t.Run("Sign double Attestation (different target root), should return error", func(t *testing.T) {
@@ -199,9 +195,7 @@ func TestAttestationSlashing(t *testing.T) {
 		// slashable attestation
 		req.Data = basicAttestationDataWithOps(false, false, false, true, false)
 		res, err = b.HandleRequest(context.Background(), req)
-		require.Error(t, err)
-		require.EqualError(t, err, "failed to sign: slashable attestation (HighestAttestationVote), not signing")
-		require.Nil(t, res)
+		requireCodedError(t, res, err, errorex.CodeSlashable, "failed to sign: slashable attestation (HighestAttestationVote), not signing")
 	})
 
 	t.Run("Sign Attestation (different domain), should sign", func(t *testing.T) {
@@ -222,8 +216,7 @@ func TestAttestationSlashing(t *testing.T) {
 		// slashable attestation
 		req.Data = basicAttestationDataWithOps(false, false, false, false, true)
 		res, err = b.HandleRequest(context.Background(), req)
-		require.EqualError(t, err, "failed to sign: slashable attestation (HighestAttestationVote), not signing")
-		require.Nil(t, res)
+		requireCodedError(t, res, err, errorex.CodeSlashable, "failed to sign: slashable attestation (HighestAttestationVote), not signing")
 	})
 
 	t.Run("Sign surrounding Attestation, should error", func(t *testing.T) {
@@ -270,9 +263,7 @@ func TestAttestationSlashing(t *testing.T) {
 		att.Target.Epoch = 80
 		req.Data = reqObject(att, domain, pubKey)
 		res, err := b.HandleRequest(context.Background(), req)
-		require.Error(t, err)
-		require.EqualError(t, err, "failed to sign: slashable attestation (HighestAttestationVote), not signing")
-		require.Nil(t, res)
+		requireCodedError(t, res, err, errorex.CodeSlashable, "failed to sign: slashable attestation (HighestAttestationVote), not signing")
 	})
 
 	t.Run("Sign surrounded Attestation, should error", func(t *testing.T) {
@@ -319,8 +310,6 @@ func TestAttestationSlashing(t *testing.T) {
 		att.Target.Epoch = 88
 		req.Data = reqObject(att, domain, pubKey)
 		res, err := b.HandleRequest(context.Background(), req)
-		require.Error(t, err)
-		require.EqualError(t, err, "failed to sign: slashable attestation (HighestAttestationVote), not signing")
-		require.Nil(t, res)
+		requireCodedError(t, res, err, errorex.CodeSlashable, "failed to sign: slashable attestation (HighestAttestationVote), not signing")
 	})
 }
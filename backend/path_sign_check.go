@@ -0,0 +1,203 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/signer"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/pkg/errors"
+
+	"github.com/bloxapp/key-vault/keymanager/models"
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+// Endpoints patterns
+const (
+	// SignCheckPattern is the path pattern for the dry-run sign endpoint
+	SignCheckPattern = "accounts/sign/check"
+)
+
+// Sign check verdicts
+const (
+	// SignVerdictSign means the request would be signed.
+	SignVerdictSign = "sign"
+	// SignVerdictSlashable means the request would be refused by the slashing protection.
+	SignVerdictSlashable = "slashable"
+	// SignVerdictRefused means the request would be refused by the mount policy.
+	SignVerdictRefused = "refused"
+)
+
+func signCheckPaths(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern:         SignCheckPattern,
+			HelpSynopsis:    "Check whether a sign request would be signed",
+			HelpDescription: `Runs the slashing protection and the policy validation of the sign endpoint without signing and without updating the slashing data`,
+			Fields: map[string]*framework.FieldSchema{
+				"sign_req": {
+					Type:        framework.TypeString,
+					Description: "SSZ Serialized sign request object",
+					Default:     "",
+				},
+			},
+			ExistenceCheck: b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathSignCheck),
+				},
+			},
+		},
+	}
+}
+
+func (b *backend) pathSignCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// Load config
+	config, err := b.readConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get config")
+	}
+
+	signReq, err := b.decodeSignRequest(data)
+	if err != nil {
+		return nil, err
+	}
+	addReportFields(ctx, signReportFields(config.Network, signReq))
+
+	var checkErr error
+	err = b.lock(signReq.GetPublicKey(), func() error {
+		storage := b.newStore(ctx, req.Storage, config.Network)
+		pubKey, protector, err := signingKey(storage, signReq.GetPublicKey())
+		if err != nil {
+			return err
+		}
+		if _, err := storage.AccountByPublicKey(pubKey); err != nil {
+			return err
+		}
+
+		if checkErr = b.checkClockBounds(config, storage.Network(), signReq); checkErr != nil {
+			return nil
+		}
+		if checkErr = b.checkDoppelgangerWindow(storage, pubKey, signReq); checkErr != nil {
+			return nil
+		}
+		if checkErr = b.checkSignedExit(storage, signReq); checkErr != nil {
+			return nil
+		}
+		checkErr = checkSignRequest(signReq, protector, storage.Network(), config)
+		return nil
+	})
+	if err != nil {
+		return nil, wrapCodedSignError(err, "failed to check sign request")
+	}
+
+	verdict := SignVerdictSign
+	if checkErr != nil {
+		code, _ := signErrorCode(checkErr)
+		switch code {
+		case errorex.CodeSlashable:
+			verdict = SignVerdictSlashable
+		case errorex.CodePolicyViolation:
+			verdict = SignVerdictRefused
+		default:
+			return nil, wrapCodedSignError(checkErr, "failed to check sign request")
+		}
+	}
+
+	respData := map[string]interface{}{
+		"verdict": verdict,
+	}
+	if checkErr != nil {
+		respData["reason"] = checkErr.Error()
+	}
+	return &logical.Response{
+		Data: respData,
+	}, nil
+}
+
+// checkSignRequest runs the checks done by the signer and by pathSign before signing the given request.
+// It only reads the slashing data, the returned errors match the ones returned when signing.
+func checkSignRequest(signReq *models.SignRequest, protector core.SlashingProtector, network core.Network, config *Config) error {
+	switch t := signReq.GetObject().(type) {
+	case *models.SignRequestBlock:
+		slot, err := t.VersionedBeaconBlock.Slot()
+		if err != nil {
+			return errorex.Wrap(errorex.CodeBadRequest, err, "could not get block slot")
+		}
+		return checkProposal(signReq.GetPublicKey(), slot, protector, network)
+	case *models.SignRequestBlindedBlock:
+		slot, err := t.VersionedBlindedBeaconBlock.Slot()
+		if err != nil {
+			return errorex.Wrap(errorex.CodeBadRequest, err, "could not get block slot")
+		}
+		return checkProposal(signReq.GetPublicKey(), slot, protector, network)
+	case *models.SignRequestAttestationData:
+		return checkAttestation(signReq.GetPublicKey(), t.AttestationData, protector, network)
+	case *models.SignRequestRegistration:
+		feeRecipient, err := t.VersionedValidatorRegistration.FeeRecipient()
+		if err != nil {
+			return errorex.Wrap(errorex.CodeBadRequest, err, "failed to get fee recipient")
+		}
+		if err := validateRequestedFeeRecipient(signReq.GetPublicKey(), config.FeeRecipients, feeRecipient); err != nil {
+			return errors.Wrap(err, "refused to sign")
+		}
+		return nil
+	case *models.SignRequestSlot,
+		*models.SignRequestEpoch,
+		*models.SignRequestAggregateAttestationAndProof,
+		*models.SignRequestSyncCommitteeMessage,
+		*models.SignRequestSyncAggregatorSelectionData,
+		*models.SignRequestContributionAndProof:
+		// Not protected.
+		return nil
+	default:
+		return errorex.NewErrBadRequest("sign request: not supported")
+	}
+}
+
+func checkAttestation(pubKey []byte, attestation *phase0.AttestationData, protector core.SlashingProtector, network core.Network) error {
+	if !signer.IsValidFarFutureEpoch(network, attestation.Target.Epoch) {
+		return newSlashableError("target epoch too far into the future")
+	}
+	if !signer.IsValidFarFutureEpoch(network, attestation.Source.Epoch) {
+		return newSlashableError("source epoch too far into the future")
+	}
+	highest, found, err := protector.FetchHighestAttestation(pubKey)
+	if err != nil {
+		return errors.Wrap(err, "could not retrieve highest attestation")
+	}
+	if !found || highest == nil {
+		return newSlashableError("highest attestation data is not found, can't determine if attestation is slashable")
+	}
+	status, err := protector.IsSlashableAttestation(pubKey, attestation)
+	if err != nil {
+		return err
+	}
+	if status != nil {
+		return newSlashableError("slashable attestation (%s), not signing", status.Status)
+	}
+	return nil
+}
+
+func checkProposal(pubKey []byte, slot phase0.Slot, protector core.SlashingProtector, network core.Network) error {
+	if !signer.IsValidFarFutureSlot(network, slot) {
+		return newSlashableError("proposed block slot too far into the future")
+	}
+	_, found, err := protector.FetchHighestProposal(pubKey)
+	if err != nil {
+		return errors.Wrap(err, "could not retrieve highest proposal")
+	}
+	if !found {
+		return newSlashableError("highest proposal data is not found, can't determine if proposal is slashable")
+	}
+	status, err := protector.IsSlashableProposal(pubKey, slot)
+	if err != nil {
+		return err
+	}
+	if status.Status != core.ValidProposal {
+		return newSlashableError("slashable proposal (%s), not signing", status.Status)
+	}
+	return nil
+}
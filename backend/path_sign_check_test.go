@@ -0,0 +1,155 @@
+package backend
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/api"
+	eth2apiv1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/keymanager/models"
+	"github.com/bloxapp/key-vault/utils/encoder"
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+func basicRegistrationData(feeRecipient string) map[string]interface{} {
+	registration := &eth2apiv1.ValidatorRegistration{
+		GasLimit:  123456,
+		Timestamp: time.Unix(1658313712, 0),
+	}
+	copy(registration.Pubkey[:], _byteArray("95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf"))
+	copy(registration.FeeRecipient[:], _byteArray(feeRecipient))
+
+	byts, _ := encoder.New().Encode(&models.SignRequest{
+		PublicKey:       registration.Pubkey[:],
+		SignatureDomain: _byteArray32("00000001f5a5fd42d16a20302798ef6ed309979b43003d2320d9f0e8ea9831a9"),
+		Object: &models.SignRequestRegistration{
+			VersionedValidatorRegistration: &api.VersionedValidatorRegistration{
+				Version: spec.BuilderVersionV1,
+				V1:      registration,
+			},
+		},
+	})
+	return map[string]interface{}{
+		"sign_req": hex.EncodeToString(byts),
+	}
+}
+
+func TestSignCheck(t *testing.T) {
+	b, _ := getBackend(t)
+
+	check := func(t *testing.T, storage logical.Storage, data map[string]interface{}) (*logical.Response, error) {
+		req := logical.TestRequest(t, logical.CreateOperation, "accounts/sign/check")
+		req.Storage = storage
+		req.Data = data
+		return b.HandleRequest(context.Background(), req)
+	}
+	sign := func(t *testing.T, storage logical.Storage, data map[string]interface{}) {
+		req := logical.TestRequest(t, logical.CreateOperation, "accounts/sign")
+		req.Storage = storage
+		req.Data = data
+		res, err := b.HandleRequest(context.Background(), req)
+		require.NoError(t, err)
+		require.NotEmpty(t, res.Data["signature"])
+	}
+	requireVerdict := func(t *testing.T, res *logical.Response, err error, verdict string, reason string) {
+		require.NoError(t, err)
+		require.Equal(t, verdict, res.Data["verdict"])
+		require.NotContains(t, res.Data, "signature")
+		if len(reason) == 0 {
+			require.NotContains(t, res.Data, "reason")
+		} else {
+			require.Equal(t, reason, res.Data["reason"])
+		}
+	}
+
+	t.Run("attestation", func(t *testing.T) {
+		req := logical.TestRequest(t, logical.CreateOperation, "accounts/sign/check")
+		setupBaseStorage(t, req)
+		require.NoError(t, setupStorageWithWalletAndAccounts(req.Storage))
+		require.NoError(t, updateWithBasicHighestAtt(req.Storage))
+
+		// the check doesn't update the watermark
+		for i := 0; i < 2; i++ {
+			res, err := check(t, req.Storage, basicAttestationData())
+			requireVerdict(t, res, err, SignVerdictSign, "")
+		}
+
+		sign(t, req.Storage, basicAttestationData())
+
+		res, err := check(t, req.Storage, basicAttestationData())
+		requireVerdict(t, res, err, SignVerdictSlashable, "slashable attestation (HighestAttestationVote), not signing")
+	})
+
+	t.Run("attestation without slashing data", func(t *testing.T) {
+		req := logical.TestRequest(t, logical.CreateOperation, "accounts/sign/check")
+		setupBaseStorage(t, req)
+		require.NoError(t, setupStorageWithWalletAndAccounts(req.Storage))
+		keys, err := req.Storage.List(context.Background(), store.WalletHighestAttestationPath)
+		require.NoError(t, err)
+		for _, key := range keys {
+			require.NoError(t, req.Storage.Delete(context.Background(), store.WalletHighestAttestationPath+key))
+		}
+
+		res, err := check(t, req.Storage, basicAttestationData())
+		requireVerdict(t, res, err, SignVerdictSlashable, "highest attestation data is not found, can't determine if attestation is slashable")
+	})
+
+	withEachBlockVersion(t, "proposal", func(t *testing.T, blockVersion spec.DataVersion, isBlinded bool) {
+		req := logical.TestRequest(t, logical.CreateOperation, "accounts/sign/check")
+		setupBaseStorage(t, req)
+		require.NoError(t, setupStorageWithWalletAndAccounts(req.Storage))
+
+		res, err := check(t, req.Storage, basicProposalData(blockVersion, isBlinded))
+		requireVerdict(t, res, err, SignVerdictSign, "")
+
+		sign(t, req.Storage, basicProposalData(blockVersion, isBlinded))
+
+		res, err = check(t, req.Storage, basicProposalData(blockVersion, isBlinded))
+		requireVerdict(t, res, err, SignVerdictSlashable, "slashable proposal (HighestProposalVote), not signing")
+	})
+
+	t.Run("registration", func(t *testing.T) {
+		req := logical.TestRequest(t, logical.CreateOperation, "accounts/sign/check")
+		setupBaseStorage(t, req)
+		require.NoError(t, setupStorageWithWalletAndAccounts(req.Storage))
+
+		res, err := check(t, req.Storage, basicRegistrationData("6a3f3ee924a940ce0d795c5a41a817607e520520"))
+		requireVerdict(t, res, err, SignVerdictSign, "")
+
+		res, err = check(t, req.Storage, basicRegistrationData("9831eef7a86c19e32becdad091c1dbc974cf452a"))
+		requireVerdict(t, res, err, SignVerdictRefused, "refused to sign: "+ErrFeeRecipientDiffers.Error())
+	})
+
+	t.Run("aggregation", func(t *testing.T) {
+		req := logical.TestRequest(t, logical.CreateOperation, "accounts/sign/check")
+		setupBaseStorage(t, req)
+		require.NoError(t, setupStorageWithWalletAndAccounts(req.Storage))
+
+		res, err := check(t, req.Storage, basicAggregationAndProofData())
+		requireVerdict(t, res, err, SignVerdictSign, "")
+	})
+
+	t.Run("unknown account", func(t *testing.T) {
+		req := logical.TestRequest(t, logical.CreateOperation, "accounts/sign/check")
+		setupBaseStorage(t, req)
+		require.NoError(t, setupStorageWithWalletAndAccounts(req.Storage))
+
+		res, err := check(t, req.Storage, basicAttestationDataWithOps(true, false, false, false, false))
+		requireCodedError(t, res, err, errorex.CodeUnknownAccount, "failed to check sign request: account not found")
+	})
+
+	t.Run("malformed request", func(t *testing.T) {
+		req := logical.TestRequest(t, logical.CreateOperation, "accounts/sign/check")
+		setupBaseStorage(t, req)
+
+		res, err := check(t, req.Storage, map[string]interface{}{"sign_req": "zz"})
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "failed to decode sign request hex: encoding/hex: invalid byte: U+007A 'z'")
+	})
+}
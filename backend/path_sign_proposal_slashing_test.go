@@ -21,6 +21,7 @@ import (
 
 	"github.com/bloxapp/key-vault/keymanager/models"
 	"github.com/bloxapp/key-vault/utils/encoder"
+	"github.com/bloxapp/key-vault/utils/errorex"
 )
 
 type signRequestModifier func(block *models.SignRequest)
@@ -249,9 +250,7 @@ func TestProposalSlashing(t *testing.T) {
 
 		req.Data = basicProposalDataWithOps(blockVersion, false, true, false, false, false)
 		res, err := b.HandleRequest(context.Background(), req)
-		require.NotNil(t, err)
-		require.EqualError(t, err, "failed to sign: account not found")
-		require.Nil(t, res)
+		requireCodedError(t, res, err, errorex.CodeUnknownAccount, "failed to sign: account not found")
 	})
 
 	withEachBlockVersion(t, "Sign proposal (exactly same), should error under minimal proposal protection", func(t *testing.T, blockVersion spec.DataVersion, isBlinded bool) {
@@ -270,8 +269,7 @@ func TestProposalSlashing(t *testing.T) {
 		// second proposal
 		req.Data = basicProposalData(blockVersion, isBlinded)
 		res, err := b.HandleRequest(context.Background(), req)
-		require.EqualError(t, err, "failed to sign: slashable proposal (HighestProposalVote), not signing")
-		require.Nil(t, res)
+		requireCodedError(t, res, err, errorex.CodeSlashable, "failed to sign: slashable proposal (HighestProposalVote), not signing")
 	})
 
 	withEachBlockVersion(t, "Sign double proposal(different state root), should error", func(t *testing.T, blockVersion spec.DataVersion, isBlinded bool) {
@@ -289,9 +287,8 @@ func TestProposalSlashing(t *testing.T) {
 
 		// second proposal
 		req.Data = basicProposalDataWithOps(blockVersion, false, false, true, false, false)
-		_, err = b.HandleRequest(context.Background(), req)
-		require.NotNil(t, err)
-		require.EqualError(t, err, "failed to sign: slashable proposal (HighestProposalVote), not signing")
+		res, err := b.HandleRequest(context.Background(), req)
+		requireCodedError(t, res, err, errorex.CodeSlashable, "failed to sign: slashable proposal (HighestProposalVote), not signing")
 	})
 
 	withEachBlockVersion(t, "Sign double proposal(different parent root), should error", func(t *testing.T, blockVersion spec.DataVersion, isBlinded bool) {
@@ -309,9 +306,8 @@ func TestProposalSlashing(t *testing.T) {
 
 		// second proposal
 		req.Data = basicProposalDataWithOps(blockVersion, false, false, false, true, false)
-		_, err = b.HandleRequest(context.Background(), req)
-		require.NotNil(t, err)
-		require.EqualError(t, err, "failed to sign: slashable proposal (HighestProposalVote), not signing")
+		res, err := b.HandleRequest(context.Background(), req)
+		requireCodedError(t, res, err, errorex.CodeSlashable, "failed to sign: slashable proposal (HighestProposalVote), not signing")
 	})
 
 	withEachBlockVersion(t, "Sign double proposal(different body root), should error", func(t *testing.T, blockVersion spec.DataVersion, isBlinded bool) {
@@ -329,8 +325,7 @@ func TestProposalSlashing(t *testing.T) {
 
 		// second proposal
 		req.Data = basicProposalDataWithOps(blockVersion, false, false, false, false, true)
-		_, err = b.HandleRequest(context.Background(), req)
-		require.NotNil(t, err)
-		require.EqualError(t, err, "failed to sign: slashable proposal (HighestProposalVote), not signing")
+		res, err := b.HandleRequest(context.Background(), req)
+		requireCodedError(t, res, err, errorex.CodeSlashable, "failed to sign: slashable proposal (HighestProposalVote), not signing")
 	})
 }
@@ -2,16 +2,18 @@ package backend
 
 import (
 	"context"
-	"encoding/hex"
 
-	vault "github.com/bloxapp/eth2-key-manager"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
 	"github.com/bloxapp/eth2-key-manager/signer"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 
 	"github.com/bloxapp/key-vault/backend/store"
 	"github.com/bloxapp/key-vault/keymanager/models"
+	"github.com/bloxapp/key-vault/utils/errorex"
 )
 
 // Endpoints patterns
@@ -20,6 +22,22 @@ const (
 	SignVoluntaryExitPattern = "accounts/sign-voluntary-exit"
 )
 
+// Voluntary exit errors
+var (
+	// ErrValidatorIndexUnknown is returned when the account has no validator index to check the exit against.
+	ErrValidatorIndexUnknown = errors.New("no validator index registered for the account")
+	// ErrValidatorIndexMismatch is returned when the validator index of the exit isn't the one of the account.
+	ErrValidatorIndexMismatch = errors.New("validator index doesn't belong to the account")
+	// ErrExitEpochAhead is returned when the exit epoch is ahead of the wall-clock epoch, it can't be included yet.
+	ErrExitEpochAhead = errors.New("exit epoch is ahead of the wall-clock epoch")
+	// ErrValidatorExited is returned when attestations or blocks are requested once the exit of the account is effective.
+	ErrValidatorExited = errors.New("validator exited")
+)
+
+// maxSeedLookahead is the MAX_SEED_LOOKAHEAD of the beacon chain spec. An exit included in an epoch takes effect
+// MAX_SEED_LOOKAHEAD+1 epochs later at the earliest (compute_activation_exit_epoch), or later with an exit queue.
+const maxSeedLookahead = 4
+
 func signsVoluntaryExitPath(b *backend) []*framework.Path {
 	return []*framework.Path{
 		{
@@ -36,7 +54,7 @@ func signsVoluntaryExitPath(b *backend) []*framework.Path {
 			ExistenceCheck: b.pathExistenceCheck,
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.CreateOperation: &framework.PathOperation{
-					Callback: b.pathSignVoluntaryExit,
+					Callback: withCodedErrors(b.pathSignVoluntaryExit),
 				},
 			},
 		},
@@ -50,56 +68,143 @@ func (b *backend) pathSignVoluntaryExit(ctx context.Context, req *logical.Reques
 		return nil, errors.Wrap(err, "failed to get config")
 	}
 
-	// Parse request data
-	reqEncoded := data.Get("sign_req").(string)
-	reqByts, err := hex.DecodeString(reqEncoded)
+	signReq, err := b.decodeSignRequest(data)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to decode sign request hex")
+		return nil, err
 	}
+	addReportFields(ctx, signReportFields(config.Network, signReq))
 
-	signReq := &models.SignRequest{}
-	if err := b.encoder.Decode(reqByts, signReq); err != nil {
-		return nil, errors.Wrap(err, "failed to unmarshal sign request")
-	}
-
-	var sig []byte
-	err = b.lock(signReq.GetPublicKey(), func() error {
-		// bring up KeyVault and wallet
-		storage := store.NewHashicorpVaultStore(ctx, req.Storage, config.Network)
-		options := vault.KeyVaultOptions{}
-		options.SetStorage(storage)
-
-		// Open wallet
-		kv, err := vault.OpenKeyVault(&options)
+	// The exit waits for the approvals when they are required, see the exit requests endpoints.
+	if config.ExitApprovals > 0 {
+		res, err := b.requestVoluntaryExit(ctx, req, config, signReq, data.Get("sign_req").(string))
 		if err != nil {
-			return errors.Wrap(err, "failed to open key vault")
+			return nil, err
 		}
+		return res, nil
+	}
 
-		wallet, err := kv.Wallet()
+	var respData map[string]interface{}
+	err = b.lock(signReq.GetPublicKey(), func() error {
+		sig, err := b.signVoluntaryExit(ctx, req.Storage, config, signReq)
 		if err != nil {
-			return errors.Wrap(err, "failed to retrieve wallet")
+			return err
 		}
-
-		var (
-			simpleSigner signer.ValidatorSigner = signer.NewSimpleSigner(wallet, nil, storage.Network())
-			sigErr       error
-		)
-
-		t, ok := signReq.GetObject().(*models.SignRequestVoluntaryExit)
-		if !ok {
-			return errors.New("failed to cast to sign request voluntary exit")
+		storage := b.newStore(ctx, req.Storage, config.Network)
+		if err := b.recordSignedExit(storage, signReq); err != nil {
+			return err
 		}
-		sig, _, sigErr = simpleSigner.SignVoluntaryExit(t.VoluntaryExit, signReq.SignatureDomain, signReq.PublicKey)
-
-		return sigErr
+		respData, err = signatureData(storage, signReq.GetPublicKey(), sig)
+		return err
 	})
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to sign")
+		err = wrapSignError(err)
+		return nil, err
 	}
+	exit := signReq.GetObject().(*models.SignRequestVoluntaryExit).VoluntaryExit
+	b.requestLogger(ctx).WithFields(logrus.Fields{
+		"exit_epoch":      exit.Epoch,
+		"validator_index": exit.ValidatorIndex,
+	}).Info("voluntary exit signed")
 
 	return &logical.Response{
-		Data: map[string]interface{}{
-			"signature": hex.EncodeToString(sig),
-		},
+		Data: respData,
 	}, nil
 }
+
+// signVoluntaryExit signs the voluntary exit of the given sign request, the caller holds the sign lock of the account.
+func (b *backend) signVoluntaryExit(ctx context.Context, s logical.Storage, config *Config, signReq *models.SignRequest) ([]byte, error) {
+	// Accounts are looked up by the public key index, the wallet isn't deserialized
+	storage := b.newStore(ctx, s, config.Network)
+	wallet := storage.IndexedWallet()
+	pubKey, err := signingAccount(storage, signReq.GetPublicKey())
+	if err != nil {
+		return nil, err
+	}
+	if err := b.checkVoluntaryExit(storage, signReq); err != nil {
+		return nil, err
+	}
+
+	simpleSigner := signer.NewSimpleSigner(wallet, nil, storage.Network())
+	t := signReq.GetObject().(*models.SignRequestVoluntaryExit)
+	sig, _, err := simpleSigner.SignVoluntaryExit(t.VoluntaryExit, signReq.SignatureDomain, pubKey)
+	return sig, err
+}
+
+// checkVoluntaryExit refuses the exits of another validator index than the one of the account,
+// and the exits of an epoch ahead of the wall-clock epoch.
+func (b *backend) checkVoluntaryExit(storage *store.HashicorpVaultStore, signReq *models.SignRequest) error {
+	t, ok := signReq.GetObject().(*models.SignRequestVoluntaryExit)
+	if !ok {
+		return errorex.NewErrBadRequest("failed to cast to sign request voluntary exit")
+	}
+	if err := b.checkExitEpoch(storage.Network(), t.VoluntaryExit.Epoch); err != nil {
+		return err
+	}
+
+	index, found, err := storage.ValidatorIndex(signReq.GetPublicKey())
+	if err != nil {
+		return errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read validator index")
+	}
+	if !found {
+		return ErrValidatorIndexUnknown
+	}
+	if index != t.VoluntaryExit.ValidatorIndex {
+		return errors.Wrapf(ErrValidatorIndexMismatch, "validator index %d, the account is %d", t.VoluntaryExit.ValidatorIndex, index)
+	}
+	return nil
+}
+
+// checkExitEpoch refuses the exit epochs ahead of the wall-clock epoch.
+func (b *backend) checkExitEpoch(network core.Network, epoch phase0.Epoch) error {
+	current := network.EstimatedEpochAtSlot(network.EstimatedSlotAtTime(b.now().Unix()))
+	if epoch > current {
+		return errors.Wrapf(ErrExitEpochAhead, "epoch %d is ahead of the wall-clock epoch %d", epoch, current)
+	}
+	return nil
+}
+
+// recordSignedExit records the signed voluntary exit of the given sign request,
+// the account doesn't sign attestations and blocks after its exit epoch.
+func (b *backend) recordSignedExit(storage *store.HashicorpVaultStore, signReq *models.SignRequest) error {
+	t := signReq.GetObject().(*models.SignRequestVoluntaryExit)
+	if err := storage.SaveSignedExit(signReq.GetPublicKey(), &store.SignedExit{
+		Epoch:          t.VoluntaryExit.Epoch,
+		ValidatorIndex: t.VoluntaryExit.ValidatorIndex,
+		SignedAt:       b.now().UTC(),
+	}); err != nil {
+		return errorex.Wrap(errorex.CodeStorageFailure, err, "failed to record signed exit")
+	}
+	return nil
+}
+
+// checkSignedExit refuses the attestations and blocks of the accounts once their signed voluntary exit may be effective.
+// An exit is included at its epoch, or at the wall-clock epoch it was signed at if later, at the earliest, and the
+// validator stays active until its exit epoch, it still has duties until then and missing them is penalized.
+// So the duties are refused from the earliest exit epoch.
+func (b *backend) checkSignedExit(storage *store.HashicorpVaultStore, signReq *models.SignRequest) error {
+	switch signReq.GetObject().(type) {
+	case *models.SignRequestBlock, *models.SignRequestBlindedBlock, *models.SignRequestAttestationData:
+	default:
+		return nil
+	}
+
+	exit, err := storage.SignedExit(signReq.GetPublicKey())
+	if err != nil {
+		return errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read signed exit")
+	}
+	if exit == nil {
+		return nil
+	}
+	ranges, err := requestSlotRanges(storage.Network(), signReq)
+	if err != nil {
+		return err
+	}
+	inclusionEpoch := exit.InclusionEpoch(storage.Network())
+	exitEpoch := inclusionEpoch + 1 + maxSeedLookahead
+	for _, r := range ranges {
+		if epoch := storage.Network().EstimatedEpochAtSlot(r.first); epoch >= exitEpoch {
+			return errors.Wrapf(ErrValidatorExited, "refused to sign %s %d from the earliest exit epoch %d of the exit at epoch %d included from epoch %d", r.name, r.value, exitEpoch, exit.Epoch, inclusionEpoch)
+		}
+	}
+	return nil
+}
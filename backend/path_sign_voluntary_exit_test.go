@@ -11,6 +11,7 @@ import (
 
 	"github.com/bloxapp/key-vault/keymanager/models"
 	"github.com/bloxapp/key-vault/utils/encoder"
+	"github.com/bloxapp/key-vault/utils/errorex"
 )
 
 func basicVoluntaryExitData(differentPubKey bool) map[string]interface{} {
@@ -46,11 +47,12 @@ func TestVoluntaryExit(t *testing.T) {
 		// setup storage
 		err := setupStorageWithWalletAndAccounts(req.Storage)
 		require.NoError(t, err)
+		setupValidatorIndex(t, req.Storage, "95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf", 1)
 
 		req.Data = basicVoluntaryExitData(false)
 		resp, err := b.HandleRequest(context.Background(), req)
 		require.NoError(t, err)
-		require.NotNil(t, resp.Data)
+		require.NotEmpty(t, resp.Data["signature"])
 	})
 
 	t.Run("Sign voluntary exit of unknown account", func(t *testing.T) {
@@ -63,8 +65,6 @@ func TestVoluntaryExit(t *testing.T) {
 
 		req.Data = basicVoluntaryExitData(true)
 		resp, err := b.HandleRequest(context.Background(), req)
-		require.NotNil(t, err)
-		require.EqualError(t, err, "failed to sign: account not found")
-		require.Nil(t, resp)
+		requireCodedError(t, resp, err, errorex.CodeUnknownAccount, "failed to sign: account not found")
 	})
 }
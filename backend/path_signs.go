@@ -6,16 +6,14 @@ import (
 	"sync"
 
 	"github.com/attestantio/go-eth2-client/spec/bellatrix"
-	vault "github.com/bloxapp/eth2-key-manager"
 	"github.com/bloxapp/eth2-key-manager/signer"
-	slashingprotection "github.com/bloxapp/eth2-key-manager/slashing_protection"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/pkg/errors"
 
-	"github.com/bloxapp/key-vault/backend/store"
 	"github.com/bloxapp/key-vault/keymanager/models"
+	"github.com/bloxapp/key-vault/utils/errorex"
 )
 
 // Endpoints patterns
@@ -40,7 +38,7 @@ func signsPaths(b *backend) []*framework.Path {
 			ExistenceCheck: b.pathExistenceCheck,
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.CreateOperation: &framework.PathOperation{
-					Callback: b.pathSign,
+					Callback: withCodedErrors(b.pathSign),
 				},
 			},
 		},
@@ -54,90 +52,105 @@ func (b *backend) pathSign(ctx context.Context, req *logical.Request, data *fram
 		return nil, errors.Wrap(err, "failed to get config")
 	}
 
-	// Parse request data
-	reqEncoded := data.Get("sign_req").(string)
-	reqByts, err := hex.DecodeString(reqEncoded)
+	signReq, err := b.decodeSignRequest(data)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to decode sign request hex")
+		return nil, err
 	}
+	addReportFields(ctx, signReportFields(config.Network, signReq))
 
-	signReq := &models.SignRequest{}
-	if err := b.encoder.Decode(reqByts, signReq); err != nil {
-		return nil, errors.Wrap(err, "failed to unmarshal sign request")
+	if err := b.checkRateLimits(config, req.EntityID, req.ClientTokenAccessor, signReq); err != nil {
+		err = wrapSignError(err)
+		return nil, err
 	}
 
-	var sig []byte
+	var respData map[string]interface{}
 	err = b.lock(signReq.GetPublicKey(), func() error {
-		// bring up KeyVault and wallet
-		storage := store.NewHashicorpVaultStore(ctx, req.Storage, config.Network)
-		options := vault.KeyVaultOptions{}
-		options.SetStorage(storage)
-
-		// Open wallet
-		kv, err := vault.OpenKeyVault(&options)
-		if err != nil {
-			return errors.Wrap(err, "failed to open key vault")
+		// Accounts are looked up by the public key index, the wallet isn't deserialized
+		storage := b.newStore(ctx, req.Storage, config.Network)
+		wallet := storage.IndexedWallet()
+		if err := b.checkClockBounds(config, storage.Network(), signReq); err != nil {
+			return err
 		}
-
-		wallet, err := kv.Wallet()
+		pubKey, protector, err := signingKey(storage, signReq.GetPublicKey())
 		if err != nil {
-			return errors.Wrap(err, "failed to retrieve wallet")
+			return err
+		}
+		if err := b.checkDoppelgangerWindow(storage, pubKey, signReq); err != nil {
+			return err
+		}
+		if err := b.checkSignedExit(storage, signReq); err != nil {
+			return err
+		}
+		// The slashing refusals of the signer aren't typed, they are returned by the same checks beforehand.
+		if _, err := storage.AccountByPublicKey(pubKey); err != nil {
+			return err
+		}
+		if err := checkSignRequest(signReq, protector, storage.Network(), config); err != nil {
+			return err
 		}
 
 		var (
-			protector                           = slashingprotection.NewNormalProtection(storage)
 			simpleSigner signer.ValidatorSigner = signer.NewSimpleSigner(wallet, protector, storage.Network())
+			sig          []byte
 			sigErr       error
 		)
 
 		switch t := signReq.GetObject().(type) {
 		case *models.SignRequestBlock:
-			sig, _, sigErr = simpleSigner.SignBeaconBlock(t.VersionedBeaconBlock, signReq.SignatureDomain, signReq.PublicKey)
+			sig, _, sigErr = simpleSigner.SignBeaconBlock(t.VersionedBeaconBlock, signReq.SignatureDomain, pubKey)
 		case *models.SignRequestBlindedBlock:
-			sig, _, sigErr = simpleSigner.SignBlindedBeaconBlock(t.VersionedBlindedBeaconBlock, signReq.SignatureDomain, signReq.PublicKey)
+			sig, _, sigErr = simpleSigner.SignBlindedBeaconBlock(t.VersionedBlindedBeaconBlock, signReq.SignatureDomain, pubKey)
 		case *models.SignRequestAttestationData:
-			sig, _, sigErr = simpleSigner.SignBeaconAttestation(t.AttestationData, signReq.SignatureDomain, signReq.PublicKey)
+			sig, _, sigErr = simpleSigner.SignBeaconAttestation(t.AttestationData, signReq.SignatureDomain, pubKey)
 		case *models.SignRequestSlot:
-			sig, _, sigErr = simpleSigner.SignSlot(t.Slot, signReq.SignatureDomain, signReq.PublicKey)
+			sig, _, sigErr = simpleSigner.SignSlot(t.Slot, signReq.SignatureDomain, pubKey)
 		case *models.SignRequestEpoch:
-			sig, _, sigErr = simpleSigner.SignEpoch(t.Epoch, signReq.SignatureDomain, signReq.PublicKey)
+			sig, _, sigErr = simpleSigner.SignEpoch(t.Epoch, signReq.SignatureDomain, pubKey)
 		case *models.SignRequestAggregateAttestationAndProof:
-			sig, _, sigErr = simpleSigner.SignAggregateAndProof(t.AggregateAttestationAndProof, signReq.SignatureDomain, signReq.PublicKey)
+			sig, _, sigErr = simpleSigner.SignAggregateAndProof(t.AggregateAttestationAndProof, signReq.SignatureDomain, pubKey)
 		case *models.SignRequestSyncCommitteeMessage:
-			sig, _, sigErr = simpleSigner.SignSyncCommittee(t.Root, signReq.SignatureDomain, signReq.PublicKey)
+			sig, _, sigErr = simpleSigner.SignSyncCommittee(t.Root, signReq.SignatureDomain, pubKey)
 		case *models.SignRequestSyncAggregatorSelectionData:
-			sig, _, sigErr = simpleSigner.SignSyncCommitteeSelectionData(t.SyncAggregatorSelectionData, signReq.SignatureDomain, signReq.PublicKey)
+			sig, _, sigErr = simpleSigner.SignSyncCommitteeSelectionData(t.SyncAggregatorSelectionData, signReq.SignatureDomain, pubKey)
 		case *models.SignRequestContributionAndProof:
-			sig, _, sigErr = simpleSigner.SignSyncCommitteeContributionAndProof(t.ContributionAndProof, signReq.SignatureDomain, signReq.PublicKey)
+			sig, _, sigErr = simpleSigner.SignSyncCommitteeContributionAndProof(t.ContributionAndProof, signReq.SignatureDomain, pubKey)
 		case *models.SignRequestRegistration:
-			feeRecipient, err := t.VersionedValidatorRegistration.FeeRecipient()
-			if err != nil {
-				return errors.Wrap(err, "failed to get fee recipient")
-			}
-			validateErr := validateRequestedFeeRecipient(signReq.PublicKey, config.FeeRecipients, feeRecipient)
-			if validateErr != nil {
-				return errors.Wrap(validateErr, "refused to sign")
-			}
-			sig, _, sigErr = simpleSigner.SignRegistration(t.VersionedValidatorRegistration, signReq.SignatureDomain, signReq.PublicKey)
+			sig, _, sigErr = simpleSigner.SignRegistration(t.VersionedValidatorRegistration, signReq.SignatureDomain, pubKey)
 		default:
-			return errors.New("sign request: not supported")
+			return errorex.NewErrBadRequest("sign request: not supported")
+		}
+		if sigErr != nil {
+			return sigErr
 		}
 
-		// Some tests rely on the error message returned by SignBeaconBlock,
-		// so this error should not be wrapped!
-		return sigErr
+		respData, err = signatureData(storage, signReq.GetPublicKey(), sig)
+		return err
 	})
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to sign")
+		err = wrapSignError(err)
+		return nil, err
 	}
+	b.requestLogger(ctx).Debug("signed")
 
 	return &logical.Response{
-		Data: map[string]interface{}{
-			"signature": hex.EncodeToString(sig),
-		},
+		Data: respData,
 	}, nil
 }
 
+// decodeSignRequest decodes the hex encoded SSZ sign request of the given data.
+func (b *backend) decodeSignRequest(data *framework.FieldData) (*models.SignRequest, error) {
+	reqByts, err := hex.DecodeString(data.Get("sign_req").(string))
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeBadRequest, err, "failed to decode sign request hex")
+	}
+
+	signReq := &models.SignRequest{}
+	if err := b.encoder.Decode(reqByts, signReq); err != nil {
+		return nil, errorex.Wrap(errorex.CodeBadRequest, err, "failed to unmarshal sign request")
+	}
+	return signReq, nil
+}
+
 func (b *backend) lock(pubKeyBytes []byte, cb func() error) error {
 	lock := func() *sync.Mutex {
 		b.signMapLock.Lock()
@@ -149,11 +162,21 @@ func (b *backend) lock(pubKeyBytes []byte, cb func() error) error {
 		return b.signLock[pubKey]
 	}()
 
+	// The lock is released on panics too, they are recovered by HandleRequest.
 	lock.Lock()
-	err := cb()
-	lock.Unlock()
+	defer lock.Unlock()
+	return cb()
+}
 
-	return err
+// lockAll runs the given callback holding the sign locks of all the given public keys.
+// The keys must be sorted to avoid deadlocks between concurrent callers.
+func (b *backend) lockAll(pubKeys [][]byte, cb func() error) error {
+	if len(pubKeys) == 0 {
+		return cb()
+	}
+	return b.lock(pubKeys[0], func() error {
+		return b.lockAll(pubKeys[1:], cb)
+	})
 }
 
 var (
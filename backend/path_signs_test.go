@@ -19,6 +19,7 @@ import (
 
 	"github.com/bloxapp/key-vault/keymanager/models"
 	"github.com/bloxapp/key-vault/utils/encoder"
+	"github.com/bloxapp/key-vault/utils/errorex"
 )
 
 func setupStorageWithWalletAndAccounts(storage logical.Storage) error {
@@ -90,9 +91,7 @@ func TestSignAttestation(t *testing.T) {
 
 		req.Data = basicAttestationDataWithOps(true, false, false, false, false)
 		resp, err := b.HandleRequest(context.Background(), req)
-		require.NotNil(t, err)
-		require.EqualError(t, err, "failed to sign: account not found")
-		require.Nil(t, resp)
+		requireCodedError(t, resp, err, errorex.CodeUnknownAccount, "failed to sign: account not found")
 	})
 }
 
@@ -133,9 +132,7 @@ func TestSignProposal(t *testing.T) {
 
 		req.Data = basicProposalDataWithOps(blockVersion, false, true, false, false, false)
 		resp, err := b.HandleRequest(context.Background(), req)
-		require.NotNil(t, err)
-		require.EqualError(t, err, "failed to sign: account not found")
-		require.Nil(t, resp)
+		requireCodedError(t, resp, err, errorex.CodeUnknownAccount, "failed to sign: account not found")
 	})
 }
 
@@ -172,9 +169,7 @@ func TestSignAggregation(t *testing.T) {
 
 		req.Data = basicAggregationAndProofDataWithOps(true)
 		resp, err := b.HandleRequest(context.Background(), req)
-		require.NotNil(t, err)
-		require.EqualError(t, err, "failed to sign: account not found")
-		require.Nil(t, resp)
+		requireCodedError(t, resp, err, errorex.CodeUnknownAccount, "failed to sign: account not found")
 	})
 }
 
@@ -1,16 +1,20 @@
 package backend
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"sort"
 
 	"github.com/bloxapp/eth2-key-manager/stores/inmemory"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 
 	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/utils/errorex"
 )
 
 // Endpoints patterns
@@ -30,11 +34,20 @@ func storagePaths(b *backend) []*framework.Path {
 					Type:        framework.TypeString,
 					Description: "storage to update",
 				},
+				"dry_run": {
+					Type:        framework.TypeBool,
+					Description: "report what the update would do without writing anything",
+					Default:     false,
+				},
+				"doppelganger_epochs": {
+					Type:        framework.TypeInt,
+					Description: "number of epochs during which the added accounts don't sign attestations and blocks, the configured one by default",
+				},
 			},
 			ExistenceCheck: b.pathExistenceCheck,
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.CreateOperation: &framework.PathOperation{
-					Callback: b.pathStorageUpdate,
+					Callback: withCodedErrors(b.pathStorageUpdate),
 				},
 			},
 		},
@@ -61,18 +74,95 @@ func buildInMemStore(data *framework.FieldData) (*inmemory.InMemStore, error) {
 func (b *backend) pathStorageUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	inMemStore, err := buildInMemStore(data)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to build in memory store")
+		return nil, errorex.Wrap(errorex.CodeBadRequest, err, "failed to build in memory store")
+	}
+	dryRun := data.Get("dry_run").(bool)
+	doppelgangerEpochs, err := doppelgangerEpochsOverride(data)
+	if err != nil {
+		return nil, err
 	}
 
+	report, err := b.importStore(ctx, req.Storage, inMemStore, dryRun, doppelgangerEpochs, func(storage *store.HashicorpVaultStore) (*store.ImportReport, error) {
+		return storage.ImportFromInMemoryStore(inMemStore, dryRun)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return importResponse(report), nil
+}
+
+// logImport logs the summary of the given import, the conflicted accounts are kept as they were and logged as a warning.
+func (b *backend) logImport(ctx context.Context, report *store.ImportReport) {
+	summary := report.Summary()
+	logger := b.requestLogger(ctx).WithFields(logrus.Fields{
+		"dry_run": report.DryRun,
+		"summary": summary,
+	})
+	if summary[store.ImportStatusConflicted] > 0 {
+		logger.Warn("storage updated with conflicted accounts")
+		return
+	}
+	logger.Info("storage updated")
+}
+
+// importStore runs the given import of the accounts of the given in-memory store into the configured network,
+// holding the sign locks of the accounts as their slashing data may be merged.
+// The added accounts don't sign attestations and blocks for the given number of epochs, the configured one if nil.
+func (b *backend) importStore(ctx context.Context, s logical.Storage, inMemStore *inmemory.InMemStore, dryRun bool, doppelgangerEpochs *uint64, importFn func(*store.HashicorpVaultStore) (*store.ImportReport, error)) (*store.ImportReport, error) {
+	// Import into the configured network, the plugin may not be configured yet
+	network := inMemStore.Network()
+	var epochs uint64
+	config, err := b.readConfig(ctx, s)
+	if err == nil {
+		network = config.Network
+		epochs = config.DoppelgangerEpochs
+	} else if codedErr, ok := errorex.AsCodedError(err); !ok || codedErr.Code != errorex.CodeNotConfigured {
+		return nil, err
+	}
+
+	if doppelgangerEpochs != nil {
+		epochs = *doppelgangerEpochs
+	}
+
+	pubKeys, err := store.PublicKeys(inMemStore)
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeBadRequest, err, "failed to build in memory store")
+	}
+	sort.Slice(pubKeys, func(i, j int) bool {
+		return bytes.Compare(pubKeys[i], pubKeys[j]) < 0
+	})
+
 	// Update hashicorp store with new account(s)
-	_, err = store.FromInMemoryStoreV2(ctx, inMemStore, req.Storage)
+	var report *store.ImportReport
+	err = b.lockAll(pubKeys, func() error {
+		storage := b.newStore(ctx, s, network)
+		var err error
+		if report, err = importFn(storage); err != nil {
+			return err
+		}
+		return b.saveDoppelgangerWindows(storage, report, epochs)
+	})
+	if !dryRun {
+		b.accountCache.Invalidate()
+	}
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to update storage from in memory")
+		if cause := errors.Cause(err); cause == store.ErrNetworkMismatch || cause == store.ErrWalletTypeMismatch {
+			return nil, errorex.Wrap(errorex.CodeBadRequest, err, "failed to update storage from in memory")
+		}
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to update storage from in memory")
 	}
+	b.logImport(ctx, report)
+	return report, nil
+}
 
+// importResponse returns the response of the given import report.
+func importResponse(report *store.ImportReport) *logical.Response {
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"status": true,
+			"status":   true,
+			"dry_run":  report.DryRun,
+			"summary":  report.Summary(),
+			"accounts": report.Accounts,
 		},
-	}, nil
+	}
 }
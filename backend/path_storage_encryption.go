@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/pkg/errors"
+
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+// Endpoints patterns
+const (
+	// EncryptionStoragePattern is the path pattern for storage encryption migration endpoint
+	EncryptionStoragePattern = "storage/encryption"
+)
+
+func storageEncryptionPaths(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern:         EncryptionStoragePattern,
+			HelpSynopsis:    "Re-encrypt storage",
+			HelpDescription: `Rewrite the wallet and the accounts in place, encrypting the plain accounts when the storage encryption is enabled`,
+			ExistenceCheck:  b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathStorageReEncrypt),
+				},
+			},
+		},
+	}
+}
+
+// pathStorageReEncrypt migrates the existing entries to the current encryption settings.
+func (b *backend) pathStorageReEncrypt(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// Load config
+	config, err := b.readConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get config")
+	}
+
+	count, err := b.newStore(ctx, req.Storage, config.Network).ReEncrypt()
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to re-encrypt storage")
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"encrypted": b.encryptor != nil,
+			"entries":   count,
+		},
+	}, nil
+}
@@ -0,0 +1,83 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/bloxapp/eth2-key-manager/encryptor/keystorev4"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/helper/logging"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/backend/store"
+)
+
+func getEncryptedBackend(t *testing.T, password string) logical.Backend {
+	config := &logical.BackendConfig{
+		Logger:      logging.NewVaultLogger(hclog.Trace),
+		System:      &logical.StaticSystemView{},
+		StorageView: &logical.InmemStorage{},
+		BackendUUID: "test",
+	}
+
+	b, err := Factory("test", logrus.New(), WithStorageEncryption(keystorev4.New(keystorev4.WithCipher("pbkdf2")), []byte(password)))(context.Background(), config)
+	require.NoError(t, err)
+	return b
+}
+
+func TestStorageEncryption(t *testing.T) {
+	b := getEncryptedBackend(t, "password")
+
+	req := logical.TestRequest(t, logical.CreateOperation, "storage/encryption")
+	setupBaseStorage(t, req)
+	require.NoError(t, setupStorageWithWalletAndAccounts(req.Storage))
+	require.NoError(t, updateWithBasicHighestAtt(req.Storage))
+
+	requireAccountsEncrypted := func(t *testing.T, encrypted bool) {
+		keys, err := req.Storage.List(context.Background(), store.AccountBase)
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+
+		entry, err := req.Storage.Get(context.Background(), store.AccountBase+keys[0])
+		require.NoError(t, err)
+		var value map[string]interface{}
+		require.NoError(t, json.Unmarshal(entry.Value, &value))
+		if encrypted {
+			require.Contains(t, value, "envelope_version")
+		} else {
+			require.NotContains(t, value, "envelope_version")
+		}
+	}
+
+	// legacy plain accounts are readable
+	requireAccountsEncrypted(t, false)
+	signReq := logical.TestRequest(t, logical.CreateOperation, "accounts/sign")
+	signReq.Storage = req.Storage
+	signReq.Data = basicAggregationAndProofData()
+	res, err := b.HandleRequest(context.Background(), signReq)
+	require.NoError(t, err)
+	require.NotEmpty(t, res.Data["signature"])
+
+	// re-encrypt the account and the wallet
+	res, err = b.HandleRequest(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, true, res.Data["encrypted"])
+	require.Equal(t, 2, res.Data["entries"])
+	requireAccountsEncrypted(t, true)
+
+	res, err = b.HandleRequest(context.Background(), signReq)
+	require.NoError(t, err)
+	require.NotEmpty(t, res.Data["signature"])
+
+	// a mount with a different password can't read the accounts
+	_, err = getEncryptedBackend(t, "wrong").HandleRequest(context.Background(), signReq)
+	require.EqualError(t, err, "failed to sign: failed to decrypt account: failed to unwrap data key: invalid checksum")
+
+	// a mount without encryption can't either
+	plain, _ := getBackend(t)
+	_, err = plain.HandleRequest(context.Background(), signReq)
+	require.EqualError(t, err, "failed to sign: failed to decrypt account: entry is encrypted but no encryptor is set")
+}
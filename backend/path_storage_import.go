@@ -0,0 +1,252 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"sort"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/pkg/errors"
+
+	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+// Endpoints patterns
+const (
+	// ImportStoragePattern is the path pattern for import sessions endpoint,
+	// a session is read or aborted at ImportStoragePattern/:session_id
+	ImportStoragePattern = "storage/import"
+)
+
+func storageImportPaths(b *backend) []*framework.Path {
+	sessionPattern := ImportStoragePattern + "/" + framework.GenericNameRegex("session_id")
+	sessionIDField := &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "import session ID",
+	}
+
+	return []*framework.Path{
+		{
+			Pattern:         ImportStoragePattern,
+			HelpSynopsis:    "Begin a multi-part import",
+			HelpDescription: `Begin an import session, its chunks are staged until the session is committed or aborted`,
+			ExistenceCheck:  b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathImportBegin),
+				},
+			},
+		},
+		{
+			Pattern:         sessionPattern,
+			HelpSynopsis:    "Read or abort an import session",
+			HelpDescription: `Read the staged chunks of an import session, or abort it and delete them`,
+			Fields: map[string]*framework.FieldSchema{
+				"session_id": sessionIDField,
+			},
+			ExistenceCheck: b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathImportRead),
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathImportAbort),
+				},
+			},
+		},
+		{
+			Pattern:         sessionPattern + "/chunks",
+			HelpSynopsis:    "Upload a chunk of an import session",
+			HelpDescription: `Stage the accounts and slashing data of an in-memory store in an import session`,
+			Fields: map[string]*framework.FieldSchema{
+				"session_id": sessionIDField,
+				"data": {
+					Type:        framework.TypeString,
+					Description: "storage chunk to stage",
+				},
+			},
+			ExistenceCheck: b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathImportChunk),
+				},
+			},
+		},
+		{
+			Pattern:         sessionPattern + "/commit",
+			HelpSynopsis:    "Commit an import session",
+			HelpDescription: `Import the staged chunks of an import session, see the storage endpoint`,
+			Fields: map[string]*framework.FieldSchema{
+				"session_id": sessionIDField,
+				"dry_run": {
+					Type:        framework.TypeBool,
+					Description: "report what the commit would do without writing anything",
+					Default:     false,
+				},
+				"doppelganger_epochs": {
+					Type:        framework.TypeInt,
+					Description: "number of epochs during which the added accounts don't sign attestations and blocks, the configured one by default",
+				},
+			},
+			ExistenceCheck: b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathImportCommit),
+				},
+			},
+		},
+	}
+}
+
+// wrapImportSessionError returns the coded error of the given import session error.
+func wrapImportSessionError(err error, message string) error {
+	switch errors.Cause(err) {
+	case store.ErrImportSessionNotFound, store.ErrImportSessionCommitting, store.ErrImportSessionEmpty,
+		store.ErrNetworkMismatch, store.ErrWalletTypeMismatch:
+		return errorex.Wrap(errorex.CodeBadRequest, err, message)
+	default:
+		return errorex.Wrap(errorex.CodeStorageFailure, err, message)
+	}
+}
+
+// importSessionResponse returns the response of the given import session.
+func importSessionResponse(session *store.ImportSession) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"session_id": session.ID,
+			"chunks":     session.Chunks,
+			"accounts":   session.Accounts,
+			"committing": session.Committing,
+		},
+	}
+}
+
+func (b *backend) pathImportBegin(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	session, err := b.newStore(ctx, req.Storage, "").BeginImportSession()
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to begin import session")
+	}
+	return importSessionResponse(session), nil
+}
+
+func (b *backend) pathImportRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	session, err := b.newStore(ctx, req.Storage, "").ImportSession(data.Get("session_id").(string))
+	if err != nil {
+		return nil, wrapImportSessionError(err, "failed to read import session")
+	}
+	return importSessionResponse(session), nil
+}
+
+func (b *backend) pathImportChunk(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	chunk, err := buildInMemStore(data)
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeBadRequest, err, "failed to build in memory store")
+	}
+
+	b.importLock.Lock()
+	defer b.importLock.Unlock()
+
+	session, err := b.newStore(ctx, req.Storage, chunk.Network()).AddImportChunk(data.Get("session_id").(string), chunk)
+	if err != nil {
+		return nil, wrapImportSessionError(err, "failed to add import chunk")
+	}
+	return importSessionResponse(session), nil
+}
+
+func (b *backend) pathImportCommit(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	id := data.Get("session_id").(string)
+	dryRun := data.Get("dry_run").(bool)
+	doppelgangerEpochs, err := doppelgangerEpochsOverride(data)
+	if err != nil {
+		return nil, err
+	}
+
+	b.importLock.Lock()
+	defer b.importLock.Unlock()
+
+	merged, err := b.newStore(ctx, req.Storage, "").ImportSessionStore(id)
+	if err != nil {
+		return nil, wrapImportSessionError(err, "failed to commit import session")
+	}
+
+	report, err := b.importStore(ctx, req.Storage, merged, dryRun, doppelgangerEpochs, func(storage *store.HashicorpVaultStore) (*store.ImportReport, error) {
+		return storage.CommitImportSession(id, merged, dryRun)
+	})
+	if err != nil {
+		// The store rolls back its failed writes, the doppelganger windows are saved afterwards.
+		if !dryRun {
+			if rollbackErr := b.rollbackImportSession(ctx, req.Storage, id); rollbackErr != nil {
+				b.requestLogger(ctx).WithError(rollbackErr).Error("failed to roll back import session")
+			}
+		}
+		return nil, err
+	}
+
+	// The session is deleted once the doppelganger windows are saved too, a failed commit is resumed until then.
+	if !dryRun {
+		if err := b.newStore(ctx, req.Storage, "").FinishImportSession(id); err != nil {
+			return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to delete committed import session")
+		}
+	}
+	return importResponse(report), nil
+}
+
+func (b *backend) pathImportAbort(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.importLock.Lock()
+	defer b.importLock.Unlock()
+
+	id := data.Get("session_id").(string)
+	storage := b.newStore(ctx, req.Storage, "")
+	session, err := storage.ImportSession(id)
+	if err != nil {
+		return nil, wrapImportSessionError(err, "failed to abort import session")
+	}
+	err = b.lockAll(addedPublicKeys(session), func() error {
+		return storage.AbortImportSession(id)
+	})
+	b.accountCache.Invalidate()
+	if err != nil {
+		return nil, wrapImportSessionError(err, "failed to abort import session")
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"session_id": id,
+		},
+	}, nil
+}
+
+// rollbackImportSession rolls back the commit of the given import session holding the sign locks of its added accounts.
+func (b *backend) rollbackImportSession(ctx context.Context, s logical.Storage, id string) error {
+	storage := b.newStore(ctx, s, "")
+	session, err := storage.ImportSession(id)
+	if err != nil {
+		return err
+	}
+	defer b.accountCache.Invalidate()
+	return b.lockAll(addedPublicKeys(session), func() error {
+		return storage.RollbackImportSession(id)
+	})
+}
+
+// addedPublicKeys returns the sorted public keys of the accounts added by the commit of the given import session.
+func addedPublicKeys(session *store.ImportSession) [][]byte {
+	if session.Report == nil {
+		return nil
+	}
+	var pubKeys [][]byte
+	for _, account := range session.Report.Accounts {
+		if account.Status != store.ImportStatusAdded {
+			continue
+		}
+		// The public keys of the report are hex encoded by the store.
+		pubKey, _ := hex.DecodeString(account.PublicKey)
+		pubKeys = append(pubKeys, pubKey)
+	}
+	sort.Slice(pubKeys, func(i, j int) bool {
+		return bytes.Compare(pubKeys[i], pubKeys[j]) < 0
+	})
+	return pubKeys
+}
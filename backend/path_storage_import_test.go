@@ -0,0 +1,131 @@
+package backend
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/stores/inmemory"
+	"github.com/bloxapp/eth2-key-manager/wallets/hd"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+// importChunkData returns the hex encoded in-memory store of the account of the given index.
+func importChunkData(t *testing.T, index int) (string, string) {
+	inMemStore := inmemory.NewInMemStore(core.PraterNetwork)
+	wallet := hd.NewWallet(&core.WalletContext{Storage: inMemStore})
+	require.NoError(t, inMemStore.SaveWallet(wallet))
+	account, err := wallet.CreateValidatorAccount(_byteArray("0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1fff"), &index)
+	require.NoError(t, err)
+
+	byts, err := json.Marshal(inMemStore)
+	require.NoError(t, err)
+	return hex.EncodeToString(byts), hex.EncodeToString(account.ValidatorPublicKey())
+}
+
+func TestStorageImportSession(t *testing.T) {
+	b, _ := getBackend(t)
+	request := func(t *testing.T, storage logical.Storage, operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		req := logical.TestRequest(t, operation, path)
+		req.Storage = storage
+		req.Data = data
+		return b.HandleRequest(context.Background(), req)
+	}
+	begin := func(t *testing.T, storage logical.Storage) string {
+		res, err := request(t, storage, logical.CreateOperation, "storage/import", nil)
+		require.NoError(t, err)
+		return res.Data["session_id"].(string)
+	}
+
+	chunk1, pubKey1 := importChunkData(t, 0)
+	chunk2, pubKey2 := importChunkData(t, 1)
+
+	t.Run("commit", func(t *testing.T) {
+		req := logical.TestRequest(t, logical.CreateOperation, "storage/import")
+		setupBaseStorage(t, req)
+		id := begin(t, req.Storage)
+
+		for i, chunk := range []string{chunk1, chunk2} {
+			res, err := request(t, req.Storage, logical.CreateOperation, "storage/import/"+id+"/chunks", map[string]interface{}{"data": chunk})
+			require.NoError(t, err)
+			require.Equal(t, i+1, res.Data["chunks"])
+		}
+
+		res, err := request(t, req.Storage, logical.ReadOperation, "storage/import/"+id, nil)
+		require.NoError(t, err)
+		require.Equal(t, 2, res.Data["chunks"])
+		require.Equal(t, 2, res.Data["accounts"])
+		require.False(t, res.Data["committing"].(bool))
+
+		res, err = request(t, req.Storage, logical.CreateOperation, "storage/import/"+id+"/commit", map[string]interface{}{"dry_run": true})
+		require.NoError(t, err)
+		require.True(t, res.Data["dry_run"].(bool))
+		require.Equal(t, 2, res.Data["summary"].(map[string]int)[store.ImportStatusAdded])
+
+		res, err = request(t, req.Storage, logical.CreateOperation, "storage/import/"+id+"/commit", nil)
+		require.NoError(t, err)
+		require.False(t, res.Data["dry_run"].(bool))
+		require.Equal(t, 2, res.Data["summary"].(map[string]int)[store.ImportStatusAdded])
+
+		s := store.NewHashicorpVaultStore(context.Background(), req.Storage, core.PraterNetwork)
+		for _, pubKey := range []string{pubKey1, pubKey2} {
+			_, err := s.AccountByPublicKey(_byteArray(pubKey))
+			require.NoError(t, err)
+		}
+
+		// the committed session is deleted
+		res, err = request(t, req.Storage, logical.ReadOperation, "storage/import/"+id, nil)
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "failed to read import session: import session not found")
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		req := logical.TestRequest(t, logical.CreateOperation, "storage/import")
+		setupBaseStorage(t, req)
+		id := begin(t, req.Storage)
+		_, err := request(t, req.Storage, logical.CreateOperation, "storage/import/"+id+"/chunks", map[string]interface{}{"data": chunk1})
+		require.NoError(t, err)
+
+		_, err = request(t, req.Storage, logical.DeleteOperation, "storage/import/"+id, nil)
+		require.NoError(t, err)
+
+		res, err := request(t, req.Storage, logical.CreateOperation, "storage/import/"+id+"/commit", nil)
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "failed to commit import session: import session not found")
+		_, err = store.NewHashicorpVaultStore(context.Background(), req.Storage, core.PraterNetwork).OpenWallet()
+		require.Error(t, err)
+	})
+
+	t.Run("empty session", func(t *testing.T) {
+		req := logical.TestRequest(t, logical.CreateOperation, "storage/import")
+		setupBaseStorage(t, req)
+		id := begin(t, req.Storage)
+
+		res, err := request(t, req.Storage, logical.CreateOperation, "storage/import/"+id+"/commit", nil)
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "failed to commit import session: import session has no chunks")
+	})
+
+	t.Run("network mismatch", func(t *testing.T) {
+		req := logical.TestRequest(t, logical.CreateOperation, "storage/import")
+		setupBaseStorage(t, req, func(config *Config) {
+			config.Network = core.MainNetwork
+		})
+		// the config is cached per mount
+		b.InvalidateKey(context.Background(), ConfigPattern)
+		id := begin(t, req.Storage)
+		_, err := request(t, req.Storage, logical.CreateOperation, "storage/import/"+id+"/chunks", map[string]interface{}{"data": chunk1})
+		require.NoError(t, err)
+
+		res, err := request(t, req.Storage, logical.CreateOperation, "storage/import/"+id+"/commit", nil)
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "failed to update storage from in memory: failed to import prater accounts into mainnet storage: network mismatch")
+
+		// the session is left as is
+		res, err = request(t, req.Storage, logical.ReadOperation, "storage/import/"+id, nil)
+		require.NoError(t, err)
+		require.False(t, res.Data["committing"].(bool))
+	})
+}
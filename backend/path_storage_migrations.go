@@ -0,0 +1,134 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+// Endpoints patterns
+const (
+	// MigrationsStoragePattern is the path pattern for storage migrations endpoint
+	MigrationsStoragePattern = "storage/migrations"
+)
+
+func storageMigrationsPaths(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern:         MigrationsStoragePattern,
+			HelpSynopsis:    "Storage schema migrations",
+			HelpDescription: `Read the storage schema version and the pending migrations, or run them`,
+			ExistenceCheck:  b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathMigrationsRead),
+				},
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathMigrationsRun),
+				},
+			},
+		},
+	}
+}
+
+// initialize runs the pending storage migrations once the plugin is mounted.
+// Failures are logged only, the migrations can be run again through the admin endpoint.
+func (b *backend) initialize(ctx context.Context, req *logical.InitializationRequest) error {
+	applied, err := b.migrate(ctx, req.Storage)
+	if err != nil {
+		b.logger.WithError(err).Error("failed to migrate storage")
+		return nil
+	}
+	if len(applied) > 0 {
+		b.logger.WithField("applied", migrationNames(applied)).Info("storage migrated")
+	}
+	return nil
+}
+
+// migrate runs the pending storage migrations.
+func (b *backend) migrate(ctx context.Context, s logical.Storage) ([]*store.Migration, error) {
+	b.migrationLock.Lock()
+	defer b.migrationLock.Unlock()
+
+	storage, err := b.migrationStore(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	// Migrations may rewrite the cached entries.
+	defer b.accountCache.Invalidate()
+	return storage.Migrate()
+}
+
+// migrationStore returns the store to migrate, the storage may not be configured yet.
+func (b *backend) migrationStore(ctx context.Context, s logical.Storage) (*store.HashicorpVaultStore, error) {
+	var network core.Network
+	config, err := b.readConfig(ctx, s)
+	if err == nil {
+		network = config.Network
+	} else if codedErr, ok := errorex.AsCodedError(err); !ok || codedErr.Code != errorex.CodeNotConfigured {
+		return nil, err
+	}
+	return b.newStore(ctx, s, network), nil
+}
+
+func (b *backend) pathMigrationsRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	storage, err := b.migrationStore(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := storage.SchemaVersion()
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read schema version")
+	}
+	pending, err := storage.PendingMigrations(store.Migrations)
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read pending migrations")
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"version": version,
+			"latest":  store.LatestSchemaVersion(),
+			"pending": migrationNames(pending),
+		},
+	}, nil
+}
+
+func (b *backend) pathMigrationsRun(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	applied, err := b.migrate(ctx, req.Storage)
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to migrate storage")
+	}
+
+	storage, err := b.migrationStore(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	version, err := storage.SchemaVersion()
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read schema version")
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"version": version,
+			"applied": migrationNames(applied),
+		},
+	}, nil
+}
+
+// migrationNames returns the names of the given migrations.
+func migrationNames(migrations []*store.Migration) []string {
+	names := make([]string, len(migrations))
+	for i, migration := range migrations {
+		names[i] = migration.Name
+	}
+	return names
+}
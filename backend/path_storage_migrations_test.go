@@ -0,0 +1,67 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/backend/store"
+)
+
+func TestStorageMigrations(t *testing.T) {
+	b, _ := getBackend(t)
+
+	read := func(t *testing.T, storage logical.Storage) map[string]interface{} {
+		req := logical.TestRequest(t, logical.ReadOperation, "storage/migrations")
+		req.Storage = storage
+		res, err := b.HandleRequest(context.Background(), req)
+		require.NoError(t, err)
+		return res.Data
+	}
+	names := migrationNames(store.Migrations)
+
+	t.Run("admin endpoint", func(t *testing.T) {
+		req := logical.TestRequest(t, logical.CreateOperation, "storage/migrations")
+		setupBaseStorage(t, req)
+		require.NoError(t, setupStorageWithWalletAndAccounts(req.Storage))
+
+		require.Equal(t, map[string]interface{}{
+			"version": 0,
+			"latest":  store.LatestSchemaVersion(),
+			"pending": names,
+		}, read(t, req.Storage))
+
+		res, err := b.HandleRequest(context.Background(), req)
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{
+			"version": store.LatestSchemaVersion(),
+			"applied": names,
+		}, res.Data)
+
+		require.Equal(t, map[string]interface{}{
+			"version": store.LatestSchemaVersion(),
+			"latest":  store.LatestSchemaVersion(),
+			"pending": []string{},
+		}, read(t, req.Storage))
+
+		// signing works on the migrated storage
+		signReq := logical.TestRequest(t, logical.CreateOperation, "accounts/sign")
+		signReq.Storage = req.Storage
+		signReq.Data = basicAggregationAndProofData()
+		res, err = b.HandleRequest(context.Background(), signReq)
+		require.NoError(t, err)
+		require.NotEmpty(t, res.Data["signature"])
+	})
+
+	t.Run("on initialization", func(t *testing.T) {
+		storage := &logical.InmemStorage{}
+		require.NoError(t, b.Initialize(context.Background(), &logical.InitializationRequest{Storage: storage}))
+
+		version, err := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork).SchemaVersion()
+		require.NoError(t, err)
+		require.Equal(t, store.LatestSchemaVersion(), version)
+	})
+}
@@ -3,16 +3,18 @@ package backend
 import (
 	"context"
 	"encoding/hex"
-	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/attestantio/go-eth2-client/spec/phase0"
-	vault "github.com/bloxapp/eth2-key-manager"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/pkg/errors"
 
 	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/utils/errorex"
 )
 
 // Endpoints patterns
@@ -21,15 +23,41 @@ const (
 	SlashingStoragePattern = "storage/slashing"
 )
 
-// SlashingHistory contains slashing history data.
+// Slashing storage read limits
+const (
+	// DefaultSlashingHistoryLimit is the page size of the slashing storage read when no limit is given.
+	DefaultSlashingHistoryLimit = 100
+	// MaxSlashingHistoryLimit is the largest page size of the slashing storage read.
+	MaxSlashingHistoryLimit = 1000
+
+	// slashingHistoryWorkers bounds the number of accounts loaded concurrently.
+	slashingHistoryWorkers = 16
+)
+
+// SlashingHistory contains slashing history data of an account.
+// NoProposals is set for the accounts that never proposed, the history is complete without a highest proposal.
+// Errors lists the missing or corrupt records of an incomplete history.
 type SlashingHistory struct {
-	HighestAttestation *phase0.AttestationData
-	HighestProposal    *HighestProposal
+	PublicKey          string                  `json:"public_key"`
+	HighestAttestation *phase0.AttestationData `json:"highest_attestation,omitempty"`
+	HighestProposal    *HighestProposal        `json:"highest_proposal,omitempty"`
+	NoProposals        bool                    `json:"no_proposals,omitempty"`
+	Errors             []string                `json:"errors,omitempty"`
 }
 
 // HighestProposal contains highest proposal data.
 type HighestProposal struct {
-	Slot phase0.Slot
+	Slot phase0.Slot `json:"slot"`
+}
+
+// SlashingHistoryPage is a page of the slashing storage read.
+// Accounts holds the complete histories and Incomplete the accounts with missing or corrupt records.
+// NextOffset is the offset of the next page, zero on the last page.
+type SlashingHistoryPage struct {
+	Accounts   []*SlashingHistory `json:"accounts"`
+	Incomplete []*SlashingHistory `json:"incomplete"`
+	Total      int                `json:"total"`
+	NextOffset int                `json:"next_offset"`
 }
 
 func storageSlashingDataPaths(b *backend) []*framework.Path {
@@ -38,10 +66,26 @@ func storageSlashingDataPaths(b *backend) []*framework.Path {
 			Pattern:         SlashingStoragePattern,
 			HelpSynopsis:    "Manage slashing storage",
 			HelpDescription: `Manage KeyVault slashing storage`,
-			ExistenceCheck:  b.pathExistenceCheck,
+			Fields: map[string]*framework.FieldSchema{
+				"public_keys": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Hex encoded public keys to read, all the accounts by default",
+				},
+				"offset": {
+					Type:        framework.TypeInt,
+					Description: "Number of accounts to skip, the accounts are sorted by public key",
+					Default:     0,
+				},
+				"limit": {
+					Type:        framework.TypeInt,
+					Description: "Maximum number of accounts to read",
+					Default:     DefaultSlashingHistoryLimit,
+				},
+			},
+			ExistenceCheck: b.pathExistenceCheck,
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.ReadOperation: &framework.PathOperation{
-					Callback: b.pathMinimalSlashingStorageRead,
+					Callback: withCodedErrors(b.pathMinimalSlashingStorageRead),
 				},
 			},
 		},
@@ -55,139 +99,167 @@ func (b *backend) pathMinimalSlashingStorageRead(ctx context.Context, req *logic
 		return nil, errors.Wrap(err, "failed to get config")
 	}
 
-	// bring up KeyVault and wallet
-	storage := store.NewHashicorpVaultStore(ctx, req.Storage, config.Network)
-	options := vault.KeyVaultOptions{}
-	options.SetStorage(storage)
+	offset := data.Get("offset").(int)
+	limit := data.Get("limit").(int)
+	if offset < 0 {
+		return nil, errorex.NewErrBadRequest("offset must not be negative")
+	}
+	if limit <= 0 || limit > MaxSlashingHistoryLimit {
+		return nil, errorex.NewErrBadRequest(fmt.Sprintf("limit must be between 1 and %d", MaxSlashingHistoryLimit))
+	}
 
-	// Open wallet
-	kv, err := vault.OpenKeyVault(&options)
+	storage := b.newStore(ctx, req.Storage, config.Network)
+	pubKeys, err := slashingHistoryPublicKeys(storage, data.Get("public_keys").([]string))
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to open key vault")
+		return nil, err
 	}
 
-	wallet, err := kv.Wallet()
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to retrieve wallet")
+	page := &SlashingHistoryPage{
+		Accounts:   []*SlashingHistory{},
+		Incomplete: []*SlashingHistory{},
+		Total:      len(pubKeys),
+	}
+	if offset >= len(pubKeys) {
+		return slashingHistoryResponse(page), nil
+	}
+	end := offset + limit
+	if end < len(pubKeys) {
+		page.NextOffset = end
+	} else {
+		end = len(pubKeys)
 	}
 
-	// Load accounts slashing history
-	accounts := wallet.Accounts()
-	responseData := make([]map[string]interface{}, len(accounts))
-	errs := make([]error, len(accounts))
-	var wg sync.WaitGroup
-	for i, account := range accounts {
-		wg.Add(1)
-		go func(i int, pubKey []byte) {
-			defer wg.Done()
+	for _, history := range loadSlashingHistories(storage, pubKeys[offset:end]) {
+		if len(history.Errors) > 0 {
+			page.Incomplete = append(page.Incomplete, history)
+		} else {
+			page.Accounts = append(page.Accounts, history)
+		}
+	}
+	return slashingHistoryResponse(page), nil
+}
 
-			// Load slashing history
-			slashingHistory, err := loadAccountSlashingHistory(storage, pubKey)
-			if err != nil {
-				errs[i] = errors.Wrap(err, "failed to load slashing history")
-				return
+// slashingHistoryPublicKeys returns the sorted hex encoded public keys to read,
+// the given filter or the public keys of every account.
+func slashingHistoryPublicKeys(storage *store.HashicorpVaultStore, filter []string) ([]string, error) {
+	pubKeys := make([]string, 0, len(filter))
+	if len(filter) > 0 {
+		seen := make(map[string]bool, len(filter))
+		for _, pubKey := range filter {
+			pubKey = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(pubKey)), "0x")
+			if _, err := hex.DecodeString(pubKey); err != nil || len(pubKey) == 0 {
+				return nil, errorex.NewErrBadRequest(fmt.Sprintf("invalid public key '%s'", pubKey))
 			}
-
-			responseData[i] = map[string]interface{}{
-				hex.EncodeToString(pubKey): slashingHistory,
+			if !seen[pubKey] {
+				seen[pubKey] = true
+				pubKeys = append(pubKeys, pubKey)
 			}
-		}(i, account.ValidatorPublicKey())
-	}
-	wg.Wait()
-
-	for _, err := range errs {
+		}
+	} else {
+		wallet, err := storage.OpenWallet()
 		if err != nil {
-			return nil, err
+			return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to retrieve wallet")
+		}
+		shares, err := storage.ListKeyShares()
+		if err != nil {
+			return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to list key shares")
+		}
+		groupPubKeys := make(map[string]string, len(shares))
+		for _, share := range shares {
+			groupPubKeys[share.SharePublicKey] = share.GroupPublicKey
 		}
-	}
 
-	historyData := make(map[string]interface{})
-	for _, d := range responseData {
-		for pubKey, history := range d {
-			historyData[pubKey] = history
+		// The slashing data of the key shares is kept on their group public key.
+		for _, account := range wallet.Accounts() {
+			pubKey := hex.EncodeToString(account.ValidatorPublicKey())
+			if groupPubKey, ok := groupPubKeys[pubKey]; ok {
+				pubKey = groupPubKey
+			}
+			pubKeys = append(pubKeys, pubKey)
 		}
 	}
 
-	return &logical.Response{
-		Data: historyData,
-	}, nil
+	sort.Strings(pubKeys)
+	return pubKeys, nil
 }
 
-func loadAccountSlashingHistory(storage *store.HashicorpVaultStore, pubKey []byte) (string, error) {
-	errs := make([]error, 2)
-	var wg sync.WaitGroup
+// loadSlashingHistories loads the slashing history of the given accounts with a bounded number of workers.
+// The histories are returned in the order of the given public keys.
+func loadSlashingHistories(storage *store.HashicorpVaultStore, pubKeys []string) []*SlashingHistory {
+	histories := make([]*SlashingHistory, len(pubKeys))
+	indexes := make(chan int)
 
-	// Fetch attestations
-	var highestAtt *phase0.AttestationData
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		defer func() {
-			if r := recover(); r != nil {
-				errs[0] = errors.New("panic: failed to retrieve highest attestation")
+	var wg sync.WaitGroup
+	for i := 0; i < slashingHistoryWorkers && i < len(pubKeys); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				histories[i] = loadAccountSlashingHistory(storage, pubKeys[i])
 			}
 		}()
+	}
+	for i := range pubKeys {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
 
-		var err error
-		highestAttestation, found, err := storage.RetrieveHighestAttestation(pubKey)
-		if err != nil {
-			errs[0] = errors.Wrap(err, "failed to retrieve highest attestation")
-			return
-		}
-		if !found {
-			errs[0] = errors.New("highest attestation not found")
-			return
-		}
-		if highestAttestation == nil {
-			errs[0] = errors.New("highest attestation is nil")
-		}
-		highestAtt = highestAttestation
-	}()
-
-	// Fetch proposals
-	var highestProposal *HighestProposal
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		defer func() {
-			if r := recover(); r != nil {
-				errs[1] = errors.New("panic: failed to retrieve highest proposal")
-			}
-		}()
+	return histories
+}
 
-		proposal, found, err := storage.RetrieveHighestProposal(pubKey)
-		if err != nil {
-			errs[1] = errors.Wrap(err, "failed to retrieve highest proposal")
-			return
-		}
-		if !found {
-			errs[1] = errors.New("highest proposal not found")
-			return
-		}
-		if proposal == 0 {
-			errs[1] = errors.Wrap(err, "highest proposal is 0")
-		} else {
-			highestProposal = &HighestProposal{
-				Slot: proposal,
-			}
+// loadAccountSlashingHistory loads the slashing history of the given account.
+// The missing or corrupt records are reported in the history errors.
+func loadAccountSlashingHistory(storage *store.HashicorpVaultStore, pubKey string) (history *SlashingHistory) {
+	history = &SlashingHistory{PublicKey: pubKey}
+	defer func() {
+		if r := recover(); r != nil {
+			history.Errors = append(history.Errors, "failed to load slashing history: panic")
 		}
 	}()
 
-	wg.Wait()
+	pubKeyBytes, _ := hex.DecodeString(pubKey)
+	signPubKey, _, err := signingKey(storage, pubKeyBytes)
+	if err == nil {
+		_, err = storage.AccountByPublicKey(signPubKey)
+	}
+	if err != nil {
+		history.Errors = append(history.Errors, errors.Wrap(err, "failed to retrieve account").Error())
+		return history
+	}
 
-	for _, err := range errs {
-		if err != nil {
-			return "", err
-		}
+	highestAttestation, found, err := storage.RetrieveHighestAttestation(pubKeyBytes)
+	switch {
+	case err != nil:
+		history.Errors = append(history.Errors, errors.Wrap(err, "failed to retrieve highest attestation").Error())
+	case !found || highestAttestation == nil:
+		history.Errors = append(history.Errors, "highest attestation not found")
+	default:
+		history.HighestAttestation = highestAttestation
 	}
 
-	slashingHistoryEncoded, err := json.Marshal(SlashingHistory{
-		HighestAttestation: highestAtt,
-		HighestProposal:    highestProposal,
-	})
-	if err != nil {
-		return "", errors.Wrap(err, "failed to marshal slashing history")
+	proposal, found, err := storage.RetrieveHighestProposal(pubKeyBytes)
+	switch {
+	case err != nil:
+		history.Errors = append(history.Errors, errors.Wrap(err, "failed to retrieve highest proposal").Error())
+	case !found || proposal == 0:
+		history.NoProposals = true
+	default:
+		history.HighestProposal = &HighestProposal{
+			Slot: proposal,
+		}
 	}
+	return history
+}
 
-	return hex.EncodeToString(slashingHistoryEncoded), nil
+// slashingHistoryResponse returns the response of the given slashing storage page.
+func slashingHistoryResponse(page *SlashingHistoryPage) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"accounts":    page.Accounts,
+			"incomplete":  page.Incomplete,
+			"total":       page.Total,
+			"next_offset": page.NextOffset,
+		},
+	}
 }
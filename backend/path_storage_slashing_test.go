@@ -3,14 +3,19 @@ package backend
 import (
 	"context"
 	"encoding/hex"
-	"encoding/json"
+	"fmt"
+	"sort"
 	"testing"
 
 	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/stores/inmemory"
+	"github.com/bloxapp/eth2-key-manager/wallets/hd"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/require"
 
 	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/utils/errorex"
 )
 
 func TestSlashingStorage_Read(t *testing.T) {
@@ -41,18 +46,147 @@ func TestSlashingStorage_Read(t *testing.T) {
 
 		res, err := b.HandleRequest(ctx, req)
 		require.NoError(t, err)
-		require.NotEmpty(t, res.Data[publicKey].(string))
+		require.Equal(t, 1, res.Data["total"])
+		require.Equal(t, 0, res.Data["next_offset"])
+		require.Empty(t, res.Data["incomplete"])
 
-		data, err := hex.DecodeString(res.Data[publicKey].(string))
+		histories := res.Data["accounts"].([]*SlashingHistory)
+		require.Len(t, histories, 1)
+		require.Equal(t, publicKey, histories[0].PublicKey)
+		require.Empty(t, histories[0].Errors)
+		decodedAttSSZ, err := histories[0].HighestAttestation.MarshalSSZ()
 		require.NoError(t, err)
-		var slashingHistory SlashingHistory
-		err = json.Unmarshal(data, &slashingHistory)
+		require.EqualValues(t, attestationDataSSZ, decodedAttSSZ)
+
+		require.NotNil(t, histories[0].HighestProposal)
+		require.EqualValues(t, proposal, histories[0].HighestProposal.Slot)
+	})
+}
+
+func TestSlashingStorage_ReadPages(t *testing.T) {
+	b, _ := getBackend(t)
+	ctx := context.Background()
+	req := logical.TestRequest(t, logical.ReadOperation, "storage/slashing")
+	setupBaseStorage(t, req)
+
+	// five accounts, the last two are missing slashing data
+	inMemStore := inmemory.NewInMemStore(core.PraterNetwork)
+	wallet := hd.NewWallet(&core.WalletContext{Storage: inMemStore})
+	require.NoError(t, inMemStore.SaveWallet(wallet))
+	pubKeys := make([]string, 5)
+	for i := range pubKeys {
+		index := i
+		account, err := wallet.CreateValidatorAccount(_byteArray("0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1fff"), &index)
 		require.NoError(t, err)
-		decodedAttSSZ, err := slashingHistory.HighestAttestation.MarshalSSZ()
+		pubKeys[i] = hex.EncodeToString(account.ValidatorPublicKey())
+	}
+	s, err := store.FromInMemoryStore(ctx, inMemStore, req.Storage)
+	require.NoError(t, err)
+	for _, pubKey := range pubKeys[:3] {
+		require.NoError(t, s.SaveHighestAttestation(_byteArray(pubKey), &phase0.AttestationData{
+			Source: &phase0.Checkpoint{Epoch: 1},
+			Target: &phase0.Checkpoint{Epoch: 2},
+		}))
+	}
+	for _, pubKey := range []string{pubKeys[0], pubKeys[1], pubKeys[3]} {
+		require.NoError(t, s.SaveHighestProposal(_byteArray(pubKey), 10))
+	}
+	sorted := append([]string{}, pubKeys...)
+	sort.Strings(sorted)
+
+	t.Run("pages", func(t *testing.T) {
+		var read []string
+		offset := 0
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 3)
+			res, err := readSlashingStorage(t, b, req.Storage, map[string]interface{}{"offset": offset, "limit": 2})
+			require.NoError(t, err)
+			require.Equal(t, 5, res.Data["total"])
+			for _, history := range res.Data["accounts"].([]*SlashingHistory) {
+				read = append(read, history.PublicKey)
+			}
+			for _, history := range res.Data["incomplete"].([]*SlashingHistory) {
+				read = append(read, history.PublicKey)
+			}
+			if offset = res.Data["next_offset"].(int); offset == 0 {
+				break
+			}
+		}
+		sort.Strings(read)
+		require.Equal(t, sorted, read)
+
+		res, err := readSlashingStorage(t, b, req.Storage, map[string]interface{}{"offset": 5})
 		require.NoError(t, err)
-		require.EqualValues(t, attestationDataSSZ, decodedAttSSZ)
+		require.Empty(t, res.Data["accounts"])
+		require.Empty(t, res.Data["incomplete"])
+	})
+
+	t.Run("incomplete accounts", func(t *testing.T) {
+		res, err := readSlashingStorage(t, b, req.Storage, nil)
+		require.NoError(t, err)
+		complete := make(map[string]*SlashingHistory)
+		for _, history := range res.Data["accounts"].([]*SlashingHistory) {
+			complete[history.PublicKey] = history
+		}
+		require.Len(t, complete, 3)
+		// the accounts that never proposed are complete
+		require.True(t, complete[pubKeys[2]].NoProposals)
+		require.Nil(t, complete[pubKeys[2]].HighestProposal)
+		require.False(t, complete[pubKeys[1]].NoProposals)
 
-		require.NotNil(t, slashingHistory.HighestProposal)
-		require.EqualValues(t, proposal, slashingHistory.HighestProposal.Slot)
+		incomplete := make(map[string]*SlashingHistory)
+		for _, history := range res.Data["incomplete"].([]*SlashingHistory) {
+			incomplete[history.PublicKey] = history
+		}
+		require.Len(t, incomplete, 2)
+		require.Equal(t, []string{"highest attestation not found"}, incomplete[pubKeys[3]].Errors)
+		require.NotNil(t, incomplete[pubKeys[3]].HighestProposal)
+		require.Equal(t, []string{"highest attestation not found"}, incomplete[pubKeys[4]].Errors)
+		require.True(t, incomplete[pubKeys[4]].NoProposals)
 	})
+
+	t.Run("corrupt data", func(t *testing.T) {
+		path := fmt.Sprintf(store.WalletHighestProposalsBase, pubKeys[0])
+		require.NoError(t, req.Storage.Put(ctx, &logical.StorageEntry{Key: path, Value: []byte{1}}))
+
+		res, err := readSlashingStorage(t, b, req.Storage, map[string]interface{}{"public_keys": pubKeys[0]})
+		require.NoError(t, err)
+		require.Empty(t, res.Data["accounts"])
+		histories := res.Data["incomplete"].([]*SlashingHistory)
+		require.Len(t, histories, 1)
+		require.NotEmpty(t, histories[0].Errors)
+	})
+
+	t.Run("public keys filter", func(t *testing.T) {
+		unknown := hex.EncodeToString(make([]byte, 48))
+		res, err := readSlashingStorage(t, b, req.Storage, map[string]interface{}{
+			"public_keys": []string{pubKeys[1], "0x" + pubKeys[2], pubKeys[1], unknown},
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, res.Data["total"])
+		require.Len(t, res.Data["accounts"], 2)
+		histories := res.Data["incomplete"].([]*SlashingHistory)
+		require.Len(t, histories, 1)
+		require.Equal(t, unknown, histories[0].PublicKey)
+		require.Contains(t, histories[0].Errors[0], "failed to retrieve account")
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		res, err := readSlashingStorage(t, b, req.Storage, map[string]interface{}{"public_keys": "zz"})
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "invalid public key 'zz'")
+
+		res, err = readSlashingStorage(t, b, req.Storage, map[string]interface{}{"limit": MaxSlashingHistoryLimit + 1})
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "limit must be between 1 and 1000")
+
+		res, err = readSlashingStorage(t, b, req.Storage, map[string]interface{}{"offset": -1})
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "offset must not be negative")
+	})
+}
+
+// readSlashingStorage reads the slashing storage with the given parameters.
+func readSlashingStorage(t *testing.T, b logical.Backend, storage logical.Storage, data map[string]interface{}) (*logical.Response, error) {
+	req := logical.TestRequest(t, logical.ReadOperation, "storage/slashing")
+	req.Storage = storage
+	req.Data = data
+	return b.HandleRequest(context.Background(), req)
 }
@@ -15,6 +15,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/utils/errorex"
 )
 
 func _byteArray(input string) []byte {
@@ -132,3 +133,57 @@ func TestStorage(t *testing.T) {
 		require.EqualValues(t, att.Target.Epoch, 0)
 	})
 }
+
+func TestStorageImportReport(t *testing.T) {
+	b, _ := getBackend(t)
+	inMemStore, _, err := baseInmemStorage()
+	require.NoError(t, err)
+	byts, err := json.Marshal(inMemStore)
+	require.NoError(t, err)
+	data := hex.EncodeToString(byts)
+	pubKey := "95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf"
+
+	t.Run("dry run", func(t *testing.T) {
+		req := logical.TestRequest(t, logical.CreateOperation, "storage")
+		req.Data = map[string]interface{}{"data": data, "dry_run": true}
+		setupBaseStorage(t, req)
+
+		res, err := b.HandleRequest(context.Background(), req)
+		require.NoError(t, err)
+		require.True(t, res.Data["status"].(bool))
+		require.True(t, res.Data["dry_run"].(bool))
+		accounts := res.Data["accounts"].([]*store.ImportAccountReport)
+		require.Len(t, accounts, 1)
+		require.Equal(t, pubKey, accounts[0].PublicKey)
+		require.Equal(t, store.ImportStatusAdded, accounts[0].Status)
+
+		_, err = store.NewHashicorpVaultStore(context.Background(), req.Storage, core.PraterNetwork).OpenWallet()
+		require.Error(t, err)
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		req := logical.TestRequest(t, logical.CreateOperation, "storage")
+		req.Data = map[string]interface{}{"data": data}
+		setupBaseStorage(t, req)
+
+		for _, status := range []string{store.ImportStatusAdded, store.ImportStatusSkippedDuplicate} {
+			res, err := b.HandleRequest(context.Background(), req)
+			require.NoError(t, err)
+			require.False(t, res.Data["dry_run"].(bool))
+			require.Equal(t, 1, res.Data["summary"].(map[string]int)[status])
+		}
+	})
+
+	t.Run("network mismatch", func(t *testing.T) {
+		req := logical.TestRequest(t, logical.CreateOperation, "storage")
+		req.Data = map[string]interface{}{"data": data}
+		setupBaseStorage(t, req, func(config *Config) {
+			config.Network = core.MainNetwork
+		})
+		// the config is cached per mount
+		b.InvalidateKey(context.Background(), ConfigPattern)
+
+		res, err := b.HandleRequest(context.Background(), req)
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "failed to update storage from in memory: failed to import prater accounts into mainnet storage: network mismatch")
+	})
+}
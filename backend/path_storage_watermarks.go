@@ -0,0 +1,299 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/pkg/errors"
+
+	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+// Endpoints patterns
+const (
+	// WatermarksStoragePattern is the path pattern for slashing watermarks override endpoint
+	WatermarksStoragePattern = "storage/slashing/watermarks"
+)
+
+// watermarksAuditKind is the kind of the watermark override audit records.
+const watermarksAuditKind = "watermarks"
+
+// Watermarks contains the slashing protection watermarks of an account.
+// Zero values stand for missing records.
+type Watermarks struct {
+	SourceEpoch  phase0.Epoch `json:"source_epoch"`
+	TargetEpoch  phase0.Epoch `json:"target_epoch"`
+	ProposalSlot phase0.Slot  `json:"proposal_slot"`
+}
+
+// WatermarksOverride contains the requested watermarks of an account, nil values are left as they are.
+type WatermarksOverride struct {
+	SourceEpoch  *phase0.Epoch `json:"source_epoch,omitempty"`
+	TargetEpoch  *phase0.Epoch `json:"target_epoch,omitempty"`
+	ProposalSlot *phase0.Slot  `json:"proposal_slot,omitempty"`
+}
+
+// WatermarksAuditRecord is the audit record of a watermarks override.
+type WatermarksAuditRecord struct {
+	Time      time.Time  `json:"time"`
+	PublicKey string     `json:"public_key"`
+	EntityID  string     `json:"entity_id,omitempty"`
+	Previous  Watermarks `json:"previous"`
+	Current   Watermarks `json:"current"`
+	Forced    bool       `json:"forced"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+func storageWatermarksPaths(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern:         WatermarksStoragePattern,
+			HelpSynopsis:    "Override slashing watermarks",
+			HelpDescription: `Raise the highest attestation and the highest proposal of accounts, lowering them requires force and reason`,
+			Fields: map[string]*framework.FieldSchema{
+				"watermarks": {
+					Type:        framework.TypeMap,
+					Description: "Hex encoded public keys and their source_epoch, target_epoch and proposal_slot watermarks",
+				},
+				"force": {
+					Type:        framework.TypeBool,
+					Description: "Allow lowering the watermarks",
+					Default:     false,
+				},
+				"reason": {
+					Type:        framework.TypeString,
+					Description: "Reason of the override, required with force",
+					Default:     "",
+				},
+			},
+			ExistenceCheck: b.pathExistenceCheck,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathWatermarksOverride),
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: withCodedErrors(b.pathWatermarksAuditRead),
+				},
+			},
+		},
+	}
+}
+
+func (b *backend) pathWatermarksOverride(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// Load config
+	config, err := b.readConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get config")
+	}
+
+	overrides, err := parseWatermarksOverrides(data.Get("watermarks").(map[string]interface{}))
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeBadRequest, err, "invalid watermarks")
+	}
+	force := data.Get("force").(bool)
+	reason := strings.TrimSpace(data.Get("reason").(string))
+	if force && len(reason) == 0 {
+		return nil, errorex.NewErrBadRequest("a reason is required to force watermarks")
+	}
+
+	storage := b.newStore(ctx, req.Storage, config.Network)
+	pubKeys := make([][]byte, 0, len(overrides))
+	for pubKey := range overrides {
+		pubKeyBytes, _ := hex.DecodeString(pubKey)
+		pubKeys = append(pubKeys, pubKeyBytes)
+	}
+	sort.Slice(pubKeys, func(i, j int) bool {
+		return bytes.Compare(pubKeys[i], pubKeys[j]) < 0
+	})
+
+	records := make([]*WatermarksAuditRecord, 0, len(pubKeys))
+	err = b.lockAll(pubKeys, func() error {
+		for _, pubKey := range pubKeys {
+			if _, err := storage.AccountByPublicKey(pubKey); err != nil {
+				return wrapCodedSignError(err, fmt.Sprintf("failed to override watermarks of '%s'", hex.EncodeToString(pubKey)))
+			}
+		}
+
+		// Validate every override before changing anything.
+		now := b.now().UTC()
+		for _, pubKey := range pubKeys {
+			record, err := planWatermarksOverride(storage, pubKey, overrides[hex.EncodeToString(pubKey)], force)
+			if err != nil {
+				return err
+			}
+			if record == nil {
+				continue
+			}
+
+			record.Time = now
+			record.EntityID = req.EntityID
+			if force {
+				record.Forced = true
+				record.Reason = reason
+			}
+			records = append(records, record)
+		}
+
+		for _, record := range records {
+			if err := applyWatermarksOverride(storage, record); err != nil {
+				return errorex.Wrap(errorex.CodeStorageFailure, err, fmt.Sprintf("failed to override watermarks of '%s'", record.PublicKey))
+			}
+			if err := putAuditRecord(ctx, req.Storage, watermarksAuditKind, record.Time, record); err != nil {
+				return errorex.Wrap(errorex.CodeStorageFailure, err, "failed to store audit record")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"changes": records,
+		},
+	}, nil
+}
+
+func (b *backend) pathWatermarksAuditRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	entries, err := listAuditRecords(ctx, req.Storage, watermarksAuditKind)
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to read audit records")
+	}
+
+	records := make([]*WatermarksAuditRecord, len(entries))
+	for i, entry := range entries {
+		records[i] = &WatermarksAuditRecord{}
+		if err := entry.DecodeJSON(records[i]); err != nil {
+			return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to decode audit record")
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"records": records,
+		},
+	}, nil
+}
+
+// parseWatermarksOverrides parses the requested overrides keyed by hex encoded public key.
+func parseWatermarksOverrides(input map[string]interface{}) (map[string]*WatermarksOverride, error) {
+	if len(input) == 0 {
+		return nil, errors.New("no watermarks provided")
+	}
+
+	overrides := make(map[string]*WatermarksOverride, len(input))
+	for key, value := range input {
+		pubKey, err := hex.DecodeString(strings.TrimPrefix(key, "0x"))
+		if err != nil || len(pubKey) != BLSPubkeyLength {
+			return nil, errors.Errorf("invalid public key '%s'", key)
+		}
+
+		byts, err := json.Marshal(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to encode watermarks of '%s'", key)
+		}
+		override := &WatermarksOverride{}
+		decoder := json.NewDecoder(bytes.NewReader(byts))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(override); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode watermarks of '%s'", key)
+		}
+		if override.ProposalSlot != nil && *override.ProposalSlot == 0 {
+			return nil, errors.Errorf("invalid proposal slot of '%s', slot could not be 0", key)
+		}
+
+		overrides[hex.EncodeToString(pubKey)] = override
+	}
+	return overrides, nil
+}
+
+// planWatermarksOverride returns the audit record of the given override, nil if nothing changes.
+// Lowering any watermark is refused unless forced.
+func planWatermarksOverride(storage *store.HashicorpVaultStore, pubKey []byte, override *WatermarksOverride, force bool) (*WatermarksAuditRecord, error) {
+	var previous Watermarks
+	highestAtt, found, err := storage.RetrieveHighestAttestation(pubKey)
+	if err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to retrieve highest attestation")
+	}
+	if found && highestAtt != nil {
+		previous.SourceEpoch = highestAtt.Source.Epoch
+		previous.TargetEpoch = highestAtt.Target.Epoch
+	}
+	if previous.ProposalSlot, _, err = storage.RetrieveHighestProposal(pubKey); err != nil {
+		return nil, errorex.Wrap(errorex.CodeStorageFailure, err, "failed to retrieve highest proposal")
+	}
+
+	current := previous
+	if override.SourceEpoch != nil {
+		current.SourceEpoch = *override.SourceEpoch
+	}
+	if override.TargetEpoch != nil {
+		current.TargetEpoch = *override.TargetEpoch
+	}
+	if override.ProposalSlot != nil {
+		current.ProposalSlot = *override.ProposalSlot
+	}
+	if current == previous {
+		return nil, nil
+	}
+
+	if current.SourceEpoch > current.TargetEpoch {
+		return nil, errorex.NewErrBadRequest(fmt.Sprintf("source epoch of '%x' is higher than its target epoch", pubKey))
+	}
+	lowered := current.SourceEpoch < previous.SourceEpoch ||
+		current.TargetEpoch < previous.TargetEpoch ||
+		current.ProposalSlot < previous.ProposalSlot
+	if lowered && !force {
+		return nil, errorex.NewCodedError(errorex.CodePolicyViolation, fmt.Sprintf("refusing to lower the watermarks of '%x' without force", pubKey))
+	}
+
+	return &WatermarksAuditRecord{
+		PublicKey: hex.EncodeToString(pubKey),
+		Previous:  previous,
+		Current:   current,
+	}, nil
+}
+
+// applyWatermarksOverride stores the watermarks of the given audit record.
+func applyWatermarksOverride(storage *store.HashicorpVaultStore, record *WatermarksAuditRecord) error {
+	pubKey, err := hex.DecodeString(record.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	if record.Current.SourceEpoch != record.Previous.SourceEpoch || record.Current.TargetEpoch != record.Previous.TargetEpoch {
+		highestAtt, _, err := storage.RetrieveHighestAttestation(pubKey)
+		if err != nil {
+			return errors.Wrap(err, "failed to retrieve highest attestation")
+		}
+		if highestAtt == nil {
+			highestAtt = &phase0.AttestationData{
+				Source: &phase0.Checkpoint{},
+				Target: &phase0.Checkpoint{},
+			}
+		}
+		highestAtt.Source.Epoch = record.Current.SourceEpoch
+		highestAtt.Target.Epoch = record.Current.TargetEpoch
+		if err := storage.SaveHighestAttestation(pubKey, highestAtt); err != nil {
+			return errors.Wrap(err, "failed to save highest attestation")
+		}
+	}
+
+	if record.Current.ProposalSlot != record.Previous.ProposalSlot {
+		if err := storage.SaveHighestProposal(pubKey, record.Current.ProposalSlot); err != nil {
+			return errors.Wrap(err, "failed to save highest proposal")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/backend/store"
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+func TestWatermarksOverride(t *testing.T) {
+	b, _ := getBackend(t)
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	b.(*backend).now = func() time.Time {
+		return now
+	}
+	defer func() {
+		b.(*backend).now = time.Now
+	}()
+	pubKey := "95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf"
+
+	setup := func(t *testing.T) logical.Storage {
+		req := logical.TestRequest(t, logical.CreateOperation, "storage/slashing/watermarks")
+		setupBaseStorage(t, req)
+		require.NoError(t, setupStorageWithWalletAndAccounts(req.Storage))
+		return req.Storage
+	}
+	override := func(t *testing.T, storage logical.Storage, data map[string]interface{}) (*logical.Response, error) {
+		// the audit records are keyed by time
+		now = now.Add(time.Second)
+		req := logical.TestRequest(t, logical.CreateOperation, "storage/slashing/watermarks")
+		req.Storage = storage
+		req.EntityID = "admin-entity"
+		req.Data = data
+		return b.HandleRequest(context.Background(), req)
+	}
+	auditRecords := func(t *testing.T, storage logical.Storage) []*WatermarksAuditRecord {
+		req := logical.TestRequest(t, logical.ReadOperation, "storage/slashing/watermarks")
+		req.Storage = storage
+		res, err := b.HandleRequest(context.Background(), req)
+		require.NoError(t, err)
+		return res.Data["records"].([]*WatermarksAuditRecord)
+	}
+	requireWatermarks := func(t *testing.T, storage logical.Storage, expected Watermarks) {
+		s := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork)
+		att, found, err := s.RetrieveHighestAttestation(_byteArray(pubKey))
+		require.NoError(t, err)
+		require.True(t, found)
+		proposal, found, err := s.RetrieveHighestProposal(_byteArray(pubKey))
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, expected, Watermarks{
+			SourceEpoch:  att.Source.Epoch,
+			TargetEpoch:  att.Target.Epoch,
+			ProposalSlot: proposal,
+		})
+	}
+
+	t.Run("raise", func(t *testing.T) {
+		storage := setup(t)
+
+		res, err := override(t, storage, map[string]interface{}{
+			"watermarks": map[string]interface{}{
+				"0x" + pubKey: map[string]interface{}{"source_epoch": 10, "target_epoch": 11, "proposal_slot": 400},
+			},
+		})
+		require.NoError(t, err)
+		changes := res.Data["changes"].([]*WatermarksAuditRecord)
+		require.Len(t, changes, 1)
+		require.Equal(t, pubKey, changes[0].PublicKey)
+		require.Equal(t, Watermarks{ProposalSlot: 1}, changes[0].Previous)
+		require.Equal(t, Watermarks{SourceEpoch: 10, TargetEpoch: 11, ProposalSlot: 400}, changes[0].Current)
+		require.Equal(t, "admin-entity", changes[0].EntityID)
+		require.Equal(t, now, changes[0].Time)
+		require.False(t, changes[0].Forced)
+
+		requireWatermarks(t, storage, Watermarks{SourceEpoch: 10, TargetEpoch: 11, ProposalSlot: 400})
+		require.Equal(t, changes, auditRecords(t, storage))
+
+		// the raised watermarks are enforced when signing
+		req := logical.TestRequest(t, logical.CreateOperation, "accounts/sign")
+		req.Storage = storage
+		req.Data = reqObject(&phase0.AttestationData{
+			Source: &phase0.Checkpoint{Epoch: 10},
+			Target: &phase0.Checkpoint{Epoch: 11},
+		}, _byteArray32("01000000f071c66c6561d0b939feb15f513a019d99a84bd85635221e3ad42dac"), _byteArray(pubKey))
+		res, err = b.HandleRequest(context.Background(), req)
+		requireCodedError(t, res, err, errorex.CodeSlashable, "failed to sign: slashable attestation (HighestAttestationVote), not signing")
+	})
+
+	t.Run("partial raise keeps the other watermarks", func(t *testing.T) {
+		storage := setup(t)
+
+		_, err := override(t, storage, map[string]interface{}{
+			"watermarks": map[string]interface{}{
+				pubKey: map[string]interface{}{"target_epoch": 5},
+			},
+		})
+		require.NoError(t, err)
+		requireWatermarks(t, storage, Watermarks{TargetEpoch: 5, ProposalSlot: 1})
+	})
+
+	t.Run("unchanged watermarks are not audited", func(t *testing.T) {
+		storage := setup(t)
+
+		res, err := override(t, storage, map[string]interface{}{
+			"watermarks": map[string]interface{}{
+				pubKey: map[string]interface{}{"source_epoch": 0, "proposal_slot": 1},
+			},
+		})
+		require.NoError(t, err)
+		require.Empty(t, res.Data["changes"])
+		require.Empty(t, auditRecords(t, storage))
+	})
+
+	t.Run("lower without force", func(t *testing.T) {
+		storage := setup(t)
+		_, err := override(t, storage, map[string]interface{}{
+			"watermarks": map[string]interface{}{
+				pubKey: map[string]interface{}{"proposal_slot": 400},
+			},
+		})
+		require.NoError(t, err)
+
+		res, err := override(t, storage, map[string]interface{}{
+			"watermarks": map[string]interface{}{
+				pubKey: map[string]interface{}{"proposal_slot": 300},
+			},
+		})
+		requireCodedError(t, res, err, errorex.CodePolicyViolation, "refusing to lower the watermarks of '"+pubKey+"' without force")
+		requireWatermarks(t, storage, Watermarks{ProposalSlot: 400})
+		require.Len(t, auditRecords(t, storage), 1)
+	})
+
+	t.Run("lower with force", func(t *testing.T) {
+		storage := setup(t)
+		_, err := override(t, storage, map[string]interface{}{
+			"watermarks": map[string]interface{}{
+				pubKey: map[string]interface{}{"proposal_slot": 400},
+			},
+		})
+		require.NoError(t, err)
+
+		res, err := override(t, storage, map[string]interface{}{
+			"watermarks": map[string]interface{}{
+				pubKey: map[string]interface{}{"proposal_slot": 300},
+			},
+			"force": true,
+		})
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "a reason is required to force watermarks")
+
+		res, err = override(t, storage, map[string]interface{}{
+			"watermarks": map[string]interface{}{
+				pubKey: map[string]interface{}{"proposal_slot": 300},
+			},
+			"force":  true,
+			"reason": "restored from backup",
+		})
+		require.NoError(t, err)
+		requireWatermarks(t, storage, Watermarks{ProposalSlot: 300})
+
+		records := auditRecords(t, storage)
+		require.Len(t, records, 2)
+		require.Equal(t, Watermarks{ProposalSlot: 400}, records[1].Previous)
+		require.Equal(t, Watermarks{ProposalSlot: 300}, records[1].Current)
+		require.True(t, records[1].Forced)
+		require.Equal(t, "restored from backup", records[1].Reason)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		storage := setup(t)
+
+		tests := []struct {
+			name       string
+			watermarks map[string]interface{}
+			code       errorex.ErrorCode
+			message    string
+		}{
+			{
+				name:       "no watermarks",
+				watermarks: map[string]interface{}{},
+				code:       errorex.CodeBadRequest,
+				message:    "invalid watermarks: no watermarks provided",
+			},
+			{
+				name:       "invalid public key",
+				watermarks: map[string]interface{}{"0x1234": map[string]interface{}{"proposal_slot": 2}},
+				code:       errorex.CodeBadRequest,
+				message:    "invalid watermarks: invalid public key '0x1234'",
+			},
+			{
+				name:       "unknown field",
+				watermarks: map[string]interface{}{pubKey: map[string]interface{}{"slot": 2}},
+				code:       errorex.CodeBadRequest,
+				message:    "invalid watermarks: failed to decode watermarks of '" + pubKey + "': json: unknown field \"slot\"",
+			},
+			{
+				name:       "zero proposal slot",
+				watermarks: map[string]interface{}{pubKey: map[string]interface{}{"proposal_slot": 0}},
+				code:       errorex.CodeBadRequest,
+				message:    "invalid watermarks: invalid proposal slot of '" + pubKey + "', slot could not be 0",
+			},
+			{
+				name:       "source higher than target",
+				watermarks: map[string]interface{}{pubKey: map[string]interface{}{"source_epoch": 3, "target_epoch": 2}},
+				code:       errorex.CodeBadRequest,
+				message:    "source epoch of '" + pubKey + "' is higher than its target epoch",
+			},
+			{
+				name: "unknown account",
+				watermarks: map[string]interface{}{
+					"95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcd": map[string]interface{}{"proposal_slot": 2},
+				},
+				code:    errorex.CodeUnknownAccount,
+				message: "failed to override watermarks of '95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcd': account not found",
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				res, err := override(t, storage, map[string]interface{}{"watermarks": tt.watermarks})
+				requireCodedError(t, res, err, tt.code, tt.message)
+			})
+		}
+		requireWatermarks(t, storage, Watermarks{ProposalSlot: 1})
+		require.Empty(t, auditRecords(t, storage))
+	})
+}
@@ -0,0 +1,266 @@
+package backend
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/pkg/errors"
+
+	"github.com/bloxapp/key-vault/keymanager/models"
+)
+
+// ErrRateLimited is returned when a sign request exceeds the rate limits of the mount.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// Periods of the rate limits.
+const (
+	RateLimitPeriodSlot  = "slot"
+	RateLimitPeriodEpoch = "epoch"
+)
+
+// Object types of the sign requests, the keys of the public key rate limits.
+// Blinded blocks are limited as blocks.
+const (
+	SignObjectAttestation          = "attestation"
+	SignObjectBlock                = "block"
+	SignObjectAggregateAndProof    = "aggregate_and_proof"
+	SignObjectSelectionProof       = "selection_proof"
+	SignObjectRandao               = "randao"
+	SignObjectSyncCommitteeMessage = "sync_committee_message"
+	SignObjectSyncSelectionProof   = "sync_selection_proof"
+	SignObjectSyncContribution     = "sync_contribution"
+	SignObjectRegistration         = "registration"
+)
+
+// signObjectTypes is the set of the known object types.
+var signObjectTypes = map[string]bool{
+	SignObjectAttestation:          true,
+	SignObjectBlock:                true,
+	SignObjectAggregateAndProof:    true,
+	SignObjectSelectionProof:       true,
+	SignObjectRandao:               true,
+	SignObjectSyncCommitteeMessage: true,
+	SignObjectSyncSelectionProof:   true,
+	SignObjectSyncContribution:     true,
+	SignObjectRegistration:         true,
+}
+
+// RateLimit allows at most Limit sign requests per slot or epoch of the wall-clock.
+type RateLimit struct {
+	Limit  uint64 `json:"limit"`
+	Period string `json:"period"`
+}
+
+// RateLimits are the sign request rate limits of the mount.
+// PublicKey limits the requests of each account by object type,
+// Entity limits the requests of each Vault entity, or of each token without entity.
+type RateLimits struct {
+	PublicKey map[string]*RateLimit `json:"public_key,omitempty"`
+	Entity    *RateLimit            `json:"entity,omitempty"`
+}
+
+// ParseRateLimits parses & validates the rate limits from a given map[string]interface{}
+func ParseRateLimits(input map[string]interface{}) (RateLimits, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return RateLimits{}, errors.Wrap(err, "invalid rate_limits provided")
+	}
+
+	var limits RateLimits
+	if err := json.Unmarshal(data, &limits); err != nil {
+		return RateLimits{}, errors.Wrap(err, "invalid rate_limits provided")
+	}
+
+	for objectType, limit := range limits.PublicKey {
+		if !signObjectTypes[objectType] {
+			return RateLimits{}, errors.Errorf("invalid rate_limits provided: unknown object type '%s'", objectType)
+		}
+		if err := limit.validate(); err != nil {
+			return RateLimits{}, errors.Wrapf(err, "invalid rate_limits provided for '%s'", objectType)
+		}
+	}
+	if limits.Entity != nil {
+		if err := limits.Entity.validate(); err != nil {
+			return RateLimits{}, errors.Wrap(err, "invalid rate_limits provided for entities")
+		}
+	}
+	return limits, nil
+}
+
+// validate checks the limit is positive and the period is known.
+func (l *RateLimit) validate() error {
+	if l == nil || l.Limit == 0 {
+		return errors.New("limit must be positive")
+	}
+	if l.Period != RateLimitPeriodSlot && l.Period != RateLimitPeriodEpoch {
+		return errors.Errorf("unknown period '%s'", l.Period)
+	}
+	return nil
+}
+
+// window returns the index of the wall-clock window of the limit holding the given slot.
+func (l *RateLimit) window(network core.Network, slot uint64) uint64 {
+	if l.Period == RateLimitPeriodEpoch {
+		return slot / network.SlotsPerEpoch()
+	}
+	return slot
+}
+
+// windowEnd returns the first slot after the wall-clock window of the limit holding the given slot.
+func (l *RateLimit) windowEnd(network core.Network, slot uint64) uint64 {
+	if l.Period == RateLimitPeriodEpoch {
+		return (l.window(network, slot) + 1) * network.SlotsPerEpoch()
+	}
+	return slot + 1
+}
+
+// signObjectType returns the object type of the given sign request, empty if unknown.
+func signObjectType(signReq *models.SignRequest) string {
+	switch signReq.GetObject().(type) {
+	case *models.SignRequestAttestationData:
+		return SignObjectAttestation
+	case *models.SignRequestBlock, *models.SignRequestBlindedBlock:
+		return SignObjectBlock
+	case *models.SignRequestAggregateAttestationAndProof:
+		return SignObjectAggregateAndProof
+	case *models.SignRequestSlot:
+		return SignObjectSelectionProof
+	case *models.SignRequestEpoch:
+		return SignObjectRandao
+	case *models.SignRequestSyncCommitteeMessage:
+		return SignObjectSyncCommitteeMessage
+	case *models.SignRequestSyncAggregatorSelectionData:
+		return SignObjectSyncSelectionProof
+	case *models.SignRequestContributionAndProof:
+		return SignObjectSyncContribution
+	case *models.SignRequestRegistration:
+		return SignObjectRegistration
+	default:
+		return ""
+	}
+}
+
+// maxRateWindows bounds the counted windows before the ones that are over are pruned.
+const maxRateWindows = 4096
+
+// rateLimiter counts the sign requests by key in the current window of their limit.
+// The counters are kept in memory, each node of a Vault cluster limits the requests it answers.
+type rateLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+}
+
+// rateWindow is the number of requests counted in the window of the given index, ending at the given slot.
+type rateWindow struct {
+	index uint64
+	end   uint64
+	count uint64
+}
+
+// newRateLimiter returns an empty rate limiter.
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		windows: make(map[string]*rateWindow),
+	}
+}
+
+// rateCounter is the counter of a key in the window of the given index, limited to the given number of requests.
+// end is the first slot after the window, err is returned when the limit is reached.
+type rateCounter struct {
+	key   string
+	index uint64
+	end   uint64
+	limit uint64
+	err   error
+}
+
+// allow counts a request made at the given slot in each of the given counters.
+// It returns the error of the first counter whose limit is reached, without counting the request in any of them.
+func (l *rateLimiter) allow(slot uint64, counters ...rateCounter) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	windows := make([]*rateWindow, len(counters))
+	for i, c := range counters {
+		w, ok := l.windows[c.key]
+		if !ok || w.index != c.index {
+			if !ok && len(l.windows) >= maxRateWindows {
+				l.prune(slot)
+			}
+			w = &rateWindow{index: c.index, end: c.end}
+			l.windows[c.key] = w
+		}
+		if w.count >= c.limit {
+			return c.err
+		}
+		windows[i] = w
+	}
+	for _, w := range windows {
+		w.count++
+	}
+	return nil
+}
+
+// prune drops the windows that are over at the given slot.
+func (l *rateLimiter) prune(slot uint64) {
+	for key, w := range l.windows {
+		if w.end <= slot {
+			delete(l.windows, key)
+		}
+	}
+}
+
+// reset drops all the counters.
+func (l *rateLimiter) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.windows = make(map[string]*rateWindow)
+}
+
+// checkRateLimits counts the given sign request against the limits of its account and object type,
+// and against the limits of the requesting entity, falling back to the token accessor.
+// It doesn't take the sign locks so that a flood of requests is refused without waiting for them.
+func (b *backend) checkRateLimits(config *Config, entityID, tokenAccessor string, signReq *models.SignRequest) error {
+	limits := config.RateLimits
+	if len(limits.PublicKey) == 0 && limits.Entity == nil {
+		return nil
+	}
+
+	slot := uint64(config.Network.EstimatedSlotAtTime(b.now().Unix()))
+	var counters []rateCounter
+
+	objectType := signObjectType(signReq)
+	if limit, ok := limits.PublicKey[objectType]; ok {
+		key := "public_key/" + hex.EncodeToString(signReq.GetPublicKey()) + "/" + objectType + "/" + limit.Period
+		counters = append(counters, rateCounter{
+			key:   key,
+			index: limit.window(config.Network, slot),
+			end:   limit.windowEnd(config.Network, slot),
+			limit: limit.Limit,
+			err:   errors.Wrapf(ErrRateLimited, "more than %d %s requests per %s for the account", limit.Limit, objectType, limit.Period),
+		})
+	}
+
+	if limit := limits.Entity; limit != nil {
+		var key string
+		switch {
+		case len(entityID) > 0:
+			key = "entity/" + entityID + "/" + limit.Period
+		case len(tokenAccessor) > 0:
+			key = "token/" + tokenAccessor + "/" + limit.Period
+		}
+		if len(key) > 0 {
+			counters = append(counters, rateCounter{
+				key:   key,
+				index: limit.window(config.Network, slot),
+				end:   limit.windowEnd(config.Network, slot),
+				limit: limit.Limit,
+				err:   errors.Wrapf(ErrRateLimited, "more than %d sign requests per %s for the entity", limit.Limit, limit.Period),
+			})
+		}
+	}
+
+	return b.rateLimiter.allow(slot, counters...)
+}
@@ -0,0 +1,174 @@
+package backend
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/keymanager/models"
+	"github.com/bloxapp/key-vault/utils/encoder"
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		input map[string]interface{}
+		err   string
+	}{
+		{
+			name: "valid",
+			input: map[string]interface{}{
+				"public_key": map[string]interface{}{
+					"attestation":     map[string]interface{}{"limit": 2, "period": "epoch"},
+					"selection_proof": map[string]interface{}{"limit": 1, "period": "slot"},
+				},
+				"entity": map[string]interface{}{"limit": 1000, "period": "slot"},
+			},
+		},
+		{
+			name: "unknown object type",
+			input: map[string]interface{}{
+				"public_key": map[string]interface{}{"exit": map[string]interface{}{"limit": 1, "period": "epoch"}},
+			},
+			err: "invalid rate_limits provided: unknown object type 'exit'",
+		},
+		{
+			name: "zero limit",
+			input: map[string]interface{}{
+				"public_key": map[string]interface{}{"attestation": map[string]interface{}{"period": "epoch"}},
+			},
+			err: "invalid rate_limits provided for 'attestation': limit must be positive",
+		},
+		{
+			name:  "unknown period",
+			input: map[string]interface{}{"entity": map[string]interface{}{"limit": 1, "period": "hour"}},
+			err:   "invalid rate_limits provided for entities: unknown period 'hour'",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limits, err := ParseRateLimits(test.input)
+			if len(test.err) > 0 {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, &RateLimit{Limit: 2, Period: RateLimitPeriodEpoch}, limits.PublicKey[SignObjectAttestation])
+			require.Equal(t, &RateLimit{Limit: 1000, Period: RateLimitPeriodSlot}, limits.Entity)
+		})
+	}
+}
+
+func TestSignRateLimits(t *testing.T) {
+	b, _ := getBackend(t)
+	now := slotTime(3200)
+	b.(*backend).now = func() time.Time {
+		return now
+	}
+	defer func() {
+		b.(*backend).now = time.Now
+	}()
+
+	req := logical.TestRequest(t, logical.CreateOperation, "accounts/sign")
+	setupBaseStorage(t, req, func(config *Config) {
+		config.RateLimits = RateLimits{
+			PublicKey: map[string]*RateLimit{
+				SignObjectSelectionProof: {Limit: 2, Period: RateLimitPeriodSlot},
+			},
+			Entity: &RateLimit{Limit: 3, Period: RateLimitPeriodEpoch},
+		}
+	})
+	// the config is cached per mount
+	b.InvalidateKey(context.Background(), ConfigPattern)
+	require.NoError(t, setupStorageWithWalletAndAccounts(req.Storage))
+
+	storage := req.Storage
+	request := func(t *testing.T, entityID string, path string) (*logical.Response, error) {
+		byts, err := encoder.New().Encode(&models.SignRequest{
+			PublicKey:       _byteArray("95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf"),
+			SignatureDomain: _byteArray32("05000000f071c66c6561d0b939feb15f513a019d99a84bd85635221e3ad42dac"),
+			Object:          &models.SignRequestSlot{Slot: phase0.Slot(3200)},
+		})
+		require.NoError(t, err)
+		req := logical.TestRequest(t, logical.CreateOperation, path)
+		req.Storage = storage
+		req.EntityID = entityID
+		req.Data = map[string]interface{}{"sign_req": hex.EncodeToString(byts)}
+		return b.HandleRequest(context.Background(), req)
+	}
+
+	t.Run("per public key", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			res, err := request(t, "", "accounts/sign")
+			require.NoError(t, err)
+			require.NotEmpty(t, res.Data["signature"])
+		}
+		res, err := request(t, "", "accounts/sign")
+		requireCodedError(t, res, err, errorex.CodeRateLimited, "failed to sign: more than 2 selection_proof requests per slot for the account: rate limit exceeded")
+
+		// the sign check doesn't count the requests
+		res, err = request(t, "", "accounts/sign/check")
+		require.NoError(t, err)
+		require.Equal(t, SignVerdictSign, res.Data["verdict"])
+
+		// the counters are reset on the next slot
+		now = slotTime(3201)
+		res, err = request(t, "", "accounts/sign")
+		require.NoError(t, err)
+		require.NotEmpty(t, res.Data["signature"])
+	})
+
+	t.Run("per entity", func(t *testing.T) {
+		now = slotTime(3202)
+		for i := 0; i < 2; i++ {
+			_, err := request(t, "entity-1", "accounts/sign")
+			require.NoError(t, err)
+		}
+		now = slotTime(3203)
+		_, err := request(t, "entity-1", "accounts/sign")
+		require.NoError(t, err)
+		res, err := request(t, "entity-1", "accounts/sign")
+		requireCodedError(t, res, err, errorex.CodeRateLimited, "failed to sign: more than 3 sign requests per epoch for the entity: rate limit exceeded")
+
+		// other entities are not limited
+		res, err = request(t, "entity-2", "accounts/sign")
+		require.NoError(t, err)
+		require.NotEmpty(t, res.Data["signature"])
+	})
+}
+
+func TestRateLimiterPrune(t *testing.T) {
+	limiter := newRateLimiter()
+	counter := func(key string, slot uint64) rateCounter {
+		limit := &RateLimit{Limit: 1, Period: RateLimitPeriodEpoch}
+		return rateCounter{
+			key:   key,
+			index: limit.window(core.PraterNetwork, slot),
+			end:   limit.windowEnd(core.PraterNetwork, slot),
+			limit: limit.Limit,
+			err:   ErrRateLimited,
+		}
+	}
+
+	for i := 0; i < maxRateWindows; i++ {
+		require.NoError(t, limiter.allow(3200, counter(fmt.Sprintf("entity/%d/epoch", i), 3200)))
+	}
+	require.Len(t, limiter.windows, maxRateWindows)
+
+	// the windows of the current epoch are kept
+	require.NoError(t, limiter.allow(3231, counter("entity/new/epoch", 3231)))
+	require.Len(t, limiter.windows, maxRateWindows+1)
+	require.Equal(t, ErrRateLimited, limiter.allow(3231, counter("entity/0/epoch", 3231)))
+
+	// the windows that are over are pruned once the limiter is full
+	require.NoError(t, limiter.allow(3232, counter("entity/next/epoch", 3232)))
+	require.Len(t, limiter.windows, 1)
+}
@@ -0,0 +1,155 @@
+package backend
+
+import (
+	"context"
+	"encoding/hex"
+	"sync"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bloxapp/key-vault/keymanager/models"
+	"github.com/bloxapp/key-vault/utils/errorex"
+	"github.com/bloxapp/key-vault/utils/sentry"
+)
+
+// requestReport collects the fields of a request, logged with its decisions, and its failure reported once the request is handled.
+type requestReport struct {
+	mu       sync.Mutex
+	fields   logrus.Fields
+	err      error
+	panicked bool
+}
+
+type requestReportKey struct{}
+
+// reportObjectVoluntaryExit is the reported object type of the voluntary exits, they aren't rate limited.
+const reportObjectVoluntaryExit = "voluntary_exit"
+
+// withRequestReport returns a context holding a new report of the request with the given fields.
+func withRequestReport(ctx context.Context, fields logrus.Fields) (context.Context, *requestReport) {
+	report := &requestReport{fields: fields}
+	return context.WithValue(ctx, requestReportKey{}, report), report
+}
+
+// addReportFields adds the given fields to the report of the request of the given context, if any.
+func addReportFields(ctx context.Context, fields logrus.Fields) {
+	report, ok := ctx.Value(requestReportKey{}).(*requestReport)
+	if !ok {
+		return
+	}
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	for key, value := range fields {
+		report.fields[key] = value
+	}
+}
+
+// reportRequestError records the failure of the request of the given context, if any.
+// The coded errors are returned as responses, they are recorded before the conversion.
+func reportRequestError(ctx context.Context, err error) {
+	report, ok := ctx.Value(requestReportKey{}).(*requestReport)
+	if !ok {
+		return
+	}
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	report.err = err
+}
+
+// requestLogger returns the logger of the request of the given context, with the fields of the request.
+func (b *backend) requestLogger(ctx context.Context) *logrus.Entry {
+	report, ok := ctx.Value(requestReportKey{}).(*requestReport)
+	if !ok {
+		return logrus.NewEntry(b.logger)
+	}
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	return b.logger.WithFields(report.fields)
+}
+
+// signReportFields returns the fields of the given sign request reported with its failures.
+func signReportFields(network core.Network, signReq *models.SignRequest) logrus.Fields {
+	fields := logrus.Fields{
+		sentry.TagNetwork:   string(network),
+		sentry.TagPublicKey: hex.EncodeToString(signReq.GetPublicKey()),
+	}
+	objectType := signObjectType(signReq)
+	if _, ok := signReq.GetObject().(*models.SignRequestVoluntaryExit); ok {
+		objectType = reportObjectVoluntaryExit
+	}
+	if objectType != "" {
+		fields[sentry.TagObjectType] = objectType
+	}
+	// The requests whose slot can't be read are reported without it.
+	ranges, _ := requestSlotRanges(network, signReq)
+	for _, r := range ranges {
+		fields[r.name] = r.value
+	}
+	return fields
+}
+
+// HandleRequest handles the given request, recovering from the panics and reporting the failures.
+func (b *backend) HandleRequest(ctx context.Context, req *logical.Request) (resp *logical.Response, err error) {
+	ctx, report := withRequestReport(ctx, logrus.Fields{
+		sentry.TagMount:     req.MountPoint,
+		sentry.TagPath:      req.Path,
+		sentry.TagOperation: string(req.Operation),
+		"request_id":        req.ID,
+		"entity_id":         req.EntityID,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			resp, err = nil, errors.Errorf("panic: %v", r)
+			report.panicked = true
+		}
+		if err != nil {
+			report.err = err
+		}
+		b.reportFailure(report)
+	}()
+	return b.Backend.HandleRequest(ctx, req)
+}
+
+// reportFailure logs the failure of the given report, if any, the logs are sent to Sentry by level.
+// Panics and storage failures are errors, sign refusals are logged as decisions, other failed sign requests
+// are warnings and other failures are client errors.
+func (b *backend) reportFailure(report *requestReport) {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	if report.err == nil {
+		return
+	}
+
+	code, coded := signErrorCode(report.err)
+	if coded {
+		report.fields[sentry.TagCode] = string(code)
+	}
+	entry := b.logger.WithFields(report.fields).WithError(report.err)
+	switch {
+	case report.panicked:
+		entry.Error("request panicked")
+	case clientRequestError(report.err):
+		entry.Debug("request failed")
+	case !coded || code == errorex.CodeStorageFailure:
+		entry.Error("request failed")
+	case report.fields[sentry.TagObjectType] == nil:
+		entry.Debug("request failed")
+	case code == errorex.CodeSlashable, code == errorex.CodePolicyViolation, code == errorex.CodeRateLimited:
+		entry.Info("refused to sign")
+	default:
+		entry.Warn("sign request failed")
+	}
+}
+
+// clientRequestError returns true if the given error is a Vault error of an invalid request.
+func clientRequestError(err error) bool {
+	switch errors.Cause(err) {
+	case logical.ErrUnsupportedOperation, logical.ErrUnsupportedPath, logical.ErrInvalidRequest, logical.ErrPermissionDenied:
+		return true
+	default:
+		return false
+	}
+}
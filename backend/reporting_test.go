@@ -0,0 +1,134 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/helper/logging"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/utils/errorex"
+	"github.com/bloxapp/key-vault/utils/sentry"
+)
+
+// panickingStorage panics on every read.
+type panickingStorage struct {
+	logical.Storage
+}
+
+func (s *panickingStorage) Get(context.Context, string) (*logical.StorageEntry, error) {
+	panic("storage is broken")
+}
+
+func TestRequestReporting(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	b, err := Factory("test", logger)(context.Background(), &logical.BackendConfig{
+		Logger:      logging.NewVaultLogger(hclog.Trace),
+		System:      &logical.StaticSystemView{},
+		StorageView: &logical.InmemStorage{},
+		BackendUUID: "test",
+	})
+	require.NoError(t, err)
+
+	req := logical.TestRequest(t, logical.CreateOperation, "accounts/sign")
+	req.MountPoint = "ethereum/prater/"
+	setupBaseStorage(t, req)
+	require.NoError(t, setupStorageWithWalletAndAccounts(req.Storage))
+
+	t.Run("failed sign request", func(t *testing.T) {
+		hook.Reset()
+		req.Data = basicAttestationDataWithOps(true, false, false, false, false)
+		res, err := b.HandleRequest(context.Background(), req)
+		requireCodedError(t, res, err, errorex.CodeUnknownAccount, "failed to sign: account not found")
+
+		entry := hook.LastEntry()
+		require.NotNil(t, entry)
+		require.Equal(t, logrus.WarnLevel, entry.Level)
+		require.Equal(t, "sign request failed", entry.Message)
+		require.Equal(t, "ethereum/prater/", entry.Data[sentry.TagMount])
+		require.Equal(t, "accounts/sign", entry.Data[sentry.TagPath])
+		require.Equal(t, "prater", entry.Data[sentry.TagNetwork])
+		require.Equal(t, SignObjectAttestation, entry.Data[sentry.TagObjectType])
+		require.Equal(t, uint64(284115), entry.Data[sentry.TagSlot])
+		require.Equal(t, uint64(78), entry.Data[sentry.TagEpoch])
+		require.Equal(t, "95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcd", entry.Data[sentry.TagPublicKey])
+		require.Equal(t, string(errorex.CodeUnknownAccount), entry.Data[sentry.TagCode])
+	})
+
+	t.Run("request logger", func(t *testing.T) {
+		hook.Reset()
+		signReq := logical.TestRequest(t, logical.CreateOperation, "accounts/sign")
+		signReq.ID = "e5b1c9e2-request"
+		signReq.EntityID = "validator-client"
+		signReq.Storage = req.Storage
+		signReq.Data = basicAggregationAndProofData()
+		res, err := b.HandleRequest(context.Background(), signReq)
+		require.NoError(t, err)
+		require.NotEmpty(t, res.Data["signature"])
+
+		entry := hook.LastEntry()
+		require.NotNil(t, entry)
+		require.Equal(t, logrus.DebugLevel, entry.Level)
+		require.Equal(t, "signed", entry.Message)
+		require.Equal(t, "e5b1c9e2-request", entry.Data["request_id"])
+		require.Equal(t, "validator-client", entry.Data["entity_id"])
+		require.Equal(t, "accounts/sign", entry.Data[sentry.TagPath])
+		require.Equal(t, "create", entry.Data[sentry.TagOperation])
+		require.Equal(t, SignObjectAggregateAndProof, entry.Data[sentry.TagObjectType])
+		require.Equal(t, "95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf", entry.Data[sentry.TagPublicKey])
+	})
+
+	t.Run("sign refusal", func(t *testing.T) {
+		hook.Reset()
+		res, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "accounts/sign-voluntary-exit",
+			Storage:   req.Storage,
+			Data:      basicVoluntaryExitData(false),
+		})
+		requireCodedError(t, res, err, errorex.CodePolicyViolation, "failed to sign: no validator index registered for the account")
+
+		// the refusal is logged once, it isn't reported as a failure
+		entries := hook.AllEntries()
+		require.Len(t, entries, 1)
+		require.Equal(t, logrus.InfoLevel, entries[0].Level)
+		require.Equal(t, "refused to sign", entries[0].Message)
+		require.Equal(t, reportObjectVoluntaryExit, entries[0].Data[sentry.TagObjectType])
+		require.Equal(t, string(errorex.CodePolicyViolation), entries[0].Data[sentry.TagCode])
+	})
+
+	t.Run("client error", func(t *testing.T) {
+		hook.Reset()
+		res, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "accounts/sign",
+			Storage:   req.Storage,
+			Data:      map[string]interface{}{"sign_req": "zz"},
+		})
+		requireCodedError(t, res, err, errorex.CodeBadRequest, "failed to decode sign request hex: encoding/hex: invalid byte: U+007A 'z'")
+		require.Equal(t, logrus.DebugLevel, hook.LastEntry().Level)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		hook.Reset()
+		// the config is cached per mount
+		b.InvalidateKey(context.Background(), ConfigPattern)
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "config",
+			Storage:   &panickingStorage{Storage: req.Storage},
+		})
+		require.EqualError(t, err, "panic: storage is broken")
+
+		entry := hook.LastEntry()
+		require.NotNil(t, entry)
+		require.Equal(t, logrus.ErrorLevel, entry.Level)
+		require.Equal(t, "request panicked", entry.Message)
+		require.Equal(t, "config", entry.Data[sentry.TagPath])
+	})
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"encoding/hex"
+	"sync"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/wallets"
+)
+
+// AccountCache keeps the decoded accounts by public key between stores of the same storage,
+// so that signing doesn't read and decode them on every request.
+// The accounts are cached without their wallet context, which holds the storage of a request,
+// they are bound to the store reading them.
+type AccountCache struct {
+	mu       sync.RWMutex
+	accounts map[string]wallets.HDAccount
+}
+
+// NewAccountCache is the constructor of AccountCache.
+func NewAccountCache() *AccountCache {
+	return &AccountCache{
+		accounts: make(map[string]wallets.HDAccount),
+	}
+}
+
+// get returns the cached account of the given public key, bound to the given wallet context.
+func (c *AccountCache) get(pubKey []byte, context *core.WalletContext) (core.ValidatorAccount, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	account, ok := c.accounts[hex.EncodeToString(pubKey)]
+	if !ok {
+		return nil, false
+	}
+	account.SetContext(context)
+	return &account, true
+}
+
+// put caches the given account, the accounts of other types than HD accounts aren't cached.
+func (c *AccountCache) put(account core.ValidatorAccount) {
+	hdAccount, ok := account.(*wallets.HDAccount)
+	if !ok {
+		return
+	}
+	cached := *hdAccount
+	cached.SetContext(nil)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.accounts[hex.EncodeToString(account.ValidatorPublicKey())] = cached
+}
+
+// delete drops the cached account of the given public key.
+func (c *AccountCache) delete(pubKey []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.accounts, hex.EncodeToString(pubKey))
+}
+
+// Invalidate drops all the cached accounts.
+func (c *AccountCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.accounts = make(map[string]wallets.HDAccount)
+}
+
+// Len returns the number of cached accounts.
+func (c *AccountCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.accounts)
+}
+
+// SetAccountCache sets the cache of the decoded accounts.
+func (store *HashicorpVaultStore) SetAccountCache(cache *AccountCache) {
+	store.accountCache = cache
+}
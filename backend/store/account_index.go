@@ -0,0 +1,248 @@
+package store
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/wallets/hd"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Paths
+const (
+	// AccountIndexBase is the prefix of the public key to account ID index entries.
+	AccountIndexBase = "wallet/index/accounts/"
+	// AccountIndexPath is the path of the index entry of a hex encoded public key.
+	AccountIndexPath = AccountIndexBase + "%s"
+	// AccountIndexReadyPath marks the index as complete, it is missing for storages written before the index.
+	AccountIndexReadyPath = "wallet/index/ready"
+)
+
+// saveAccountIndex stores the index entry of the given account.
+func (store *HashicorpVaultStore) saveAccountIndex(account core.ValidatorAccount) error {
+	path := fmt.Sprintf(AccountIndexPath, hex.EncodeToString(account.ValidatorPublicKey()))
+	return store.putEntry(path, []byte(account.ID().String()))
+}
+
+// accountIDByPublicKey returns the indexed account ID of the given public key.
+func (store *HashicorpVaultStore) accountIDByPublicKey(pubKey []byte) (uuid.UUID, bool, error) {
+	path := fmt.Sprintf(AccountIndexPath, hex.EncodeToString(pubKey))
+	entry, err := store.storage.Get(store.ctx, path)
+	if err != nil {
+		return uuid.Nil, false, errors.Wrapf(err, "failed to get record with path '%s'", path)
+	}
+	if entry == nil {
+		return uuid.Nil, false, nil
+	}
+
+	id, err := uuid.ParseBytes(entry.Value)
+	if err != nil {
+		return uuid.Nil, false, errors.Wrapf(err, "failed to parse record with path '%s'", path)
+	}
+	return id, true, nil
+}
+
+// accountIndexReady returns true if the index contains every account.
+func (store *HashicorpVaultStore) accountIndexReady() (bool, error) {
+	entry, err := store.storage.Get(store.ctx, AccountIndexReadyPath)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get account index state")
+	}
+	return entry != nil, nil
+}
+
+// deleteAccountIndex deletes the whole index.
+func (store *HashicorpVaultStore) deleteAccountIndex() error {
+	keys, err := store.storage.List(store.ctx, AccountIndexBase)
+	if err != nil {
+		return errors.Wrap(err, "failed to list account index")
+	}
+	for _, key := range keys {
+		if err := store.storage.Delete(store.ctx, AccountIndexBase+key); err != nil {
+			return errors.Wrapf(err, "failed to delete record with path '%s'", AccountIndexBase+key)
+		}
+	}
+	return store.storage.Delete(store.ctx, AccountIndexReadyPath)
+}
+
+// RebuildAccountIndex rebuilds the public key index from the wallet.
+// It is done on the first lookup of a storage written before the index.
+func (store *HashicorpVaultStore) RebuildAccountIndex() error {
+	wallet, err := store.OpenWallet()
+	if err != nil {
+		return errors.Wrap(err, "failed to open wallet")
+	}
+
+	// Existing entries are overwritten rather than deleted, so that concurrent lookups keep working.
+	for _, account := range wallet.Accounts() {
+		if err := store.saveAccountIndex(account); err != nil {
+			return errors.Wrap(err, "failed to save account index")
+		}
+	}
+	return store.putEntry(AccountIndexReadyPath, []byte{1})
+}
+
+// AccountByPublicKey returns the account with the given public key using the account cache and the index,
+// without deserializing the wallet. hd.ErrAccountNotFound is returned if there is no such account.
+func (store *HashicorpVaultStore) AccountByPublicKey(pubKey []byte) (core.ValidatorAccount, error) {
+	if store.accountCache != nil {
+		if account, ok := store.accountCache.get(pubKey, store.freshContext()); ok {
+			return account, nil
+		}
+	}
+
+	id, found, err := store.accountIDByPublicKey(pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		ready, err := store.accountIndexReady()
+		if err != nil {
+			return nil, err
+		}
+		if ready {
+			return nil, hd.ErrAccountNotFound
+		}
+
+		// The index is incomplete, rebuild it unless there is no wallet at all.
+		entry, err := store.storage.Get(store.ctx, WalletDataPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get wallet data")
+		}
+		if entry == nil {
+			return nil, hd.ErrAccountNotFound
+		}
+		if err := store.RebuildAccountIndex(); err != nil {
+			return nil, errors.Wrap(err, "failed to rebuild account index")
+		}
+		if id, found, err = store.accountIDByPublicKey(pubKey); err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, hd.ErrAccountNotFound
+		}
+	}
+
+	// Stale entries of deleted accounts are ignored.
+	account, err := store.OpenAccount(id)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil || !bytes.Equal(account.ValidatorPublicKey(), pubKey) {
+		return nil, hd.ErrAccountNotFound
+	}
+	if store.accountCache != nil {
+		store.accountCache.put(account)
+	}
+	return account, nil
+}
+
+// IndexedWallet returns the wallet of the store, looking up the accounts by public key using the index.
+func (store *HashicorpVaultStore) IndexedWallet() *IndexedWallet {
+	return &IndexedWallet{store: store}
+}
+
+// IndexedWallet implements core.Wallet, only the lookups by public key and by ID don't open the wallet.
+type IndexedWallet struct {
+	store *HashicorpVaultStore
+
+	once   sync.Once
+	wallet core.Wallet
+	err    error
+}
+
+// open opens the underlying wallet once.
+func (w *IndexedWallet) open() (core.Wallet, error) {
+	w.once.Do(func() {
+		w.wallet, w.err = w.store.OpenWallet()
+	})
+	return w.wallet, w.err
+}
+
+// ID provides the ID for the wallet.
+func (w *IndexedWallet) ID() uuid.UUID {
+	wallet, err := w.open()
+	if err != nil {
+		return uuid.Nil
+	}
+	return wallet.ID()
+}
+
+// Type provides the type of the wallet.
+func (w *IndexedWallet) Type() core.WalletType {
+	return core.HDWallet
+}
+
+// CreateValidatorAccount creates a new validation (validator) key pair in the wallet.
+func (w *IndexedWallet) CreateValidatorAccount(seed []byte, indexPointer *int) (core.ValidatorAccount, error) {
+	wallet, err := w.open()
+	if err != nil {
+		return nil, err
+	}
+	return wallet.CreateValidatorAccount(seed, indexPointer)
+}
+
+// CreateValidatorAccountFromPrivateKey creates validator account from Private Key
+func (w *IndexedWallet) CreateValidatorAccountFromPrivateKey(privateKey []byte, indexPointer *int) (core.ValidatorAccount, error) {
+	wallet, err := w.open()
+	if err != nil {
+		return nil, err
+	}
+	return wallet.CreateValidatorAccountFromPrivateKey(privateKey, indexPointer)
+}
+
+// AddValidatorAccount adds the given account to the wallet.
+func (w *IndexedWallet) AddValidatorAccount(account core.ValidatorAccount) error {
+	wallet, err := w.open()
+	if err != nil {
+		return err
+	}
+	return wallet.AddValidatorAccount(account)
+}
+
+// Accounts provides all accounts in the wallet.
+func (w *IndexedWallet) Accounts() []core.ValidatorAccount {
+	wallet, err := w.open()
+	if err != nil {
+		return []core.ValidatorAccount{}
+	}
+	return wallet.Accounts()
+}
+
+// AccountByID provides a single account from the wallet given its ID.
+func (w *IndexedWallet) AccountByID(id uuid.UUID) (core.ValidatorAccount, error) {
+	account, err := w.store.OpenAccount(id)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, hd.ErrAccountNotFound
+	}
+	return account, nil
+}
+
+// AccountByPublicKey provides a single account from the wallet given its hex encoded public key.
+func (w *IndexedWallet) AccountByPublicKey(pubKey string) (core.ValidatorAccount, error) {
+	pubKeyBytes, err := hex.DecodeString(pubKey)
+	if err != nil {
+		return nil, hd.ErrAccountNotFound
+	}
+	return w.store.AccountByPublicKey(pubKeyBytes)
+}
+
+// DeleteAccountByPublicKey deletes an account from the wallet given its public key.
+func (w *IndexedWallet) DeleteAccountByPublicKey(pubKey string) error {
+	wallet, err := w.open()
+	if err != nil {
+		return err
+	}
+	return wallet.DeleteAccountByPublicKey(pubKey)
+}
+
+// SetContext is a no-op, the wallet is bound to its store.
+func (w *IndexedWallet) SetContext(ctx *core.WalletContext) {}
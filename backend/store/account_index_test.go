@@ -0,0 +1,175 @@
+package store_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	eth2keymanager "github.com/bloxapp/eth2-key-manager"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/wallets/hd"
+	"github.com/google/uuid"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/backend/store"
+)
+
+func TestAccountIndex(t *testing.T) {
+	t.Run("lookup", func(t *testing.T) {
+		s := store.NewHashicorpVaultStore(context.Background(), getStorage(), core.PraterNetwork)
+		accounts := createAccounts(t, s, 2)
+
+		for _, account := range accounts {
+			found, err := s.AccountByPublicKey(account.ValidatorPublicKey())
+			require.NoError(t, err)
+			require.Equal(t, account.ID(), found.ID())
+
+			found, err = s.IndexedWallet().AccountByPublicKey(hex.EncodeToString(account.ValidatorPublicKey()))
+			require.NoError(t, err)
+			require.Equal(t, account.ID(), found.ID())
+		}
+
+		_, err := s.AccountByPublicKey(_byteArray("95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcd"))
+		require.Equal(t, hd.ErrAccountNotFound, err)
+	})
+
+	t.Run("storage without index", func(t *testing.T) {
+		storage := getStorage()
+		s := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork)
+		accounts := createAccounts(t, s, 2)
+
+		// drop the index, as written before it existed
+		keys, err := storage.List(context.Background(), store.AccountIndexBase)
+		require.NoError(t, err)
+		require.Len(t, keys, 2)
+		for _, key := range keys {
+			require.NoError(t, storage.Delete(context.Background(), store.AccountIndexBase+key))
+		}
+
+		found, err := s.AccountByPublicKey(accounts[1].ValidatorPublicKey())
+		require.NoError(t, err)
+		require.Equal(t, accounts[1].ID(), found.ID())
+
+		keys, err = storage.List(context.Background(), store.AccountIndexBase)
+		require.NoError(t, err)
+		require.Len(t, keys, 2)
+		entry, err := storage.Get(context.Background(), store.AccountIndexReadyPath)
+		require.NoError(t, err)
+		require.NotNil(t, entry)
+	})
+
+	t.Run("deleted account", func(t *testing.T) {
+		s := store.NewHashicorpVaultStore(context.Background(), getStorage(), core.PraterNetwork)
+		accounts := createAccounts(t, s, 2)
+		require.NoError(t, s.RebuildAccountIndex())
+
+		wallet, err := s.OpenWallet()
+		require.NoError(t, err)
+		require.NoError(t, wallet.DeleteAccountByPublicKey(hex.EncodeToString(accounts[0].ValidatorPublicKey())))
+
+		_, err = s.AccountByPublicKey(accounts[0].ValidatorPublicKey())
+		require.Equal(t, hd.ErrAccountNotFound, err)
+		found, err := s.AccountByPublicKey(accounts[1].ValidatorPublicKey())
+		require.NoError(t, err)
+		require.Equal(t, accounts[1].ID(), found.ID())
+	})
+
+	t.Run("cached account", func(t *testing.T) {
+		storage := getStorage()
+		cache := store.NewAccountCache()
+		s := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork)
+		s.SetAccountCache(cache)
+		accounts := createAccounts(t, s, 1)
+		_, err := s.AccountByPublicKey(accounts[0].ValidatorPublicKey())
+		require.NoError(t, err)
+		require.Equal(t, 1, cache.Len())
+
+		// the cached account is bound to the store of the lookup, not to the one which cached it
+		other := store.NewHashicorpVaultStore(context.Background(), storage, core.MainNetwork)
+		other.SetAccountCache(cache)
+		found, err := other.AccountByPublicKey(accounts[0].ValidatorPublicKey())
+		require.NoError(t, err)
+		depositData, err := found.GetDepositData()
+		require.NoError(t, err)
+		require.Equal(t, core.MainNetwork.DepositContractAddress(), depositData["depositContractAddress"])
+	})
+
+	t.Run("no wallet", func(t *testing.T) {
+		s := store.NewHashicorpVaultStore(context.Background(), getStorage(), core.PraterNetwork)
+		_, err := s.AccountByPublicKey(_byteArray("95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcd"))
+		require.Equal(t, hd.ErrAccountNotFound, err)
+	})
+}
+
+// benchmarkStorage returns a storage with the given number of accounts and the public key of one of them.
+// Only that account is stored, the others are only in the wallet and the index.
+func benchmarkStorage(b *testing.B, count int) (logical.Storage, []byte) {
+	storage := getStorage()
+	s := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork)
+
+	options := &eth2keymanager.KeyVaultOptions{}
+	options.SetStorage(s)
+	kv, err := eth2keymanager.NewKeyVault(options)
+	require.NoError(b, err)
+	wallet, err := kv.Wallet()
+	require.NoError(b, err)
+	account, err := wallet.CreateValidatorAccount(_byteArray("0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1fff"), nil)
+	require.NoError(b, err)
+
+	indexMapper := map[string]string{
+		hex.EncodeToString(account.ValidatorPublicKey()): account.ID().String(),
+	}
+	for len(indexMapper) < count {
+		pubKey := make([]byte, 48)
+		_, err := rand.Read(pubKey)
+		require.NoError(b, err)
+		id := uuid.New().String()
+		indexMapper[hex.EncodeToString(pubKey)] = id
+		require.NoError(b, storage.Put(context.Background(), &logical.StorageEntry{
+			Key:   fmt.Sprintf(store.AccountIndexPath, hex.EncodeToString(pubKey)),
+			Value: []byte(id),
+		}))
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"id":          wallet.ID(),
+		"type":        wallet.Type(),
+		"indexMapper": indexMapper,
+	})
+	require.NoError(b, err)
+	require.NoError(b, storage.Put(context.Background(), &logical.StorageEntry{Key: store.WalletDataPath, Value: data}))
+	require.NoError(b, storage.Put(context.Background(), &logical.StorageEntry{Key: store.AccountIndexReadyPath, Value: []byte{1}}))
+	return storage, account.ValidatorPublicKey()
+}
+
+func BenchmarkAccountByPublicKey(b *testing.B) {
+	for _, count := range []int{1000, 10000, 50000} {
+		storage, pubKey := benchmarkStorage(b, count)
+
+		b.Run(fmt.Sprintf("wallet/%d", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork)
+				options := &eth2keymanager.KeyVaultOptions{}
+				options.SetStorage(s)
+				kv, err := eth2keymanager.OpenKeyVault(options)
+				require.NoError(b, err)
+				wallet, err := kv.Wallet()
+				require.NoError(b, err)
+				_, err = wallet.AccountByPublicKey(hex.EncodeToString(pubKey))
+				require.NoError(b, err)
+			}
+		})
+
+		b.Run(fmt.Sprintf("index/%d", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork)
+				_, err := s.IndexedWallet().AccountByPublicKey(hex.EncodeToString(pubKey))
+				require.NoError(b, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/pkg/errors"
+)
+
+// Paths of the doppelganger protection windows of the accounts, keyed by hex encoded public key.
+const (
+	DoppelgangerBase = "doppelganger/"
+	DoppelgangerPath = DoppelgangerBase + "%s"
+)
+
+// DoppelgangerWindow is the period following the import of an account during which its attestations and blocks
+// are refused, so that a validator still running elsewhere is noticed before both of them sign.
+type DoppelgangerWindow struct {
+	ImportedAt      time.Time    `json:"imported_at"`
+	ActivationEpoch phase0.Epoch `json:"activation_epoch"`
+	ActivationTime  time.Time    `json:"activation_time"`
+}
+
+// NewDoppelgangerWindow returns the window of an account imported at the given time, lasting the given number of epochs.
+// The epoch of the import is partly over and isn't counted, the window lasts the given number of full epochs after it.
+// The account signs again from the start of the activation epoch.
+func NewDoppelgangerWindow(network core.Network, importedAt time.Time, epochs uint64) *DoppelgangerWindow {
+	slot := network.EstimatedSlotAtTime(importedAt.Unix())
+	activationEpoch := network.EstimatedEpochAtSlot(slot) + phase0.Epoch(epochs) + 1
+	activationSlot := uint64(activationEpoch) * network.SlotsPerEpoch()
+
+	return &DoppelgangerWindow{
+		ImportedAt:      importedAt.UTC(),
+		ActivationEpoch: activationEpoch,
+		ActivationTime:  time.Unix(int64(network.MinGenesisTime()), 0).Add(time.Duration(activationSlot) * network.SlotDurationSec()).UTC(),
+	}
+}
+
+// Pending returns true if the window isn't over at the given time.
+func (w *DoppelgangerWindow) Pending(now time.Time) bool {
+	return now.Before(w.ActivationTime)
+}
+
+// SaveDoppelgangerWindow stores the doppelganger protection window of the given account.
+func (store *HashicorpVaultStore) SaveDoppelgangerWindow(pubKey []byte, window *DoppelgangerWindow) error {
+	data, err := json.Marshal(window)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal doppelganger window")
+	}
+	return store.putEntry(fmt.Sprintf(DoppelgangerPath, hex.EncodeToString(pubKey)), data)
+}
+
+// DoppelgangerWindow returns the doppelganger protection window of the given account, nil if it has none.
+func (store *HashicorpVaultStore) DoppelgangerWindow(pubKey []byte) (*DoppelgangerWindow, error) {
+	path := fmt.Sprintf(DoppelgangerPath, hex.EncodeToString(pubKey))
+	entry, err := store.storage.Get(store.ctx, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get record with path '%s'", path)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var window DoppelgangerWindow
+	if err := json.Unmarshal(entry.Value, &window); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal doppelganger window")
+	}
+	return &window, nil
+}
+
+// DeleteDoppelgangerWindow deletes the doppelganger protection window of the given account.
+func (store *HashicorpVaultStore) DeleteDoppelgangerWindow(pubKey []byte) error {
+	path := fmt.Sprintf(DoppelgangerPath, hex.EncodeToString(pubKey))
+	if err := store.storage.Delete(store.ctx, path); err != nil {
+		return errors.Wrapf(err, "failed to delete record with path '%s'", path)
+	}
+	return nil
+}
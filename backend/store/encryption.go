@@ -0,0 +1,286 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/pkg/errors"
+)
+
+// Paths
+const (
+	// SealWrapPrefix is the prefix of the entries seal-wrapped by Vault, see PathsSpecial.SealWrapStorage.
+	SealWrapPrefix = "wallet/"
+	// DataKeyPath is the path of the wrapped data key used to encrypt the accounts.
+	DataKeyPath = SealWrapPrefix + "encryption/key"
+)
+
+// envelopeVersion is the version of the encrypted entries format.
+const envelopeVersion = 1
+
+// dataKeyLength is the length of the AES-256 data key.
+const dataKeyLength = 32
+
+// wrappedDataKey is the storage format of the data key, encrypted by the store encryptor.
+type wrappedDataKey struct {
+	Encryptor string                 `json:"encryptor"`
+	Version   uint                   `json:"version"`
+	Crypto    map[string]interface{} `json:"crypto"`
+}
+
+// encryptedValue is the storage format of the entries encrypted by the data key.
+type encryptedValue struct {
+	EnvelopeVersion int    `json:"envelope_version"`
+	Nonce           []byte `json:"nonce"`
+	Ciphertext      []byte `json:"ciphertext"`
+}
+
+// DataKeyCache keeps the unwrapped data key between stores of the same storage,
+// the encryptors are too slow to unwrap it on every request.
+type DataKeyCache struct {
+	mu  sync.Mutex
+	key []byte
+}
+
+// Invalidate drops the cached data key.
+func (c *DataKeyCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.key = nil
+}
+
+// SetDataKeyCache sets the cache of the unwrapped data key.
+func (store *HashicorpVaultStore) SetDataKeyCache(cache *DataKeyCache) {
+	store.dataKeyCache = cache
+}
+
+// sealWrap returns true if the entry with the given key is seal-wrapped by Vault.
+func sealWrap(key string) bool {
+	return strings.HasPrefix(key, SealWrapPrefix)
+}
+
+// putEntry stores the given value, seal-wrapped according to its key.
+func (store *HashicorpVaultStore) putEntry(key string, value []byte) error {
+	return store.storage.Put(store.ctx, &logical.StorageEntry{
+		Key:      key,
+		Value:    value,
+		SealWrap: sealWrap(key),
+	})
+}
+
+// encryptValue encrypts the given value of the entry with the given key by the data key, creating it if missing.
+// The entry key is authenticated so that encrypted values can't be swapped between entries.
+// The value is returned as is if no encryptor is set.
+func (store *HashicorpVaultStore) encryptValue(entryKey string, value []byte) ([]byte, error) {
+	if store.encryptor == nil {
+		return value, nil
+	}
+
+	key, err := store.dataKey(true)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "failed to generate nonce")
+	}
+	return json.Marshal(&encryptedValue{
+		EnvelopeVersion: envelopeVersion,
+		Nonce:           nonce,
+		Ciphertext:      gcm.Seal(nil, nonce, value, []byte(entryKey)),
+	})
+}
+
+// decryptValue decrypts the given value of the entry with the given key if it is encrypted,
+// plain values are returned as is.
+func (store *HashicorpVaultStore) decryptValue(entryKey string, value []byte) ([]byte, error) {
+	var encrypted encryptedValue
+	if err := json.Unmarshal(value, &encrypted); err != nil || encrypted.EnvelopeVersion == 0 {
+		return value, nil
+	}
+	if encrypted.EnvelopeVersion != envelopeVersion {
+		return nil, errors.Errorf("unsupported envelope version %d", encrypted.EnvelopeVersion)
+	}
+	if store.encryptor == nil {
+		return nil, errors.New("entry is encrypted but no encryptor is set")
+	}
+
+	key, err := store.dataKey(false)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(encrypted.Nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid nonce size")
+	}
+
+	plaintext, err := gcm.Open(nil, encrypted.Nonce, encrypted.Ciphertext, []byte(entryKey))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt entry")
+	}
+	return plaintext, nil
+}
+
+// dataKey returns the unwrapped data key, a new one is stored if missing and create is true.
+func (store *HashicorpVaultStore) dataKey(create bool) ([]byte, error) {
+	cache := store.dataKeyCache
+	if cache == nil {
+		cache = &DataKeyCache{}
+		store.dataKeyCache = cache
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.key != nil {
+		return cache.key, nil
+	}
+
+	entry, err := store.storage.Get(store.ctx, DataKeyPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get data key")
+	}
+
+	var key []byte
+	if entry != nil {
+		if key, err = store.unwrapDataKey(entry.Value); err != nil {
+			return nil, err
+		}
+	} else {
+		if !create {
+			return nil, errors.New("data key not found")
+		}
+		if key, err = store.createDataKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	cache.key = key
+	return key, nil
+}
+
+// createDataKey generates and stores a new data key wrapped by the encryptor.
+func (store *HashicorpVaultStore) createDataKey() ([]byte, error) {
+	key := make([]byte, dataKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, errors.Wrap(err, "failed to generate data key")
+	}
+
+	crypto, err := store.encryptor.Encrypt(key, string(store.encryptionPassword))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to wrap data key")
+	}
+	data, err := json.Marshal(&wrappedDataKey{
+		Encryptor: store.encryptor.Name(),
+		Version:   store.encryptor.Version(),
+		Crypto:    crypto,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal data key")
+	}
+
+	if err := store.putEntry(DataKeyPath, data); err != nil {
+		return nil, errors.Wrap(err, "failed to store data key")
+	}
+	return key, nil
+}
+
+// unwrapDataKey decrypts the given stored data key.
+func (store *HashicorpVaultStore) unwrapDataKey(data []byte) ([]byte, error) {
+	var wrapped wrappedDataKey
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal data key")
+	}
+	if wrapped.Encryptor != store.encryptor.Name() || wrapped.Version != store.encryptor.Version() {
+		return nil, errors.Errorf("data key is wrapped by %s v%d", wrapped.Encryptor, wrapped.Version)
+	}
+
+	key, err := store.encryptor.Decrypt(wrapped.Crypto, string(store.encryptionPassword))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unwrap data key")
+	}
+	if len(key) != dataKeyLength {
+		return nil, errors.New("invalid data key length")
+	}
+	return key, nil
+}
+
+// ReEncrypt rewrites the wallet, the accounts and the exits in place,
+// encrypting the plain accounts and exits and seal-wrapping the entries.
+// It returns the number of rewritten entries.
+func (store *HashicorpVaultStore) ReEncrypt() (int, error) {
+	count := 0
+	for _, prefix := range []string{AccountBase, ExitEscrowBase, ExitRequestBase} {
+		rewritten, err := store.reEncryptEntries(prefix)
+		count += rewritten
+		if err != nil {
+			return count, err
+		}
+	}
+
+	entry, err := store.storage.Get(store.ctx, WalletDataPath)
+	if err != nil {
+		return count, errors.Wrap(err, "failed to get wallet data")
+	}
+	if entry != nil {
+		if err := store.putEntry(WalletDataPath, entry.Value); err != nil {
+			return count, errors.Wrap(err, "failed to store wallet data")
+		}
+		count++
+	}
+	return count, nil
+}
+
+// reEncryptEntries rewrites the entries under the given prefix encrypted, it returns the number of rewritten entries.
+func (store *HashicorpVaultStore) reEncryptEntries(prefix string) (int, error) {
+	keys, err := store.storage.List(store.ctx, prefix)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to list records with prefix '%s'", prefix)
+	}
+
+	count := 0
+	for _, key := range keys {
+		path := prefix + key
+		entry, err := store.storage.Get(store.ctx, path)
+		if err != nil {
+			return count, errors.Wrapf(err, "failed to get record with path '%s'", path)
+		}
+		if entry == nil {
+			continue
+		}
+
+		plaintext, err := store.decryptValue(path, entry.Value)
+		if err != nil {
+			return count, errors.Wrapf(err, "failed to decrypt record with path '%s'", path)
+		}
+		value, err := store.encryptValue(path, plaintext)
+		if err != nil {
+			return count, errors.Wrapf(err, "failed to encrypt record with path '%s'", path)
+		}
+		if err := store.putEntry(path, value); err != nil {
+			return count, errors.Wrapf(err, "failed to store record with path '%s'", path)
+		}
+		count++
+	}
+	return count, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cipher")
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,203 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/encryptor/keystorev4"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/backend/store"
+)
+
+// sealWrapStorage records the seal-wrapped entries, the in-memory storage doesn't keep the flag.
+type sealWrapStorage struct {
+	*logical.InmemStorage
+	sealWrapped map[string]bool
+}
+
+func newSealWrapStorage() *sealWrapStorage {
+	return &sealWrapStorage{
+		InmemStorage: &logical.InmemStorage{},
+		sealWrapped:  make(map[string]bool),
+	}
+}
+
+func (s *sealWrapStorage) Put(ctx context.Context, entry *logical.StorageEntry) error {
+	s.sealWrapped[entry.Key] = entry.SealWrap
+	return s.InmemStorage.Put(ctx, entry)
+}
+
+func getEncryptedStore(storage logical.Storage, password string) *store.HashicorpVaultStore {
+	s := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork)
+	s.SetEncryptor(keystorev4.New(keystorev4.WithCipher("pbkdf2")), []byte(password))
+	return s
+}
+
+func createAccounts(t *testing.T, s *store.HashicorpVaultStore, count int) []core.ValidatorAccount {
+	kv, err := keyVault(s)
+	require.NoError(t, err)
+	wallet, err := kv.Wallet()
+	require.NoError(t, err)
+
+	seed := _byteArray("0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1fff")
+	accounts := make([]core.ValidatorAccount, count)
+	for i := range accounts {
+		accounts[i], err = wallet.CreateValidatorAccount(seed, nil)
+		require.NoError(t, err)
+	}
+	return accounts
+}
+
+func requireEncrypted(t *testing.T, storage *sealWrapStorage, account core.ValidatorAccount, encrypted bool) {
+	path := fmt.Sprintf(store.AccountPath, account.ID().String())
+	entry, err := storage.Get(context.Background(), path)
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	require.True(t, storage.sealWrapped[path])
+
+	var value map[string]interface{}
+	require.NoError(t, json.Unmarshal(entry.Value, &value))
+	if encrypted {
+		require.EqualValues(t, 1, value["envelope_version"])
+		require.NotContains(t, value, "validationKey")
+	} else {
+		require.NotContains(t, value, "envelope_version")
+		require.Contains(t, value, "validationKey")
+	}
+}
+
+func TestAccountsEncryption(t *testing.T) {
+	storage := newSealWrapStorage()
+	accounts := createAccounts(t, getEncryptedStore(storage, "password"), 2)
+
+	t.Run("accounts are encrypted at rest", func(t *testing.T) {
+		for _, account := range accounts {
+			requireEncrypted(t, storage, account, true)
+		}
+
+		entry, err := storage.Get(context.Background(), store.DataKeyPath)
+		require.NoError(t, err)
+		require.NotNil(t, entry)
+		require.True(t, storage.sealWrapped[store.DataKeyPath])
+		require.True(t, storage.sealWrapped[store.WalletDataPath])
+	})
+
+	t.Run("open with the same password", func(t *testing.T) {
+		account, err := getEncryptedStore(storage, "password").OpenAccount(accounts[0].ID())
+		require.NoError(t, err)
+		require.Equal(t, accounts[0].ValidatorPublicKey(), account.ValidatorPublicKey())
+	})
+
+	t.Run("open with a wrong password", func(t *testing.T) {
+		_, err := getEncryptedStore(storage, "wrong").OpenAccount(accounts[0].ID())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to unwrap data key")
+	})
+
+	t.Run("open without encryptor", func(t *testing.T) {
+		s := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork)
+		_, err := s.OpenAccount(accounts[0].ID())
+		require.EqualError(t, err, "failed to decrypt account: entry is encrypted but no encryptor is set")
+	})
+
+	t.Run("swapped entries", func(t *testing.T) {
+		swapped := &logical.InmemStorage{}
+		for _, key := range []string{store.DataKeyPath, fmt.Sprintf(store.AccountPath, accounts[0].ID().String())} {
+			entry, err := storage.Get(context.Background(), key)
+			require.NoError(t, err)
+			require.NoError(t, swapped.Put(context.Background(), entry))
+		}
+		entry, err := swapped.Get(context.Background(), fmt.Sprintf(store.AccountPath, accounts[0].ID().String()))
+		require.NoError(t, err)
+		entry.Key = fmt.Sprintf(store.AccountPath, accounts[1].ID().String())
+		require.NoError(t, swapped.Put(context.Background(), entry))
+
+		_, err = getEncryptedStore(swapped, "password").OpenAccount(accounts[1].ID())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to decrypt entry")
+	})
+}
+
+func TestReEncrypt(t *testing.T) {
+	storage := newSealWrapStorage()
+	plain := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork)
+	accounts := createAccounts(t, plain, 2)
+	for _, account := range accounts {
+		requireEncrypted(t, storage, account, false)
+	}
+
+	require.NoError(t, plain.SaveEscrowedExit(&store.EscrowedExit{PublicKey: "ab", Signature: "cd"}))
+	s := getEncryptedStore(storage, "password")
+
+	// plain accounts are readable before being re-encrypted
+	account, err := s.OpenAccount(accounts[0].ID())
+	require.NoError(t, err)
+	require.Equal(t, accounts[0].ValidatorPublicKey(), account.ValidatorPublicKey())
+
+	// accounts, exits and wallet
+	count, err := s.ReEncrypt()
+	require.NoError(t, err)
+	require.Equal(t, 4, count)
+	for _, account := range accounts {
+		requireEncrypted(t, storage, account, true)
+
+		opened, err := getEncryptedStore(storage, "password").OpenAccount(account.ID())
+		require.NoError(t, err)
+		require.Equal(t, account.ValidatorPublicKey(), opened.ValidatorPublicKey())
+	}
+	entry, err := storage.Get(context.Background(), fmt.Sprintf(store.ExitEscrowPath, "ab"))
+	require.NoError(t, err)
+	require.Contains(t, string(entry.Value), "envelope_version")
+	exit, err := s.EscrowedExit("ab")
+	require.NoError(t, err)
+	require.Equal(t, "cd", exit.Signature)
+
+	// re-encrypting again is a no-op for the content
+	count, err = s.ReEncrypt()
+	require.NoError(t, err)
+	require.Equal(t, 4, count)
+	for _, account := range accounts {
+		requireEncrypted(t, storage, account, true)
+	}
+}
+
+func TestExitsEncryption(t *testing.T) {
+	storage := newSealWrapStorage()
+	s := getEncryptedStore(storage, "password")
+	pubKey := "95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf"
+	signature := "b4a9ba6c8b6e9e0e52ac4fb8ce6b2a41f5f8c3e7a0a1e2e1c3f3a9d8e0c1b2a3"
+
+	require.NoError(t, s.SaveEscrowedExit(&store.EscrowedExit{PublicKey: pubKey, Epoch: 10, Signature: signature}))
+	request := store.NewExitRequest(pubKey, "", "requester", time.Now(), time.Hour, 1)
+	request.Signature = signature
+	require.NoError(t, s.SaveExitRequest(request))
+
+	// the signatures are encrypted at rest
+	for _, path := range []string{fmt.Sprintf(store.ExitEscrowPath, pubKey), fmt.Sprintf(store.ExitRequestPath, request.ID)} {
+		entry, err := storage.Get(context.Background(), path)
+		require.NoError(t, err)
+		require.NotNil(t, entry)
+		require.True(t, storage.sealWrapped[path])
+		require.NotContains(t, string(entry.Value), signature)
+
+		var value map[string]interface{}
+		require.NoError(t, json.Unmarshal(entry.Value, &value))
+		require.EqualValues(t, 1, value["envelope_version"])
+	}
+
+	exit, err := getEncryptedStore(storage, "password").EscrowedExit(pubKey)
+	require.NoError(t, err)
+	require.Equal(t, signature, exit.Signature)
+	read, err := getEncryptedStore(storage, "password").ExitRequest(request.ID)
+	require.NoError(t, err)
+	require.Equal(t, signature, read.Signature)
+
+	_, err = store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork).EscrowedExit(pubKey)
+	require.EqualError(t, err, "failed to decrypt escrowed exit: entry is encrypted but no encryptor is set")
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+)
+
+// Paths of the exits holding signatures, they are seal-wrapped and encrypted as the accounts.
+// The escrowed voluntary exits are keyed by hex encoded public key.
+const (
+	ExitBase       = SealWrapPrefix + "exits/"
+	ExitEscrowBase = ExitBase + "escrow/"
+	ExitEscrowPath = ExitEscrowBase + "%s"
+)
+
+// ErrEscrowedExitNotFound is returned when the account has no escrowed voluntary exit.
+var ErrEscrowedExitNotFound = errors.New("escrowed exit not found")
+
+// EscrowedExit is a signed voluntary exit of an account kept until it is retrieved,
+// the public key and the signature are hex encoded.
+type EscrowedExit struct {
+	PublicKey      string                `json:"public_key"`
+	ValidatorIndex phase0.ValidatorIndex `json:"validator_index"`
+	Epoch          phase0.Epoch          `json:"epoch"`
+	Signature      string                `json:"signature"`
+	CreatedAt      time.Time             `json:"created_at"`
+	CreatedBy      string                `json:"created_by,omitempty"`
+}
+
+// SaveEscrowedExit stores the given escrowed exit, replacing the previous one of the account.
+func (store *HashicorpVaultStore) SaveEscrowedExit(exit *EscrowedExit) error {
+	data, err := json.Marshal(exit)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal escrowed exit")
+	}
+	path := fmt.Sprintf(ExitEscrowPath, exit.PublicKey)
+	value, err := store.encryptValue(path, data)
+	if err != nil {
+		return errors.Wrap(err, "failed to encrypt escrowed exit")
+	}
+	return store.putEntry(path, value)
+}
+
+// EscrowedExit returns the escrowed exit of the given hex encoded public key.
+func (store *HashicorpVaultStore) EscrowedExit(pubKey string) (*EscrowedExit, error) {
+	path := fmt.Sprintf(ExitEscrowPath, pubKey)
+	entry, err := store.storage.Get(store.ctx, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get record with path '%s'", path)
+	}
+	if entry == nil {
+		return nil, ErrEscrowedExitNotFound
+	}
+
+	data, err := store.decryptValue(path, entry.Value)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt escrowed exit")
+	}
+	var exit EscrowedExit
+	if err := json.Unmarshal(data, &exit); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal escrowed exit")
+	}
+	return &exit, nil
+}
+
+// ListEscrowedExits returns the hex encoded public keys of the accounts with an escrowed exit.
+func (store *HashicorpVaultStore) ListEscrowedExits() ([]string, error) {
+	keys, err := store.storage.List(store.ctx, ExitEscrowBase)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list records with prefix '%s'", ExitEscrowBase)
+	}
+
+	pubKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !strings.HasSuffix(key, "/") {
+			pubKeys = append(pubKeys, key)
+		}
+	}
+	return pubKeys, nil
+}
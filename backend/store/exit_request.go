@@ -0,0 +1,148 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Paths of the voluntary exit requests, keyed by request ID.
+// The approved requests hold the signature, they are encrypted as the escrowed exits.
+const (
+	ExitRequestBase = ExitBase + "requests/"
+	ExitRequestPath = ExitRequestBase + "%s"
+)
+
+// Statuses of the voluntary exit requests.
+const (
+	ExitRequestPending  = "pending"
+	ExitRequestApproved = "approved"
+	ExitRequestRejected = "rejected"
+	ExitRequestExpired  = "expired"
+)
+
+// ErrExitRequestNotFound is returned when the voluntary exit request doesn't exist.
+var ErrExitRequestNotFound = errors.New("exit request not found")
+
+// ExitDecision is the approval or the rejection of a voluntary exit request by a Vault entity.
+type ExitDecision struct {
+	EntityID string    `json:"entity_id"`
+	Time     time.Time `json:"time"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+// ExitRequest is a voluntary exit waiting for the approval of RequiredApprovals distinct Vault entities,
+// other than the requesting one, before ExpiresAt. The signature is set once the request is approved.
+// SignRequest is the hex encoded SSZ sign request of the exit.
+type ExitRequest struct {
+	ID                string          `json:"id"`
+	PublicKey         string          `json:"public_key"`
+	SignRequest       string          `json:"sign_request"`
+	RequestedBy       string          `json:"requested_by,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
+	ExpiresAt         time.Time       `json:"expires_at"`
+	RequiredApprovals uint64          `json:"required_approvals"`
+	Status            string          `json:"status"`
+	Approvals         []*ExitDecision `json:"approvals"`
+	Rejection         *ExitDecision   `json:"rejection,omitempty"`
+	ExpiredAt         *time.Time      `json:"expired_at,omitempty"`
+	Signature         string          `json:"signature,omitempty"`
+}
+
+// NewExitRequest returns a pending voluntary exit request created at the given time.
+func NewExitRequest(pubKey, signRequest, requestedBy string, createdAt time.Time, ttl time.Duration, requiredApprovals uint64) *ExitRequest {
+	return &ExitRequest{
+		ID:                uuid.New().String(),
+		PublicKey:         pubKey,
+		SignRequest:       signRequest,
+		RequestedBy:       requestedBy,
+		CreatedAt:         createdAt.UTC(),
+		ExpiresAt:         createdAt.Add(ttl).UTC(),
+		RequiredApprovals: requiredApprovals,
+		Status:            ExitRequestPending,
+		Approvals:         []*ExitDecision{},
+	}
+}
+
+// Expire marks the pending request expired if it is at the given time, and returns true if it did.
+func (r *ExitRequest) Expire(now time.Time) bool {
+	if r.Status != ExitRequestPending || now.Before(r.ExpiresAt) {
+		return false
+	}
+	expiredAt := r.ExpiresAt
+	r.Status = ExitRequestExpired
+	r.ExpiredAt = &expiredAt
+	return true
+}
+
+// Decided returns true if the given entity already approved or rejected the request.
+func (r *ExitRequest) Decided(entityID string) bool {
+	for _, approval := range r.Approvals {
+		if approval.EntityID == entityID {
+			return true
+		}
+	}
+	return r.Rejection != nil && r.Rejection.EntityID == entityID
+}
+
+// SaveExitRequest stores the given voluntary exit request.
+func (store *HashicorpVaultStore) SaveExitRequest(request *ExitRequest) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal exit request")
+	}
+	path := fmt.Sprintf(ExitRequestPath, request.ID)
+	value, err := store.encryptValue(path, data)
+	if err != nil {
+		return errors.Wrap(err, "failed to encrypt exit request")
+	}
+	return store.putEntry(path, value)
+}
+
+// ExitRequest returns the voluntary exit request of the given ID.
+func (store *HashicorpVaultStore) ExitRequest(id string) (*ExitRequest, error) {
+	path := fmt.Sprintf(ExitRequestPath, id)
+	entry, err := store.storage.Get(store.ctx, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get record with path '%s'", path)
+	}
+	if entry == nil {
+		return nil, ErrExitRequestNotFound
+	}
+
+	data, err := store.decryptValue(path, entry.Value)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt exit request")
+	}
+	var request ExitRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal exit request")
+	}
+	return &request, nil
+}
+
+// ListExitRequests returns all the voluntary exit requests, oldest first.
+func (store *HashicorpVaultStore) ListExitRequests() ([]*ExitRequest, error) {
+	keys, err := store.storage.List(store.ctx, ExitRequestBase)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list records with prefix '%s'", ExitRequestBase)
+	}
+
+	requests := make([]*ExitRequest, 0, len(keys))
+	for _, key := range keys {
+		request, err := store.ExitRequest(strings.TrimSuffix(key, "/"))
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	sort.SliceStable(requests, func(i, j int) bool {
+		return requests[i].CreatedAt.Before(requests[j].CreatedAt)
+	})
+	return requests, nil
+}
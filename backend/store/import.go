@@ -0,0 +1,266 @@
+package store
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	vault "github.com/bloxapp/eth2-key-manager"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/stores/inmemory"
+	"github.com/bloxapp/eth2-key-manager/wallets/hd"
+	"github.com/bloxapp/eth2-key-manager/wallets/nd"
+	"github.com/pkg/errors"
+)
+
+// Import errors
+var (
+	// ErrNetworkMismatch is returned when the imported store is of another network.
+	ErrNetworkMismatch = errors.New("network mismatch")
+	// ErrWalletTypeMismatch is returned when the imported wallet is of another type.
+	ErrWalletTypeMismatch = errors.New("wallet type mismatch")
+)
+
+// Import statuses of the accounts
+const (
+	// ImportStatusAdded is the status of the new accounts, imported with their slashing data.
+	ImportStatusAdded = "added"
+	// ImportStatusSkippedDuplicate is the status of the existing accounts, left as they are.
+	ImportStatusSkippedDuplicate = "skipped-duplicate"
+	// ImportStatusSlashingDataMerged is the status of the existing accounts whose slashing data was raised by the imported one.
+	ImportStatusSlashingDataMerged = "slashing-data-merged"
+	// ImportStatusConflicted is the status of the accounts colliding with an existing account of another public key.
+	ImportStatusConflicted = "conflicted"
+)
+
+// ImportAccountReport is the import report of an account.
+type ImportAccountReport struct {
+	PublicKey string `json:"public_key"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// ImportReport is the report of an import, the accounts are in the order of the imported wallet.
+type ImportReport struct {
+	DryRun   bool                   `json:"dry_run"`
+	Accounts []*ImportAccountReport `json:"accounts"`
+}
+
+// Summary returns the number of accounts by status.
+func (r *ImportReport) Summary() map[string]int {
+	summary := map[string]int{
+		ImportStatusAdded:              0,
+		ImportStatusSkippedDuplicate:   0,
+		ImportStatusSlashingDataMerged: 0,
+		ImportStatusConflicted:         0,
+	}
+	for _, account := range r.Accounts {
+		summary[account.Status]++
+	}
+	return summary
+}
+
+// PublicKeys returns the public keys of the imported store.
+func PublicKeys(newStorage *inmemory.InMemStore) ([][]byte, error) {
+	wallet, err := newStorage.OpenWallet()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open newStorage wallet")
+	}
+
+	accounts := wallet.Accounts()
+	pubKeys := make([][]byte, len(accounts))
+	for i, account := range accounts {
+		pubKeys[i] = account.ValidatorPublicKey()
+	}
+	return pubKeys, nil
+}
+
+// ImportFromInMemoryStore adds the new accounts of the given in-memory store and their slashing data, and reports
+// what was done to each account. Existing accounts are not changed, but their slashing data is raised to the imported one.
+// Accounts colliding by name or index with an existing account of another public key are not imported.
+// Nothing is written if dryRun is true.
+func (store *HashicorpVaultStore) ImportFromInMemoryStore(newStorage *inmemory.InMemStore, dryRun bool) (*ImportReport, error) {
+	if newStorage.Network() != store.network {
+		return nil, errors.Wrapf(ErrNetworkMismatch, "failed to import %s accounts into %s storage", newStorage.Network(), store.network)
+	}
+
+	// Open newStorage wallet
+	newStorageWallet, err := newStorage.OpenWallet()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open newStorage wallet")
+	}
+
+	// Get existing hashicorp storage, if any
+	options := vault.KeyVaultOptions{}
+	options.SetStorage(store)
+
+	var existingWallet core.Wallet
+	if _, err := vault.OpenKeyVault(&options); err == nil {
+		if existingWallet, err = store.OpenWallet(); err != nil {
+			return nil, errors.Wrap(err, "failed to open existing wallet")
+		}
+		if existingWallet.Type() != newStorageWallet.Type() {
+			return nil, errors.Wrapf(ErrWalletTypeMismatch, "failed to import %s wallet into %s wallet", newStorageWallet.Type(), existingWallet.Type())
+		}
+	}
+
+	// Names and base paths of the existing and added accounts to detect collisions, the ones of ND accounts are empty
+	existingNames := make(map[string]string)
+	existingPaths := make(map[string]string)
+	fresh := existingWallet == nil
+	if !fresh {
+		for _, account := range existingWallet.Accounts() {
+			pubKey := hex.EncodeToString(account.ValidatorPublicKey())
+			existingNames[account.Name()] = pubKey
+			existingPaths[account.BasePath()] = pubKey
+		}
+	}
+
+	report := &ImportReport{DryRun: dryRun}
+	for _, newAccount := range newStorageWallet.Accounts() {
+		pubKey := newAccount.ValidatorPublicKey()
+		accountReport := &ImportAccountReport{
+			PublicKey: hex.EncodeToString(pubKey),
+			Name:      newAccount.Name(),
+		}
+		report.Accounts = append(report.Accounts, accountReport)
+
+		// Check if account already exists and don't change it
+		if !fresh {
+			_, err := store.AccountByPublicKey(pubKey)
+			if err == nil {
+				merged, err := store.mergeSlashingData(newStorage, pubKey, dryRun)
+				if err != nil {
+					return nil, err
+				}
+				accountReport.Status = ImportStatusSkippedDuplicate
+				if merged {
+					accountReport.Status = ImportStatusSlashingDataMerged
+				}
+				continue
+			}
+			if errors.Cause(err) != hd.ErrAccountNotFound {
+				return nil, errors.Wrapf(err, "failed to look up account '%x'", pubKey)
+			}
+		}
+
+		if existing, ok := existingNames[newAccount.Name()]; ok && newAccount.Name() != "" {
+			accountReport.Status = ImportStatusConflicted
+			accountReport.Reason = fmt.Sprintf("name '%s' is used by account '%s'", newAccount.Name(), existing)
+			continue
+		}
+		if existing, ok := existingPaths[newAccount.BasePath()]; ok && newAccount.BasePath() != "" {
+			accountReport.Status = ImportStatusConflicted
+			accountReport.Reason = fmt.Sprintf("index '%s' is used by account '%s'", newAccount.BasePath(), existing)
+			continue
+		}
+
+		accountReport.Status = ImportStatusAdded
+		existingNames[newAccount.Name()] = accountReport.PublicKey
+		existingPaths[newAccount.BasePath()] = accountReport.PublicKey
+		if dryRun {
+			continue
+		}
+
+		if existingWallet == nil {
+			// Save an empty wallet of the imported type, so that conflicted accounts are left out of it
+			if existingWallet, err = store.newWallet(newStorageWallet.Type()); err != nil {
+				return nil, err
+			}
+		}
+
+		// Add validator account in wallet, this saves the account too
+		if err := existingWallet.AddValidatorAccount(newAccount); err != nil {
+			return nil, errors.Wrap(err, "failed to save account")
+		}
+		if _, err := store.mergeSlashingData(newStorage, pubKey, false); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// newWallet stores and returns an empty wallet of the given type.
+func (store *HashicorpVaultStore) newWallet(walletType core.WalletType) (core.Wallet, error) {
+	var wallet core.Wallet
+	switch walletType {
+	case core.HDWallet:
+		wallet = hd.NewWallet(store.freshContext())
+	case core.NDWallet:
+		wallet = nd.NewWallet(store.freshContext())
+	default:
+		return nil, errors.Wrapf(ErrWalletTypeMismatch, "unsupported wallet type %s", walletType)
+	}
+
+	if err := store.SaveWallet(wallet); err != nil {
+		return nil, errors.Wrap(err, "failed to save wallet to hashicorp store")
+	}
+	return store.OpenWallet()
+}
+
+// mergeSlashingData raises the slashing data of the given account to the one of the imported store.
+// It returns true if the stored data is, or would be if dryRun is true, changed.
+func (store *HashicorpVaultStore) mergeSlashingData(newStorage *inmemory.InMemStore, pubKey []byte, dryRun bool) (bool, error) {
+	merged := false
+
+	// Highest attestation
+	newAtt, found, err := newStorage.RetrieveHighestAttestation(pubKey)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to retrieve highest attestation")
+	}
+	if found && newAtt != nil {
+		existingAtt, found, err := store.RetrieveHighestAttestation(pubKey)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to retrieve existing highest attestation")
+		}
+
+		att := newAtt
+		if found && existingAtt != nil {
+			att = &phase0.AttestationData{
+				Slot:            existingAtt.Slot,
+				Index:           existingAtt.Index,
+				BeaconBlockRoot: existingAtt.BeaconBlockRoot,
+				Source:          maxCheckpoint(existingAtt.Source, newAtt.Source),
+				Target:          maxCheckpoint(existingAtt.Target, newAtt.Target),
+			}
+		}
+		if !found || existingAtt == nil || att.Source.Epoch != existingAtt.Source.Epoch || att.Target.Epoch != existingAtt.Target.Epoch {
+			merged = true
+			if !dryRun {
+				if err := store.SaveHighestAttestation(pubKey, att); err != nil {
+					return false, errors.Wrap(err, "failed to save highest attestation")
+				}
+			}
+		}
+	}
+
+	// Highest proposal
+	newProposal, found, err := newStorage.RetrieveHighestProposal(pubKey)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to retrieve highest proposal")
+	}
+	if found && newProposal != 0 {
+		existingProposal, _, err := store.RetrieveHighestProposal(pubKey)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to retrieve existing highest proposal")
+		}
+		if newProposal > existingProposal {
+			merged = true
+			if !dryRun {
+				if err := store.SaveHighestProposal(pubKey, newProposal); err != nil {
+					return false, errors.Wrap(err, "failed to save highest proposal")
+				}
+			}
+		}
+	}
+	return merged, nil
+}
+
+// maxCheckpoint returns the checkpoint of the highest epoch.
+func maxCheckpoint(a, b *phase0.Checkpoint) *phase0.Checkpoint {
+	if b.Epoch > a.Epoch {
+		return b
+	}
+	return a
+}
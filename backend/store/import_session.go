@@ -0,0 +1,347 @@
+package store
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/stores/inmemory"
+	"github.com/bloxapp/eth2-key-manager/wallets/hd"
+	"github.com/bloxapp/eth2-key-manager/wallets/nd"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Paths of the import sessions, staged under the seal-wrapped prefix as the chunks hold the account keys.
+const (
+	ImportSessionBase      = SealWrapPrefix + "import/sessions/"
+	ImportSessionPath      = ImportSessionBase + "%s"
+	ImportSessionChunkPath = ImportSessionBase + "%s/chunks/%d"
+)
+
+// Import session errors
+var (
+	// ErrImportSessionNotFound is returned when the import session doesn't exist.
+	ErrImportSessionNotFound = errors.New("import session not found")
+	// ErrImportSessionCommitting is returned when chunks are uploaded to a session being committed.
+	ErrImportSessionCommitting = errors.New("import session is being committed")
+	// ErrImportSessionEmpty is returned when a session without chunks is committed.
+	ErrImportSessionEmpty = errors.New("import session has no chunks")
+)
+
+// ImportSession is a multi-part import, its chunks are staged until the session is committed or aborted.
+// Report is the import report checked before the commit started writing, NewWallet is set if the commit
+// started without a stored wallet, they are kept to roll the commit back.
+type ImportSession struct {
+	ID         string          `json:"id"`
+	Network    core.Network    `json:"network,omitempty"`
+	WalletType core.WalletType `json:"wallet_type,omitempty"`
+	Chunks     int             `json:"chunks"`
+	Accounts   int             `json:"accounts"`
+	Committing bool            `json:"committing"`
+	Report     *ImportReport   `json:"report,omitempty"`
+	NewWallet  bool            `json:"new_wallet,omitempty"`
+}
+
+// BeginImportSession creates a new import session.
+func (store *HashicorpVaultStore) BeginImportSession() (*ImportSession, error) {
+	session := &ImportSession{ID: uuid.New().String()}
+	if err := store.saveImportSession(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// ImportSession returns the import session of the given ID.
+func (store *HashicorpVaultStore) ImportSession(id string) (*ImportSession, error) {
+	path := fmt.Sprintf(ImportSessionPath, id)
+	entry, err := store.storage.Get(store.ctx, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get record with path '%s'", path)
+	}
+	if entry == nil {
+		return nil, ErrImportSessionNotFound
+	}
+
+	var session ImportSession
+	if err := json.Unmarshal(entry.Value, &session); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal import session")
+	}
+	return &session, nil
+}
+
+// saveImportSession stores the given import session.
+func (store *HashicorpVaultStore) saveImportSession(session *ImportSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal import session")
+	}
+	return store.putEntry(fmt.Sprintf(ImportSessionPath, session.ID), data)
+}
+
+// AddImportChunk stages the accounts and slashing data of the given in-memory store in the import session.
+// All the chunks of a session must be of the same network and wallet type.
+func (store *HashicorpVaultStore) AddImportChunk(id string, chunk *inmemory.InMemStore) (*ImportSession, error) {
+	session, err := store.ImportSession(id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Committing {
+		return nil, ErrImportSessionCommitting
+	}
+
+	wallet, err := chunk.OpenWallet()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open chunk wallet")
+	}
+	if session.Chunks == 0 {
+		session.Network = chunk.Network()
+		session.WalletType = wallet.Type()
+	}
+	if chunk.Network() != session.Network {
+		return nil, errors.Wrapf(ErrNetworkMismatch, "failed to add %s chunk to %s import session", chunk.Network(), session.Network)
+	}
+	if wallet.Type() != session.WalletType {
+		return nil, errors.Wrapf(ErrWalletTypeMismatch, "failed to add %s chunk to %s import session", wallet.Type(), session.WalletType)
+	}
+
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal chunk")
+	}
+	path := fmt.Sprintf(ImportSessionChunkPath, session.ID, session.Chunks)
+	value, err := store.encryptValue(path, data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encrypt chunk")
+	}
+	if err := store.putEntry(path, value); err != nil {
+		return nil, errors.Wrap(err, "failed to save chunk")
+	}
+
+	session.Chunks++
+	session.Accounts += len(wallet.Accounts())
+	if err := store.saveImportSession(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// importSessionStore merges the staged chunks of the given session in a single in-memory store.
+func (store *HashicorpVaultStore) importSessionStore(session *ImportSession) (*inmemory.InMemStore, error) {
+	merged := inmemory.NewInMemStore(session.Network)
+
+	var mergedWallet core.Wallet
+	for i := 0; i < session.Chunks; i++ {
+		chunk, err := store.importChunk(session.ID, i)
+		if err != nil {
+			return nil, err
+		}
+		wallet, err := chunk.OpenWallet()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open wallet of chunk %d", i)
+		}
+		accounts := wallet.Accounts()
+
+		// The wallet of the first chunk is the wallet of the import, opening it from the merged store changes its context
+		if mergedWallet == nil {
+			if err := merged.SaveWallet(wallet); err != nil {
+				return nil, errors.Wrap(err, "failed to save merged wallet")
+			}
+			if mergedWallet, err = merged.OpenWallet(); err != nil {
+				return nil, errors.Wrap(err, "failed to open merged wallet")
+			}
+		}
+
+		for _, account := range accounts {
+			if err := mergedWallet.AddValidatorAccount(account); err != nil {
+				return nil, errors.Wrapf(err, "failed to merge account of chunk %d", i)
+			}
+			pubKey := account.ValidatorPublicKey()
+			if att, found, err := chunk.RetrieveHighestAttestation(pubKey); err == nil && found && att != nil {
+				if err := merged.SaveHighestAttestation(pubKey, att); err != nil {
+					return nil, errors.Wrap(err, "failed to merge highest attestation")
+				}
+			}
+			if proposal, found, err := chunk.RetrieveHighestProposal(pubKey); err == nil && found {
+				if err := merged.SaveHighestProposal(pubKey, proposal); err != nil {
+					return nil, errors.Wrap(err, "failed to merge highest proposal")
+				}
+			}
+		}
+	}
+	return merged, nil
+}
+
+// importChunk returns the staged chunk of the given session and number.
+func (store *HashicorpVaultStore) importChunk(id string, number int) (*inmemory.InMemStore, error) {
+	path := fmt.Sprintf(ImportSessionChunkPath, id, number)
+	entry, err := store.storage.Get(store.ctx, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get record with path '%s'", path)
+	}
+	if entry == nil {
+		return nil, errors.Errorf("chunk %d is missing", number)
+	}
+
+	data, err := store.decryptValue(path, entry.Value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decrypt chunk %d", number)
+	}
+	var chunk inmemory.InMemStore
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal chunk %d", number)
+	}
+	return &chunk, nil
+}
+
+// ImportSessionStore returns the staged chunks of the given import session merged in a single in-memory store.
+func (store *HashicorpVaultStore) ImportSessionStore(id string) (*inmemory.InMemStore, error) {
+	session, err := store.ImportSession(id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Chunks == 0 {
+		return nil, ErrImportSessionEmpty
+	}
+	return store.importSessionStore(session)
+}
+
+// CommitImportSession imports the given merged store of the import session, see ImportSessionStore and ImportFromInMemoryStore.
+// The whole import is checked before anything is written, then the session is marked as being committed along with the
+// checked report so that no chunk can be added anymore. A failed commit is rolled back, see RollbackImportSession, and
+// the session can be committed again. A commit interrupted before its rollback, e.g. by a restart, is resumed by
+// committing the session again, the checked report is returned, or rolled back by aborting the session.
+// The session is kept until FinishImportSession. Nothing is written if dryRun is true.
+func (store *HashicorpVaultStore) CommitImportSession(id string, merged *inmemory.InMemStore, dryRun bool) (*ImportReport, error) {
+	session, err := store.ImportSession(id)
+	if err != nil {
+		return nil, err
+	}
+
+	report := session.Report
+	switch {
+	case report == nil:
+		if report, err = store.ImportFromInMemoryStore(merged, true); err != nil || dryRun {
+			return report, err
+		}
+		walletEntry, err := store.storage.Get(store.ctx, WalletDataPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get wallet data")
+		}
+		report.DryRun = false
+		session.Committing = true
+		session.Report = report
+		session.NewWallet = walletEntry == nil
+		if err := store.saveImportSession(session); err != nil {
+			return nil, err
+		}
+	case dryRun:
+		return &ImportReport{DryRun: true, Accounts: report.Accounts}, nil
+	}
+
+	if _, err := store.ImportFromInMemoryStore(merged, false); err != nil {
+		if rollbackErr := store.RollbackImportSession(id); rollbackErr != nil {
+			return nil, errors.Wrapf(err, "failed to roll back import session (%s)", rollbackErr)
+		}
+		return nil, err
+	}
+	return report, nil
+}
+
+// RollbackImportSession removes the accounts added by the commit of the given import session, along with their
+// doppelganger windows, and the wallet if the commit created it. The slashing data written by the commit is kept,
+// it only raises the watermarks. The session is left with its chunks and can be committed again.
+func (store *HashicorpVaultStore) RollbackImportSession(id string) error {
+	session, err := store.ImportSession(id)
+	if err != nil {
+		return err
+	}
+	if session.Report == nil {
+		return nil
+	}
+
+	walletEntry, err := store.storage.Get(store.ctx, WalletDataPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to get wallet data")
+	}
+	if walletEntry != nil {
+		wallet, err := store.OpenWallet()
+		if err != nil {
+			return errors.Wrap(err, "failed to open wallet")
+		}
+		for _, account := range session.Report.Accounts {
+			if account.Status != ImportStatusAdded {
+				continue
+			}
+			pubKey, err := hex.DecodeString(account.PublicKey)
+			if err != nil {
+				return errors.Wrap(err, "failed to decode public key")
+			}
+			if err := store.removeImportedAccount(wallet, pubKey); err != nil {
+				return errors.Wrapf(err, "failed to remove account '%s'", account.PublicKey)
+			}
+			if err := store.DeleteDoppelgangerWindow(pubKey); err != nil {
+				return err
+			}
+		}
+		if session.NewWallet && len(wallet.Accounts()) == 0 {
+			if err := store.storage.Delete(store.ctx, WalletDataPath); err != nil {
+				return errors.Wrapf(err, "failed to delete record with path '%s'", WalletDataPath)
+			}
+		}
+	}
+
+	session.Committing = false
+	session.Report = nil
+	session.NewWallet = false
+	return store.saveImportSession(session)
+}
+
+// removeImportedAccount deletes the account of the given public key from the given wallet.
+// An account written without being added to the wallet is deleted from the storage.
+func (store *HashicorpVaultStore) removeImportedAccount(wallet core.Wallet, pubKey []byte) error {
+	err := wallet.DeleteAccountByPublicKey(hex.EncodeToString(pubKey))
+	if cause := errors.Cause(err); err == nil || (cause != hd.ErrAccountNotFound && cause != nd.ErrAccountNotFound) {
+		return err
+	}
+
+	id, found, err := store.accountIDByPublicKey(pubKey)
+	if err != nil || !found {
+		return err
+	}
+	return store.DeleteAccount(id)
+}
+
+// FinishImportSession deletes the given committed import session and its staged chunks.
+func (store *HashicorpVaultStore) FinishImportSession(id string) error {
+	return store.deleteImportSession(id)
+}
+
+// AbortImportSession rolls back the commit of the given import session, if any, then deletes it and its staged chunks.
+func (store *HashicorpVaultStore) AbortImportSession(id string) error {
+	if err := store.RollbackImportSession(id); err != nil {
+		return err
+	}
+	return store.deleteImportSession(id)
+}
+
+// deleteImportSession deletes the given import session and its staged chunks.
+func (store *HashicorpVaultStore) deleteImportSession(id string) error {
+	session, err := store.ImportSession(id)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < session.Chunks; i++ {
+		path := fmt.Sprintf(ImportSessionChunkPath, id, i)
+		if err := store.storage.Delete(store.ctx, path); err != nil {
+			return errors.Wrapf(err, "failed to delete record with path '%s'", path)
+		}
+	}
+	path := fmt.Sprintf(ImportSessionPath, id)
+	if err := store.storage.Delete(store.ctx, path); err != nil {
+		return errors.Wrapf(err, "failed to delete record with path '%s'", path)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package store_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/stores/inmemory"
+	"github.com/bloxapp/eth2-key-manager/wallets/hd"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/backend/store"
+)
+
+// importChunks splits the given accounts in chunks of one account.
+func importChunks(t *testing.T, network core.Network, accounts []core.ValidatorAccount) []*inmemory.InMemStore {
+	chunks := make([]*inmemory.InMemStore, len(accounts))
+	for i, account := range accounts {
+		chunk := inmemory.NewInMemStore(network)
+		wallet := hd.NewWallet(&core.WalletContext{Storage: chunk})
+		require.NoError(t, chunk.SaveWallet(wallet))
+		require.NoError(t, wallet.AddValidatorAccount(account))
+		chunks[i] = chunk
+	}
+	return chunks
+}
+
+// failingStorage fails the write of the given number under the given prefix, and the deletes if failDeletes is set.
+type failingStorage struct {
+	logical.Storage
+	prefix      string
+	failAt      int
+	writes      int
+	failDeletes bool
+}
+
+func (s *failingStorage) Put(ctx context.Context, entry *logical.StorageEntry) error {
+	if strings.HasPrefix(entry.Key, s.prefix) {
+		s.writes++
+		if s.writes == s.failAt {
+			return errors.New("storage is down")
+		}
+	}
+	return s.Storage.Put(ctx, entry)
+}
+
+func (s *failingStorage) Delete(ctx context.Context, key string) error {
+	if s.failDeletes {
+		return errors.New("storage is down")
+	}
+	return s.Storage.Delete(ctx, key)
+}
+
+// writtenAccounts returns the number of the given accounts found in the given store.
+func writtenAccounts(s *store.HashicorpVaultStore, accounts []core.ValidatorAccount) int {
+	written := 0
+	for _, account := range accounts {
+		if _, err := s.AccountByPublicKey(account.ValidatorPublicKey()); err == nil {
+			written++
+		}
+	}
+	return written
+}
+
+func TestImportSession(t *testing.T) {
+	seed := _byteArray("0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1fff")
+
+	t.Run("commit", func(t *testing.T) {
+		_, _, accounts := baseKeyVault(seed, t)
+		chunks := importChunks(t, core.PraterNetwork, accounts)
+		saveSlashingData(t, chunks[1], accounts[1].ValidatorPublicKey(), 1, 2, 10)
+		storage := newSealWrapStorage()
+		s := getEncryptedStore(storage, "password")
+
+		session, err := s.BeginImportSession()
+		require.NoError(t, err)
+		for i, chunk := range chunks {
+			session, err = s.AddImportChunk(session.ID, chunk)
+			require.NoError(t, err)
+			require.Equal(t, i+1, session.Chunks)
+		}
+		require.Equal(t, 2, session.Accounts)
+		require.Equal(t, core.PraterNetwork, session.Network)
+
+		// the staged chunks are sealed and encrypted
+		keys, err := storage.List(context.Background(), store.ImportSessionBase+session.ID+"/chunks/")
+		require.NoError(t, err)
+		require.Len(t, keys, 2)
+		for _, key := range keys {
+			path := store.ImportSessionBase + session.ID + "/chunks/" + key
+			require.True(t, storage.sealWrapped[path])
+			entry, err := storage.Get(context.Background(), path)
+			require.NoError(t, err)
+			require.Contains(t, string(entry.Value), "envelope_version")
+			require.NotContains(t, string(entry.Value), "accounts")
+		}
+
+		merged, err := s.ImportSessionStore(session.ID)
+		require.NoError(t, err)
+		report, err := s.CommitImportSession(session.ID, merged, true)
+		require.NoError(t, err)
+		requireImportStatuses(t, report, accounts, store.ImportStatusAdded, store.ImportStatusAdded)
+		_, err = s.ImportSession(session.ID)
+		require.NoError(t, err)
+
+		report, err = s.CommitImportSession(session.ID, merged, false)
+		require.NoError(t, err)
+		requireImportStatuses(t, report, accounts, store.ImportStatusAdded, store.ImportStatusAdded)
+		for _, account := range accounts {
+			_, err := s.AccountByPublicKey(account.ValidatorPublicKey())
+			require.NoError(t, err)
+		}
+		proposal, found, err := s.RetrieveHighestProposal(accounts[1].ValidatorPublicKey())
+		require.NoError(t, err)
+		require.True(t, found)
+		require.EqualValues(t, 10, proposal)
+
+		// the session is deleted once committed
+		require.NoError(t, s.FinishImportSession(session.ID))
+		_, err = s.ImportSession(session.ID)
+		require.Equal(t, store.ErrImportSessionNotFound, err)
+		keys, err = storage.List(context.Background(), store.ImportSessionBase)
+		require.NoError(t, err)
+		require.Empty(t, keys)
+	})
+
+	t.Run("failed commit", func(t *testing.T) {
+		_, _, accounts := baseKeyVault(seed, t)
+		chunks := importChunks(t, core.PraterNetwork, accounts)
+		storage := &failingStorage{Storage: getStorage(), prefix: store.AccountBase, failAt: 2}
+		s := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork)
+
+		session, err := s.BeginImportSession()
+		require.NoError(t, err)
+		for _, chunk := range chunks {
+			_, err = s.AddImportChunk(session.ID, chunk)
+			require.NoError(t, err)
+		}
+		merged, err := s.ImportSessionStore(session.ID)
+		require.NoError(t, err)
+
+		// the commit fails after writing the first account, which is rolled back with the wallet
+		_, err = s.CommitImportSession(session.ID, merged, false)
+		require.ErrorContains(t, err, "storage is down")
+		require.Equal(t, 0, writtenAccounts(s, accounts))
+		entry, err := storage.Get(context.Background(), store.WalletDataPath)
+		require.NoError(t, err)
+		require.Nil(t, entry)
+		session, err = s.ImportSession(session.ID)
+		require.NoError(t, err)
+		require.False(t, session.Committing)
+		require.Nil(t, session.Report)
+
+		// the session is committed again
+		report, err := s.CommitImportSession(session.ID, merged, false)
+		require.NoError(t, err)
+		requireImportStatuses(t, report, accounts, store.ImportStatusAdded, store.ImportStatusAdded)
+		require.Equal(t, 2, writtenAccounts(s, accounts))
+		require.NoError(t, s.FinishImportSession(session.ID))
+	})
+
+	t.Run("interrupted commit", func(t *testing.T) {
+		_, _, accounts := baseKeyVault(seed, t)
+		chunks := importChunks(t, core.PraterNetwork, accounts)
+		storage := &failingStorage{Storage: getStorage(), prefix: store.AccountBase, failAt: 2, failDeletes: true}
+		s := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork)
+
+		session, err := s.BeginImportSession()
+		require.NoError(t, err)
+		for _, chunk := range chunks {
+			_, err = s.AddImportChunk(session.ID, chunk)
+			require.NoError(t, err)
+		}
+		merged, err := s.ImportSessionStore(session.ID)
+		require.NoError(t, err)
+
+		// the commit fails after writing the first account, and so does its rollback
+		_, err = s.CommitImportSession(session.ID, merged, false)
+		require.ErrorContains(t, err, "failed to roll back import session")
+		require.Equal(t, 1, writtenAccounts(s, accounts))
+		session, err = s.ImportSession(session.ID)
+		require.NoError(t, err)
+		require.True(t, session.Committing)
+		_, err = s.AddImportChunk(session.ID, chunks[0])
+		require.Equal(t, store.ErrImportSessionCommitting, err)
+
+		// the retry resumes the writes and reports the accounts as checked
+		storage.failDeletes = false
+		report, err := s.CommitImportSession(session.ID, merged, true)
+		require.NoError(t, err)
+		require.True(t, report.DryRun)
+		requireImportStatuses(t, report, accounts, store.ImportStatusAdded, store.ImportStatusAdded)
+		report, err = s.CommitImportSession(session.ID, merged, false)
+		require.NoError(t, err)
+		require.False(t, report.DryRun)
+		requireImportStatuses(t, report, accounts, store.ImportStatusAdded, store.ImportStatusAdded)
+		require.Equal(t, 2, writtenAccounts(s, accounts))
+
+		// aborting the session removes them
+		require.NoError(t, s.AbortImportSession(session.ID))
+		require.Equal(t, 0, writtenAccounts(s, accounts))
+		entry, err := storage.Get(context.Background(), store.WalletDataPath)
+		require.NoError(t, err)
+		require.Nil(t, entry)
+		_, err = s.ImportSession(session.ID)
+		require.Equal(t, store.ErrImportSessionNotFound, err)
+	})
+
+	t.Run("abort into existing wallet", func(t *testing.T) {
+		_, _, accounts := baseKeyVault(seed, t)
+		chunks := importChunks(t, core.PraterNetwork, accounts)
+		s := store.NewHashicorpVaultStore(context.Background(), getStorage(), core.PraterNetwork)
+		_, err := s.ImportFromInMemoryStore(chunks[0], false)
+		require.NoError(t, err)
+
+		session, err := s.BeginImportSession()
+		require.NoError(t, err)
+		_, err = s.AddImportChunk(session.ID, chunks[1])
+		require.NoError(t, err)
+		merged, err := s.ImportSessionStore(session.ID)
+		require.NoError(t, err)
+		_, err = s.CommitImportSession(session.ID, merged, false)
+		require.NoError(t, err)
+		require.Equal(t, 2, writtenAccounts(s, accounts))
+
+		// only the account added by the session is removed
+		require.NoError(t, s.AbortImportSession(session.ID))
+		_, err = s.AccountByPublicKey(accounts[0].ValidatorPublicKey())
+		require.NoError(t, err)
+		_, err = s.AccountByPublicKey(accounts[1].ValidatorPublicKey())
+		require.Equal(t, hd.ErrAccountNotFound, err)
+		wallet, err := s.OpenWallet()
+		require.NoError(t, err)
+		require.Len(t, wallet.Accounts(), 1)
+	})
+
+	t.Run("chunks mismatch", func(t *testing.T) {
+		_, _, accounts := baseKeyVault(seed, t)
+		s := store.NewHashicorpVaultStore(context.Background(), getStorage(), core.PraterNetwork)
+
+		session, err := s.BeginImportSession()
+		require.NoError(t, err)
+		_, err = s.AddImportChunk(session.ID, importChunks(t, core.PraterNetwork, accounts[:1])[0])
+		require.NoError(t, err)
+		_, err = s.AddImportChunk(session.ID, importChunks(t, core.MainNetwork, accounts[1:])[0])
+		require.Equal(t, store.ErrNetworkMismatch, errors.Cause(err))
+
+		session, err = s.ImportSession(session.ID)
+		require.NoError(t, err)
+		require.Equal(t, 1, session.Chunks)
+	})
+
+	t.Run("empty session", func(t *testing.T) {
+		s := store.NewHashicorpVaultStore(context.Background(), getStorage(), core.PraterNetwork)
+		session, err := s.BeginImportSession()
+		require.NoError(t, err)
+
+		_, err = s.ImportSessionStore(session.ID)
+		require.Equal(t, store.ErrImportSessionEmpty, err)
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		_, _, accounts := baseKeyVault(seed, t)
+		storage := getStorage()
+		s := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork)
+
+		session, err := s.BeginImportSession()
+		require.NoError(t, err)
+		_, err = s.AddImportChunk(session.ID, importChunks(t, core.PraterNetwork, accounts[:1])[0])
+		require.NoError(t, err)
+
+		require.NoError(t, s.AbortImportSession(session.ID))
+		keys, err := storage.List(context.Background(), "")
+		require.NoError(t, err)
+		require.Empty(t, keys)
+		require.Equal(t, store.ErrImportSessionNotFound, s.AbortImportSession(session.ID))
+		_, err = s.AddImportChunk(session.ID, importChunks(t, core.PraterNetwork, accounts[:1])[0])
+		require.Equal(t, store.ErrImportSessionNotFound, err)
+	})
+}
@@ -0,0 +1,167 @@
+package store_test
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/stores/inmemory"
+	"github.com/bloxapp/eth2-key-manager/wallets/nd"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/backend/store"
+)
+
+func saveSlashingData(t *testing.T, s core.SlashingStore, pubKey []byte, source, target phase0.Epoch, proposal phase0.Slot) {
+	require.NoError(t, s.SaveHighestAttestation(pubKey, &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: source},
+		Target: &phase0.Checkpoint{Epoch: target},
+	}))
+	require.NoError(t, s.SaveHighestProposal(pubKey, proposal))
+}
+
+func requireImportStatuses(t *testing.T, report *store.ImportReport, accounts []core.ValidatorAccount, statuses ...string) {
+	require.Len(t, report.Accounts, len(statuses))
+	for i, status := range statuses {
+		var accountReport *store.ImportAccountReport
+		for _, r := range report.Accounts {
+			if r.PublicKey == hex.EncodeToString(accounts[i].ValidatorPublicKey()) {
+				accountReport = r
+			}
+		}
+		require.NotNil(t, accountReport)
+		require.Equal(t, status, accountReport.Status, accountReport.Reason)
+	}
+}
+
+func TestImportFromInMemoryStore(t *testing.T) {
+	seed := _byteArray("0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1fff")
+
+	t.Run("dry run", func(t *testing.T) {
+		inMemStore, _, accounts := baseKeyVault(seed, t)
+		storage := getStorage()
+		s := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork)
+
+		report, err := s.ImportFromInMemoryStore(inMemStore, true)
+		require.NoError(t, err)
+		require.True(t, report.DryRun)
+		requireImportStatuses(t, report, accounts, store.ImportStatusAdded, store.ImportStatusAdded)
+
+		keys, err := storage.List(context.Background(), "")
+		require.NoError(t, err)
+		require.Empty(t, keys)
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		inMemStore, _, accounts := baseKeyVault(seed, t)
+		saveSlashingData(t, inMemStore, accounts[0].ValidatorPublicKey(), 1, 2, 10)
+		s := store.NewHashicorpVaultStore(context.Background(), getStorage(), core.PraterNetwork)
+
+		report, err := s.ImportFromInMemoryStore(inMemStore, false)
+		require.NoError(t, err)
+		requireImportStatuses(t, report, accounts, store.ImportStatusAdded, store.ImportStatusAdded)
+
+		report, err = s.ImportFromInMemoryStore(inMemStore, false)
+		require.NoError(t, err)
+		requireImportStatuses(t, report, accounts, store.ImportStatusSkippedDuplicate, store.ImportStatusSkippedDuplicate)
+		require.Equal(t, map[string]int{
+			store.ImportStatusAdded:              0,
+			store.ImportStatusSkippedDuplicate:   2,
+			store.ImportStatusSlashingDataMerged: 0,
+			store.ImportStatusConflicted:         0,
+		}, report.Summary())
+	})
+
+	t.Run("slashing data merged", func(t *testing.T) {
+		inMemStore, _, accounts := baseKeyVault(seed, t)
+		pubKey := accounts[0].ValidatorPublicKey()
+		saveSlashingData(t, inMemStore, pubKey, 1, 2, 10)
+		s := store.NewHashicorpVaultStore(context.Background(), getStorage(), core.PraterNetwork)
+		_, err := s.ImportFromInMemoryStore(inMemStore, false)
+		require.NoError(t, err)
+
+		// the stored data is only raised
+		saveSlashingData(t, inMemStore, pubKey, 0, 5, 5)
+		for _, dryRun := range []bool{true, false} {
+			report, err := s.ImportFromInMemoryStore(inMemStore, dryRun)
+			require.NoError(t, err)
+			requireImportStatuses(t, report, accounts, store.ImportStatusSlashingDataMerged, store.ImportStatusSkippedDuplicate)
+		}
+
+		att, found, err := s.RetrieveHighestAttestation(pubKey)
+		require.NoError(t, err)
+		require.True(t, found)
+		require.EqualValues(t, 1, att.Source.Epoch)
+		require.EqualValues(t, 5, att.Target.Epoch)
+		proposal, found, err := s.RetrieveHighestProposal(pubKey)
+		require.NoError(t, err)
+		require.True(t, found)
+		require.EqualValues(t, 10, proposal)
+	})
+
+	t.Run("conflicts", func(t *testing.T) {
+		inMemStore, _, _ := baseKeyVault(seed, t)
+		s := store.NewHashicorpVaultStore(context.Background(), getStorage(), core.PraterNetwork)
+		_, err := s.ImportFromInMemoryStore(inMemStore, false)
+		require.NoError(t, err)
+
+		// same names and indexes from another seed
+		otherStore, _, otherAccounts := baseKeyVault(_byteArray("0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1fdf"), t)
+		report, err := s.ImportFromInMemoryStore(otherStore, false)
+		require.NoError(t, err)
+		requireImportStatuses(t, report, otherAccounts, store.ImportStatusConflicted, store.ImportStatusConflicted)
+		require.Contains(t, report.Accounts[0].Reason, "is used by account")
+
+		_, err = s.AccountByPublicKey(otherAccounts[0].ValidatorPublicKey())
+		require.Error(t, err)
+		wallet, err := s.OpenWallet()
+		require.NoError(t, err)
+		require.Len(t, wallet.Accounts(), 2)
+	})
+
+	t.Run("conflicts within the import", func(t *testing.T) {
+		inMemStore, wallet, _ := baseKeyVault(seed, t)
+		index := 0
+		_, err := wallet.CreateValidatorAccount(_byteArray("0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1fdf"), &index)
+		require.NoError(t, err)
+		s := store.NewHashicorpVaultStore(context.Background(), getStorage(), core.PraterNetwork)
+
+		report, err := s.ImportFromInMemoryStore(inMemStore, false)
+		require.NoError(t, err)
+		require.Equal(t, 2, report.Summary()[store.ImportStatusAdded])
+		require.Equal(t, 1, report.Summary()[store.ImportStatusConflicted])
+
+		// the wallet holds the added accounts only
+		storedWallet, err := s.OpenWallet()
+		require.NoError(t, err)
+		require.Len(t, storedWallet.Accounts(), 2)
+		for _, accountReport := range report.Accounts {
+			_, err := storedWallet.AccountByPublicKey(accountReport.PublicKey)
+			require.Equal(t, accountReport.Status == store.ImportStatusAdded, err == nil)
+		}
+	})
+
+	t.Run("network mismatch", func(t *testing.T) {
+		inMemStore, _, _ := baseKeyVault(seed, t)
+		s := store.NewHashicorpVaultStore(context.Background(), getStorage(), core.MainNetwork)
+
+		_, err := s.ImportFromInMemoryStore(inMemStore, false)
+		require.Equal(t, store.ErrNetworkMismatch, errors.Cause(err))
+		require.EqualError(t, err, "failed to import prater accounts into mainnet storage: network mismatch")
+	})
+
+	t.Run("wallet type mismatch", func(t *testing.T) {
+		inMemStore, _, _ := baseKeyVault(seed, t)
+		s := store.NewHashicorpVaultStore(context.Background(), getStorage(), core.PraterNetwork)
+		_, err := s.ImportFromInMemoryStore(inMemStore, false)
+		require.NoError(t, err)
+
+		ndStore := inmemory.NewInMemStore(core.PraterNetwork)
+		require.NoError(t, ndStore.SaveWallet(nd.NewWallet(&core.WalletContext{Storage: ndStore})))
+		_, err = s.ImportFromInMemoryStore(ndStore, true)
+		require.Equal(t, store.ErrWalletTypeMismatch, errors.Cause(err))
+	})
+}
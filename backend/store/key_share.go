@@ -0,0 +1,115 @@
+package store
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Paths of the key shares, keyed by hex encoded group public key,
+// and of the group public keys of the share accounts, keyed by hex encoded share public key.
+const (
+	KeyShareBase        = "keyshares/"
+	KeySharePath        = KeyShareBase + "%s"
+	KeyShareAccountBase = "keyshare-accounts/"
+	KeyShareAccountPath = KeyShareAccountBase + "%s"
+)
+
+// KeyShare is a share of a BLS key split between several signers, any Threshold of them sign for the group key.
+// The share itself is a wallet account, the group key is not held by the wallet.
+// The public keys are hex encoded, PeerPublicKeys are the public keys of the other shares by share index.
+type KeyShare struct {
+	GroupPublicKey string            `json:"group_public_key"`
+	SharePublicKey string            `json:"share_public_key"`
+	Index          uint64            `json:"index"`
+	Threshold      uint64            `json:"threshold"`
+	PeerPublicKeys map[uint64]string `json:"peer_public_keys"`
+}
+
+// SaveKeyShare stores the given key share.
+func (store *HashicorpVaultStore) SaveKeyShare(share *KeyShare) error {
+	data, err := json.Marshal(share)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal key share")
+	}
+	if err := store.putEntry(fmt.Sprintf(KeyShareAccountPath, share.SharePublicKey), []byte(share.GroupPublicKey)); err != nil {
+		return err
+	}
+	return store.putEntry(fmt.Sprintf(KeySharePath, share.GroupPublicKey), data)
+}
+
+// KeyShare returns the key share of the given group public key, nil if there is none.
+func (store *HashicorpVaultStore) KeyShare(groupPubKey []byte) (*KeyShare, error) {
+	path := fmt.Sprintf(KeySharePath, hex.EncodeToString(groupPubKey))
+	entry, err := store.storage.Get(store.ctx, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get record with path '%s'", path)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var share KeyShare
+	if err := json.Unmarshal(entry.Value, &share); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal key share")
+	}
+	return &share, nil
+}
+
+// KeyShareGroup returns the group public key of the given share account, nil if the account isn't a key share.
+func (store *HashicorpVaultStore) KeyShareGroup(sharePubKey []byte) ([]byte, error) {
+	path := fmt.Sprintf(KeyShareAccountPath, hex.EncodeToString(sharePubKey))
+	entry, err := store.storage.Get(store.ctx, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get record with path '%s'", path)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	groupPubKey, err := hex.DecodeString(string(entry.Value))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode group public key")
+	}
+	return groupPubKey, nil
+}
+
+// ListKeyShares returns all the key shares.
+func (store *HashicorpVaultStore) ListKeyShares() ([]*KeyShare, error) {
+	keys, err := store.storage.List(store.ctx, KeyShareBase)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list records with prefix '%s'", KeyShareBase)
+	}
+
+	shares := make([]*KeyShare, 0, len(keys))
+	for _, key := range keys {
+		groupPubKey, err := hex.DecodeString(strings.TrimSuffix(key, "/"))
+		if err != nil {
+			continue
+		}
+		share, err := store.KeyShare(groupPubKey)
+		if err != nil {
+			return nil, err
+		}
+		if share != nil {
+			shares = append(shares, share)
+		}
+	}
+	return shares, nil
+}
+
+// DeleteKeyShare deletes the given key share, the share account is left as it is.
+func (store *HashicorpVaultStore) DeleteKeyShare(share *KeyShare) error {
+	path := fmt.Sprintf(KeyShareAccountPath, share.SharePublicKey)
+	if err := store.storage.Delete(store.ctx, path); err != nil {
+		return errors.Wrapf(err, "failed to delete record with path '%s'", path)
+	}
+	path = fmt.Sprintf(KeySharePath, share.GroupPublicKey)
+	if err := store.storage.Delete(store.ctx, path); err != nil {
+		return errors.Wrapf(err, "failed to delete record with path '%s'", path)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// SchemaVersionPath is the path of the storage schema version.
+const SchemaVersionPath = "schema/version"
+
+// Migration is a storage migration step.
+// Steps must be idempotent, an interrupted migration is run again from its last recorded version.
+type Migration struct {
+	Version int
+	Name    string
+	Migrate func(store *HashicorpVaultStore) error
+}
+
+// Migrations are the storage migrations ordered by version, the version of the last one is the current schema version.
+// New steps must be appended with the next version.
+var Migrations = []*Migration{
+	{
+		Version: 1,
+		Name:    "account public key index",
+		Migrate: migrateAccountIndex,
+	},
+	{
+		Version: 2,
+		Name:    "encrypted exits",
+		Migrate: migrateExits,
+	},
+}
+
+// Paths of the exits before they were moved under ExitBase.
+const (
+	legacyExitEscrowBase  = "exit-escrow/"
+	legacyExitRequestBase = "exits/"
+)
+
+// schemaVersion is the storage format of the schema version.
+type schemaVersion struct {
+	Version int `json:"version"`
+}
+
+// LatestSchemaVersion returns the schema version after running all the migrations.
+func LatestSchemaVersion() int {
+	return Migrations[len(Migrations)-1].Version
+}
+
+// SchemaVersion returns the schema version recorded in the storage, 0 for storages written before versioning.
+func (store *HashicorpVaultStore) SchemaVersion() (int, error) {
+	entry, err := store.storage.Get(store.ctx, SchemaVersionPath)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get schema version")
+	}
+	if entry == nil {
+		return 0, nil
+	}
+
+	var version schemaVersion
+	if err := json.Unmarshal(entry.Value, &version); err != nil {
+		return 0, errors.Wrap(err, "failed to unmarshal schema version")
+	}
+	return version.Version, nil
+}
+
+// setSchemaVersion records the given schema version.
+func (store *HashicorpVaultStore) setSchemaVersion(version int) error {
+	data, err := json.Marshal(&schemaVersion{Version: version})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal schema version")
+	}
+	return store.putEntry(SchemaVersionPath, data)
+}
+
+// PendingMigrations returns the given migrations which are not applied yet.
+func (store *HashicorpVaultStore) PendingMigrations(migrations []*Migration) ([]*Migration, error) {
+	version, err := store.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if len(migrations) > 0 && version > migrations[len(migrations)-1].Version {
+		return nil, errors.Errorf("storage schema version %d is newer than the supported version %d", version, migrations[len(migrations)-1].Version)
+	}
+
+	pending := make([]*Migration, 0)
+	for _, migration := range migrations {
+		if migration.Version > version {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate runs the pending storage migrations, see RunMigrations.
+func (store *HashicorpVaultStore) Migrate() ([]*Migration, error) {
+	return store.RunMigrations(Migrations)
+}
+
+// RunMigrations runs the pending given migrations in order and returns the applied ones.
+// The schema version is recorded after each step, so that a failed migration resumes from it.
+func (store *HashicorpVaultStore) RunMigrations(migrations []*Migration) ([]*Migration, error) {
+	pending, err := store.PendingMigrations(migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]*Migration, 0, len(pending))
+	for _, migration := range pending {
+		if err := migration.Migrate(store); err != nil {
+			return applied, errors.Wrapf(err, "failed to migrate storage to version %d (%s)", migration.Version, migration.Name)
+		}
+		if err := store.setSchemaVersion(migration.Version); err != nil {
+			return applied, errors.Wrapf(err, "failed to record schema version %d", migration.Version)
+		}
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+// migrateAccountIndex builds the public key index of the accounts stored before it.
+func migrateAccountIndex(store *HashicorpVaultStore) error {
+	entry, err := store.storage.Get(store.ctx, WalletDataPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to get wallet data")
+	}
+
+	// Without wallet, the index is complete as the accounts are indexed when saved.
+	if entry == nil {
+		return store.putEntry(AccountIndexReadyPath, []byte{1})
+	}
+	return store.RebuildAccountIndex()
+}
+
+// migrateExits moves the escrowed exits and the exit requests under ExitBase, seal-wrapped and encrypted.
+// An entry is deleted once moved, the entries left by an interrupted migration are moved again.
+func migrateExits(store *HashicorpVaultStore) error {
+	for legacyBase, base := range map[string]string{
+		legacyExitEscrowBase:  ExitEscrowBase,
+		legacyExitRequestBase: ExitRequestBase,
+	} {
+		keys, err := store.storage.List(store.ctx, legacyBase)
+		if err != nil {
+			return errors.Wrapf(err, "failed to list records with prefix '%s'", legacyBase)
+		}
+		for _, key := range keys {
+			legacyPath := legacyBase + key
+			entry, err := store.storage.Get(store.ctx, legacyPath)
+			if err != nil {
+				return errors.Wrapf(err, "failed to get record with path '%s'", legacyPath)
+			}
+			if entry == nil {
+				continue
+			}
+
+			path := base + key
+			value, err := store.encryptValue(path, entry.Value)
+			if err != nil {
+				return errors.Wrapf(err, "failed to encrypt record with path '%s'", path)
+			}
+			if err := store.putEntry(path, value); err != nil {
+				return errors.Wrapf(err, "failed to store record with path '%s'", path)
+			}
+			if err := store.storage.Delete(store.ctx, legacyPath); err != nil {
+				return errors.Wrapf(err, "failed to delete record with path '%s'", legacyPath)
+			}
+		}
+	}
+	return nil
+}
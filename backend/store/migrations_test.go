@@ -0,0 +1,174 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/backend/store"
+)
+
+func TestMigrations(t *testing.T) {
+	t.Run("fresh storage", func(t *testing.T) {
+		s := store.NewHashicorpVaultStore(context.Background(), getStorage(), core.PraterNetwork)
+
+		version, err := s.SchemaVersion()
+		require.NoError(t, err)
+		require.Equal(t, 0, version)
+		pending, err := s.PendingMigrations(store.Migrations)
+		require.NoError(t, err)
+		require.Equal(t, store.Migrations, pending)
+
+		applied, err := s.Migrate()
+		require.NoError(t, err)
+		require.Equal(t, store.Migrations, applied)
+		version, err = s.SchemaVersion()
+		require.NoError(t, err)
+		require.Equal(t, store.LatestSchemaVersion(), version)
+
+		// nothing left to apply
+		applied, err = s.Migrate()
+		require.NoError(t, err)
+		require.Empty(t, applied)
+	})
+
+	t.Run("newer schema version", func(t *testing.T) {
+		storage := getStorage()
+		require.NoError(t, storage.Put(context.Background(), &logical.StorageEntry{
+			Key:   store.SchemaVersionPath,
+			Value: []byte(`{"version":1000}`),
+		}))
+
+		_, err := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork).Migrate()
+		require.EqualError(t, err, fmt.Sprintf("storage schema version 1000 is newer than the supported version %d", store.LatestSchemaVersion()))
+	})
+
+	t.Run("resume after failure", func(t *testing.T) {
+		s := store.NewHashicorpVaultStore(context.Background(), getStorage(), core.PraterNetwork)
+
+		runs := make(map[int]int)
+		fail := true
+		step := func(version int) func(*store.HashicorpVaultStore) error {
+			return func(*store.HashicorpVaultStore) error {
+				runs[version]++
+				if version == 2 && fail {
+					return errors.New("interrupted")
+				}
+				return nil
+			}
+		}
+		migrations := []*store.Migration{
+			{Version: 1, Name: "first", Migrate: step(1)},
+			{Version: 2, Name: "second", Migrate: step(2)},
+			{Version: 3, Name: "third", Migrate: step(3)},
+		}
+
+		applied, err := s.RunMigrations(migrations)
+		require.EqualError(t, err, "failed to migrate storage to version 2 (second): interrupted")
+		require.Equal(t, migrations[:1], applied)
+		version, err := s.SchemaVersion()
+		require.NoError(t, err)
+		require.Equal(t, 1, version)
+
+		fail = false
+		applied, err = s.RunMigrations(migrations)
+		require.NoError(t, err)
+		require.Equal(t, migrations[1:], applied)
+		require.Equal(t, map[int]int{1: 1, 2: 2, 3: 1}, runs)
+		version, err = s.SchemaVersion()
+		require.NoError(t, err)
+		require.Equal(t, 3, version)
+	})
+}
+
+func TestMigrateAccountIndex(t *testing.T) {
+	migration := store.Migrations[0]
+	require.Equal(t, 1, migration.Version)
+
+	t.Run("legacy storage", func(t *testing.T) {
+		storage := getStorage()
+		s := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork)
+		createAccounts(t, s, 3)
+
+		// drop the index, as written before it existed
+		keys, err := storage.List(context.Background(), store.AccountIndexBase)
+		require.NoError(t, err)
+		for _, key := range keys {
+			require.NoError(t, storage.Delete(context.Background(), store.AccountIndexBase+key))
+		}
+
+		// idempotent
+		for i := 0; i < 2; i++ {
+			require.NoError(t, migration.Migrate(s))
+
+			keys, err = storage.List(context.Background(), store.AccountIndexBase)
+			require.NoError(t, err)
+			require.Len(t, keys, 3)
+			entry, err := storage.Get(context.Background(), store.AccountIndexReadyPath)
+			require.NoError(t, err)
+			require.NotNil(t, entry)
+		}
+	})
+
+	t.Run("no wallet", func(t *testing.T) {
+		storage := getStorage()
+		s := store.NewHashicorpVaultStore(context.Background(), storage, core.PraterNetwork)
+		require.NoError(t, migration.Migrate(s))
+
+		entry, err := storage.Get(context.Background(), store.AccountIndexReadyPath)
+		require.NoError(t, err)
+		require.NotNil(t, entry)
+
+		// accounts added later are indexed when saved
+		accounts := createAccounts(t, s, 1)
+		found, err := s.AccountByPublicKey(accounts[0].ValidatorPublicKey())
+		require.NoError(t, err)
+		require.Equal(t, accounts[0].ID(), found.ID())
+	})
+}
+
+func TestMigrateExits(t *testing.T) {
+	migration := store.Migrations[1]
+	require.Equal(t, 2, migration.Version)
+
+	storage := newSealWrapStorage()
+	s := getEncryptedStore(storage, "password")
+	pubKey := "95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf"
+
+	// the exits as written before they were encrypted
+	legacy := map[string]string{
+		"exit-escrow/" + pubKey: `{"public_key":"` + pubKey + `","epoch":10,"signature":"cd"}`,
+		"exits/request-id":      `{"id":"request-id","public_key":"` + pubKey + `","status":"approved","signature":"cd"}`,
+	}
+	for key, value := range legacy {
+		require.NoError(t, storage.Put(context.Background(), &logical.StorageEntry{Key: key, Value: []byte(value)}))
+	}
+
+	// idempotent
+	for i := 0; i < 2; i++ {
+		require.NoError(t, migration.Migrate(s))
+
+		for key := range legacy {
+			entry, err := storage.Get(context.Background(), key)
+			require.NoError(t, err)
+			require.Nil(t, entry)
+		}
+		exit, err := s.EscrowedExit(pubKey)
+		require.NoError(t, err)
+		require.Equal(t, "cd", exit.Signature)
+		request, err := s.ExitRequest("request-id")
+		require.NoError(t, err)
+		require.Equal(t, "cd", request.Signature)
+
+		path := fmt.Sprintf(store.ExitRequestPath, "request-id")
+		entry, err := storage.Get(context.Background(), path)
+		require.NoError(t, err)
+		require.True(t, storage.sealWrapped[path])
+		require.Contains(t, string(entry.Value), "envelope_version")
+	}
+}
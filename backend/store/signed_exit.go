@@ -0,0 +1,73 @@
+package store
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/pkg/errors"
+)
+
+// Paths of the voluntary exits signed for the accounts, keyed by hex encoded public key.
+// The records don't hold the signatures, they are kept out of ExitBase.
+const (
+	SignedExitBase = "signed-exits/"
+	SignedExitPath = SignedExitBase + "%s"
+)
+
+// SignedExit records the voluntary exit signed for an account, the account doesn't sign attestations
+// and blocks once the exit may be effective.
+type SignedExit struct {
+	Epoch          phase0.Epoch          `json:"epoch"`
+	ValidatorIndex phase0.ValidatorIndex `json:"validator_index"`
+	SignedAt       time.Time             `json:"signed_at"`
+}
+
+// InclusionEpoch returns the first epoch the exit may be included at, its epoch or the wall-clock epoch
+// it was signed at if later, as an exit of a past epoch can't be included before it is signed.
+func (exit *SignedExit) InclusionEpoch(network core.Network) phase0.Epoch {
+	signedEpoch := network.EstimatedEpochAtSlot(network.EstimatedSlotAtTime(exit.SignedAt.Unix()))
+	if signedEpoch > exit.Epoch {
+		return signedEpoch
+	}
+	return exit.Epoch
+}
+
+// SaveSignedExit records the given voluntary exit of the given account.
+// An exit that may be included earlier is kept, as it is the first one to be effective.
+func (store *HashicorpVaultStore) SaveSignedExit(pubKey []byte, exit *SignedExit) error {
+	previous, err := store.SignedExit(pubKey)
+	if err != nil {
+		return err
+	}
+	if previous != nil && previous.InclusionEpoch(store.network) <= exit.InclusionEpoch(store.network) {
+		return nil
+	}
+
+	data, err := json.Marshal(exit)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal signed exit")
+	}
+	return store.putEntry(fmt.Sprintf(SignedExitPath, hex.EncodeToString(pubKey)), data)
+}
+
+// SignedExit returns the voluntary exit signed for the given account, nil if there is none.
+func (store *HashicorpVaultStore) SignedExit(pubKey []byte) (*SignedExit, error) {
+	path := fmt.Sprintf(SignedExitPath, hex.EncodeToString(pubKey))
+	entry, err := store.storage.Get(store.ctx, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get record with path '%s'", path)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var exit SignedExit
+	if err := json.Unmarshal(entry.Value, &exit); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal signed exit")
+	}
+	return &exit, nil
+}
@@ -6,7 +6,6 @@ import (
 
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	ssz "github.com/ferranbt/fastssz"
-	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/pkg/errors"
 )
 
@@ -32,11 +31,7 @@ func (store *HashicorpVaultStore) SaveHighestAttestation(pubKey []byte, attestat
 		return errors.Wrap(err, "failed to marshal attestation request")
 	}
 
-	return store.storage.Put(store.ctx, &logical.StorageEntry{
-		Key:      path,
-		Value:    data,
-		SealWrap: false,
-	})
+	return store.putEntry(path, data)
 }
 
 // RetrieveHighestAttestation retrieves highest attestation
@@ -80,11 +75,7 @@ func (store *HashicorpVaultStore) SaveHighestProposal(pubKey []byte, slot phase0
 		return errors.Wrap(err, "failed to marshal proposal request")
 	}
 
-	return store.storage.Put(store.ctx, &logical.StorageEntry{
-		Key:      path,
-		Value:    data,
-		SealWrap: false,
-	})
+	return store.putEntry(path, data)
 }
 
 // RetrieveHighestProposal implements Storage interface.
@@ -2,10 +2,10 @@ package store
 
 import (
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 
-	vault "github.com/bloxapp/eth2-key-manager"
 	"github.com/bloxapp/eth2-key-manager/core"
 	"github.com/bloxapp/eth2-key-manager/encryptor"
 	"github.com/bloxapp/eth2-key-manager/stores/inmemory"
@@ -34,6 +34,8 @@ type HashicorpVaultStore struct {
 
 	encryptor          encryptor.Encryptor
 	encryptionPassword []byte
+	dataKeyCache       *DataKeyCache
+	accountCache       *AccountCache
 }
 
 // NewHashicorpVaultStore is the constructor of HashicorpVaultStore.
@@ -48,80 +50,20 @@ func NewHashicorpVaultStore(ctx context.Context, storage logical.Storage, networ
 
 // FromInMemoryStoreV2 updates HashicorpVaultStore with new accounts.
 func FromInMemoryStoreV2(ctx context.Context, newStorage *inmemory.InMemStore, existingStorage logical.Storage) (*HashicorpVaultStore, error) {
-
-	// Open newStorage wallet
-	newStorageWallet, err := newStorage.OpenWallet()
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to open newStorage wallet")
-	}
-
-	// Get existing hashicorp storage
 	hashicorpStore := NewHashicorpVaultStore(ctx, existingStorage, newStorage.Network())
-	options := vault.KeyVaultOptions{}
-	options.SetStorage(hashicorpStore)
-
-	_, err = vault.OpenKeyVault(&options)
-	// If no existing hashicorp store - use in memory store
-	if err != nil {
-		// Save wallet in hashicorp store
-		err = hashicorpStore.SaveWallet(newStorageWallet)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to save wallet to hashicorp store")
-		}
-	}
-
-	// Open existing wallet
-	existingWallet, err := hashicorpStore.OpenWallet()
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to open existing wallet")
-	}
-
-	// Save new accounts
-	for _, newAccount := range newStorageWallet.Accounts() {
-		// Check if account already exists and don't change it
-		// TODO: how to handle the same account name with index but different public keys?
-		existingAccount, _ := existingWallet.AccountByPublicKey(string(newAccount.ValidatorPublicKey()))
-		if existingAccount != nil {
-			continue
-		}
-
-		// Add validator account in wallet
-		err := existingWallet.AddValidatorAccount(newAccount)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to save account")
-		}
-
-		// Save account in vault
-		if err := hashicorpStore.SaveAccount(newAccount); err != nil {
-			return nil, errors.Wrap(err, "failed to save account")
-		}
-
-		// Save highest attestation
-		highestAtt, found, err := newStorage.RetrieveHighestAttestation(newAccount.ValidatorPublicKey())
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to retrieve highest attestation")
-		}
-		if found && highestAtt != nil {
-			if err := hashicorpStore.SaveHighestAttestation(newAccount.ValidatorPublicKey(), highestAtt); err != nil {
-				return nil, errors.Wrap(err, "failed to save highest attestation")
-			}
-		}
-
-		// Save highest proposal
-		highestProposal, found, err := newStorage.RetrieveHighestProposal(newAccount.ValidatorPublicKey())
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to retrieve highest attestation")
-		}
-		if found && highestProposal != 0 {
-			if err := hashicorpStore.SaveHighestProposal(newAccount.ValidatorPublicKey(), highestProposal); err != nil {
-				return nil, errors.Wrap(err, "failed to save highest proposal")
-			}
-		}
+	if err := hashicorpStore.UpdateFromInMemoryStore(newStorage); err != nil {
+		return nil, err
 	}
-
 	return hashicorpStore, nil
 }
 
+// UpdateFromInMemoryStore adds the new accounts of the given in-memory store and their slashing data,
+// see ImportFromInMemoryStore.
+func (store *HashicorpVaultStore) UpdateFromInMemoryStore(newStorage *inmemory.InMemStore) error {
+	_, err := store.ImportFromInMemoryStore(newStorage, false)
+	return err
+}
+
 // FromInMemoryStore creates the HashicorpVaultStore based on the given in-memory store.
 func FromInMemoryStore(ctx context.Context, newStorage *inmemory.InMemStore, existingStorage logical.Storage) (*HashicorpVaultStore, error) {
 	// first delete old data
@@ -146,6 +88,13 @@ func FromInMemoryStore(ctx context.Context, newStorage *inmemory.InMemStore, exi
 		return nil, err
 	}
 
+	// Create new store
+	newHashicorpVaultStore := NewHashicorpVaultStore(ctx, existingStorage, newStorage.Network())
+
+	if err := newHashicorpVaultStore.deleteAccountIndex(); err != nil {
+		return nil, err
+	}
+
 	if err := existingStorage.Delete(ctx, WalletHighestAttestationPath); err != nil {
 		return nil, err
 	}
@@ -154,9 +103,6 @@ func FromInMemoryStore(ctx context.Context, newStorage *inmemory.InMemStore, exi
 		return nil, err
 	}
 
-	// Create new store
-	newHashicorpVaultStore := NewHashicorpVaultStore(ctx, existingStorage, newStorage.Network())
-
 	// Save wallet
 	wallet, err := newStorage.OpenWallet()
 	if err != nil {
@@ -221,11 +167,7 @@ func (store *HashicorpVaultStore) SaveWallet(wallet core.Wallet) error {
 		return errors.Wrap(err, "failed to marshal wallet")
 	}
 
-	return store.storage.Put(store.ctx, &logical.StorageEntry{
-		Key:      WalletDataPath,
-		Value:    data,
-		SealWrap: false,
-	})
+	return store.putEntry(WalletDataPath, data)
 }
 
 // OpenWallet returns nil,nil if no wallet was found
@@ -266,11 +208,18 @@ func (store *HashicorpVaultStore) SaveAccount(account core.ValidatorAccount) err
 		return errors.Wrap(err, "failed to marshal account object")
 	}
 
-	return store.storage.Put(store.ctx, &logical.StorageEntry{
-		Key:      fmt.Sprintf(AccountPath, account.ID().String()),
-		Value:    data,
-		SealWrap: false,
-	})
+	path := fmt.Sprintf(AccountPath, account.ID().String())
+	value, err := store.encryptValue(path, data)
+	if err != nil {
+		return errors.Wrap(err, "failed to encrypt account")
+	}
+	if err := store.putEntry(path, value); err != nil {
+		return err
+	}
+	if store.accountCache != nil {
+		store.accountCache.delete(account.ValidatorPublicKey())
+	}
+	return store.saveAccountIndex(account)
 }
 
 // OpenAccount opens an account by the given ID. Returns nil,nil if no account was found.
@@ -286,10 +235,15 @@ func (store *HashicorpVaultStore) OpenAccount(accountID uuid.UUID) (core.Validat
 		return nil, nil
 	}
 
+	data, err := store.decryptValue(path, entry.Value)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt account")
+	}
+
 	// un-marshal
 	var ret wallets.HDAccount
 	ret.SetContext(store.freshContext())
-	if err := json.Unmarshal(entry.Value, &ret); err != nil {
+	if err := json.Unmarshal(data, &ret); err != nil {
 		return nil, errors.Wrap(err, "failed to unmarshal HD account object")
 	}
 	return &ret, nil
@@ -297,6 +251,19 @@ func (store *HashicorpVaultStore) OpenAccount(accountID uuid.UUID) (core.Validat
 
 // DeleteAccount deletes the given account
 func (store *HashicorpVaultStore) DeleteAccount(accountID uuid.UUID) error {
+	// Accounts which can't be opened leave a stale index entry, lookups ignore it.
+	if account, err := store.OpenAccount(accountID); err == nil && account != nil {
+		indexPath := fmt.Sprintf(AccountIndexPath, hex.EncodeToString(account.ValidatorPublicKey()))
+		if err := store.storage.Delete(store.ctx, indexPath); err != nil {
+			return errors.Wrapf(err, "failed to delete record with path '%s'", indexPath)
+		}
+		if store.accountCache != nil {
+			store.accountCache.delete(account.ValidatorPublicKey())
+		}
+	} else if store.accountCache != nil {
+		store.accountCache.Invalidate()
+	}
+
 	path := fmt.Sprintf(AccountPath, accountID)
 	if err := store.storage.Delete(store.ctx, path); err != nil {
 		return errors.Wrapf(err, "failed to delete record with path '%s'", path)
@@ -305,6 +272,7 @@ func (store *HashicorpVaultStore) DeleteAccount(accountID uuid.UUID) error {
 }
 
 // SetEncryptor sets the given encryptor. Could be nil value.
+// The accounts are encrypted by a data key, the data key is encrypted by the encryptor with the given password.
 func (store *HashicorpVaultStore) SetEncryptor(encryptor encryptor.Encryptor, password []byte) {
 	store.encryptor = encryptor
 	store.encryptionPassword = password
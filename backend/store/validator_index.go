@@ -0,0 +1,110 @@
+package store
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+)
+
+// Paths of the validator indexes of the accounts, keyed by hex encoded public key,
+// and of the public keys of the validator indexes, keyed by index.
+const (
+	ValidatorIndexBase        = "validator-indexes/"
+	ValidatorIndexPath        = ValidatorIndexBase + "%s"
+	ValidatorIndexAccountBase = "validator-index-accounts/"
+	ValidatorIndexAccountPath = ValidatorIndexAccountBase + "%d"
+)
+
+// ErrValidatorIndexTaken is returned when the validator index belongs to another account.
+var ErrValidatorIndexTaken = errors.New("validator index belongs to another account")
+
+// SaveValidatorIndex stores the validator index of the given account, replacing the previous one.
+func (store *HashicorpVaultStore) SaveValidatorIndex(pubKey []byte, index phase0.ValidatorIndex) error {
+	owner, err := store.ValidatorIndexAccount(index)
+	if err != nil {
+		return err
+	}
+	if owner != nil && hex.EncodeToString(owner) != hex.EncodeToString(pubKey) {
+		return errors.Wrapf(ErrValidatorIndexTaken, "validator index %d of '%s'", index, hex.EncodeToString(owner))
+	}
+
+	previous, found, err := store.ValidatorIndex(pubKey)
+	if err != nil {
+		return err
+	}
+	if found && previous != index {
+		path := fmt.Sprintf(ValidatorIndexAccountPath, previous)
+		if err := store.storage.Delete(store.ctx, path); err != nil {
+			return errors.Wrapf(err, "failed to delete record with path '%s'", path)
+		}
+	}
+
+	if err := store.putEntry(fmt.Sprintf(ValidatorIndexAccountPath, index), []byte(hex.EncodeToString(pubKey))); err != nil {
+		return err
+	}
+	return store.putEntry(fmt.Sprintf(ValidatorIndexPath, hex.EncodeToString(pubKey)), []byte(strconv.FormatUint(uint64(index), 10)))
+}
+
+// ValidatorIndex returns the validator index of the given account, false if it has none.
+func (store *HashicorpVaultStore) ValidatorIndex(pubKey []byte) (phase0.ValidatorIndex, bool, error) {
+	path := fmt.Sprintf(ValidatorIndexPath, hex.EncodeToString(pubKey))
+	entry, err := store.storage.Get(store.ctx, path)
+	if err != nil {
+		return 0, false, errors.Wrapf(err, "failed to get record with path '%s'", path)
+	}
+	if entry == nil {
+		return 0, false, nil
+	}
+
+	index, err := strconv.ParseUint(string(entry.Value), 10, 64)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "failed to parse validator index")
+	}
+	return phase0.ValidatorIndex(index), true, nil
+}
+
+// ValidatorIndexAccount returns the public key of the account of the given validator index, nil if there is none.
+func (store *HashicorpVaultStore) ValidatorIndexAccount(index phase0.ValidatorIndex) ([]byte, error) {
+	path := fmt.Sprintf(ValidatorIndexAccountPath, index)
+	entry, err := store.storage.Get(store.ctx, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get record with path '%s'", path)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	pubKey, err := hex.DecodeString(string(entry.Value))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode public key")
+	}
+	return pubKey, nil
+}
+
+// ListValidatorIndexes returns the validator indexes of the accounts by hex encoded public key.
+func (store *HashicorpVaultStore) ListValidatorIndexes() (map[string]phase0.ValidatorIndex, error) {
+	keys, err := store.storage.List(store.ctx, ValidatorIndexBase)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list records with prefix '%s'", ValidatorIndexBase)
+	}
+
+	indexes := make(map[string]phase0.ValidatorIndex, len(keys))
+	for _, key := range keys {
+		pubKey, err := hex.DecodeString(strings.TrimSuffix(key, "/"))
+		if err != nil {
+			continue
+		}
+		index, found, err := store.ValidatorIndex(pubKey)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			indexes[hex.EncodeToString(pubKey)] = index
+		}
+	}
+	return indexes, nil
+}
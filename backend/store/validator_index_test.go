@@ -0,0 +1,79 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/backend/store"
+)
+
+func TestValidatorIndex(t *testing.T) {
+	s := store.NewHashicorpVaultStore(context.Background(), getStorage(), core.PraterNetwork)
+	pubKey := _byteArray("95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf")
+	otherPubKey := _byteArray("95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcd")
+
+	_, found, err := s.ValidatorIndex(pubKey)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, s.SaveValidatorIndex(pubKey, 42))
+	index, found, err := s.ValidatorIndex(pubKey)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.EqualValues(t, 42, index)
+
+	err = s.SaveValidatorIndex(otherPubKey, 42)
+	require.Equal(t, store.ErrValidatorIndexTaken, errors.Cause(err))
+
+	// the previous index is released
+	require.NoError(t, s.SaveValidatorIndex(pubKey, 43))
+	owner, err := s.ValidatorIndexAccount(42)
+	require.NoError(t, err)
+	require.Nil(t, owner)
+	require.NoError(t, s.SaveValidatorIndex(otherPubKey, 42))
+
+	indexes, err := s.ListValidatorIndexes()
+	require.NoError(t, err)
+	require.Equal(t, map[string]phase0.ValidatorIndex{
+		"95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf": 43,
+		"95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcd": 42,
+	}, indexes)
+}
+
+func TestSignedExit(t *testing.T) {
+	s := store.NewHashicorpVaultStore(context.Background(), getStorage(), core.PraterNetwork)
+	pubKey := _byteArray("95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf")
+
+	exit, err := s.SignedExit(pubKey)
+	require.NoError(t, err)
+	require.Nil(t, exit)
+
+	// signed at the wall-clock epoch 60
+	signedAt := time.Unix(int64(core.PraterNetwork.MinGenesisTime()), 0).Add(60 * 32 * 12 * time.Second).UTC()
+	require.NoError(t, s.SaveSignedExit(pubKey, &store.SignedExit{Epoch: 50, ValidatorIndex: 42, SignedAt: signedAt}))
+
+	// the exit included first is kept
+	require.NoError(t, s.SaveSignedExit(pubKey, &store.SignedExit{Epoch: 40, ValidatorIndex: 42, SignedAt: signedAt.Add(time.Hour)}))
+	exit, err = s.SignedExit(pubKey)
+	require.NoError(t, err)
+	require.EqualValues(t, 50, exit.Epoch)
+
+	require.NoError(t, s.SaveSignedExit(pubKey, &store.SignedExit{Epoch: 200, ValidatorIndex: 42, SignedAt: signedAt}))
+	exit, err = s.SignedExit(pubKey)
+	require.NoError(t, err)
+	require.EqualValues(t, 50, exit.Epoch)
+
+	earlier := signedAt.Add(-time.Hour)
+	require.NoError(t, s.SaveSignedExit(pubKey, &store.SignedExit{Epoch: 40, ValidatorIndex: 42, SignedAt: earlier}))
+	exit, err = s.SignedExit(pubKey)
+	require.NoError(t, err)
+	require.EqualValues(t, 40, exit.Epoch)
+	require.EqualValues(t, 50, exit.InclusionEpoch(core.PraterNetwork))
+	require.Equal(t, earlier, exit.SignedAt)
+}
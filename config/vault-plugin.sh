@@ -10,6 +10,8 @@ vault plugin register \
     -args="--log-format=${LOG_FORMAT}" \
     -args="--log-dsn=${LOG_DSN}" \
     -args="--log-levels=${LOG_LEVELS}" \
+    -args="--log-env=${LOG_ENV}" \
+    -args="--storage-password-file=${STORAGE_PASSWORD_FILE}" \
     secret ethsign
 
 # Enable secrets
@@ -46,14 +46,25 @@ func (e *ServiceError) Error() string {
 	return fmt.Sprintf("%#v", e.Data)
 }
 
-// ErrorValue returns error value from the data
+// ErrorValue returns error value from the data.
+// Coded errors of the plugin carry their message in the data, Vault errors in the errors list.
 func (e *ServiceError) ErrorValue() string {
+	if message, ok := e.DataValue("message").(string); ok {
+		return message
+	}
 	return e.Data["errors"].([]interface{})[0].(string)
 }
 
+// ErrorCode returns the code of the plugin error, empty for Vault errors.
+func (e *ServiceError) ErrorCode() string {
+	code, _ := e.DataValue("code").(string)
+	return code
+}
+
 // DataValue returns "field" value from data
 func (e *ServiceError) DataValue(field string) interface{} {
-	return e.Data["data"].(map[string]interface{})[field]
+	data, _ := e.Data["data"].(map[string]interface{})
+	return data[field]
 }
 
 func init() {
@@ -227,6 +238,40 @@ func (setup *BaseSetup) UpdateConfig(t *testing.T, network core.Network, data ba
 	return respBodyByts, resp.StatusCode
 }
 
+// RegisterValidatorIndexes registers the validator indexes of the accounts, by hex encoded public key.
+func (setup *BaseSetup) RegisterValidatorIndexes(t *testing.T, network core.Network, indexes map[string]uint64) ([]byte, int) {
+	// body
+	body, err := json.Marshal(map[string]interface{}{
+		"validator_indexes": indexes,
+	})
+	require.NoError(t, err)
+
+	// build req
+	targetURL := fmt.Sprintf("%s/v1/ethereum/%s/accounts/validator-indexes", setup.baseURL, network)
+	req, err := http.NewRequest(http.MethodPost, targetURL, bytes.NewBuffer(body))
+	require.NoError(t, err)
+
+	req.Header.Set("Authorization", "Bearer "+setup.RootKey)
+
+	// Do request
+	httpClient := http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+	}
+	resp, err := httpClient.Do(req)
+	require.NoError(t, err)
+
+	// Read response body
+	respBodyByts, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	return respBodyByts, resp.StatusCode
+}
+
 // ReadSlashingStorage reads slashing storage.
 func (setup *BaseSetup) ReadSlashingStorage(t *testing.T, network core.Network) ([]byte, int) {
 	// build req
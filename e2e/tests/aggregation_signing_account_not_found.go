@@ -61,7 +61,7 @@ func (test *AggregationSigningAccountNotFound) Run(t *testing.T) {
 	_, err = setup.Sign("sign", req, core.PraterNetwork)
 	require.Error(t, err)
 	require.IsType(t, &e2e.ServiceError{}, err)
-	require.EqualValues(t, "1 error occurred:\n\t* failed to sign: account not found\n\n", err.(*e2e.ServiceError).ErrorValue())
+	require.EqualValues(t, "failed to sign: account not found", err.(*e2e.ServiceError).ErrorValue())
 }
 
 func (test *AggregationSigningAccountNotFound) serializedReq(pk, root []byte, domain [32]byte, agg *phase0.AggregateAndProof) (map[string]interface{}, error) {
@@ -60,7 +60,7 @@ func (test *AttestationDoubleSigning) Run(t *testing.T) {
 	req, err = test.serializedReq(pubKey, nil, domain, att)
 	require.NoError(t, err)
 	_, err = setup.Sign("sign", req, core.PraterNetwork)
-	expectedErr := "1 error occurred:\n\t* failed to sign: slashable attestation (HighestAttestationVote), not signing\n\n"
+	expectedErr := "failed to sign: slashable attestation (HighestAttestationVote), not signing"
 	require.Error(t, err)
 	require.IsType(t, &e2e.ServiceError{}, err)
 	require.EqualValues(t, expectedErr, err.(*e2e.ServiceError).ErrorValue())
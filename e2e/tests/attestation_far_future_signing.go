@@ -35,8 +35,8 @@ func (test *AttestationFarFutureSigning) Run(t *testing.T) {
 	require.NotNil(t, account)
 	pubKeyBytes := account.ValidatorPublicKey()
 
-	expectedSourceErr := "map[string]interface {}{\"errors\":[]interface {}{\"1 error occurred:\\n\\t* failed to sign: source epoch too far into the future\\n\\n\"}}"
-	expectedTargetErr := "map[string]interface {}{\"errors\":[]interface {}{\"1 error occurred:\\n\\t* failed to sign: target epoch too far into the future\\n\\n\"}}"
+	expectedSourceErr := "failed to sign: source epoch too far into the future"
+	expectedTargetErr := "failed to sign: target epoch too far into the future"
 
 	currentEpoch := core.PraterNetwork.EstimatedCurrentEpoch()
 	futureEpoch := core.PraterNetwork.EstimatedCurrentEpoch() + 1000
@@ -73,7 +73,7 @@ func (test *AttestationFarFutureSigning) testFarFuture(
 	require.NoError(t, err)
 	_, err = setup.Sign("sign", req, core.PraterNetwork)
 	require.NotNil(t, err)
-	require.EqualError(t, err, expectedErr, fmt.Sprintf("actual: %s\n", err.Error()))
+	require.EqualValues(t, expectedErr, err.(*e2e.ServiceError).ErrorValue(), fmt.Sprintf("actual: %s\n", err.Error()))
 }
 
 func (test *AttestationFarFutureSigning) serializedReq(pk, root []byte, domain [32]byte, attestation *phase0.AttestationData) (map[string]interface{}, error) {
@@ -54,8 +54,8 @@ func (test *AttestationNoSlashingDataSigning) Run(t *testing.T) {
 	req, err := test.serializedReq(pubKeyBytes, nil, domain, att)
 	require.NoError(t, err)
 	_, err = setup.Sign("sign", req, core.PraterNetwork)
-	expectedErr := "map[string]interface {}{\"errors\":[]interface {}{\"1 error occurred:\\n\\t* failed to sign: highest attestation data is not found, can't determine if attestation is slashable\\n\\n\"}}"
-	require.EqualError(t, err, expectedErr, fmt.Sprintf("actual: %s\n", err.Error()))
+	expectedErr := "failed to sign: highest attestation data is not found, can't determine if attestation is slashable"
+	require.EqualValues(t, expectedErr, err.(*e2e.ServiceError).ErrorValue(), fmt.Sprintf("actual: %s\n", err.Error()))
 }
 
 func (test *AttestationNoSlashingDataSigning) serializedReq(pk, root []byte, domain [32]byte, attestation *phase0.AttestationData) (map[string]interface{}, error) {
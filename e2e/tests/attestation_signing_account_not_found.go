@@ -53,7 +53,7 @@ func (test *AttestationSigningAccountNotFound) Run(t *testing.T) {
 	res, err := setup.Sign("sign", req, core.PraterNetwork)
 	require.Error(t, err)
 	require.IsType(t, &e2e.ServiceError{}, err)
-	require.EqualValues(t, "1 error occurred:\n\t* failed to sign: account not found\n\n", err.(*e2e.ServiceError).ErrorValue())
+	require.EqualValues(t, "failed to sign: account not found", err.(*e2e.ServiceError).ErrorValue())
 	require.Nil(t, res)
 }
 
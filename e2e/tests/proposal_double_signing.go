@@ -50,7 +50,7 @@ func (test *ProposalDoubleSigning) Run(t *testing.T) {
 	require.IsType(t, &e2e.ServiceError{}, err)
 
 	errValue := err.(*e2e.ServiceError).ErrorValue()
-	protected := errValue == "1 error occurred:\n\t* failed to sign: slashable proposal (HighestProposalVote), not signing\n\n"
+	protected := errValue == "failed to sign: slashable proposal (HighestProposalVote), not signing"
 	require.True(t, protected, err.Error())
 }
 
@@ -42,8 +42,8 @@ func (test *ProposalFarFutureSigning) Run(t *testing.T) {
 	require.NoError(t, err)
 	_, err = setup.Sign("sign", req, core.PraterNetwork)
 	require.NotNil(t, err)
-	expectedErr := "map[string]interface {}{\"errors\":[]interface {}{\"1 error occurred:\\n\\t* failed to sign: proposed block slot too far into the future\\n\\n\"}}"
-	require.EqualError(t, err, expectedErr, fmt.Sprintf("actual: %s\n", err.Error()))
+	expectedErr := "failed to sign: proposed block slot too far into the future"
+	require.EqualValues(t, expectedErr, err.(*e2e.ServiceError).ErrorValue(), fmt.Sprintf("actual: %s\n", err.Error()))
 }
 
 func (test *ProposalFarFutureSigning) serializedReq(pk, root []byte, domain [32]byte, blk *spec.VersionedBeaconBlock) (map[string]interface{}, error) {
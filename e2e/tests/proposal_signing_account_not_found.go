@@ -39,7 +39,7 @@ func (test *ProposalSigningAccountNotFound) Run(t *testing.T) {
 	_, err = setup.Sign("sign", req, core.PraterNetwork)
 	require.Error(t, err)
 	require.IsType(t, &e2e.ServiceError{}, err)
-	require.EqualValues(t, "1 error occurred:\n\t* failed to sign: account not found\n\n", err.(*e2e.ServiceError).ErrorValue())
+	require.EqualValues(t, "failed to sign: account not found", err.(*e2e.ServiceError).ErrorValue())
 }
 
 func (test *ProposalSigningAccountNotFound) serializedReq(pk, root []byte, domain [32]byte, blk *spec.VersionedBeaconBlock) (map[string]interface{}, error) {
@@ -12,13 +12,14 @@ import (
 
 	"github.com/bloxapp/key-vault/utils/encoder"
 
+	"github.com/bloxapp/key-vault/backend"
 	"github.com/bloxapp/key-vault/e2e"
 	"github.com/bloxapp/key-vault/e2e/shared"
 	"github.com/bloxapp/key-vault/keymanager/models"
 )
 
 type slashingHistoryModel struct {
-	Data map[string]string `json:"data"`
+	Data *backend.SlashingHistoryPage `json:"data"`
 }
 
 // SlashingStorageRead tests slashing storage reading endpoint.
@@ -56,9 +57,10 @@ func (test *SlashingStorageRead) Run(t *testing.T) {
 	err = json.Unmarshal(storageBytes, &slashingHistory)
 	require.NoError(t, err)
 
-	pubKeyHistory, ok := slashingHistory.Data[hex.EncodeToString(pubKey)]
-	require.True(t, ok)
-	require.NotEmpty(t, pubKeyHistory)
+	require.Equal(t, 1, slashingHistory.Data.Total)
+	require.Len(t, slashingHistory.Data.Accounts, 1)
+	require.Equal(t, hex.EncodeToString(pubKey), slashingHistory.Data.Accounts[0].PublicKey)
+	require.NotNil(t, slashingHistory.Data.Accounts[0].HighestProposal)
 }
 
 func (test *SlashingStorageRead) serializedReq(pk, root []byte, domain [32]byte, blk *spec.VersionedBeaconBlock) (map[string]interface{}, error) {
@@ -2,6 +2,7 @@ package tests
 
 import (
 	"encoding/hex"
+	"net/http"
 	"testing"
 
 	"github.com/attestantio/go-eth2-client/spec/phase0"
@@ -35,6 +36,12 @@ func (test *VoluntaryExitSigning) Run(t *testing.T) {
 	require.NotNil(t, account)
 	pubKeyBytes := account.ValidatorPublicKey()
 
+	// The exits are only signed for the registered validator index of the account
+	_, statusCode := setup.RegisterValidatorIndexes(t, core.PraterNetwork, map[string]uint64{
+		hex.EncodeToString(pubKeyBytes): 1,
+	})
+	require.Equal(t, http.StatusOK, statusCode)
+
 	// Get wallet
 	wallet, err := storage.OpenWallet()
 	require.NoError(t, err)
@@ -0,0 +1,474 @@
+package keymanager
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/stores/inmemory"
+	"github.com/bloxapp/eth2-key-manager/wallets/hd"
+	"github.com/bloxapp/eth2-key-manager/wallets/nd"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bloxapp/key-vault/backend"
+	"github.com/bloxapp/key-vault/keymanager/models"
+)
+
+// Predefined admin errors
+var (
+	ErrStorageNotUpdated = NewGenericErrorMessage("storage was not updated")
+)
+
+// AdminClient is the administrative client of the key-vault plugin.
+// Unlike KeyManager it is not bound to a single public key and covers every plugin endpoint.
+type AdminClient struct {
+	*transport
+}
+
+// NewAdminClient is the constructor of AdminClient.
+// The public key of the given config is ignored.
+func NewAdminClient(log *logrus.Entry, opts *Config) (*AdminClient, error) {
+	t, err := newTransport(log, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AdminClient{
+		transport: t,
+	}, nil
+}
+
+// Version returns the version of the plugin.
+func (c *AdminClient) Version(ctx context.Context) (string, error) {
+	var resp models.VersionResponse
+	if err := c.sendRequest(ctx, http.MethodGet, backend.VersionPattern, nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.Data.Version, nil
+}
+
+// ReadConfig returns the configuration of the plugin mount.
+func (c *AdminClient) ReadConfig(ctx context.Context) (*backend.Config, error) {
+	var resp struct {
+		Data *backend.Config `json:"data"`
+	}
+	if err := c.sendRequest(ctx, http.MethodGet, backend.ConfigPattern, nil, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Data == nil {
+		return nil, NewGenericErrorMessage("no config in the response")
+	}
+	return resp.Data, nil
+}
+
+// WriteConfig replaces the configuration of the plugin mount and returns the stored one.
+// Every field is written, the backend keeps the stored value of the omitted ones, except a zero ExitApprovalTTL
+// which keeps the stored TTL.
+func (c *AdminClient) WriteConfig(ctx context.Context, config *backend.Config) (*backend.Config, error) {
+	feeRecipients := config.FeeRecipients
+	if feeRecipients == nil {
+		feeRecipients = backend.FeeRecipients{}
+	}
+	reqMap := map[string]interface{}{
+		"network":             config.Network,
+		"fee_recipients":      feeRecipients,
+		"doppelganger_epochs": config.DoppelgangerEpochs,
+		"max_slots_ahead":     config.MaxSlotsAhead,
+		"max_slots_behind":    config.MaxSlotsBehind,
+		"rate_limits":         config.RateLimits,
+		"exit_approvals":      config.ExitApprovals,
+	}
+	if config.ExitApprovalTTL > 0 {
+		reqMap["exit_approval_ttl"] = config.ExitApprovalTTL
+	}
+
+	var resp struct {
+		Data *backend.Config `json:"data"`
+	}
+	if err := c.sendRequest(ctx, http.MethodPost, backend.ConfigPattern, reqMap, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Data == nil {
+		return nil, NewGenericErrorMessage("no config in the response")
+	}
+	return resp.Data, nil
+}
+
+// UpdateStorage imports the accounts and the slashing data of the given store.
+func (c *AdminClient) UpdateStorage(ctx context.Context, store *inmemory.InMemStore) error {
+	_, err := c.ImportStorage(ctx, store, false)
+	return err
+}
+
+// ImportStorage imports the accounts and the slashing data of the given store and returns the import report.
+// Nothing is imported if dryRun is true.
+func (c *AdminClient) ImportStorage(ctx context.Context, store *inmemory.InMemStore, dryRun bool) (*models.StorageModel, error) {
+	storeByts, err := json.Marshal(store)
+	if err != nil {
+		return nil, NewGenericError(err, "failed to JSON marshal storage")
+	}
+	reqMap := map[string]interface{}{
+		"data":    hex.EncodeToString(storeByts),
+		"dry_run": dryRun,
+	}
+
+	var resp models.StorageResponse
+	if err := c.sendRequest(ctx, http.MethodPost, backend.StoragePattern, reqMap, &resp); err != nil {
+		return nil, err
+	}
+	if !resp.Data.Status {
+		return nil, ErrStorageNotUpdated
+	}
+	return &resp.Data, nil
+}
+
+// ImportStorageInChunks imports the accounts and the slashing data of the given store through an import session,
+// uploading them by chunks of chunkSize accounts so that large stores fit in the Vault request size limit.
+// The session is aborted if any step fails. Nothing is imported if dryRun is true.
+func (c *AdminClient) ImportStorageInChunks(ctx context.Context, store *inmemory.InMemStore, chunkSize int, dryRun bool) (*models.StorageModel, error) {
+	chunks, err := splitStore(store, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var session struct {
+		Data struct {
+			SessionID string `json:"session_id"`
+		} `json:"data"`
+	}
+	if err := c.sendRequest(ctx, http.MethodPost, backend.ImportStoragePattern, map[string]interface{}{}, &session); err != nil {
+		return nil, err
+	}
+	sessionPath := backend.ImportStoragePattern + "/" + session.Data.SessionID
+
+	// Dry runs leave the session staged, it is aborted too
+	report, err := c.importChunks(ctx, sessionPath, chunks, dryRun)
+	if err != nil || dryRun {
+		if abortErr := c.sendRequest(ctx, http.MethodDelete, sessionPath, nil, nil); abortErr != nil {
+			c.log.WithError(abortErr).Warn("failed to abort import session")
+		}
+	}
+	return report, err
+}
+
+// importChunks uploads the given chunks to the import session of the given path and commits it.
+func (c *AdminClient) importChunks(ctx context.Context, sessionPath string, chunks []*inmemory.InMemStore, dryRun bool) (*models.StorageModel, error) {
+	for _, chunk := range chunks {
+		chunkByts, err := json.Marshal(chunk)
+		if err != nil {
+			return nil, NewGenericError(err, "failed to JSON marshal storage chunk")
+		}
+		reqMap := map[string]interface{}{
+			"data": hex.EncodeToString(chunkByts),
+		}
+		if err := c.sendRequest(ctx, http.MethodPost, sessionPath+"/chunks", reqMap, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	var resp models.StorageResponse
+	if err := c.sendRequest(ctx, http.MethodPost, sessionPath+"/commit", map[string]interface{}{"dry_run": dryRun}, &resp); err != nil {
+		return nil, err
+	}
+	if !resp.Data.Status {
+		return nil, ErrStorageNotUpdated
+	}
+	return &resp.Data, nil
+}
+
+// splitStore splits the accounts and the slashing data of the given store in stores of chunkSize accounts.
+func splitStore(store *inmemory.InMemStore, chunkSize int) ([]*inmemory.InMemStore, error) {
+	if chunkSize <= 0 {
+		return nil, NewGenericErrorMessage("chunk size must be positive")
+	}
+	wallet, err := store.OpenWallet()
+	if err != nil {
+		return nil, NewGenericError(err, "failed to open storage wallet")
+	}
+
+	accounts := wallet.Accounts()
+	chunks := make([]*inmemory.InMemStore, 0, (len(accounts)+chunkSize-1)/chunkSize)
+	for start := 0; start < len(accounts); start += chunkSize {
+		chunk := inmemory.NewInMemStore(store.Network())
+		walletCtx := &core.WalletContext{Storage: chunk}
+		var chunkWallet core.Wallet
+		if wallet.Type() == core.NDWallet {
+			chunkWallet = nd.NewWallet(walletCtx)
+		} else {
+			chunkWallet = hd.NewWallet(walletCtx)
+		}
+		if err := chunk.SaveWallet(chunkWallet); err != nil {
+			return nil, NewGenericError(err, "failed to save chunk wallet")
+		}
+
+		end := start + chunkSize
+		if end > len(accounts) {
+			end = len(accounts)
+		}
+		for _, account := range accounts[start:end] {
+			if err := chunkWallet.AddValidatorAccount(account); err != nil {
+				return nil, NewGenericError(err, "failed to add account to chunk")
+			}
+			pubKey := account.ValidatorPublicKey()
+			if att, found, err := store.RetrieveHighestAttestation(pubKey); err == nil && found && att != nil {
+				if err := chunk.SaveHighestAttestation(pubKey, att); err != nil {
+					return nil, NewGenericError(err, "failed to add highest attestation to chunk")
+				}
+			}
+			if proposal, found, err := store.RetrieveHighestProposal(pubKey); err == nil && found {
+				if err := chunk.SaveHighestProposal(pubKey, proposal); err != nil {
+					return nil, NewGenericError(err, "failed to add highest proposal to chunk")
+				}
+			}
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// ListAccounts returns the accounts of the wallet.
+func (c *AdminClient) ListAccounts(ctx context.Context) ([]*models.AccountModel, error) {
+	var resp models.AccountsResponse
+	if err := c.sendRequest(ctx, methodList, backend.AccountsPattern, nil, &resp); err != nil {
+		// Vault answers list operations without results with 404.
+		if IsNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return resp.Data.Accounts, nil
+}
+
+// ReleaseDoppelgangerWindow lets the account of the given hex encoded public key sign attestations and blocks
+// before the end of its doppelganger protection window. It returns false if the account had no pending window.
+func (c *AdminClient) ReleaseDoppelgangerWindow(ctx context.Context, pubKey string) (bool, error) {
+	var resp struct {
+		Data struct {
+			Released bool `json:"released"`
+		} `json:"data"`
+	}
+	if err := c.sendRequest(ctx, http.MethodDelete, backend.DoppelgangerPattern+"/"+pubKey, nil, &resp); err != nil {
+		return false, err
+	}
+	return resp.Data.Released, nil
+}
+
+// SlashingHistory returns the slashing history of every account keyed by hex encoded public key, reading every page.
+// The histories of accounts with missing or corrupt records hold the errors.
+func (c *AdminClient) SlashingHistory(ctx context.Context) (map[string]*backend.SlashingHistory, error) {
+	history := make(map[string]*backend.SlashingHistory)
+	offset := 0
+	for {
+		page, err := c.SlashingHistoryPage(ctx, nil, offset, backend.MaxSlashingHistoryLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, accountHistory := range append(page.Accounts, page.Incomplete...) {
+			history[accountHistory.PublicKey] = accountHistory
+		}
+		if page.NextOffset == 0 {
+			return history, nil
+		}
+		offset = page.NextOffset
+	}
+}
+
+// SlashingHistoryPage returns a page of the slashing history of the given accounts, all the accounts if none is given.
+// The accounts are sorted by public key.
+func (c *AdminClient) SlashingHistoryPage(ctx context.Context, publicKeys []string, offset, limit int) (*backend.SlashingHistoryPage, error) {
+	query := url.Values{}
+	if len(publicKeys) > 0 {
+		query.Set("public_keys", strings.Join(publicKeys, ","))
+	}
+	query.Set("offset", strconv.Itoa(offset))
+	query.Set("limit", strconv.Itoa(limit))
+
+	var resp struct {
+		Data *backend.SlashingHistoryPage `json:"data"`
+	}
+	if err := c.sendRequest(ctx, http.MethodGet, backend.SlashingStoragePattern+"?"+query.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// Sign signs the given request with any account of the wallet.
+// Voluntary exits are sent to the dedicated endpoint.
+func (c *AdminClient) Sign(ctx context.Context, req *models.SignRequest) (phase0.BLSSignature, error) {
+	return c.sign(ctx, req)
+}
+
+// ListExitRequests returns the voluntary exit requests with their approvals, rejection and expiry.
+func (c *AdminClient) ListExitRequests(ctx context.Context) ([]*models.ExitRequestModel, error) {
+	var resp models.ExitRequestsResponse
+	if err := c.sendRequest(ctx, methodList, backend.ExitRequestsPattern, nil, &resp); err != nil {
+		// Vault answers list operations without results with 404.
+		if IsNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return resp.Data.ExitRequests, nil
+}
+
+// ExitRequest returns the voluntary exit request of the given ID, its signature is set once it is approved.
+func (c *AdminClient) ExitRequest(ctx context.Context, exitID string) (*models.ExitRequestModel, error) {
+	var resp models.ExitRequestResponse
+	if err := c.sendRequest(ctx, http.MethodGet, backend.ExitRequestsPattern+"/"+exitID, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// ApproveExitRequest approves the voluntary exit request of the given ID as the Vault entity of the client,
+// the exit is signed with the last required approval.
+func (c *AdminClient) ApproveExitRequest(ctx context.Context, exitID string) (*models.ExitRequestModel, error) {
+	var resp models.ExitRequestResponse
+	if err := c.sendRequest(ctx, http.MethodPost, backend.ExitRequestsPattern+"/"+exitID+"/approve", map[string]interface{}{}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// RejectExitRequest rejects the voluntary exit request of the given ID as the Vault entity of the client.
+func (c *AdminClient) RejectExitRequest(ctx context.Context, exitID, reason string) (*models.ExitRequestModel, error) {
+	var resp models.ExitRequestResponse
+	if err := c.sendRequest(ctx, http.MethodPost, backend.ExitRequestsPattern+"/"+exitID+"/reject", map[string]interface{}{
+		"reason": reason,
+	}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// CombinePartialSignatures recovers the signature of a group public key from the partial signatures of its key shares,
+// as returned by Sign. The partial signatures must be of the same group public key and signing root.
+func (c *AdminClient) CombinePartialSignatures(ctx context.Context, partials ...*PartialSignatureError) (phase0.BLSSignature, error) {
+	if len(partials) == 0 {
+		return phase0.BLSSignature{}, NewGenericErrorMessage("no partial signatures provided")
+	}
+	partialSigs := make(map[string]interface{}, len(partials))
+	for _, partial := range partials {
+		if partial.PubKey != partials[0].PubKey || partial.SigningRoot != partials[0].SigningRoot {
+			return phase0.BLSSignature{}, NewGenericErrorMessage("partial signatures of share %d and %d differ in public key or signing root", partials[0].ShareIndex, partial.ShareIndex)
+		}
+		partialSigs[strconv.FormatUint(partial.ShareIndex, 10)] = partial.Signature
+	}
+
+	pubKey, err := hex.DecodeString(partials[0].PubKey)
+	if err != nil {
+		return phase0.BLSSignature{}, NewGenericError(err, "failed to decode group public key")
+	}
+	rootBytes, err := hex.DecodeString(partials[0].SigningRoot)
+	if err != nil || len(rootBytes) != len(phase0.Root{}) {
+		return phase0.BLSSignature{}, NewGenericErrorMessage("invalid signing root")
+	}
+	var signingRoot phase0.Root
+	copy(signingRoot[:], rootBytes)
+
+	var resp models.SignResponse
+	if err := c.sendRequest(ctx, http.MethodPost, backend.KeySharesCombinePattern, map[string]interface{}{
+		"group_public_key":   partials[0].PubKey,
+		"signing_root":       partials[0].SigningRoot,
+		"partial_signatures": partialSigs,
+	}, &resp); err != nil {
+		return phase0.BLSSignature{}, err
+	}
+
+	sig, err := hex.DecodeString(resp.Data.Signature)
+	if err != nil {
+		return phase0.BLSSignature{}, NewGenericError(err, "failed to decode signature")
+	}
+	if err := verifySignature(pubKey, signingRoot, sig); err != nil {
+		c.log.WithError(err).Error("remote key manager returned an invalid combined signature")
+		return phase0.BLSSignature{}, err
+	}
+
+	var signature phase0.BLSSignature
+	copy(signature[:], sig)
+	return signature, nil
+}
+
+// CheckSign returns whether the given request would be signed, without signing it.
+// The slashing data of the account is not updated.
+func (c *AdminClient) CheckSign(ctx context.Context, req *models.SignRequest) (*models.SignCheckModel, error) {
+	byts, err := c.encoder.Encode(req)
+	if err != nil {
+		return nil, NewGenericError(err, "failed to encode request")
+	}
+	reqMap := map[string]interface{}{
+		"sign_req": hex.EncodeToString(byts),
+	}
+
+	var resp models.SignCheckResponse
+	if err := c.sendRequest(ctx, http.MethodPost, backend.SignCheckPattern, reqMap, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// OverrideWatermarks raises the slashing watermarks of the given accounts keyed by hex encoded public key.
+// Lowering any watermark requires force along with a reason.
+func (c *AdminClient) OverrideWatermarks(ctx context.Context, watermarks map[string]*backend.WatermarksOverride, force bool, reason string) ([]*backend.WatermarksAuditRecord, error) {
+	reqMap := map[string]interface{}{
+		"watermarks": watermarks,
+		"force":      force,
+		"reason":     reason,
+	}
+
+	var resp struct {
+		Data struct {
+			Changes []*backend.WatermarksAuditRecord `json:"changes"`
+		} `json:"data"`
+	}
+	if err := c.sendRequest(ctx, http.MethodPost, backend.WatermarksStoragePattern, reqMap, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data.Changes, nil
+}
+
+// WatermarksAuditRecords returns the audit records of the watermark overrides, oldest first.
+func (c *AdminClient) WatermarksAuditRecords(ctx context.Context) ([]*backend.WatermarksAuditRecord, error) {
+	var resp struct {
+		Data struct {
+			Records []*backend.WatermarksAuditRecord `json:"records"`
+		} `json:"data"`
+	}
+	if err := c.sendRequest(ctx, http.MethodGet, backend.WatermarksStoragePattern, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data.Records, nil
+}
+
+// ReEncryptStorage rewrites the stored accounts with the encryption settings of the plugin.
+// It returns the number of rewritten entries.
+func (c *AdminClient) ReEncryptStorage(ctx context.Context) (int, error) {
+	var resp struct {
+		Data struct {
+			Entries int `json:"entries"`
+		} `json:"data"`
+	}
+	if err := c.sendRequest(ctx, http.MethodPost, backend.EncryptionStoragePattern, map[string]interface{}{}, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Data.Entries, nil
+}
+
+// MigrateStorage runs the pending storage migrations and returns the names of the applied ones.
+func (c *AdminClient) MigrateStorage(ctx context.Context) ([]string, error) {
+	var resp struct {
+		Data struct {
+			Applied []string `json:"applied"`
+		} `json:"data"`
+	}
+	if err := c.sendRequest(ctx, http.MethodPost, backend.MigrationsStoragePattern, map[string]interface{}{}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data.Applied, nil
+}
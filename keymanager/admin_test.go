@@ -0,0 +1,355 @@
+package keymanager_test
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/bloxapp/eth2-key-manager/stores/inmemory"
+	"github.com/bloxapp/eth2-key-manager/wallets/hd"
+	"github.com/hashicorp/vault/sdk/helper/logging"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/backend"
+	"github.com/bloxapp/key-vault/keymanager"
+	"github.com/bloxapp/key-vault/keymanager/models"
+)
+
+// newTestBackendServer serves the plugin backend over the Vault HTTP API.
+func newTestBackendServer(t *testing.T) *httptest.Server {
+	storage := &logical.InmemStorage{}
+	b, err := backend.Factory("v1.2.3", logrus.New())(context.Background(), &logical.BackendConfig{
+		Logger:      logging.NewVaultLogger(0),
+		System:      &logical.StaticSystemView{},
+		StorageView: storage,
+		BackendUUID: "test",
+	})
+	require.NoError(t, err)
+
+	s := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		require.Equal(t, "Bearer "+DefaultAccessToken, request.Header.Get("Authorization"))
+
+		req := &logical.Request{
+			Path:    strings.TrimPrefix(request.URL.Path, "/v1/ethereum/prater/"),
+			Storage: storage,
+		}
+		switch request.Method {
+		case http.MethodGet:
+			req.Operation = logical.ReadOperation
+			// like Vault, the query parameters are the request data
+			if query := request.URL.Query(); len(query) > 0 {
+				req.Data = make(map[string]interface{}, len(query))
+				for key := range query {
+					req.Data[key] = query.Get(key)
+				}
+			}
+		case "LIST":
+			req.Operation = logical.ListOperation
+		case http.MethodDelete:
+			req.Operation = logical.DeleteOperation
+		default:
+			req.Operation = logical.CreateOperation
+			if _, exists, err := b.HandleExistenceCheck(context.Background(), req); err == nil && exists {
+				req.Operation = logical.UpdateOperation
+			}
+			require.NoError(t, json.NewDecoder(request.Body).Decode(&req.Data))
+		}
+
+		resp, err := b.HandleRequest(context.Background(), req)
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			require.NoError(t, json.NewEncoder(writer).Encode(map[string]interface{}{
+				"errors": []string{err.Error()},
+			}))
+			return
+		}
+		if resp == nil || len(resp.Data) == 0 && req.Operation == logical.ListOperation {
+			writer.WriteHeader(http.StatusNotFound)
+			_, _ = writer.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		if statusCode, ok := resp.Data[logical.HTTPStatusCode].(int); ok {
+			// Raw responses, e.g. the coded errors of the backend.
+			writer.WriteHeader(statusCode)
+			_, _ = writer.Write([]byte(resp.Data[logical.HTTPRawBody].(string)))
+			return
+		}
+		require.NoError(t, json.NewEncoder(writer).Encode(map[string]interface{}{
+			"data": resp.Data,
+		}))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func testInMemStore(t *testing.T) (*inmemory.InMemStore, []byte) {
+	store := inmemory.NewInMemStore(core.PraterNetwork)
+	wallet := hd.NewWallet(&core.WalletContext{Storage: store})
+	require.NoError(t, store.SaveWallet(wallet))
+
+	acc, err := wallet.CreateValidatorAccount(_byteArray("0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1fff"), nil)
+	require.NoError(t, err)
+	require.NoError(t, store.SaveAccount(acc))
+
+	require.NoError(t, store.SaveHighestAttestation(acc.ValidatorPublicKey(), &phase0.AttestationData{
+		Source: &phase0.Checkpoint{Epoch: 1},
+		Target: &phase0.Checkpoint{Epoch: 2},
+	}))
+	require.NoError(t, store.SaveHighestProposal(acc.ValidatorPublicKey(), 10))
+	return store, acc.ValidatorPublicKey()
+}
+
+func TestAdminClient(t *testing.T) {
+	s := newTestBackendServer(t)
+	client, err := keymanager.NewAdminClient(logrus.NewEntry(logrus.New()), &keymanager.Config{
+		Location:    s.URL,
+		AccessToken: DefaultAccessToken,
+		Network:     "prater",
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	t.Run("version", func(t *testing.T) {
+		version, err := client.Version(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "v1.2.3", version)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		_, err := client.ReadConfig(ctx)
+		require.Error(t, err)
+		require.True(t, keymanager.IsHTTPRequestError(err))
+
+		var notConfigured *keymanager.ErrNotConfigured
+		require.True(t, errors.As(err, &notConfigured))
+		require.Equal(t, http.StatusPreconditionFailed, notConfigured.StatusCode)
+		require.Equal(t, "the plugin has not been configured yet", notConfigured.Message)
+	})
+
+	t.Run("write and read config", func(t *testing.T) {
+		feeRecipients := backend.FeeRecipients{"default": "0x6a3f3ee924a940ce0d795c5a41a817607e520520"}
+		rateLimits := backend.RateLimits{
+			PublicKey: map[string]*backend.RateLimit{
+				backend.SignObjectAttestation: {Limit: 100, Period: backend.RateLimitPeriodEpoch},
+			},
+			Entity: &backend.RateLimit{Limit: 1000, Period: backend.RateLimitPeriodSlot},
+		}
+		written, err := client.WriteConfig(ctx, &backend.Config{
+			Network:       core.PraterNetwork,
+			FeeRecipients: feeRecipients,
+			RateLimits:    rateLimits,
+			ExitApprovals: 2,
+		})
+		require.NoError(t, err)
+		require.Equal(t, core.PraterNetwork, written.Network)
+		require.Equal(t, feeRecipients, written.FeeRecipients)
+		require.Equal(t, rateLimits, written.RateLimits)
+		require.EqualValues(t, 2, written.ExitApprovals)
+		require.EqualValues(t, 24*60*60, written.ExitApprovalTTL)
+
+		config, err := client.ReadConfig(ctx)
+		require.NoError(t, err)
+		require.Equal(t, written, config)
+	})
+
+	store, pubKey := testInMemStore(t)
+
+	t.Run("import storage dry run", func(t *testing.T) {
+		report, err := client.ImportStorage(ctx, store, true)
+		require.NoError(t, err)
+		require.True(t, report.DryRun)
+		require.Len(t, report.Accounts, 1)
+		require.Equal(t, hex.EncodeToString(pubKey), report.Accounts[0].PublicKey)
+		require.Equal(t, "added", report.Accounts[0].Status)
+	})
+
+	t.Run("update storage", func(t *testing.T) {
+		require.NoError(t, client.UpdateStorage(ctx, store))
+	})
+
+	t.Run("import storage again", func(t *testing.T) {
+		report, err := client.ImportStorage(ctx, store, false)
+		require.NoError(t, err)
+		require.False(t, report.DryRun)
+		require.Equal(t, 1, report.Summary["skipped-duplicate"])
+	})
+
+	t.Run("import storage in chunks", func(t *testing.T) {
+		chunkedStore, _ := testInMemStore(t)
+		wallet, err := chunkedStore.OpenWallet()
+		require.NoError(t, err)
+		_, err = wallet.CreateValidatorAccount(_byteArray("0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1fff"), nil)
+		require.NoError(t, err)
+
+		report, err := client.ImportStorageInChunks(ctx, chunkedStore, 1, true)
+		require.NoError(t, err)
+		require.True(t, report.DryRun)
+		require.Equal(t, 1, report.Summary["added"])
+		require.Equal(t, 1, report.Summary["skipped-duplicate"])
+
+		_, err = client.ImportStorageInChunks(ctx, chunkedStore, 0, true)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "chunk size must be positive")
+	})
+
+	t.Run("list accounts", func(t *testing.T) {
+		accounts, err := client.ListAccounts(ctx)
+		require.NoError(t, err)
+		require.Len(t, accounts, 1)
+		require.Equal(t, hex.EncodeToString(pubKey), accounts[0].ValidationPubKey)
+		require.NotEmpty(t, accounts[0].ID)
+		require.NotEmpty(t, accounts[0].WithdrawalPubKey)
+	})
+
+	t.Run("release doppelganger window", func(t *testing.T) {
+		// the account was imported without doppelganger protection
+		released, err := client.ReleaseDoppelgangerWindow(ctx, hex.EncodeToString(pubKey))
+		require.NoError(t, err)
+		require.False(t, released)
+	})
+
+	t.Run("slashing history", func(t *testing.T) {
+		history, err := client.SlashingHistory(ctx)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+
+		accountHistory := history[hex.EncodeToString(pubKey)]
+		require.NotNil(t, accountHistory)
+		require.EqualValues(t, 2, accountHistory.HighestAttestation.Target.Epoch)
+		require.EqualValues(t, 10, accountHistory.HighestProposal.Slot)
+		require.Empty(t, accountHistory.Errors)
+
+		page, err := client.SlashingHistoryPage(ctx, []string{hex.EncodeToString(pubKey)}, 0, 1)
+		require.NoError(t, err)
+		require.Equal(t, 1, page.Total)
+		require.Zero(t, page.NextOffset)
+		require.Len(t, page.Accounts, 1)
+		require.Empty(t, page.Incomplete)
+	})
+
+	t.Run("override watermarks", func(t *testing.T) {
+		proposalSlot := phase0.Slot(20)
+		changes, err := client.OverrideWatermarks(ctx, map[string]*backend.WatermarksOverride{
+			hex.EncodeToString(pubKey): {ProposalSlot: &proposalSlot},
+		}, false, "")
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		require.Equal(t, backend.Watermarks{SourceEpoch: 1, TargetEpoch: 2, ProposalSlot: 10}, changes[0].Previous)
+		require.Equal(t, backend.Watermarks{SourceEpoch: 1, TargetEpoch: 2, ProposalSlot: 20}, changes[0].Current)
+
+		proposalSlot = 15
+		_, err = client.OverrideWatermarks(ctx, map[string]*backend.WatermarksOverride{
+			hex.EncodeToString(pubKey): {ProposalSlot: &proposalSlot},
+		}, false, "")
+		var policyErr *keymanager.ErrPolicyViolation
+		require.True(t, errors.As(err, &policyErr))
+
+		records, err := client.WatermarksAuditRecords(ctx)
+		require.NoError(t, err)
+		require.Equal(t, changes[0].Current, records[0].Current)
+		require.Len(t, records, 1)
+	})
+
+	t.Run("re-encrypt storage", func(t *testing.T) {
+		entries, err := client.ReEncryptStorage(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 2, entries)
+	})
+
+	t.Run("migrate storage", func(t *testing.T) {
+		applied, err := client.MigrateStorage(ctx)
+		require.NoError(t, err)
+		require.NotEmpty(t, applied)
+
+		applied, err = client.MigrateStorage(ctx)
+		require.NoError(t, err)
+		require.Empty(t, applied)
+	})
+
+	t.Run("check sign", func(t *testing.T) {
+		verdict, err := client.CheckSign(ctx, &models.SignRequest{
+			PublicKey:       pubKey,
+			SignatureDomain: _byteArray32("01000000f071c66c6561d0b939feb15f513a019d99a84bd85635221e3ad42dac"),
+			Object: &models.SignRequestAttestationData{AttestationData: &phase0.AttestationData{
+				Source: &phase0.Checkpoint{Epoch: 1},
+				Target: &phase0.Checkpoint{Epoch: 2},
+			}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, backend.SignVerdictSlashable, verdict.Verdict)
+		require.Equal(t, "slashable attestation (HighestAttestationVote), not signing", verdict.Reason)
+	})
+
+	t.Run("sign", func(t *testing.T) {
+		sig, err := client.Sign(ctx, &models.SignRequest{
+			PublicKey:       pubKey,
+			SignatureDomain: _byteArray32("0200000081509579e35e84020ad8751eca180b44df470332d3ad17fc6fd52459"),
+			Object:          &models.SignRequestEpoch{Epoch: 3},
+		})
+		require.NoError(t, err)
+		require.NotEqual(t, phase0.BLSSignature{}, sig)
+	})
+
+	t.Run("exit requests", func(t *testing.T) {
+		requests, err := client.ListExitRequests(ctx)
+		require.NoError(t, err)
+		require.Empty(t, requests)
+
+		_, err = client.ExitRequest(ctx, "unknown")
+		var badRequest *keymanager.ErrBadRequest
+		require.True(t, errors.As(err, &badRequest))
+		require.Equal(t, "failed to read exit request: exit request not found", badRequest.Message)
+
+		// the decisions require a Vault entity
+		_, err = client.ApproveExitRequest(ctx, "unknown")
+		require.True(t, errors.As(err, &badRequest))
+		require.Equal(t, "deciding on an exit request requires a Vault entity", badRequest.Message)
+		_, err = client.RejectExitRequest(ctx, "unknown", "wrong validator")
+		require.True(t, errors.As(err, &badRequest))
+	})
+
+	t.Run("sign with unknown account", func(t *testing.T) {
+		_, err := client.Sign(ctx, &models.SignRequest{
+			PublicKey:       _byteArray("8e80066551a81b318258709edaf7dd1f63cd686a0e4db8b29bbb7acfe65608677af5a527d9448ee47835485e02b50bc0"),
+			SignatureDomain: _byteArray32("0200000081509579e35e84020ad8751eca180b44df470332d3ad17fc6fd52459"),
+			Object:          &models.SignRequestEpoch{Epoch: 3},
+		})
+		require.Error(t, err)
+		require.True(t, keymanager.IsHTTPRequestError(err))
+		require.False(t, keymanager.IsNotFoundError(err))
+
+		var unknownAccount *keymanager.ErrUnknownAccount
+		require.True(t, errors.As(err, &unknownAccount))
+		require.Equal(t, "failed to sign: account not found", unknownAccount.Message)
+	})
+}
+
+func TestAdminClient_ListAccountsEmpty(t *testing.T) {
+	s := newTestRemoteWallet(func(writer http.ResponseWriter, request *http.Request) {
+		require.Equal(t, "LIST", request.Method)
+		require.Equal(t, "/v1/ethereum/prater/accounts/", request.URL.Path)
+		writer.WriteHeader(http.StatusNotFound)
+		_, _ = writer.Write([]byte(`{"errors":[]}`))
+	})
+	defer s.Close()
+
+	client, err := keymanager.NewAdminClient(logrus.NewEntry(logrus.New()), &keymanager.Config{
+		Location:    s.URL,
+		AccessToken: DefaultAccessToken,
+		Network:     "prater",
+	})
+	require.NoError(t, err)
+
+	accounts, err := client.ListAccounts(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, accounts)
+}
@@ -0,0 +1,244 @@
+package keymanager
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Supported authentication methods.
+const (
+	AuthMethodToken      = "token"
+	AuthMethodTokenFile  = "token_file"
+	AuthMethodAppRole    = "approle"
+	AuthMethodKubernetes = "kubernetes"
+	AuthMethodCert       = "cert"
+)
+
+// Default values of the authentication methods.
+const (
+	defaultKubernetesJWTFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	tokenFileReloadInterval  = time.Minute
+)
+
+// AuthConfig contains configuration of the Vault authentication method.
+type AuthConfig struct {
+	// Method is one of: token, token_file, approle, kubernetes, cert.
+	Method string `json:"method"`
+
+	// MountPath is the path the auth method is mounted at. Defaults to the method name.
+	MountPath string `json:"mount_path,omitempty"`
+
+	// TokenFile is the file to read the token from (token_file).
+	TokenFile string `json:"token_file,omitempty"`
+
+	// RoleID, SecretID and SecretIDFile are the AppRole credentials (approle).
+	RoleID       string `json:"role_id,omitempty"`
+	SecretID     string `json:"secret_id,omitempty"`
+	SecretIDFile string `json:"secret_id_file,omitempty"`
+
+	// Role is the role to log in with (kubernetes, cert).
+	Role string `json:"role,omitempty"`
+
+	// JWTFile is the service account token file (kubernetes).
+	JWTFile string `json:"jwt_file,omitempty"`
+}
+
+// AuthToken represents a Vault token issued by an authentication method.
+type AuthToken struct {
+	Token     string
+	TTL       time.Duration
+	Renewable bool
+}
+
+// LoginFunc sends a login request to the given auth mount and returns the issued token.
+type LoginFunc func(ctx context.Context, mountPath string, body map[string]interface{}) (*AuthToken, error)
+
+// Authenticator obtains Vault tokens.
+type Authenticator interface {
+	Login(ctx context.Context, login LoginFunc) (*AuthToken, error)
+}
+
+// NewAuthenticator creates an authenticator based on the given configuration.
+// The static access token is used if no authentication method is configured.
+func NewAuthenticator(cfg *AuthConfig, accessToken string) (Authenticator, error) {
+	if cfg == nil || cfg.Method == AuthMethodToken {
+		if len(accessToken) == 0 {
+			return nil, ErrTokenMissing
+		}
+		return NewStaticTokenAuth(accessToken), nil
+	}
+
+	mountPath := cfg.MountPath
+	if len(mountPath) == 0 {
+		mountPath = cfg.Method
+	}
+
+	switch cfg.Method {
+	case AuthMethodTokenFile:
+		if len(cfg.TokenFile) == 0 {
+			return nil, NewGenericErrorMessage("token file is required for %s auth", cfg.Method)
+		}
+		return NewTokenFileAuth(cfg.TokenFile), nil
+	case AuthMethodAppRole:
+		if len(cfg.RoleID) == 0 || (len(cfg.SecretID) == 0 && len(cfg.SecretIDFile) == 0) {
+			return nil, NewGenericErrorMessage("role ID and secret ID are required for %s auth", cfg.Method)
+		}
+		return NewAppRoleAuth(mountPath, cfg.RoleID, cfg.SecretID, cfg.SecretIDFile), nil
+	case AuthMethodKubernetes:
+		if len(cfg.Role) == 0 {
+			return nil, NewGenericErrorMessage("role is required for %s auth", cfg.Method)
+		}
+		jwtFile := cfg.JWTFile
+		if len(jwtFile) == 0 {
+			jwtFile = defaultKubernetesJWTFile
+		}
+		return NewKubernetesAuth(mountPath, cfg.Role, jwtFile), nil
+	case AuthMethodCert:
+		return NewCertAuth(mountPath, cfg.Role), nil
+	default:
+		return nil, NewGenericErrorMessage("unsupported auth method '%s'", cfg.Method)
+	}
+}
+
+// StaticTokenAuth always returns the same token.
+type StaticTokenAuth struct {
+	token string
+}
+
+// NewStaticTokenAuth is the constructor of StaticTokenAuth.
+func NewStaticTokenAuth(token string) *StaticTokenAuth {
+	return &StaticTokenAuth{token: token}
+}
+
+// Login implements Authenticator interface.
+func (a *StaticTokenAuth) Login(_ context.Context, _ LoginFunc) (*AuthToken, error) {
+	return &AuthToken{Token: a.token}, nil
+}
+
+// TokenFileAuth reads the token from a file, e.g. written by Vault agent.
+// The file is reloaded periodically and whenever the token is rejected.
+type TokenFileAuth struct {
+	path string
+}
+
+// NewTokenFileAuth is the constructor of TokenFileAuth.
+func NewTokenFileAuth(path string) *TokenFileAuth {
+	return &TokenFileAuth{path: path}
+}
+
+// Login implements Authenticator interface.
+func (a *TokenFileAuth) Login(_ context.Context, _ LoginFunc) (*AuthToken, error) {
+	token, err := readSecretFile(a.path)
+	if err != nil {
+		return nil, NewGenericError(err, "failed to read token file")
+	}
+	return &AuthToken{
+		Token: token,
+		TTL:   tokenFileReloadInterval,
+	}, nil
+}
+
+// AppRoleAuth logs in using the AppRole auth method.
+type AppRoleAuth struct {
+	mountPath    string
+	roleID       string
+	secretID     string
+	secretIDFile string
+}
+
+// NewAppRoleAuth is the constructor of AppRoleAuth.
+// The secret ID is read from secretIDFile on every login if given.
+func NewAppRoleAuth(mountPath, roleID, secretID, secretIDFile string) *AppRoleAuth {
+	return &AppRoleAuth{
+		mountPath:    mountPath,
+		roleID:       roleID,
+		secretID:     secretID,
+		secretIDFile: secretIDFile,
+	}
+}
+
+// Login implements Authenticator interface.
+func (a *AppRoleAuth) Login(ctx context.Context, login LoginFunc) (*AuthToken, error) {
+	secretID := a.secretID
+	if len(a.secretIDFile) > 0 {
+		var err error
+		if secretID, err = readSecretFile(a.secretIDFile); err != nil {
+			return nil, NewGenericError(err, "failed to read secret ID file")
+		}
+	}
+
+	return login(ctx, a.mountPath, map[string]interface{}{
+		"role_id":   a.roleID,
+		"secret_id": secretID,
+	})
+}
+
+// KubernetesAuth logs in using the Kubernetes service account JWT.
+type KubernetesAuth struct {
+	mountPath string
+	role      string
+	jwtFile   string
+}
+
+// NewKubernetesAuth is the constructor of KubernetesAuth.
+func NewKubernetesAuth(mountPath, role, jwtFile string) *KubernetesAuth {
+	return &KubernetesAuth{
+		mountPath: mountPath,
+		role:      role,
+		jwtFile:   jwtFile,
+	}
+}
+
+// Login implements Authenticator interface.
+func (a *KubernetesAuth) Login(ctx context.Context, login LoginFunc) (*AuthToken, error) {
+	// The service account token is rotated by kubelet, so read it every time.
+	jwt, err := readSecretFile(a.jwtFile)
+	if err != nil {
+		return nil, NewGenericError(err, "failed to read service account token")
+	}
+
+	return login(ctx, a.mountPath, map[string]interface{}{
+		"role": a.role,
+		"jwt":  jwt,
+	})
+}
+
+// CertAuth logs in using the TLS client certificate configured in the TLS options.
+type CertAuth struct {
+	mountPath string
+	role      string
+}
+
+// NewCertAuth is the constructor of CertAuth.
+func NewCertAuth(mountPath, role string) *CertAuth {
+	return &CertAuth{
+		mountPath: mountPath,
+		role:      role,
+	}
+}
+
+// Login implements Authenticator interface.
+func (a *CertAuth) Login(ctx context.Context, login LoginFunc) (*AuthToken, error) {
+	body := map[string]interface{}{}
+	if len(a.role) > 0 {
+		body["name"] = a.role
+	}
+	return login(ctx, a.mountPath, body)
+}
+
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	secret := strings.TrimSpace(string(data))
+	if len(secret) == 0 {
+		return "", errors.Errorf("file '%s' is empty", path)
+	}
+	return secret, nil
+}
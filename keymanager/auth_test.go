@@ -0,0 +1,242 @@
+package keymanager_test
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/keymanager"
+)
+
+// testVault emulates Vault auth endpoints and the sign endpoint.
+type testVault struct {
+	t *testing.T
+
+	mu          sync.Mutex
+	logins      []map[string]interface{}
+	loginPaths  []string
+	validTokens map[string]bool
+	issued      int
+}
+
+func (v *testVault) revokeAll() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.validTokens = map[string]bool{}
+}
+
+func (v *testVault) allow(token string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.validTokens[token] = true
+}
+
+func (v *testVault) loginRequests() ([]string, []map[string]interface{}) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.loginPaths, v.logins
+}
+
+func (v *testVault) handler(expectedSig []byte) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+
+		if strings.HasPrefix(request.URL.Path, "/v1/auth/") && strings.HasSuffix(request.URL.Path, "/login") {
+			require.Empty(v.t, request.Header.Get("Authorization"))
+
+			var body map[string]interface{}
+			require.NoError(v.t, json.NewDecoder(request.Body).Decode(&body))
+			v.logins = append(v.logins, body)
+			v.loginPaths = append(v.loginPaths, request.URL.Path)
+
+			v.issued++
+			token := fmt.Sprintf("token-%d", v.issued)
+			v.validTokens[token] = true
+			require.NoError(v.t, json.NewEncoder(writer).Encode(map[string]interface{}{
+				"auth": map[string]interface{}{
+					"client_token":   token,
+					"lease_duration": 3600,
+					"renewable":      true,
+				},
+			}))
+			return
+		}
+
+		token := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
+		if !v.validTokens[token] {
+			writer.WriteHeader(http.StatusForbidden)
+			_, _ = writer.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		require.Equal(v.t, "/v1/ethereum/prater/accounts/sign", request.URL.Path)
+		require.NoError(v.t, json.NewEncoder(writer).Encode(&logical.Response{
+			Data: map[string]interface{}{
+				"signature": hex.EncodeToString(expectedSig),
+			},
+		}))
+	}
+}
+
+func writeSecretFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content+"\n"), 0600))
+	return path
+}
+
+func TestKeyManager_Auth(t *testing.T) {
+	expectedSig := _byteArray("aa16fbea0f61bba00816297a11bb1ea8ca1d9db8dd0c5b4b4cfaded37664bc043efa9e259bed01a33a6383cb04fc59a61483c6e4c2a63051eea661cc2e52642fc7e502047b68acc5be9b863f8c762d42f0b19c4856f16fe9855f3ccfb2658aa4")
+
+	setup := func(t *testing.T, auth *keymanager.AuthConfig, accessToken string) (*keymanager.KeyManager, *testVault) {
+		vault := &testVault{t: t, validTokens: map[string]bool{}}
+		s := newTestRemoteWallet(vault.handler(expectedSig))
+		t.Cleanup(s.Close)
+
+		km, err := keymanager.NewKeyManager(logrus.NewEntry(logrus.New()), &keymanager.Config{
+			Location:    s.URL,
+			AccessToken: accessToken,
+			Auth:        auth,
+			PubKey:      "8e80066551a81b318258709edaf7dd1f63cd686a0e4db8b29bbb7acfe65608677af5a527d9448ee47835485e02b50bc0",
+			Network:     "prater",
+		})
+		require.NoError(t, err)
+		return km, vault
+	}
+
+	t.Run("approle", func(t *testing.T) {
+		km, vault := setup(t, &keymanager.AuthConfig{
+			Method:       keymanager.AuthMethodAppRole,
+			RoleID:       "role-id",
+			SecretIDFile: writeSecretFile(t, "secret-id", "secret-id"),
+		}, "")
+
+		sig, err := km.Sign(context.Background(), testRequest(t))
+		require.NoError(t, err)
+		require.EqualValues(t, expectedSig, sig[:])
+		paths, logins := vault.loginRequests()
+		require.Equal(t, []string{"/v1/auth/approle/login"}, paths)
+		require.Equal(t, map[string]interface{}{"role_id": "role-id", "secret_id": "secret-id"}, logins[0])
+
+		// The token is reused
+		_, err = km.Sign(context.Background(), testRequest(t))
+		require.NoError(t, err)
+		_, logins = vault.loginRequests()
+		require.Len(t, logins, 1)
+	})
+
+	t.Run("re-authenticates on permission denied", func(t *testing.T) {
+		km, vault := setup(t, &keymanager.AuthConfig{
+			Method:   keymanager.AuthMethodAppRole,
+			RoleID:   "role-id",
+			SecretID: "secret-id",
+		}, "")
+
+		_, err := km.Sign(context.Background(), testRequest(t))
+		require.NoError(t, err)
+
+		vault.revokeAll()
+		_, err = km.Sign(context.Background(), testRequest(t))
+		require.NoError(t, err)
+		_, logins := vault.loginRequests()
+		require.Len(t, logins, 2)
+	})
+
+	t.Run("kubernetes", func(t *testing.T) {
+		km, vault := setup(t, &keymanager.AuthConfig{
+			Method:    keymanager.AuthMethodKubernetes,
+			MountPath: "k8s",
+			Role:      "validator",
+			JWTFile:   writeSecretFile(t, "jwt", "service-account-jwt"),
+		}, "")
+
+		_, err := km.Sign(context.Background(), testRequest(t))
+		require.NoError(t, err)
+		paths, logins := vault.loginRequests()
+		require.Equal(t, []string{"/v1/auth/k8s/login"}, paths)
+		require.Equal(t, map[string]interface{}{"role": "validator", "jwt": "service-account-jwt"}, logins[0])
+	})
+
+	t.Run("cert", func(t *testing.T) {
+		km, vault := setup(t, &keymanager.AuthConfig{
+			Method: keymanager.AuthMethodCert,
+			Role:   "validator",
+		}, "")
+
+		_, err := km.Sign(context.Background(), testRequest(t))
+		require.NoError(t, err)
+		paths, logins := vault.loginRequests()
+		require.Equal(t, []string{"/v1/auth/cert/login"}, paths)
+		require.Equal(t, map[string]interface{}{"name": "validator"}, logins[0])
+	})
+
+	t.Run("token file is reloaded when rejected", func(t *testing.T) {
+		tokenFile := writeSecretFile(t, "token", "token-1")
+		km, vault := setup(t, &keymanager.AuthConfig{
+			Method:    keymanager.AuthMethodTokenFile,
+			TokenFile: tokenFile,
+		}, "")
+		vault.allow("token-1")
+
+		_, err := km.Sign(context.Background(), testRequest(t))
+		require.NoError(t, err)
+
+		vault.revokeAll()
+		vault.allow("token-2")
+		require.NoError(t, os.WriteFile(tokenFile, []byte("token-2"), 0600))
+
+		_, err = km.Sign(context.Background(), testRequest(t))
+		require.NoError(t, err)
+		_, logins := vault.loginRequests()
+		require.Empty(t, logins)
+	})
+
+	t.Run("static token is not re-authenticated", func(t *testing.T) {
+		km, vault := setup(t, nil, "static")
+
+		_, err := km.Sign(context.Background(), testRequest(t))
+		require.Error(t, err)
+		require.True(t, keymanager.IsHTTPRequestError(err))
+		_, logins := vault.loginRequests()
+		require.Empty(t, logins)
+	})
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         *keymanager.AuthConfig
+		accessToken string
+		wantErr     bool
+	}{
+		{name: "static token", accessToken: "token"},
+		{name: "missing static token", wantErr: true},
+		{name: "token method", cfg: &keymanager.AuthConfig{Method: keymanager.AuthMethodToken}, accessToken: "token"},
+		{name: "token file", cfg: &keymanager.AuthConfig{Method: keymanager.AuthMethodTokenFile, TokenFile: "token"}},
+		{name: "missing token file", cfg: &keymanager.AuthConfig{Method: keymanager.AuthMethodTokenFile}, wantErr: true},
+		{name: "approle", cfg: &keymanager.AuthConfig{Method: keymanager.AuthMethodAppRole, RoleID: "role", SecretID: "secret"}},
+		{name: "approle without secret", cfg: &keymanager.AuthConfig{Method: keymanager.AuthMethodAppRole, RoleID: "role"}, wantErr: true},
+		{name: "kubernetes", cfg: &keymanager.AuthConfig{Method: keymanager.AuthMethodKubernetes, Role: "role"}},
+		{name: "kubernetes without role", cfg: &keymanager.AuthConfig{Method: keymanager.AuthMethodKubernetes}, wantErr: true},
+		{name: "cert", cfg: &keymanager.AuthConfig{Method: keymanager.AuthMethodCert}},
+		{name: "unsupported method", cfg: &keymanager.AuthConfig{Method: "ldap"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, err := keymanager.NewAuthenticator(tt.cfg, tt.accessToken)
+			require.Equal(t, tt.wantErr, err != nil, err)
+			require.Equal(t, tt.wantErr, auth == nil)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package keymanager
+
+import (
+	"context"
+
+	"github.com/attestantio/go-eth2-client/api"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+
+	"github.com/bloxapp/key-vault/keymanager/models"
+)
+
+// SignAttestation signs the given attestation data.
+func (km *KeyManager) SignAttestation(ctx context.Context, fork *ForkInfo, data *phase0.AttestationData) (phase0.BLSSignature, error) {
+	if data == nil || data.Target == nil {
+		return phase0.BLSSignature{}, NewGenericErrorMessage("attestation data is required")
+	}
+
+	return km.signDuty(ctx, fork, DomainBeaconAttester, data.Target.Epoch, &models.SignRequestAttestationData{
+		AttestationData: data,
+	})
+}
+
+// SignBlock signs the given beacon block.
+func (km *KeyManager) SignBlock(ctx context.Context, fork *ForkInfo, block *spec.VersionedBeaconBlock) (phase0.BLSSignature, error) {
+	if block == nil {
+		return phase0.BLSSignature{}, NewGenericErrorMessage("block is required")
+	}
+	slot, err := block.Slot()
+	if err != nil {
+		return phase0.BLSSignature{}, NewGenericError(err, "failed to get block slot")
+	}
+
+	return km.signDuty(ctx, fork, DomainBeaconProposer, epochAtSlot(slot), &models.SignRequestBlock{
+		VersionedBeaconBlock: block,
+	})
+}
+
+// SignBlindedBlock signs the given blinded beacon block.
+func (km *KeyManager) SignBlindedBlock(ctx context.Context, fork *ForkInfo, block *api.VersionedBlindedBeaconBlock) (phase0.BLSSignature, error) {
+	if block == nil {
+		return phase0.BLSSignature{}, NewGenericErrorMessage("blinded block is required")
+	}
+	slot, err := block.Slot()
+	if err != nil {
+		return phase0.BLSSignature{}, NewGenericError(err, "failed to get blinded block slot")
+	}
+
+	return km.signDuty(ctx, fork, DomainBeaconProposer, epochAtSlot(slot), &models.SignRequestBlindedBlock{
+		VersionedBlindedBeaconBlock: block,
+	})
+}
+
+// SignRandao signs the RANDAO reveal of the given epoch.
+func (km *KeyManager) SignRandao(ctx context.Context, fork *ForkInfo, epoch phase0.Epoch) (phase0.BLSSignature, error) {
+	return km.signDuty(ctx, fork, DomainRandao, epoch, &models.SignRequestEpoch{
+		Epoch: epoch,
+	})
+}
+
+// SignSelectionProof signs the aggregator selection proof of the given slot.
+func (km *KeyManager) SignSelectionProof(ctx context.Context, fork *ForkInfo, slot phase0.Slot) (phase0.BLSSignature, error) {
+	return km.signDuty(ctx, fork, DomainSelectionProof, epochAtSlot(slot), &models.SignRequestSlot{
+		Slot: slot,
+	})
+}
+
+// SignAggregateAndProof signs the given aggregate and proof.
+func (km *KeyManager) SignAggregateAndProof(ctx context.Context, fork *ForkInfo, aggregateAndProof *phase0.AggregateAndProof) (phase0.BLSSignature, error) {
+	if aggregateAndProof == nil || aggregateAndProof.Aggregate == nil || aggregateAndProof.Aggregate.Data == nil {
+		return phase0.BLSSignature{}, NewGenericErrorMessage("aggregate and proof is required")
+	}
+
+	return km.signDuty(ctx, fork, DomainAggregateAndProof, epochAtSlot(aggregateAndProof.Aggregate.Data.Slot), &models.SignRequestAggregateAttestationAndProof{
+		AggregateAttestationAndProof: aggregateAndProof,
+	})
+}
+
+// SignSyncCommitteeMessage signs the sync committee message of the given block root at the given slot.
+func (km *KeyManager) SignSyncCommitteeMessage(ctx context.Context, fork *ForkInfo, slot phase0.Slot, blockRoot phase0.Root) (phase0.BLSSignature, error) {
+	return km.signDuty(ctx, fork, DomainSyncCommittee, epochAtSlot(slot), &models.SignRequestSyncCommitteeMessage{
+		Root: blockRoot[:],
+	})
+}
+
+// SignSyncCommitteeSelectionProof signs the sync committee aggregator selection data.
+func (km *KeyManager) SignSyncCommitteeSelectionProof(ctx context.Context, fork *ForkInfo, data *altair.SyncAggregatorSelectionData) (phase0.BLSSignature, error) {
+	if data == nil {
+		return phase0.BLSSignature{}, NewGenericErrorMessage("sync aggregator selection data is required")
+	}
+
+	return km.signDuty(ctx, fork, DomainSyncCommitteeSelectionProof, epochAtSlot(data.Slot), &models.SignRequestSyncAggregatorSelectionData{
+		SyncAggregatorSelectionData: data,
+	})
+}
+
+// SignContribution signs the given sync committee contribution and proof.
+func (km *KeyManager) SignContribution(ctx context.Context, fork *ForkInfo, contribution *altair.ContributionAndProof) (phase0.BLSSignature, error) {
+	if contribution == nil || contribution.Contribution == nil {
+		return phase0.BLSSignature{}, NewGenericErrorMessage("contribution and proof is required")
+	}
+
+	return km.signDuty(ctx, fork, DomainContributionAndProof, epochAtSlot(contribution.Contribution.Slot), &models.SignRequestContributionAndProof{
+		ContributionAndProof: contribution,
+	})
+}
+
+// SignRegistration signs the given builder validator registration.
+// The domain is computed from the genesis fork version and a zero genesis validators root.
+func (km *KeyManager) SignRegistration(ctx context.Context, fork *ForkInfo, registration *api.VersionedValidatorRegistration) (phase0.BLSSignature, error) {
+	if registration == nil {
+		return phase0.BLSSignature{}, NewGenericErrorMessage("registration is required")
+	}
+	if fork == nil {
+		return phase0.BLSSignature{}, NewGenericErrorMessage("fork info is required")
+	}
+
+	domain, err := ComputeDomain(DomainApplicationBuilder, fork.GenesisForkVersion, phase0.Root{})
+	if err != nil {
+		return phase0.BLSSignature{}, NewGenericError(err, "failed to compute domain")
+	}
+
+	return km.signWithDomain(ctx, domain, &models.SignRequestRegistration{
+		VersionedValidatorRegistration: registration,
+	})
+}
+
+// SignVoluntaryExit signs the given voluntary exit.
+// It returns ErrExitPending if the exit waits for approvals, see AdminClient.ApproveExitRequest.
+func (km *KeyManager) SignVoluntaryExit(ctx context.Context, fork *ForkInfo, exit *phase0.VoluntaryExit) (phase0.BLSSignature, error) {
+	if exit == nil {
+		return phase0.BLSSignature{}, NewGenericErrorMessage("voluntary exit is required")
+	}
+
+	return km.signDuty(ctx, fork, DomainVoluntaryExit, exit.Epoch, &models.SignRequestVoluntaryExit{
+		VoluntaryExit: exit,
+	})
+}
+
+// signDuty computes the domain of the given duty and signs the object.
+func (km *KeyManager) signDuty(ctx context.Context, fork *ForkInfo, domainType phase0.DomainType, epoch phase0.Epoch, obj models.ISignObject) (phase0.BLSSignature, error) {
+	domain, err := fork.Domain(domainType, epoch)
+	if err != nil {
+		return phase0.BLSSignature{}, NewGenericError(err, "failed to compute domain")
+	}
+
+	return km.signWithDomain(ctx, domain, obj)
+}
+
+// signWithDomain computes the signing root of the given object and signs it.
+func (km *KeyManager) signWithDomain(ctx context.Context, domain phase0.Domain, obj models.ISignObject) (phase0.BLSSignature, error) {
+	req := &models.SignRequest{
+		PublicKey:       km.pubKey[:],
+		SignatureDomain: domain,
+		Object:          obj,
+	}
+	signingRoot, err := ComputeSigningRoot(req)
+	if err != nil {
+		return phase0.BLSSignature{}, NewGenericError(err, "failed to compute signing root")
+	}
+	req.SigningRoot = signingRoot[:]
+
+	return km.Sign(ctx, req)
+}
@@ -0,0 +1,211 @@
+package keymanager_test
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/api"
+	eth2apiv1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/prysmaticlabs/go-bitfield"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/keymanager"
+	"github.com/bloxapp/key-vault/keymanager/models"
+	"github.com/bloxapp/key-vault/utils/encoder"
+)
+
+func TestKeyManager_Duties(t *testing.T) {
+	pubKey := "8e80066551a81b318258709edaf7dd1f63cd686a0e4db8b29bbb7acfe65608677af5a527d9448ee47835485e02b50bc0"
+	var lastPath string
+	var lastReq *models.SignRequest
+	var lastSig []byte
+	s := newTestRemoteWallet(func(writer http.ResponseWriter, request *http.Request) {
+		var reqBody map[string]interface{}
+		require.NoError(t, json.NewDecoder(request.Body).Decode(&reqBody))
+		valByts, err := hex.DecodeString(reqBody["sign_req"].(string))
+		require.NoError(t, err)
+
+		lastPath = request.URL.Path
+		lastReq = &models.SignRequest{}
+		require.NoError(t, encoder.New().Decode(valByts, lastReq))
+
+		root, err := keymanager.ComputeSigningRoot(lastReq)
+		require.NoError(t, err)
+		lastSig = signTestRoot(t, root)
+
+		require.NoError(t, json.NewEncoder(writer).Encode(&logical.Response{
+			Data: map[string]interface{}{
+				"signature": hex.EncodeToString(lastSig),
+			},
+		}))
+	})
+	defer s.Close()
+
+	km, err := keymanager.NewKeyManager(logrus.NewEntry(logrus.New()), &keymanager.Config{
+		Location:    s.URL,
+		AccessToken: DefaultAccessToken,
+		PubKey:      pubKey,
+		Network:     "prater",
+	})
+	require.NoError(t, err)
+
+	fork := testForkInfo()
+	domainAt := func(t *testing.T, domainType phase0.DomainType, epoch phase0.Epoch) phase0.Domain {
+		domain, err := fork.Domain(domainType, epoch)
+		require.NoError(t, err)
+		return domain
+	}
+	attestationData := &phase0.AttestationData{
+		Slot:   352,
+		Source: &phase0.Checkpoint{Epoch: 9},
+		Target: &phase0.Checkpoint{Epoch: 11},
+	}
+
+	tests := []struct {
+		name           string
+		sign           func() (phase0.BLSSignature, error)
+		expectedPath   string
+		expectedDomain phase0.Domain
+		expectedObject models.ISignObject
+	}{
+		{
+			name: "attestation",
+			sign: func() (phase0.BLSSignature, error) {
+				return km.SignAttestation(context.Background(), fork, attestationData)
+			},
+			expectedPath:   "/v1/ethereum/prater/accounts/sign",
+			expectedDomain: domainAt(t, keymanager.DomainBeaconAttester, 11),
+			expectedObject: &models.SignRequestAttestationData{},
+		},
+		{
+			name: "block",
+			sign: func() (phase0.BLSSignature, error) {
+				return km.SignBlock(context.Background(), fork, testRequest(t).GetBlock())
+			},
+			expectedPath:   "/v1/ethereum/prater/accounts/sign",
+			expectedDomain: domainAt(t, keymanager.DomainBeaconProposer, 0),
+			expectedObject: &models.SignRequestBlock{},
+		},
+		{
+			name: "randao",
+			sign: func() (phase0.BLSSignature, error) {
+				return km.SignRandao(context.Background(), fork, 12)
+			},
+			expectedPath:   "/v1/ethereum/prater/accounts/sign",
+			expectedDomain: domainAt(t, keymanager.DomainRandao, 12),
+			expectedObject: &models.SignRequestEpoch{},
+		},
+		{
+			name: "selection proof",
+			sign: func() (phase0.BLSSignature, error) {
+				return km.SignSelectionProof(context.Background(), fork, 320)
+			},
+			expectedPath:   "/v1/ethereum/prater/accounts/sign",
+			expectedDomain: domainAt(t, keymanager.DomainSelectionProof, 10),
+			expectedObject: &models.SignRequestSlot{},
+		},
+		{
+			name: "aggregate and proof",
+			sign: func() (phase0.BLSSignature, error) {
+				return km.SignAggregateAndProof(context.Background(), fork, &phase0.AggregateAndProof{
+					Aggregate: &phase0.Attestation{
+						AggregationBits: bitfield.NewBitlist(12),
+						Data:            attestationData,
+					},
+				})
+			},
+			expectedPath:   "/v1/ethereum/prater/accounts/sign",
+			expectedDomain: domainAt(t, keymanager.DomainAggregateAndProof, 11),
+			expectedObject: &models.SignRequestAggregateAttestationAndProof{},
+		},
+		{
+			name: "sync committee message",
+			sign: func() (phase0.BLSSignature, error) {
+				return km.SignSyncCommitteeMessage(context.Background(), fork, 352, phase0.Root{1})
+			},
+			expectedPath:   "/v1/ethereum/prater/accounts/sign",
+			expectedDomain: domainAt(t, keymanager.DomainSyncCommittee, 11),
+			expectedObject: &models.SignRequestSyncCommitteeMessage{},
+		},
+		{
+			name: "sync committee selection proof",
+			sign: func() (phase0.BLSSignature, error) {
+				return km.SignSyncCommitteeSelectionProof(context.Background(), fork, &altair.SyncAggregatorSelectionData{Slot: 352})
+			},
+			expectedPath:   "/v1/ethereum/prater/accounts/sign",
+			expectedDomain: domainAt(t, keymanager.DomainSyncCommitteeSelectionProof, 11),
+			expectedObject: &models.SignRequestSyncAggregatorSelectionData{},
+		},
+		{
+			name: "contribution",
+			sign: func() (phase0.BLSSignature, error) {
+				return km.SignContribution(context.Background(), fork, &altair.ContributionAndProof{
+					Contribution: &altair.SyncCommitteeContribution{
+						Slot:            352,
+						AggregationBits: bitfield.NewBitvector128(),
+					},
+				})
+			},
+			expectedPath:   "/v1/ethereum/prater/accounts/sign",
+			expectedDomain: domainAt(t, keymanager.DomainContributionAndProof, 11),
+			expectedObject: &models.SignRequestContributionAndProof{},
+		},
+		{
+			name: "registration",
+			sign: func() (phase0.BLSSignature, error) {
+				return km.SignRegistration(context.Background(), fork, &api.VersionedValidatorRegistration{
+					Version: spec.BuilderVersionV1,
+					V1: &eth2apiv1.ValidatorRegistration{
+						GasLimit: 30000000,
+					},
+				})
+			},
+			expectedPath: "/v1/ethereum/prater/accounts/sign",
+			expectedDomain: func() phase0.Domain {
+				domain, err := keymanager.ComputeDomain(keymanager.DomainApplicationBuilder, fork.GenesisForkVersion, phase0.Root{})
+				require.NoError(t, err)
+				return domain
+			}(),
+			expectedObject: &models.SignRequestRegistration{},
+		},
+		{
+			name: "voluntary exit",
+			sign: func() (phase0.BLSSignature, error) {
+				return km.SignVoluntaryExit(context.Background(), fork, &phase0.VoluntaryExit{Epoch: 9, ValidatorIndex: 1})
+			},
+			expectedPath:   "/v1/ethereum/prater/accounts/sign-voluntary-exit",
+			expectedDomain: domainAt(t, keymanager.DomainVoluntaryExit, 9),
+			expectedObject: &models.SignRequestVoluntaryExit{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig, err := tt.sign()
+			require.NoError(t, err)
+			require.EqualValues(t, lastSig, sig[:])
+
+			require.Equal(t, tt.expectedPath, lastPath)
+			require.EqualValues(t, _byteArray(pubKey), lastReq.PublicKey)
+			require.Equal(t, tt.expectedDomain, lastReq.GetSignatureDomain())
+			require.IsType(t, tt.expectedObject, lastReq.GetObject())
+
+			expectedRoot, err := keymanager.ComputeSigningRoot(lastReq)
+			require.NoError(t, err)
+			require.EqualValues(t, expectedRoot[:], lastReq.SigningRoot)
+		})
+	}
+
+	t.Run("fork info is required", func(t *testing.T) {
+		_, err := km.SignRandao(context.Background(), nil, 1)
+		require.Error(t, err)
+		require.True(t, keymanager.IsGenericError(err))
+	})
+}
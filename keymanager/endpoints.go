@@ -0,0 +1,247 @@
+package keymanager
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Default configuration of the endpoints circuit breaker.
+const (
+	endpointFailureThreshold = 3
+	endpointCooldown         = time.Second * 30
+)
+
+// EndpointState represents the health state of a remote endpoint.
+type EndpointState string
+
+// Available endpoint states.
+const (
+	// EndpointUnknown is the state of an endpoint that has not been used yet.
+	EndpointUnknown EndpointState = "unknown"
+
+	// EndpointActive is the state of an endpoint that served the last request.
+	EndpointActive EndpointState = "active"
+
+	// EndpointStandby is the state of an endpoint that is reachable but is not the active Vault node.
+	EndpointStandby EndpointState = "standby"
+
+	// EndpointUnhealthy is the state of an endpoint that failed the last request.
+	EndpointUnhealthy EndpointState = "unhealthy"
+
+	// EndpointOpen is the state of an endpoint which is skipped until its cooldown expires.
+	EndpointOpen EndpointState = "open"
+)
+
+// EndpointStatus contains the health status of a remote endpoint.
+type EndpointStatus struct {
+	Address  string        `json:"address"`
+	State    EndpointState `json:"state"`
+	Failures int           `json:"failures"`
+}
+
+// remoteEndpoint represents a single remote key manager address.
+type remoteEndpoint struct {
+	address   string
+	scheme    string
+	host      string
+	state     EndpointState
+	failures  int
+	openUntil time.Time
+}
+
+// endpointPool tracks the health of remote endpoints and orders them for failover.
+type endpointPool struct {
+	mu        sync.Mutex
+	endpoints []*remoteEndpoint
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	log       *logrus.Entry
+}
+
+// newEndpointPool is the constructor of endpointPool.
+func newEndpointPool(log *logrus.Entry, addresses []string) *endpointPool {
+	pool := &endpointPool{
+		threshold: endpointFailureThreshold,
+		cooldown:  endpointCooldown,
+		now:       time.Now,
+		log:       log,
+	}
+
+	seen := make(map[string]struct{}, len(addresses))
+	for _, address := range addresses {
+		address = strings.TrimSuffix(address, "/")
+		if len(address) == 0 {
+			continue
+		}
+		if _, ok := seen[address]; ok {
+			continue
+		}
+		seen[address] = struct{}{}
+
+		scheme, host := schemeAndHostOf(address)
+		pool.endpoints = append(pool.endpoints, &remoteEndpoint{
+			address: address,
+			scheme:  scheme,
+			host:    host,
+			state:   EndpointUnknown,
+		})
+	}
+	return pool
+}
+
+// candidates returns the endpoints in the order they should be tried.
+// The active node goes first, then unknown, standby and unhealthy endpoints.
+// Endpoints with an open circuit are skipped until their cooldown expires,
+// unless there is nothing else to try.
+func (p *endpointPool) candidates() []*remoteEndpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	ordered := make([]*remoteEndpoint, 0, len(p.endpoints))
+	for _, state := range []EndpointState{EndpointActive, EndpointUnknown, EndpointStandby, EndpointUnhealthy} {
+		for _, e := range p.endpoints {
+			if e.state == state {
+				ordered = append(ordered, e)
+			}
+		}
+	}
+
+	var open []*remoteEndpoint
+	for _, e := range p.endpoints {
+		if e.state != EndpointOpen {
+			continue
+		}
+		if now.After(e.openUntil) {
+			// Half-open: give the endpoint another chance after healthy ones.
+			ordered = append(ordered, e)
+			continue
+		}
+		open = append(open, e)
+	}
+	if len(ordered) == 0 {
+		return open
+	}
+	return ordered
+}
+
+// markSuccess marks the given endpoint as reachable.
+// If the request was redirected to another node, that node is considered active.
+func (p *endpointPool) markSuccess(e *remoteEndpoint, finalURL *url.URL) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e.failures = 0
+	if finalURL == nil || finalURL.Host == e.host {
+		p.setState(e, EndpointActive)
+		p.demoteOthers(e)
+		return
+	}
+
+	p.setState(e, EndpointStandby)
+	for _, other := range p.endpoints {
+		if other.host == finalURL.Host {
+			other.failures = 0
+			p.setState(other, EndpointActive)
+			p.demoteOthers(other)
+			return
+		}
+	}
+}
+
+// markStandby marks the given endpoint as a reachable, but not active node.
+func (p *endpointPool) markStandby(e *remoteEndpoint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e.failures = 0
+	p.setState(e, EndpointStandby)
+}
+
+// markFailure registers a failure of the given endpoint and opens its circuit
+// once the number of consecutive failures reaches the threshold.
+func (p *endpointPool) markFailure(e *remoteEndpoint, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e.failures++
+	if e.failures >= p.threshold {
+		e.openUntil = p.now().Add(p.cooldown)
+		p.log.WithError(err).WithFields(logrus.Fields{
+			"endpoint": e.address,
+			"failures": e.failures,
+			"cooldown": p.cooldown.String(),
+		}).Warn("endpoint circuit opened")
+		p.setState(e, EndpointOpen)
+		return
+	}
+	p.log.WithError(err).WithFields(logrus.Fields{
+		"endpoint": e.address,
+		"failures": e.failures,
+	}).Warn("endpoint request failed")
+	p.setState(e, EndpointUnhealthy)
+}
+
+// contains returns true if the given URL is on one of the endpoints, with the same scheme.
+func (p *endpointPool) contains(u *url.URL) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, e := range p.endpoints {
+		if e.scheme == u.Scheme && e.host == u.Host {
+			return true
+		}
+	}
+	return false
+}
+
+// statuses returns the current state of all endpoints.
+func (p *endpointPool) statuses() []EndpointStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	statuses := make([]EndpointStatus, len(p.endpoints))
+	for i, e := range p.endpoints {
+		statuses[i] = EndpointStatus{
+			Address:  e.address,
+			State:    e.state,
+			Failures: e.failures,
+		}
+	}
+	return statuses
+}
+
+// demoteOthers makes sure there is only one active endpoint.
+func (p *endpointPool) demoteOthers(active *remoteEndpoint) {
+	for _, e := range p.endpoints {
+		if e != active && e.state == EndpointActive {
+			p.setState(e, EndpointStandby)
+		}
+	}
+}
+
+func (p *endpointPool) setState(e *remoteEndpoint, state EndpointState) {
+	if e.state == state {
+		return
+	}
+
+	p.log.WithFields(logrus.Fields{
+		"endpoint": e.address,
+		"from":     e.state,
+		"to":       state,
+	}).Info("endpoint state changed")
+	e.state = state
+}
+
+func schemeAndHostOf(address string) (string, string) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", address
+	}
+	return u.Scheme, u.Host
+}
@@ -0,0 +1,101 @@
+package keymanager
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func candidateAddresses(pool *endpointPool) []string {
+	var addresses []string
+	for _, e := range pool.candidates() {
+		addresses = append(addresses, e.address)
+	}
+	return addresses
+}
+
+func TestEndpointPool(t *testing.T) {
+	newPool := func() *endpointPool {
+		return newEndpointPool(logrus.NewEntry(logrus.New()), []string{
+			"https://vault-1:8200",
+			"https://vault-2:8200/",
+			"https://vault-2:8200",
+			"",
+			"https://vault-3:8200",
+		})
+	}
+
+	t.Run("deduplicates addresses and keeps the order", func(t *testing.T) {
+		pool := newPool()
+		require.Equal(t, []string{"https://vault-1:8200", "https://vault-2:8200", "https://vault-3:8200"}, candidateAddresses(pool))
+	})
+
+	t.Run("prefers the active endpoint", func(t *testing.T) {
+		pool := newPool()
+		pool.markSuccess(pool.endpoints[1], nil)
+		require.Equal(t, []string{"https://vault-2:8200", "https://vault-1:8200", "https://vault-3:8200"}, candidateAddresses(pool))
+
+		pool.markSuccess(pool.endpoints[2], nil)
+		require.Equal(t, EndpointStandby, pool.endpoints[1].state)
+		require.Equal(t, []string{"https://vault-3:8200", "https://vault-1:8200", "https://vault-2:8200"}, candidateAddresses(pool))
+	})
+
+	t.Run("follows standby redirects", func(t *testing.T) {
+		pool := newPool()
+		redirected, err := url.Parse("https://vault-3:8200/v1/ethereum/prater/accounts/sign")
+		require.NoError(t, err)
+
+		pool.markSuccess(pool.endpoints[0], redirected)
+		require.Equal(t, EndpointStandby, pool.endpoints[0].state)
+		require.Equal(t, EndpointActive, pool.endpoints[2].state)
+		require.Equal(t, []string{"https://vault-3:8200", "https://vault-2:8200", "https://vault-1:8200"}, candidateAddresses(pool))
+	})
+
+	t.Run("opens the circuit after consecutive failures", func(t *testing.T) {
+		pool := newPool()
+		now := time.Now()
+		pool.now = func() time.Time { return now }
+
+		for i := 0; i < endpointFailureThreshold-1; i++ {
+			pool.markFailure(pool.endpoints[0], errors.New("connection refused"))
+		}
+		require.Equal(t, EndpointUnhealthy, pool.endpoints[0].state)
+		require.Equal(t, []string{"https://vault-2:8200", "https://vault-3:8200", "https://vault-1:8200"}, candidateAddresses(pool))
+
+		pool.markFailure(pool.endpoints[0], errors.New("connection refused"))
+		require.Equal(t, EndpointOpen, pool.endpoints[0].state)
+		require.Equal(t, []string{"https://vault-2:8200", "https://vault-3:8200"}, candidateAddresses(pool))
+
+		// Half-open after cooldown
+		now = now.Add(endpointCooldown + time.Second)
+		require.Equal(t, []string{"https://vault-2:8200", "https://vault-3:8200", "https://vault-1:8200"}, candidateAddresses(pool))
+
+		pool.markSuccess(pool.endpoints[0], nil)
+		require.Equal(t, EndpointActive, pool.endpoints[0].state)
+		require.Zero(t, pool.endpoints[0].failures)
+	})
+
+	t.Run("returns open endpoints if nothing else is available", func(t *testing.T) {
+		pool := newEndpointPool(logrus.NewEntry(logrus.New()), []string{"https://vault-1:8200"})
+		for i := 0; i < endpointFailureThreshold; i++ {
+			pool.markFailure(pool.endpoints[0], errors.New("connection refused"))
+		}
+		require.Equal(t, []string{"https://vault-1:8200"}, candidateAddresses(pool))
+	})
+
+	t.Run("reports endpoint statuses", func(t *testing.T) {
+		pool := newPool()
+		pool.markSuccess(pool.endpoints[0], nil)
+		pool.markStandby(pool.endpoints[1])
+		pool.markFailure(pool.endpoints[2], errors.New("connection refused"))
+		require.Equal(t, []EndpointStatus{
+			{Address: "https://vault-1:8200", State: EndpointActive},
+			{Address: "https://vault-2:8200", State: EndpointStandby},
+			{Address: "https://vault-3:8200", State: EndpointUnhealthy, Failures: 1},
+		}, pool.statuses())
+	})
+}
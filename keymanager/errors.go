@@ -1,11 +1,15 @@
 package keymanager
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/http"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+
+	"github.com/bloxapp/key-vault/utils/errorex"
 )
 
 // HTTPRequestError represents an HTTP request error.
@@ -26,10 +30,27 @@ func NewHTTPRequestError(url string, statusCode int, responseBody []byte, messag
 	}
 }
 
-// IsHTTPRequestError returns true if the given error is HTTPRequestError
+// IsHTTPRequestError returns true if the given error is HTTPRequestError or any of the backend errors
 func IsHTTPRequestError(err error) bool {
-	_, ok := errors.Cause(err).(*HTTPRequestError)
-	return ok
+	var httpErr *HTTPRequestError
+	return errors.As(err, &httpErr)
+}
+
+// IsNotFoundError returns true if the given error is HTTPRequestError with 404 status code
+func IsNotFoundError(err error) bool {
+	httpErr, ok := errors.Cause(err).(*HTTPRequestError)
+	return ok && httpErr.StatusCode == http.StatusNotFound
+}
+
+// Errors returns the error messages of the Vault error response.
+func (e *HTTPRequestError) Errors() []string {
+	var resp struct {
+		Errors []string `json:"errors"`
+	}
+	if err := json.Unmarshal(e.ResponseBody, &resp); err != nil {
+		return nil
+	}
+	return resp.Errors
 }
 
 // Error implements error interface
@@ -50,6 +71,204 @@ func (e *HTTPRequestError) String() string {
 	return string(data)
 }
 
+// BackendError represents a typed error answered by the plugin backend.
+// It is never returned as is for known codes, use errors.As with the distinct types below.
+type BackendError struct {
+	*HTTPRequestError
+	Code errorex.ErrorCode `json:"code"`
+}
+
+// Backend errors by code.
+type (
+	// ErrBadRequest is returned when the backend rejects a malformed request.
+	ErrBadRequest struct{ *BackendError }
+	// ErrUnknownAccount is returned when the requested account does not exist in the wallet.
+	ErrUnknownAccount struct{ *BackendError }
+	// ErrSlashable is returned when signing the request could get the validator slashed.
+	ErrSlashable struct{ *BackendError }
+	// ErrPolicyViolation is returned when the request violates the mount configuration, e.g. the fee recipient.
+	ErrPolicyViolation struct{ *BackendError }
+	// ErrNotConfigured is returned when the plugin mount has not been configured yet.
+	ErrNotConfigured struct{ *BackendError }
+	// ErrStorageFailure is returned when the backend fails to read or write its storage.
+	ErrStorageFailure struct{ *BackendError }
+	// ErrRateLimited is returned when the request exceeds the rate limits of the plugin mount.
+	ErrRateLimited struct{ *BackendError }
+)
+
+// ErrExitPending is returned when a voluntary exit waits for the approval of other Vault entities before it is signed.
+// ExitID identifies the exit request, see AdminClient.ApproveExitRequest.
+type ErrExitPending struct {
+	*BackendError
+	ExitID string `json:"exit_id"`
+}
+
+// newBackendError decodes the coded error of the backend response body.
+// It returns the given HTTP error if the body has no error code.
+func newBackendError(httpErr *HTTPRequestError) error {
+	var resp struct {
+		Data struct {
+			Code    errorex.ErrorCode `json:"code"`
+			Message string            `json:"message"`
+			ExitID  string            `json:"exit_id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(httpErr.ResponseBody, &resp); err != nil || len(resp.Data.Code) == 0 {
+		return httpErr
+	}
+
+	httpErr.Message = resp.Data.Message
+	backendErr := &BackendError{
+		HTTPRequestError: httpErr,
+		Code:             resp.Data.Code,
+	}
+	switch backendErr.Code {
+	case errorex.CodeBadRequest:
+		return &ErrBadRequest{backendErr}
+	case errorex.CodeUnknownAccount:
+		return &ErrUnknownAccount{backendErr}
+	case errorex.CodeSlashable:
+		return &ErrSlashable{backendErr}
+	case errorex.CodePolicyViolation:
+		return &ErrPolicyViolation{backendErr}
+	case errorex.CodeNotConfigured:
+		return &ErrNotConfigured{backendErr}
+	case errorex.CodeStorageFailure:
+		return &ErrStorageFailure{backendErr}
+	case errorex.CodeRateLimited:
+		return &ErrRateLimited{backendErr}
+	case errorex.CodeExitPending:
+		return &ErrExitPending{BackendError: backendErr, ExitID: resp.Data.ExitID}
+	default:
+		return backendErr
+	}
+}
+
+// BackendErrorCode returns the code of the given backend error.
+func BackendErrorCode(err error) (errorex.ErrorCode, bool) {
+	var coded interface{ ErrorCode() errorex.ErrorCode }
+	if errors.As(err, &coded) {
+		return coded.ErrorCode(), true
+	}
+	return "", false
+}
+
+// ErrorCode returns the machine-readable code of the error.
+func (e *BackendError) ErrorCode() errorex.ErrorCode {
+	return e.Code
+}
+
+// Unwrap returns the underlying HTTP request error.
+func (e *BackendError) Unwrap() error {
+	return e.HTTPRequestError
+}
+
+// Error implements error interface.
+func (e *BackendError) Error() string {
+	return e.String()
+}
+
+// String returns a readable string representation of a BackendError struct.
+func (e *BackendError) String() string {
+	if e == nil {
+		return ""
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	return string(data)
+}
+
+// SignatureVerificationError represents an invalid signature returned by the remote key manager.
+type SignatureVerificationError struct {
+	PubKey      string `json:"public_key"`
+	SigningRoot string `json:"signing_root"`
+	Signature   string `json:"signature,omitempty"`
+	Message     string `json:"message"`
+}
+
+// NewSignatureVerificationError is the constructor of SignatureVerificationError.
+func NewSignatureVerificationError(pubKey []byte, signingRoot [32]byte, signature []byte, message string) *SignatureVerificationError {
+	return &SignatureVerificationError{
+		PubKey:      hex.EncodeToString(pubKey),
+		SigningRoot: hex.EncodeToString(signingRoot[:]),
+		Signature:   hex.EncodeToString(signature),
+		Message:     message,
+	}
+}
+
+// IsSignatureVerificationError returns true if the given error is SignatureVerificationError
+func IsSignatureVerificationError(err error) bool {
+	_, ok := errors.Cause(err).(*SignatureVerificationError)
+	return ok
+}
+
+// Error implements error interface.
+func (e *SignatureVerificationError) Error() string {
+	return e.String()
+}
+
+// String returns a readable string representation of a SignatureVerificationError struct.
+func (e *SignatureVerificationError) String() string {
+	if e == nil {
+		return ""
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	return string(data)
+}
+
+// PartialSignatureError is returned when the remote key manager signs for a group public key with its key share.
+// The partial signature is verified against the share public key, it is combined with the partial signatures
+// of the other shares by AdminClient.CombinePartialSignatures.
+type PartialSignatureError struct {
+	PubKey         string `json:"public_key"`
+	SigningRoot    string `json:"signing_root"`
+	ShareIndex     uint64 `json:"share_index"`
+	SharePublicKey string `json:"share_public_key"`
+	Signature      string `json:"signature"`
+}
+
+// NewPartialSignatureError is the constructor of PartialSignatureError.
+func NewPartialSignatureError(pubKey []byte, signingRoot [32]byte, shareIndex uint64, sharePubKey, signature []byte) *PartialSignatureError {
+	return &PartialSignatureError{
+		PubKey:         hex.EncodeToString(pubKey),
+		SigningRoot:    hex.EncodeToString(signingRoot[:]),
+		ShareIndex:     shareIndex,
+		SharePublicKey: hex.EncodeToString(sharePubKey),
+		Signature:      hex.EncodeToString(signature),
+	}
+}
+
+// IsPartialSignatureError returns true if the given error is PartialSignatureError
+func IsPartialSignatureError(err error) bool {
+	_, ok := errors.Cause(err).(*PartialSignatureError)
+	return ok
+}
+
+// Error implements error interface.
+func (e *PartialSignatureError) Error() string {
+	return e.String()
+}
+
+// String returns a readable string representation of a PartialSignatureError struct.
+func (e *PartialSignatureError) String() string {
+	if e == nil {
+		return ""
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	return string(data)
+}
+
 // GenericError represents the generic error of keymanager.
 type GenericError struct {
 	ErrorMsg string `json:"error"`
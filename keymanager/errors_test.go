@@ -0,0 +1,141 @@
+package keymanager_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/keymanager"
+	"github.com/bloxapp/key-vault/utils/errorex"
+)
+
+func TestKeyManager_BackendErrors(t *testing.T) {
+	var codedErr *errorex.CodedError
+	s := newTestRemoteWallet(func(writer http.ResponseWriter, request *http.Request) {
+		resp, err := codedErr.ToLogicalResponse()
+		require.NoError(t, err)
+		writer.WriteHeader(resp.Data[logical.HTTPStatusCode].(int))
+		_, _ = writer.Write([]byte(resp.Data[logical.HTTPRawBody].(string)))
+	})
+	defer s.Close()
+
+	km, err := keymanager.NewKeyManager(logrus.NewEntry(logrus.New()), &keymanager.Config{
+		Location:    s.URL,
+		AccessToken: DefaultAccessToken,
+		PubKey:      "8e80066551a81b318258709edaf7dd1f63cd686a0e4db8b29bbb7acfe65608677af5a527d9448ee47835485e02b50bc0",
+		Network:     "prater",
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		code  errorex.ErrorCode
+		match func(err error) bool
+	}{
+		{
+			code:  errorex.CodeBadRequest,
+			match: func(err error) bool { var target *keymanager.ErrBadRequest; return errors.As(err, &target) },
+		},
+		{
+			code:  errorex.CodeUnknownAccount,
+			match: func(err error) bool { var target *keymanager.ErrUnknownAccount; return errors.As(err, &target) },
+		},
+		{
+			code:  errorex.CodeSlashable,
+			match: func(err error) bool { var target *keymanager.ErrSlashable; return errors.As(err, &target) },
+		},
+		{
+			code:  errorex.CodePolicyViolation,
+			match: func(err error) bool { var target *keymanager.ErrPolicyViolation; return errors.As(err, &target) },
+		},
+		{
+			code:  errorex.CodeNotConfigured,
+			match: func(err error) bool { var target *keymanager.ErrNotConfigured; return errors.As(err, &target) },
+		},
+		{
+			code:  errorex.CodeStorageFailure,
+			match: func(err error) bool { var target *keymanager.ErrStorageFailure; return errors.As(err, &target) },
+		},
+		{
+			code:  errorex.CodeRateLimited,
+			match: func(err error) bool { var target *keymanager.ErrRateLimited; return errors.As(err, &target) },
+		},
+		{
+			code:  errorex.CodeExitPending,
+			match: func(err error) bool { var target *keymanager.ErrExitPending; return errors.As(err, &target) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.code), func(t *testing.T) {
+			codedErr = errorex.NewCodedError(tt.code, "failed to sign: test")
+
+			_, err := km.Sign(context.Background(), testRequest(t))
+			require.Error(t, err)
+			require.True(t, tt.match(err))
+			require.True(t, keymanager.IsHTTPRequestError(err))
+
+			code, ok := keymanager.BackendErrorCode(err)
+			require.True(t, ok)
+			require.Equal(t, tt.code, code)
+
+			var httpErr *keymanager.HTTPRequestError
+			require.True(t, errors.As(err, &httpErr))
+			require.Equal(t, tt.code.StatusCode(), httpErr.StatusCode)
+			require.Equal(t, "failed to sign: test", httpErr.Message)
+		})
+	}
+
+	t.Run("pending exit", func(t *testing.T) {
+		s := newTestRemoteWallet(func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusAccepted)
+			_, _ = writer.Write([]byte(`{"data":{"code":"exit_pending","message":"voluntary exit is pending approval","exit_id":"9c7c1d6e-4f2a-4bde-a1a6-2f0f3a8b1c55","status":"pending"}}`))
+		})
+		defer s.Close()
+
+		km, err := keymanager.NewKeyManager(logrus.NewEntry(logrus.New()), &keymanager.Config{
+			Location:    s.URL,
+			AccessToken: DefaultAccessToken,
+			PubKey:      "8e80066551a81b318258709edaf7dd1f63cd686a0e4db8b29bbb7acfe65608677af5a527d9448ee47835485e02b50bc0",
+			Network:     "prater",
+		})
+		require.NoError(t, err)
+
+		// the pending exit isn't verified as a signature
+		invalidCount := keymanager.InvalidSignaturesCount()
+		_, err = km.Sign(context.Background(), testRequest(t))
+		require.False(t, keymanager.IsSignatureVerificationError(err))
+		require.Equal(t, invalidCount, keymanager.InvalidSignaturesCount())
+
+		var pending *keymanager.ErrExitPending
+		require.True(t, errors.As(err, &pending))
+		require.Equal(t, "9c7c1d6e-4f2a-4bde-a1a6-2f0f3a8b1c55", pending.ExitID)
+		require.Equal(t, "voluntary exit is pending approval", pending.Message)
+	})
+
+	t.Run("uncoded error", func(t *testing.T) {
+		s := newTestRemoteWallet(func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = writer.Write([]byte(`{"errors":["missing client token"]}`))
+		})
+		defer s.Close()
+
+		km, err := keymanager.NewKeyManager(logrus.NewEntry(logrus.New()), &keymanager.Config{
+			Location:    s.URL,
+			AccessToken: DefaultAccessToken,
+			PubKey:      "8e80066551a81b318258709edaf7dd1f63cd686a0e4db8b29bbb7acfe65608677af5a527d9448ee47835485e02b50bc0",
+			Network:     "prater",
+		})
+		require.NoError(t, err)
+
+		_, err = km.Sign(context.Background(), testRequest(t))
+		require.Error(t, err)
+		require.True(t, keymanager.IsHTTPRequestError(err))
+
+		_, ok := keymanager.BackendErrorCode(err)
+		require.False(t, ok)
+	})
+}
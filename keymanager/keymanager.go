@@ -1,23 +1,14 @@
 package keymanager
 
 import (
-	"bytes"
 	"context"
 	"encoding/hex"
-	"encoding/json"
-	"io"
-	"net/http"
 
 	"github.com/attestantio/go-eth2-client/spec/phase0"
-	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
-	"github.com/bloxapp/key-vault/backend"
 	"github.com/bloxapp/key-vault/keymanager/models"
 	"github.com/bloxapp/key-vault/utils/bytex"
-	"github.com/bloxapp/key-vault/utils/encoder"
-	"github.com/bloxapp/key-vault/utils/endpoint"
-	"github.com/bloxapp/key-vault/utils/httpex"
 )
 
 // Predefined errors
@@ -39,26 +30,19 @@ type IkeyManager interface {
 
 // KeyManager is a key manager that accesses a remote vault wallet daemon through HTTP connection.
 type KeyManager struct {
-	remoteAddress string
-	accessToken   string
-	originPubKey  string
-	pubKey        [48]byte
-	network       string
-	httpClient    *http.Client
-	encoder       encoder.IEncoder
-
-	log *logrus.Entry
+	*transport
+
+	originPubKey string
+	pubKey       [48]byte
 }
 
 var _ IkeyManager = (*KeyManager)(nil)
 
 // NewKeyManager is the constructor of KeyManager.
 func NewKeyManager(log *logrus.Entry, opts *Config) (*KeyManager, error) {
-	if len(opts.Location) == 0 {
-		return nil, ErrLocationMissing
-	}
-	if len(opts.AccessToken) == 0 {
-		return nil, ErrTokenMissing
+	t, err := newTransport(log, opts)
+	if err != nil {
+		return nil, err
 	}
 	if len(opts.PubKey) == 0 {
 		return nil, ErrPubKeyMissing
@@ -73,35 +57,9 @@ func NewKeyManager(log *logrus.Entry, opts *Config) (*KeyManager, error) {
 	log.Logf(logrus.InfoLevel, "KeyManager initialing for %s network", opts.Network)
 
 	return &KeyManager{
-		remoteAddress: opts.Location,
-		accessToken:   opts.AccessToken,
-		originPubKey:  opts.PubKey,
-		pubKey:        bytex.ToBytes48(decodedPubKey),
-		network:       opts.Network,
-		encoder:       encoder.New(),
-		httpClient: httpex.CreateClient(log, func(resp *http.Response, err error, numTries int) (*http.Response, error) {
-			if err == nil {
-				return resp, nil
-			}
-
-			fields := logrus.Fields{}
-			if resp != nil {
-				fields["status_code"] = resp.StatusCode
-
-				if resp.Body != nil {
-					defer resp.Body.Close()
-
-					respBody, err := io.ReadAll(resp.Body)
-					if err != nil {
-						return resp, err
-					}
-					fields["response_body"] = string(respBody)
-				}
-			}
-			log.WithError(err).WithFields(fields).Error("failed to send request to key manager")
-			return resp, errors.Errorf("giving up after %d attempt(s): %s", numTries, err)
-		}),
-		log: log,
+		transport:    t,
+		originPubKey: opts.PubKey,
+		pubKey:       bytex.ToBytes48(decodedPubKey),
 	}, nil
 }
 
@@ -116,79 +74,11 @@ func (km *KeyManager) FetchAllValidatingPublicKeys(_ context.Context) ([][48]byt
 }
 
 // Sign implements IKeymanager interface.
+// The signatures of a group public key are returned as PartialSignatureError, see AdminClient.CombinePartialSignatures.
 func (km *KeyManager) Sign(ctx context.Context, req *models.SignRequest) (phase0.BLSSignature, error) {
 	if bytex.ToBytes48(req.GetPublicKey()) != km.pubKey {
 		return phase0.BLSSignature{}, ErrNoSuchKey
 	}
 
-	byts, err := km.encoder.Encode(req)
-	if err != nil {
-		return phase0.BLSSignature{}, errors.Wrap(err, "failed to encode request")
-	}
-	reqMap := map[string]interface{}{
-		"sign_req": hex.EncodeToString(byts),
-	}
-
-	var resp models.SignResponse
-	if err := km.sendRequest(ctx, http.MethodPost, backend.SignPattern, reqMap, &resp); err != nil {
-		return phase0.BLSSignature{}, err
-	}
-
-	// Signature is base64 encoded, so we have to decode that.
-	decodedSignature, err := hex.DecodeString(resp.Data.Signature)
-	if err != nil {
-		return phase0.BLSSignature{}, NewGenericError(err, "failed to base64 decode")
-	}
-
-	var signature phase0.BLSSignature
-	copy(signature[:], decodedSignature)
-	return signature, nil
-}
-
-// sendRequest implements the logic to work with HTTP requests.
-func (km *KeyManager) sendRequest(ctx context.Context, method, path string, reqBody interface{}, respBody interface{}) error {
-	networkPath, err := endpoint.Build(km.network, path)
-	if err != nil {
-		return NewGenericError(err, "could not build network path")
-	}
-	endpointStr := km.remoteAddress + networkPath
-
-	payloadByts, err := json.Marshal(reqBody)
-	if err != nil {
-		return err
-	}
-
-	// Prepare a new request
-	req, err := http.NewRequestWithContext(ctx, method, endpointStr, bytes.NewBuffer(payloadByts))
-	if err != nil {
-		return NewGenericError(err, "failed to create HTTP request")
-	}
-
-	// Pass auth token.
-	req.Header.Set("Authorization", "Bearer "+km.accessToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	// Send request.
-	resp, err := km.httpClient.Do(req)
-	if err != nil {
-		return NewGenericError(err, "failed to send HTTP request")
-	}
-	defer resp.Body.Close()
-
-	// Check status code. Must be 200.
-	if resp.StatusCode != http.StatusOK {
-		responseBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			km.log.WithError(err).Error("failed to read error response body")
-		}
-
-		return NewHTTPRequestError(endpointStr, resp.StatusCode, responseBody, "unexpected status code")
-	}
-
-	// Read response body into the given object.
-	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
-		return NewGenericError(err, "failed to decode response body")
-	}
-
-	return nil
+	return km.sign(ctx, req)
 }
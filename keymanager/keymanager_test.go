@@ -3,22 +3,38 @@ package keymanager_test
 import (
 	"context"
 	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/bloxapp/key-vault/keymanager/models"
 
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 
 	"github.com/bloxapp/key-vault/keymanager"
 	"github.com/bloxapp/key-vault/utils/bytex"
+	"github.com/bloxapp/key-vault/utils/httpex"
 )
 
 var (
 	DefaultAccountPublicKey = "965586b5d05c851873f26cb736ed42de96591674772576e7b43848cd7a5c2827a5c5228034fdd55be0e9dc0f0cbc91d7"
 	DefaultAccessToken      = "supersecureaccesstoken"
+
+	// testAccountSecretKey is the secret key of the account used by the fake remote wallets.
+	testAccountSecretKey = "3515c7d08e5affd729e9579f7588d30f2342ee6f6a9334acf006345262162c6f"
 )
 
 func TestNewKeyManager(t *testing.T) {
@@ -69,6 +85,22 @@ func TestNewKeyManager(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "invalid CA certificate",
+			args: args{
+				log: entry,
+				opts: &keymanager.Config{
+					Location:    "Location",
+					AccessToken: "AccessToken",
+					PubKey:      DefaultAccountPublicKey,
+					Network:     "Network",
+					TLS: &httpex.TLSOptions{
+						CACertFile: "/not/existing/ca.crt",
+					},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid public key",
 			args: args{
@@ -180,7 +212,7 @@ func TestUnknownAccount(t *testing.T) {
 	km, err := keymanager.NewKeyManager(logrus.NewEntry(logrus.New()), &keymanager.Config{
 		Location:    "location",
 		AccessToken: "access token",
-		PubKey:      "a3862121db5914d7272b0b705e6e3c5336b79e316735661873566245207329c30f9a33d4fb5f5857fc6fd0a368186972",
+		PubKey:      "8e80066551a81b318258709edaf7dd1f63cd686a0e4db8b29bbb7acfe65608677af5a527d9448ee47835485e02b50bc0",
 		Network:     "prater",
 	})
 	require.NoError(t, err)
@@ -193,6 +225,195 @@ func TestUnknownAccount(t *testing.T) {
 	require.EqualError(t, err, "{\"error\":\"no such key\"}")
 }
 
+func TestKeyManager_TLS(t *testing.T) {
+	expectedSig := _byteArray("aa16fbea0f61bba00816297a11bb1ea8ca1d9db8dd0c5b4b4cfaded37664bc043efa9e259bed01a33a6383cb04fc59a61483c6e4c2a63051eea661cc2e52642fc7e502047b68acc5be9b863f8c762d42f0b19c4856f16fe9855f3ccfb2658aa4")
+
+	s := httptest.NewTLSServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		require.NoError(t, json.NewEncoder(writer).Encode(&logical.Response{
+			Data: map[string]interface{}{
+				"signature": hex.EncodeToString(expectedSig),
+			},
+		}))
+	}))
+	defer s.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}), 0600))
+
+	newKeyManager := func(t *testing.T, tlsOpts *httpex.TLSOptions) *keymanager.KeyManager {
+		km, err := keymanager.NewKeyManager(logrus.NewEntry(logrus.New()), &keymanager.Config{
+			Location:    s.URL,
+			AccessToken: DefaultAccessToken,
+			PubKey:      "8e80066551a81b318258709edaf7dd1f63cd686a0e4db8b29bbb7acfe65608677af5a527d9448ee47835485e02b50bc0",
+			Network:     "prater",
+			TLS:         tlsOpts,
+		})
+		require.NoError(t, err)
+		return km
+	}
+
+	t.Run("trusted server certificate", func(t *testing.T) {
+		km := newKeyManager(t, &httpex.TLSOptions{CACertFile: caFile})
+		sig, err := km.Sign(context.Background(), testRequest(t))
+		require.NoError(t, err)
+		require.EqualValues(t, expectedSig, sig[:])
+	})
+
+	t.Run("pinned server public key", func(t *testing.T) {
+		km := newKeyManager(t, &httpex.TLSOptions{
+			CACertFile:       caFile,
+			PinnedPublicKeys: []string{httpex.PublicKeyPin(s.Certificate())},
+		})
+		_, err := km.Sign(context.Background(), testRequest(t))
+		require.NoError(t, err)
+	})
+
+	t.Run("untrusted server certificate", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		km := newKeyManager(t, nil)
+		_, err := km.Sign(ctx, testRequest(t))
+		require.Error(t, err)
+	})
+}
+
+func TestKeyManager_Failover(t *testing.T) {
+	expectedSig := _byteArray("aa16fbea0f61bba00816297a11bb1ea8ca1d9db8dd0c5b4b4cfaded37664bc043efa9e259bed01a33a6383cb04fc59a61483c6e4c2a63051eea661cc2e52642fc7e502047b68acc5be9b863f8c762d42f0b19c4856f16fe9855f3ccfb2658aa4")
+
+	var activeHits int32
+	active := newTestRemoteWallet(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&activeHits, 1)
+		require.NoError(t, json.NewEncoder(writer).Encode(&logical.Response{
+			Data: map[string]interface{}{
+				"signature": hex.EncodeToString(expectedSig),
+			},
+		}))
+	})
+	defer active.Close()
+
+	down := newTestRemoteWallet(func(writer http.ResponseWriter, request *http.Request) {})
+	down.Close()
+
+	standby := newTestRemoteWallet(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	})
+	defer standby.Close()
+
+	redirecting := newTestRemoteWallet(func(writer http.ResponseWriter, request *http.Request) {
+		http.Redirect(writer, request, active.URL+request.URL.Path, http.StatusTemporaryRedirect)
+	})
+	defer redirecting.Close()
+
+	failing := newTestRemoteWallet(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusInternalServerError)
+	})
+	defer failing.Close()
+
+	newKeyManager := func(t *testing.T, locations ...string) *keymanager.KeyManager {
+		km, err := keymanager.NewKeyManager(logrus.NewEntry(logrus.New()), &keymanager.Config{
+			Location:    locations[0],
+			Locations:   locations[1:],
+			AccessToken: DefaultAccessToken,
+			PubKey:      "8e80066551a81b318258709edaf7dd1f63cd686a0e4db8b29bbb7acfe65608677af5a527d9448ee47835485e02b50bc0",
+			Network:     "prater",
+		})
+		require.NoError(t, err)
+		return km
+	}
+
+	t.Run("fails over unreachable endpoint", func(t *testing.T) {
+		km := newKeyManager(t, down.URL, active.URL)
+		sig, err := km.Sign(context.Background(), testRequest(t))
+		require.NoError(t, err)
+		require.EqualValues(t, expectedSig, sig[:])
+		require.Equal(t, []keymanager.EndpointStatus{
+			{Address: down.URL, State: keymanager.EndpointUnhealthy, Failures: 1},
+			{Address: active.URL, State: keymanager.EndpointActive},
+		}, km.EndpointStatuses())
+
+		// The active endpoint is preferred afterwards
+		hits := atomic.LoadInt32(&activeHits)
+		_, err = km.Sign(context.Background(), testRequest(t))
+		require.NoError(t, err)
+		require.Equal(t, hits+1, atomic.LoadInt32(&activeHits))
+		require.Equal(t, 1, km.EndpointStatuses()[0].Failures)
+	})
+
+	t.Run("fails over standby endpoint", func(t *testing.T) {
+		km := newKeyManager(t, standby.URL, active.URL)
+		_, err := km.Sign(context.Background(), testRequest(t))
+		require.NoError(t, err)
+		require.Equal(t, keymanager.EndpointStandby, km.EndpointStatuses()[0].State)
+		require.Equal(t, keymanager.EndpointActive, km.EndpointStatuses()[1].State)
+	})
+
+	t.Run("honors standby redirects", func(t *testing.T) {
+		km := newKeyManager(t, redirecting.URL, active.URL)
+		_, err := km.Sign(context.Background(), testRequest(t))
+		require.NoError(t, err)
+		require.Equal(t, keymanager.EndpointStandby, km.EndpointStatuses()[0].State)
+		require.Equal(t, keymanager.EndpointActive, km.EndpointStatuses()[1].State)
+	})
+
+	t.Run("keeps the token across standby redirects", func(t *testing.T) {
+		// The HTTP client drops the token on a redirect to another host name
+		authenticated := newTestRemoteWallet(func(writer http.ResponseWriter, request *http.Request) {
+			if request.Header.Get("Authorization") != "Bearer "+DefaultAccessToken {
+				writer.WriteHeader(http.StatusForbidden)
+				return
+			}
+			require.NoError(t, json.NewEncoder(writer).Encode(&logical.Response{
+				Data: map[string]interface{}{
+					"signature": hex.EncodeToString(expectedSig),
+				},
+			}))
+		})
+		defer authenticated.Close()
+		authenticatedURL := strings.Replace(authenticated.URL, "127.0.0.1", "localhost", 1)
+		redirectingHost := newTestRemoteWallet(func(writer http.ResponseWriter, request *http.Request) {
+			http.Redirect(writer, request, authenticatedURL+request.URL.Path, http.StatusTemporaryRedirect)
+		})
+		defer redirectingHost.Close()
+
+		km := newKeyManager(t, redirectingHost.URL, authenticatedURL)
+		sig, err := km.Sign(context.Background(), testRequest(t))
+		require.NoError(t, err)
+		require.EqualValues(t, expectedSig, sig[:])
+		require.Equal(t, keymanager.EndpointStandby, km.EndpointStatuses()[0].State)
+		require.Equal(t, keymanager.EndpointActive, km.EndpointStatuses()[1].State)
+
+		// The token isn't sent to hosts out of the configured endpoints
+		hits := atomic.LoadInt32(&activeHits)
+		km = newKeyManager(t, redirectingHost.URL, active.URL)
+		_, err = km.Sign(context.Background(), testRequest(t))
+		require.NoError(t, err)
+		require.Equal(t, hits+1, atomic.LoadInt32(&activeHits))
+		require.Equal(t, keymanager.EndpointStandby, km.EndpointStatuses()[0].State)
+
+		km = newKeyManager(t, redirectingHost.URL)
+		_, err = km.Sign(context.Background(), testRequest(t))
+		require.True(t, keymanager.IsHTTPRequestError(err))
+		require.Equal(t, http.StatusTemporaryRedirect, errors.Cause(err).(*keymanager.HTTPRequestError).StatusCode)
+	})
+
+	t.Run("does not fail over on request errors", func(t *testing.T) {
+		hits := atomic.LoadInt32(&activeHits)
+		km := newKeyManager(t, failing.URL, active.URL)
+		_, err := km.Sign(context.Background(), testRequest(t))
+		require.Error(t, err)
+		require.True(t, keymanager.IsHTTPRequestError(err))
+		require.Equal(t, hits, atomic.LoadInt32(&activeHits))
+	})
+
+	t.Run("returns the last error if all endpoints fail", func(t *testing.T) {
+		km := newKeyManager(t, down.URL, standby.URL)
+		_, err := km.Sign(context.Background(), testRequest(t))
+		require.Error(t, err)
+		require.True(t, keymanager.IsHTTPRequestError(err))
+	})
+}
+
 func newTestRemoteWallet(handler http.HandlerFunc) *httptest.Server {
 	s := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		handler(writer, request)
@@ -212,3 +433,12 @@ func _byteArray32(input string) [32]byte {
 	copy(res32[:], res)
 	return res32
 }
+
+// signTestRoot signs the given signing root with the test account secret key.
+func signTestRoot(t *testing.T, root phase0.Root) []byte {
+	require.NoError(t, core.InitBLS())
+
+	var sk bls.SecretKey
+	require.NoError(t, sk.SetHexString(testAccountSecretKey))
+	return sk.SignByte(root[:]).Serialize()
+}
@@ -0,0 +1,25 @@
+package models
+
+// AccountsResponse is the vault list accounts response model.
+type AccountsResponse struct {
+	Data AccountsModel `json:"data"`
+}
+
+// AccountsModel represents vault accounts list model.
+type AccountsModel struct {
+	Accounts []*AccountModel `json:"accounts"`
+}
+
+// AccountModel represents vault wallet account model.
+// The activation epoch and time are set while the account is in its doppelganger protection window,
+// the group public key and the share index are set if the account is a key share.
+type AccountModel struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	ValidationPubKey string `json:"validationPubKey"`
+	WithdrawalPubKey string `json:"withdrawalPubKey"`
+	ActivationEpoch  string `json:"activationEpoch,omitempty"`
+	ActivationTime   string `json:"activationTime,omitempty"`
+	GroupPubKey      string `json:"groupPubKey,omitempty"`
+	ShareIndex       string `json:"shareIndex,omitempty"`
+}
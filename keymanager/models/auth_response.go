@@ -0,0 +1,13 @@
+package models
+
+// AuthResponse is the vault login and token renewal response model.
+type AuthResponse struct {
+	Auth *AuthModel `json:"auth"`
+}
+
+// AuthModel represents vault auth model.
+type AuthModel struct {
+	ClientToken   string `json:"client_token"`
+	LeaseDuration int64  `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+// ExitRequestsResponse is the vault list voluntary exit requests response model.
+type ExitRequestsResponse struct {
+	Data ExitRequestsModel `json:"data"`
+}
+
+// ExitRequestsModel represents vault voluntary exit requests list model.
+type ExitRequestsModel struct {
+	ExitRequests []*ExitRequestModel `json:"exit_requests"`
+}
+
+// ExitRequestResponse is the vault voluntary exit request response model.
+type ExitRequestResponse struct {
+	Data ExitRequestModel `json:"data"`
+}
+
+// ExitRequestModel represents vault voluntary exit request model.
+// The signature is set once the request is approved, the rejection once it is rejected.
+type ExitRequestModel struct {
+	ExitID            string               `json:"exit_id"`
+	PublicKey         string               `json:"public_key"`
+	Status            string               `json:"status"`
+	RequestedBy       string               `json:"requested_by,omitempty"`
+	CreatedAt         time.Time            `json:"created_at"`
+	ExpiresAt         time.Time            `json:"expires_at"`
+	RequiredApprovals uint64               `json:"required_approvals"`
+	Approvals         []*ExitDecisionModel `json:"approvals"`
+	Rejection         *ExitDecisionModel   `json:"rejection,omitempty"`
+	ExpiredAt         *time.Time           `json:"expired_at,omitempty"`
+	Signature         string               `json:"signature,omitempty"`
+}
+
+// ExitDecisionModel represents vault voluntary exit request decision model.
+type ExitDecisionModel struct {
+	EntityID string    `json:"entity_id"`
+	Time     time.Time `json:"time"`
+	Reason   string    `json:"reason,omitempty"`
+}
@@ -0,0 +1,12 @@
+package models
+
+// SignCheckResponse is the vault dry-run sign response model.
+type SignCheckResponse struct {
+	Data SignCheckModel `json:"data"`
+}
+
+// SignCheckModel represents vault dry-run sign verdict model.
+type SignCheckModel struct {
+	Verdict string `json:"verdict"`
+	Reason  string `json:"reason,omitempty"`
+}
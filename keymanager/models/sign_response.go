@@ -6,6 +6,9 @@ type SignResponse struct {
 }
 
 // SignatureModel represents vault signature model.
+// The signatures of a group public key are partial signatures, answered with the index and public key of the share.
 type SignatureModel struct {
-	Signature string `json:"signature"`
+	Signature      string `json:"signature"`
+	ShareIndex     uint64 `json:"share_index,omitempty"`
+	SharePublicKey string `json:"share_public_key,omitempty"`
 }
@@ -0,0 +1,22 @@
+package models
+
+// StorageResponse is the vault storage update response model.
+type StorageResponse struct {
+	Data StorageModel `json:"data"`
+}
+
+// StorageModel represents vault storage update model.
+type StorageModel struct {
+	Status   bool                  `json:"status"`
+	DryRun   bool                  `json:"dry_run"`
+	Summary  map[string]int        `json:"summary"`
+	Accounts []*ImportAccountModel `json:"accounts"`
+}
+
+// ImportAccountModel represents the import report of an account.
+type ImportAccountModel struct {
+	PublicKey string `json:"public_key"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Reason    string `json:"reason,omitempty"`
+}
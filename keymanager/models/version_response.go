@@ -0,0 +1,11 @@
+package models
+
+// VersionResponse is the vault plugin version response model.
+type VersionResponse struct {
+	Data VersionModel `json:"data"`
+}
+
+// VersionModel represents vault plugin version model.
+type VersionModel struct {
+	Version string `json:"version"`
+}
@@ -5,14 +5,37 @@ import (
 	"io"
 
 	"github.com/pkg/errors"
+
+	"github.com/bloxapp/key-vault/utils/httpex"
 )
 
 // Config contains configuration of the remote HTTP keymanager
 type Config struct {
-	Location    string `json:"location"`
+	Location string `json:"location"`
+
+	// Locations is the list of additional key-vault endpoints used for failover.
+	Locations []string `json:"locations,omitempty"`
+
 	AccessToken string `json:"access_token"`
 	PubKey      string `json:"public_key"`
 	Network     string `json:"network"`
+
+	// Auth configures how the Vault token is obtained.
+	// The static AccessToken is used if not set.
+	Auth *AuthConfig `json:"auth,omitempty"`
+
+	// TLS contains the TLS settings used to connect to the remote key manager.
+	// The server certificate is verified against the system roots if not set.
+	TLS *httpex.TLSOptions `json:"tls,omitempty"`
+}
+
+// Addresses returns all configured key-vault endpoints, primary location first.
+func (c *Config) Addresses() []string {
+	var addresses []string
+	if len(c.Location) > 0 {
+		addresses = append(addresses, c.Location)
+	}
+	return append(addresses, c.Locations...)
 }
 
 // UnmarshalConfigFile attempts to JSON unmarshal a keymanager
@@ -6,6 +6,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/utils/httpex"
 )
 
 func TestUnmarshalConfigFile(t *testing.T) {
@@ -38,6 +40,40 @@ func TestUnmarshalConfigFile(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid JSON string with failover locations",
+			args: args{
+				r: io.NopCloser(strings.NewReader(`{"location":"location","locations":["location-2","location-3"],"access_token":"access_token","public_key":"public_key","network":"network"}`)),
+			},
+			want: &Config{
+				Location:    "location",
+				Locations:   []string{"location-2", "location-3"},
+				AccessToken: "access_token",
+				PubKey:      "public_key",
+				Network:     "network",
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid JSON string with TLS options",
+			args: args{
+				r: io.NopCloser(strings.NewReader(`{"location":"location","access_token":"access_token","public_key":"public_key","network":"network","tls":{"ca_cert_file":"ca.crt","server_name":"vault","client_cert_file":"client.crt","client_key_file":"client.key","pinned_public_keys":["pin"]}}`)),
+			},
+			want: &Config{
+				Location:    "location",
+				AccessToken: "access_token",
+				PubKey:      "public_key",
+				Network:     "network",
+				TLS: &httpex.TLSOptions{
+					CACertFile:       "ca.crt",
+					ServerName:       "vault",
+					ClientCertFile:   "client.crt",
+					ClientKeyFile:    "client.key",
+					PinnedPublicKeys: []string{"pin"},
+				},
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
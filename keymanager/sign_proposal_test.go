@@ -21,7 +21,7 @@ import (
 )
 
 func TestSignProposal(t *testing.T) {
-	expectedSig := _byteArray("b75a751c2c5c16175c4678e8fc8ed75e903153b221f3803bf55982934113468139d91049d4c8f9efae92889505b42dda045df95e233d7ae0140f5bf882d91373a98056b09410769a7bc9319c9a42bc90c626a2301ba8f084522def59840aec80")
+	expectedSig := _byteArray("aa16fbea0f61bba00816297a11bb1ea8ca1d9db8dd0c5b4b4cfaded37664bc043efa9e259bed01a33a6383cb04fc59a61483c6e4c2a63051eea661cc2e52642fc7e502047b68acc5be9b863f8c762d42f0b19c4856f16fe9855f3ccfb2658aa4")
 
 	var protect sync.Mutex
 	var currentMethod http.HandlerFunc
@@ -33,7 +33,7 @@ func TestSignProposal(t *testing.T) {
 	km, err := keymanager.NewKeyManager(logrus.NewEntry(logrus.New()), &keymanager.Config{
 		Location:    s.URL,
 		AccessToken: DefaultAccessToken,
-		PubKey:      "a3862121db5914d7272b0b705e6e3c5336b79e316735661873566245207329c30f9a33d4fb5f5857fc6fd0a368186972",
+		PubKey:      "8e80066551a81b318258709edaf7dd1f63cd686a0e4db8b29bbb7acfe65608677af5a527d9448ee47835485e02b50bc0",
 		Network:     "prater",
 	})
 	require.NoError(t, err)
@@ -55,7 +55,7 @@ func TestSignProposal(t *testing.T) {
 			req := &models.SignRequest{}
 			require.NoError(t, encoder.New().Decode(valByts, req))
 
-			require.EqualValues(t, _byteArray("a3862121db5914d7272b0b705e6e3c5336b79e316735661873566245207329c30f9a33d4fb5f5857fc6fd0a368186972"), req.PublicKey)
+			require.EqualValues(t, _byteArray("8e80066551a81b318258709edaf7dd1f63cd686a0e4db8b29bbb7acfe65608677af5a527d9448ee47835485e02b50bc0"), req.PublicKey)
 			require.EqualValues(t, _byteArray32("0000000081509579e35e84020ad8751eca180b44df470332d3ad17fc6fd52459"), req.SignatureDomain)
 
 			// root block
@@ -135,7 +135,7 @@ func testRequest(t *testing.T) *models.SignRequest {
 
 	// build request
 	return &models.SignRequest{
-		PublicKey:       _byteArray("a3862121db5914d7272b0b705e6e3c5336b79e316735661873566245207329c30f9a33d4fb5f5857fc6fd0a368186972"),
+		PublicKey:       _byteArray("8e80066551a81b318258709edaf7dd1f63cd686a0e4db8b29bbb7acfe65608677af5a527d9448ee47835485e02b50bc0"),
 		SigningRoot:     nil,
 		SignatureDomain: _byteArray32("0000000081509579e35e84020ad8751eca180b44df470332d3ad17fc6fd52459"),
 		Object: &models.SignRequestBlock{VersionedBeaconBlock: &spec.VersionedBeaconBlock{
@@ -0,0 +1,121 @@
+package keymanager
+
+import (
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/signer"
+	ssz "github.com/ferranbt/fastssz"
+	"github.com/pkg/errors"
+
+	"github.com/bloxapp/key-vault/keymanager/models"
+)
+
+// slotsPerEpoch is the number of slots per epoch on all supported networks.
+const slotsPerEpoch = 32
+
+// Signature domain types.
+// https://github.com/ethereum/consensus-specs/blob/dev/specs/phase0/beacon-chain.md#domain-types
+var (
+	DomainBeaconProposer              = phase0.DomainType{0x00, 0x00, 0x00, 0x00}
+	DomainBeaconAttester              = phase0.DomainType{0x01, 0x00, 0x00, 0x00}
+	DomainRandao                      = phase0.DomainType{0x02, 0x00, 0x00, 0x00}
+	DomainVoluntaryExit               = phase0.DomainType{0x04, 0x00, 0x00, 0x00}
+	DomainSelectionProof              = phase0.DomainType{0x05, 0x00, 0x00, 0x00}
+	DomainAggregateAndProof           = phase0.DomainType{0x06, 0x00, 0x00, 0x00}
+	DomainSyncCommittee               = phase0.DomainType{0x07, 0x00, 0x00, 0x00}
+	DomainSyncCommitteeSelectionProof = phase0.DomainType{0x08, 0x00, 0x00, 0x00}
+	DomainContributionAndProof        = phase0.DomainType{0x09, 0x00, 0x00, 0x00}
+	DomainApplicationBuilder          = phase0.DomainType{0x00, 0x00, 0x00, 0x01}
+)
+
+// ForkInfo contains the chain data needed to compute signature domains.
+type ForkInfo struct {
+	// Fork is the fork of the beacon state at the time of signing.
+	Fork *phase0.Fork
+
+	// GenesisValidatorsRoot is the genesis validators root of the chain.
+	GenesisValidatorsRoot phase0.Root
+
+	// GenesisForkVersion is the genesis fork version, used by builder registrations.
+	GenesisForkVersion phase0.Version
+}
+
+// Domain returns the signature domain of the given type at the given epoch.
+func (f *ForkInfo) Domain(domainType phase0.DomainType, epoch phase0.Epoch) (phase0.Domain, error) {
+	if f == nil || f.Fork == nil {
+		return phase0.Domain{}, errors.New("fork info is required")
+	}
+
+	forkVersion := f.Fork.CurrentVersion
+	if epoch < f.Fork.Epoch {
+		forkVersion = f.Fork.PreviousVersion
+	}
+	return ComputeDomain(domainType, forkVersion, f.GenesisValidatorsRoot)
+}
+
+// ComputeDomain computes the signature domain.
+// https://github.com/ethereum/consensus-specs/blob/dev/specs/phase0/beacon-chain.md#compute_domain
+func ComputeDomain(domainType phase0.DomainType, forkVersion phase0.Version, genesisValidatorsRoot phase0.Root) (phase0.Domain, error) {
+	forkData := &phase0.ForkData{
+		CurrentVersion:        forkVersion,
+		GenesisValidatorsRoot: genesisValidatorsRoot,
+	}
+	forkDataRoot, err := forkData.HashTreeRoot()
+	if err != nil {
+		return phase0.Domain{}, errors.Wrap(err, "failed to compute fork data root")
+	}
+
+	var domain phase0.Domain
+	copy(domain[:], domainType[:])
+	copy(domain[4:], forkDataRoot[:28])
+	return domain, nil
+}
+
+// ComputeSigningRoot computes the signing root of the given sign request,
+// exactly as the key-vault signer does.
+func ComputeSigningRoot(req *models.SignRequest) (phase0.Root, error) {
+	var obj ssz.HashRoot
+	switch t := req.GetObject().(type) {
+	case *models.SignRequestBlock:
+		root, err := t.VersionedBeaconBlock.Root()
+		if err != nil {
+			return phase0.Root{}, errors.Wrap(err, "failed to compute block root")
+		}
+		obj = signer.SSZBytes(root[:])
+	case *models.SignRequestBlindedBlock:
+		root, err := t.VersionedBlindedBeaconBlock.Root()
+		if err != nil {
+			return phase0.Root{}, errors.Wrap(err, "failed to compute blinded block root")
+		}
+		obj = signer.SSZBytes(root[:])
+	case *models.SignRequestAttestationData:
+		obj = t.AttestationData
+	case *models.SignRequestSlot:
+		obj = signer.SSZUint64(t.Slot)
+	case *models.SignRequestEpoch:
+		obj = signer.SSZUint64(t.Epoch)
+	case *models.SignRequestAggregateAttestationAndProof:
+		obj = t.AggregateAttestationAndProof
+	case *models.SignRequestSyncCommitteeMessage:
+		obj = signer.SSZBytes(t.Root)
+	case *models.SignRequestSyncAggregatorSelectionData:
+		obj = t.SyncAggregatorSelectionData
+	case *models.SignRequestContributionAndProof:
+		obj = t.ContributionAndProof
+	case *models.SignRequestRegistration:
+		root, err := t.VersionedValidatorRegistration.Root()
+		if err != nil {
+			return phase0.Root{}, errors.Wrap(err, "failed to compute registration root")
+		}
+		obj = signer.SSZBytes(root[:])
+	case *models.SignRequestVoluntaryExit:
+		obj = t.VoluntaryExit
+	default:
+		return phase0.Root{}, errors.Errorf("unsupported sign request object %T", t)
+	}
+
+	return signer.ComputeETHSigningRoot(obj, req.SignatureDomain)
+}
+
+func epochAtSlot(slot phase0.Slot) phase0.Epoch {
+	return phase0.Epoch(slot / slotsPerEpoch)
+}
@@ -0,0 +1,75 @@
+package keymanager_test
+
+import (
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/signer"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/keymanager"
+	"github.com/bloxapp/key-vault/keymanager/models"
+)
+
+func testForkInfo() *keymanager.ForkInfo {
+	return &keymanager.ForkInfo{
+		Fork: &phase0.Fork{
+			PreviousVersion: phase0.Version{0x00, 0x00, 0x10, 0x20},
+			CurrentVersion:  phase0.Version{0x01, 0x00, 0x10, 0x20},
+			Epoch:           10,
+		},
+		GenesisValidatorsRoot: _byteArray32("043db0d9a83813551ee2f33450d23797757d430911a9320530ad8a0eabc43efb"),
+		GenesisForkVersion:    phase0.Version{0x00, 0x00, 0x10, 0x20},
+	}
+}
+
+func TestComputeDomain(t *testing.T) {
+	domain, err := keymanager.ComputeDomain(keymanager.DomainApplicationBuilder, phase0.Version{}, phase0.Root{})
+	require.NoError(t, err)
+	require.EqualValues(t, _byteArray("00000001f5a5fd42d16a20302798ef6ed309979b43003d2320d9f0e8ea9831a9"), domain[:])
+
+	fork := testForkInfo()
+	previous, err := fork.Domain(keymanager.DomainBeaconAttester, 9)
+	require.NoError(t, err)
+	current, err := fork.Domain(keymanager.DomainBeaconAttester, 10)
+	require.NoError(t, err)
+	require.NotEqual(t, previous, current)
+
+	expected, err := keymanager.ComputeDomain(keymanager.DomainBeaconAttester, fork.Fork.PreviousVersion, fork.GenesisValidatorsRoot)
+	require.NoError(t, err)
+	require.Equal(t, expected, previous)
+
+	_, err = (&keymanager.ForkInfo{}).Domain(keymanager.DomainBeaconAttester, 1)
+	require.Error(t, err)
+}
+
+func TestComputeSigningRoot(t *testing.T) {
+	domain := _byteArray32("0000000081509579e35e84020ad8751eca180b44df470332d3ad17fc6fd52459")
+
+	t.Run("block", func(t *testing.T) {
+		req := testRequest(t)
+		root, err := keymanager.ComputeSigningRoot(req)
+		require.NoError(t, err)
+
+		expected, err := signer.ComputeETHSigningRoot(req.GetBlock().Phase0, domain)
+		require.NoError(t, err)
+		require.Equal(t, expected, root)
+	})
+
+	t.Run("epoch", func(t *testing.T) {
+		root, err := keymanager.ComputeSigningRoot(&models.SignRequest{
+			SignatureDomain: domain,
+			Object:          &models.SignRequestEpoch{Epoch: 12},
+		})
+		require.NoError(t, err)
+
+		expected, err := signer.ComputeETHSigningRoot(signer.SSZUint64(12), domain)
+		require.NoError(t, err)
+		require.Equal(t, expected, root)
+	})
+
+	t.Run("unsupported object", func(t *testing.T) {
+		_, err := keymanager.ComputeSigningRoot(&models.SignRequest{})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,113 @@
+package keymanager
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// tokenSource talks to the Vault token endpoints.
+type tokenSource interface {
+	login(ctx context.Context, mountPath string, body map[string]interface{}) (*AuthToken, error)
+	renewSelf(ctx context.Context, token string) (*AuthToken, error)
+}
+
+// tokenManager keeps a valid Vault token, renewing it before its TTL expires
+// and logging in again when the token can't be renewed or gets rejected.
+type tokenManager struct {
+	mu       sync.Mutex
+	auth     Authenticator
+	source   tokenSource
+	token    *AuthToken
+	issuedAt time.Time
+	now      func() time.Time
+	log      *logrus.Entry
+}
+
+// newTokenManager is the constructor of tokenManager.
+func newTokenManager(log *logrus.Entry, auth Authenticator, source tokenSource) *tokenManager {
+	return &tokenManager{
+		auth:   auth,
+		source: source,
+		now:    time.Now,
+		log:    log,
+	}
+}
+
+// Token returns the current token, renewing or re-issuing it if needed.
+func (m *tokenManager) Token(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.token == nil {
+		return m.loginLocked(ctx)
+	}
+
+	// Tokens without TTL never expire.
+	if m.token.TTL == 0 {
+		return m.token.Token, nil
+	}
+
+	// Renew once two thirds of the TTL are gone.
+	age := m.now().Sub(m.issuedAt)
+	if age < m.token.TTL*2/3 {
+		return m.token.Token, nil
+	}
+
+	if m.token.Renewable {
+		renewed, err := m.source.renewSelf(ctx, m.token.Token)
+		if err == nil {
+			if len(renewed.Token) == 0 {
+				renewed.Token = m.token.Token
+			}
+			m.setLocked(renewed)
+			m.log.WithField("ttl", renewed.TTL.String()).Debug("vault token renewed")
+			return m.token.Token, nil
+		}
+		m.log.WithError(err).Warn("failed to renew vault token, logging in again")
+	}
+
+	token, err := m.loginLocked(ctx)
+	if err != nil && age < m.token.TTL {
+		// Keep using the current token until it really expires.
+		m.log.WithError(err).Warn("failed to re-authenticate, using the current token")
+		return m.token.Token, nil
+	}
+	return token, err
+}
+
+// Invalidate drops the given token, so the next call of Token logs in again.
+// It returns false if the token can't be replaced by logging in again.
+func (m *tokenManager) Invalidate(token string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.auth.(*StaticTokenAuth); ok {
+		return false
+	}
+	if m.token != nil && m.token.Token == token {
+		m.token = nil
+	}
+	return true
+}
+
+func (m *tokenManager) loginLocked(ctx context.Context) (string, error) {
+	token, err := m.auth.Login(ctx, m.source.login)
+	if err != nil {
+		return "", err
+	}
+	if len(token.Token) == 0 {
+		return "", NewGenericErrorMessage("no token returned by the auth method")
+	}
+
+	m.setLocked(token)
+	m.log.WithField("ttl", token.TTL.String()).Debug("vault token issued")
+	return token.Token, nil
+}
+
+func (m *tokenManager) setLocked(token *AuthToken) {
+	m.token = token
+	m.issuedAt = m.now()
+}
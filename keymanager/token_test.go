@@ -0,0 +1,141 @@
+package keymanager
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+type testTokenSource struct {
+	logins   int
+	renewals int
+	ttl      time.Duration
+	renewErr error
+	loginErr error
+}
+
+func (s *testTokenSource) login(_ context.Context, _ string, _ map[string]interface{}) (*AuthToken, error) {
+	if s.loginErr != nil {
+		return nil, s.loginErr
+	}
+	s.logins++
+	return &AuthToken{
+		Token:     fmt.Sprintf("token-%d", s.logins),
+		TTL:       s.ttl,
+		Renewable: true,
+	}, nil
+}
+
+func (s *testTokenSource) renewSelf(_ context.Context, token string) (*AuthToken, error) {
+	if s.renewErr != nil {
+		return nil, s.renewErr
+	}
+	s.renewals++
+	return &AuthToken{
+		Token:     token,
+		TTL:       s.ttl,
+		Renewable: true,
+	}, nil
+}
+
+func TestTokenManager(t *testing.T) {
+	newManager := func(auth Authenticator, source *testTokenSource) (*tokenManager, *time.Time) {
+		now := time.Now()
+		m := newTokenManager(logrus.NewEntry(logrus.New()), auth, source)
+		m.now = func() time.Time { return now }
+		return m, &now
+	}
+	appRole := NewAppRoleAuth("approle", "role", "secret", "")
+
+	t.Run("static token is never renewed", func(t *testing.T) {
+		source := &testTokenSource{}
+		m, now := newManager(NewStaticTokenAuth("static"), source)
+
+		token, err := m.Token(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "static", token)
+
+		*now = now.Add(time.Hour * 24)
+		token, err = m.Token(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "static", token)
+		require.Zero(t, source.renewals)
+		require.False(t, m.Invalidate(token))
+	})
+
+	t.Run("renews token before TTL expiry", func(t *testing.T) {
+		source := &testTokenSource{ttl: time.Hour}
+		m, now := newManager(appRole, source)
+
+		token, err := m.Token(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "token-1", token)
+
+		*now = now.Add(time.Minute * 30)
+		_, err = m.Token(context.Background())
+		require.NoError(t, err)
+		require.Zero(t, source.renewals)
+
+		*now = now.Add(time.Minute * 11)
+		token, err = m.Token(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "token-1", token)
+		require.Equal(t, 1, source.renewals)
+		require.Equal(t, 1, source.logins)
+	})
+
+	t.Run("logs in again if renewal fails", func(t *testing.T) {
+		source := &testTokenSource{ttl: time.Hour, renewErr: errors.New("permission denied")}
+		m, now := newManager(appRole, source)
+
+		_, err := m.Token(context.Background())
+		require.NoError(t, err)
+
+		*now = now.Add(time.Minute * 50)
+		token, err := m.Token(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "token-2", token)
+	})
+
+	t.Run("keeps the current token if re-authentication fails before expiry", func(t *testing.T) {
+		source := &testTokenSource{ttl: time.Hour, renewErr: errors.New("permission denied")}
+		m, now := newManager(appRole, source)
+
+		_, err := m.Token(context.Background())
+		require.NoError(t, err)
+
+		source.loginErr = errors.New("connection refused")
+		*now = now.Add(time.Minute * 50)
+		token, err := m.Token(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "token-1", token)
+
+		*now = now.Add(time.Minute * 11)
+		_, err = m.Token(context.Background())
+		require.Error(t, err)
+	})
+
+	t.Run("logs in again after invalidation", func(t *testing.T) {
+		source := &testTokenSource{ttl: time.Hour}
+		m, _ := newManager(appRole, source)
+
+		token, err := m.Token(context.Background())
+		require.NoError(t, err)
+		require.True(t, m.Invalidate(token))
+
+		token, err = m.Token(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "token-2", token)
+
+		// Stale tokens don't drop the current one
+		require.True(t, m.Invalidate("token-1"))
+		token, err = m.Token(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "token-2", token)
+	})
+}
@@ -0,0 +1,349 @@
+package keymanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bloxapp/key-vault/backend"
+	"github.com/bloxapp/key-vault/keymanager/models"
+	"github.com/bloxapp/key-vault/utils/encoder"
+	"github.com/bloxapp/key-vault/utils/endpoint"
+	"github.com/bloxapp/key-vault/utils/httpex"
+)
+
+// vaultAPIPath is the base path of the Vault HTTP API.
+const vaultAPIPath = "/v1/"
+
+// methodList is the HTTP method Vault uses for list operations.
+const methodList = "LIST"
+
+// maxRedirects is the number of redirects followed by a request, as by the default HTTP client.
+const maxRedirects = 10
+
+// transport sends authenticated requests to the key-vault endpoints of a network.
+type transport struct {
+	endpoints  *endpointPool
+	tokens     *tokenManager
+	network    string
+	httpClient *http.Client
+	encoder    encoder.IEncoder
+
+	log *logrus.Entry
+}
+
+// newTransport is the constructor of transport.
+func newTransport(log *logrus.Entry, opts *Config) (*transport, error) {
+	endpoints := newEndpointPool(log, opts.Addresses())
+	if len(endpoints.endpoints) == 0 {
+		return nil, ErrLocationMissing
+	}
+	auth, err := NewAuthenticator(opts.Auth, opts.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := httpex.NewTLSConfig(opts.TLS)
+	if err != nil {
+		return nil, NewGenericError(err, "failed to build TLS configuration")
+	}
+
+	clientOpts := []httpex.ClientOption{
+		httpex.WithCheckRedirect(func(req *http.Request, via []*http.Request) error {
+			return checkRedirect(endpoints, req, via)
+		}),
+	}
+	// Fail over to the next endpoint instead of retrying the same one.
+	if len(endpoints.endpoints) > 1 {
+		clientOpts = append(clientOpts, httpex.WithRetryMax(0))
+	}
+
+	t := &transport{
+		endpoints: endpoints,
+		network:   opts.Network,
+		encoder:   encoder.New(),
+		httpClient: httpex.CreateClient(log, func(resp *http.Response, err error, numTries int) (*http.Response, error) {
+			if err == nil {
+				return resp, nil
+			}
+
+			fields := logrus.Fields{}
+			if resp != nil {
+				fields["status_code"] = resp.StatusCode
+
+				if resp.Body != nil {
+					defer resp.Body.Close()
+
+					respBody, err := io.ReadAll(resp.Body)
+					if err != nil {
+						return resp, err
+					}
+					fields["response_body"] = string(respBody)
+				}
+			}
+			log.WithError(err).WithFields(fields).Error("failed to send request to key manager")
+			return resp, errors.Errorf("giving up after %d attempt(s): %s", numTries, err)
+		}, tlsConfig, clientOpts...),
+		log: log,
+	}
+	t.tokens = newTokenManager(log, auth, t)
+
+	return t, nil
+}
+
+// EndpointStatuses returns the health state of the configured endpoints.
+func (t *transport) EndpointStatuses() []EndpointStatus {
+	return t.endpoints.statuses()
+}
+
+// sign sends the given sign request to the sign endpoint matching its object.
+// The returned signature is verified against the locally computed signing root.
+func (t *transport) sign(ctx context.Context, req *models.SignRequest) (phase0.BLSSignature, error) {
+	signingRoot, err := ComputeSigningRoot(req)
+	if err != nil {
+		return phase0.BLSSignature{}, NewGenericError(err, "failed to compute signing root")
+	}
+
+	byts, err := t.encoder.Encode(req)
+	if err != nil {
+		return phase0.BLSSignature{}, errors.Wrap(err, "failed to encode request")
+	}
+	reqMap := map[string]interface{}{
+		"sign_req": hex.EncodeToString(byts),
+	}
+
+	// Voluntary exits are signed by a dedicated endpoint.
+	pattern := backend.SignPattern
+	if _, ok := req.GetObject().(*models.SignRequestVoluntaryExit); ok {
+		pattern = backend.SignVoluntaryExitPattern
+	}
+
+	var resp models.SignResponse
+	if err := t.sendRequest(ctx, http.MethodPost, pattern, reqMap, &resp); err != nil {
+		return phase0.BLSSignature{}, err
+	}
+
+	// Signature is base64 encoded, so we have to decode that.
+	decodedSignature, err := hex.DecodeString(resp.Data.Signature)
+	if err != nil {
+		return phase0.BLSSignature{}, NewGenericError(err, "failed to base64 decode")
+	}
+
+	// The group public keys are signed with a key share, the partial signature is verified against the share.
+	if resp.Data.ShareIndex != 0 {
+		sharePubKey, err := hex.DecodeString(resp.Data.SharePublicKey)
+		if err != nil {
+			return phase0.BLSSignature{}, NewGenericError(err, "failed to decode share public key")
+		}
+		if err := verifySignature(sharePubKey, signingRoot, decodedSignature); err != nil {
+			t.log.WithError(err).Error("remote key manager returned an invalid partial signature")
+			return phase0.BLSSignature{}, err
+		}
+		return phase0.BLSSignature{}, NewPartialSignatureError(req.GetPublicKey(), signingRoot, resp.Data.ShareIndex, sharePubKey, decodedSignature)
+	}
+
+	if err := verifySignature(req.GetPublicKey(), signingRoot, decodedSignature); err != nil {
+		t.log.WithError(err).Error("remote key manager returned an invalid signature")
+		return phase0.BLSSignature{}, err
+	}
+
+	var signature phase0.BLSSignature
+	copy(signature[:], decodedSignature)
+	return signature, nil
+}
+
+// sendRequest implements the logic to work with HTTP requests.
+// The request is sent to the configured endpoints one by one until one of them answers.
+func (t *transport) sendRequest(ctx context.Context, method, path string, reqBody interface{}, respBody interface{}) error {
+	networkPath, err := endpoint.Build(t.network, path)
+	if err != nil {
+		return NewGenericError(err, "could not build network path")
+	}
+
+	var payloadByts []byte
+	if reqBody != nil {
+		if payloadByts, err = json.Marshal(reqBody); err != nil {
+			return err
+		}
+	}
+
+	token, err := t.tokens.Token(ctx)
+	if err != nil {
+		return NewGenericError(err, "failed to authenticate")
+	}
+
+	err = t.send(ctx, method, networkPath, token, payloadByts, respBody)
+	if isPermissionDenied(err) && t.tokens.Invalidate(token) {
+		// The token was revoked or expired, log in again and retry once.
+		t.log.Warn("vault token was rejected, re-authenticating")
+		if token, err = t.tokens.Token(ctx); err != nil {
+			return NewGenericError(err, "failed to re-authenticate")
+		}
+		err = t.send(ctx, method, networkPath, token, payloadByts, respBody)
+	}
+	return err
+}
+
+// login implements tokenSource interface.
+func (t *transport) login(ctx context.Context, mountPath string, body map[string]interface{}) (*AuthToken, error) {
+	payloadByts, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp models.AuthResponse
+	if err := t.send(ctx, http.MethodPost, vaultAPIPath+"auth/"+mountPath+"/login", "", payloadByts, &resp); err != nil {
+		return nil, err
+	}
+	return authTokenFromResponse(&resp)
+}
+
+// renewSelf implements tokenSource interface.
+func (t *transport) renewSelf(ctx context.Context, token string) (*AuthToken, error) {
+	var resp models.AuthResponse
+	if err := t.send(ctx, http.MethodPost, vaultAPIPath+"auth/token/renew-self", token, []byte("{}"), &resp); err != nil {
+		return nil, err
+	}
+	return authTokenFromResponse(&resp)
+}
+
+// send sends the request to the configured endpoints one by one until one of them answers.
+func (t *transport) send(ctx context.Context, method, path, token string, payload []byte, respBody interface{}) error {
+	var lastErr error
+	for _, e := range t.endpoints.candidates() {
+		if ctx.Err() != nil {
+			return NewGenericError(ctx.Err(), "failed to send HTTP request")
+		}
+
+		failover, err := t.sendEndpointRequest(ctx, e, method, path, token, payload, respBody)
+		if !failover {
+			return err
+		}
+		lastErr = err
+	}
+
+	return lastErr
+}
+
+// sendEndpointRequest sends the request to the given endpoint.
+// It returns true if the request should be retried on the next endpoint.
+func (t *transport) sendEndpointRequest(ctx context.Context, e *remoteEndpoint, method, path, token string, payload []byte, respBody interface{}) (bool, error) {
+	endpointStr := e.address + path
+
+	// Prepare a new request
+	req, err := http.NewRequestWithContext(ctx, method, endpointStr, bytes.NewReader(payload))
+	if err != nil {
+		return false, NewGenericError(err, "failed to create HTTP request")
+	}
+
+	// Pass auth token.
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Send request.
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, NewGenericError(err, "failed to send HTTP request")
+		}
+		t.endpoints.markFailure(e, err)
+		return true, NewGenericError(err, "failed to send HTTP request")
+	}
+	defer resp.Body.Close()
+
+	var finalURL *url.URL
+	if resp.Request != nil {
+		finalURL = resp.Request.URL
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		httpErr := NewHTTPRequestError(endpointStr, resp.StatusCode, t.readErrorBody(resp), "endpoint is not active")
+		// Rate limited requests are answered by an active node, they are not retried on the others.
+		if err := newBackendError(httpErr); err != error(httpErr) {
+			t.endpoints.markSuccess(e, finalURL)
+			return false, err
+		}
+		// Vault standby, sealed or not yet initialized node.
+		t.endpoints.markStandby(e)
+		return true, httpErr
+	case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		// Standby redirect to a node out of the configured endpoints, see checkRedirect.
+		httpErr := NewHTTPRequestError(endpointStr, resp.StatusCode, []byte(resp.Header.Get("Location")), "endpoint redirected to an unknown node")
+		t.endpoints.markStandby(e)
+		return true, httpErr
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		httpErr := NewHTTPRequestError(endpointStr, resp.StatusCode, t.readErrorBody(resp), "endpoint is not reachable")
+		t.endpoints.markFailure(e, httpErr)
+		return true, httpErr
+	}
+
+	t.endpoints.markSuccess(e, finalURL)
+
+	// Check status code. Must be 200.
+	if resp.StatusCode != http.StatusOK {
+		return false, newBackendError(NewHTTPRequestError(endpointStr, resp.StatusCode, t.readErrorBody(resp), "unexpected status code"))
+	}
+
+	// Read response body into the given object.
+	if respBody == nil {
+		return false, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+		return false, NewGenericError(err, "failed to decode response body")
+	}
+
+	return false, nil
+}
+
+// checkRedirect follows the redirects of Vault standbys to the active node.
+// The HTTP client drops the token on a redirect to another host, it is set again when the redirect goes to
+// a configured endpoint. Other redirects aren't followed, the token isn't sent to unknown hosts.
+func checkRedirect(endpoints *endpointPool, req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if !endpoints.contains(req.URL) {
+		return http.ErrUseLastResponse
+	}
+	if auth := via[0].Header.Get("Authorization"); len(auth) > 0 {
+		req.Header.Set("Authorization", auth)
+	}
+	return nil
+}
+
+func authTokenFromResponse(resp *models.AuthResponse) (*AuthToken, error) {
+	if resp.Auth == nil || len(resp.Auth.ClientToken) == 0 {
+		return nil, NewGenericErrorMessage("no auth data in the response")
+	}
+
+	return &AuthToken{
+		Token:     resp.Auth.ClientToken,
+		TTL:       time.Duration(resp.Auth.LeaseDuration) * time.Second,
+		Renewable: resp.Auth.Renewable,
+	}, nil
+}
+
+func isPermissionDenied(err error) bool {
+	httpErr, ok := errors.Cause(err).(*HTTPRequestError)
+	return ok && httpErr.StatusCode == http.StatusForbidden
+}
+
+func (t *transport) readErrorBody(resp *http.Response) []byte {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.log.WithError(err).Error("failed to read error response body")
+	}
+	return responseBody
+}
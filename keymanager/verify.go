@@ -0,0 +1,65 @@
+package keymanager
+
+import (
+	"expvar"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/pkg/errors"
+)
+
+// Signature verification failure reasons, used as keys of the invalid signatures metric.
+const (
+	invalidSignatureLength    = "length"
+	invalidSignatureMalformed = "malformed"
+	invalidSignatureMismatch  = "mismatch"
+)
+
+// invalidSignatures counts the signatures rejected by the client-side verification by reason.
+// It is published as "keymanager_invalid_signatures" by expvar.
+var invalidSignatures = expvar.NewMap("keymanager_invalid_signatures")
+
+// verifySignature verifies the signature returned by the remote key manager
+// against the public key and the locally computed signing root.
+func verifySignature(pubKey []byte, signingRoot phase0.Root, sig []byte) error {
+	newErr := func(reason, message string) error {
+		invalidSignatures.Add(reason, 1)
+		return NewSignatureVerificationError(pubKey, signingRoot, sig, message)
+	}
+
+	if len(sig) != phase0.SignatureLength {
+		return newErr(invalidSignatureLength, "invalid signature length")
+	}
+
+	if err := core.InitBLS(); err != nil {
+		return NewGenericError(err, "failed to init BLS")
+	}
+
+	// The key is copied as cgo rejects slices of structs holding Go pointers.
+	var blsPubKey bls.PublicKey
+	if err := blsPubKey.Deserialize(append([]byte(nil), pubKey...)); err != nil {
+		return NewGenericError(err, "failed to deserialize public key")
+	}
+
+	var blsSig bls.Sign
+	if err := blsSig.Deserialize(sig); err != nil {
+		return newErr(invalidSignatureMalformed, errors.Wrap(err, "failed to deserialize signature").Error())
+	}
+
+	if !blsSig.VerifyByte(&blsPubKey, signingRoot[:]) {
+		return newErr(invalidSignatureMismatch, "signature does not match the public key and signing root")
+	}
+	return nil
+}
+
+// InvalidSignaturesCount returns the number of signatures rejected by the client-side verification.
+func InvalidSignaturesCount() int64 {
+	var count int64
+	invalidSignatures.Do(func(kv expvar.KeyValue) {
+		if v, ok := kv.Value.(*expvar.Int); ok {
+			count += v.Value()
+		}
+	})
+	return count
+}
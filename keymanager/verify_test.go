@@ -0,0 +1,166 @@
+package keymanager_test
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bloxapp/key-vault/backend"
+	"github.com/bloxapp/key-vault/keymanager"
+)
+
+func TestKeyManager_SignatureVerification(t *testing.T) {
+	var signature []byte
+	s := newTestRemoteWallet(func(writer http.ResponseWriter, request *http.Request) {
+		require.NoError(t, json.NewEncoder(writer).Encode(&logical.Response{
+			Data: map[string]interface{}{
+				"signature": hex.EncodeToString(signature),
+			},
+		}))
+	})
+	defer s.Close()
+
+	km, err := keymanager.NewKeyManager(logrus.NewEntry(logrus.New()), &keymanager.Config{
+		Location:    s.URL,
+		AccessToken: DefaultAccessToken,
+		PubKey:      "8e80066551a81b318258709edaf7dd1f63cd686a0e4db8b29bbb7acfe65608677af5a527d9448ee47835485e02b50bc0",
+		Network:     "prater",
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		signature []byte
+	}{
+		{
+			name:      "invalid length",
+			signature: _byteArray("aa16fbea0f61bba00816297a11bb1ea8"),
+		},
+		{
+			name:      "malformed signature",
+			signature: make([]byte, phase0.SignatureLength),
+		},
+		{
+			name:      "signature of another signing root",
+			signature: signTestRoot(t, phase0.Root{1}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signature = tt.signature
+			invalidCount := keymanager.InvalidSignaturesCount()
+
+			sig, err := km.Sign(context.Background(), testRequest(t))
+			require.Error(t, err)
+			require.True(t, keymanager.IsSignatureVerificationError(err))
+			require.Equal(t, phase0.BLSSignature{}, sig)
+			require.Equal(t, invalidCount+1, keymanager.InvalidSignaturesCount())
+		})
+	}
+
+	t.Run("valid signature", func(t *testing.T) {
+		req := testRequest(t)
+		root, err := keymanager.ComputeSigningRoot(req)
+		require.NoError(t, err)
+		signature = signTestRoot(t, root)
+
+		sig, err := km.Sign(context.Background(), req)
+		require.NoError(t, err)
+		require.EqualValues(t, signature, sig[:])
+	})
+}
+
+func TestKeyManager_PartialSignature(t *testing.T) {
+	require.NoError(t, core.InitBLS())
+	var share bls.SecretKey
+	share.SetByCSPRNG()
+
+	var partial, combined []byte
+	var combineData map[string]interface{}
+	s := newTestRemoteWallet(func(writer http.ResponseWriter, request *http.Request) {
+		data := map[string]interface{}{
+			"signature":        hex.EncodeToString(partial),
+			"share_index":      2,
+			"share_public_key": hex.EncodeToString(share.GetPublicKey().Serialize()),
+		}
+		if strings.HasSuffix(request.URL.Path, backend.KeySharesCombinePattern) {
+			require.NoError(t, json.NewDecoder(request.Body).Decode(&combineData))
+			data = map[string]interface{}{"signature": hex.EncodeToString(combined)}
+		}
+		require.NoError(t, json.NewEncoder(writer).Encode(&logical.Response{Data: data}))
+	})
+	defer s.Close()
+
+	opts := &keymanager.Config{
+		Location:    s.URL,
+		AccessToken: DefaultAccessToken,
+		PubKey:      "8e80066551a81b318258709edaf7dd1f63cd686a0e4db8b29bbb7acfe65608677af5a527d9448ee47835485e02b50bc0",
+		Network:     "prater",
+	}
+	km, err := keymanager.NewKeyManager(logrus.NewEntry(logrus.New()), opts)
+	require.NoError(t, err)
+	admin, err := keymanager.NewAdminClient(logrus.NewEntry(logrus.New()), opts)
+	require.NoError(t, err)
+
+	req := testRequest(t)
+	root, err := keymanager.ComputeSigningRoot(req)
+	require.NoError(t, err)
+
+	t.Run("invalid partial signature", func(t *testing.T) {
+		// the partial signature is verified against the share public key
+		partial = signTestRoot(t, root)
+		invalidCount := keymanager.InvalidSignaturesCount()
+
+		_, err := km.Sign(context.Background(), req)
+		require.True(t, keymanager.IsSignatureVerificationError(err))
+		require.Equal(t, invalidCount+1, keymanager.InvalidSignaturesCount())
+	})
+
+	t.Run("partial signature", func(t *testing.T) {
+		partial = share.SignByte(root[:]).Serialize()
+		invalidCount := keymanager.InvalidSignaturesCount()
+
+		sig, err := km.Sign(context.Background(), req)
+		require.True(t, keymanager.IsPartialSignatureError(err))
+		require.Equal(t, phase0.BLSSignature{}, sig)
+		require.Equal(t, invalidCount, keymanager.InvalidSignaturesCount())
+
+		var partialErr *keymanager.PartialSignatureError
+		require.True(t, errors.As(err, &partialErr))
+		require.Equal(t, opts.PubKey, partialErr.PubKey)
+		require.Equal(t, hex.EncodeToString(root[:]), partialErr.SigningRoot)
+		require.EqualValues(t, 2, partialErr.ShareIndex)
+		require.Equal(t, hex.EncodeToString(partial), partialErr.Signature)
+
+		// the partial signatures are combined by the remote key manager and the group signature is verified
+		combined = signTestRoot(t, root)
+		other := *partialErr
+		other.ShareIndex = 3
+		sig, err = admin.CombinePartialSignatures(context.Background(), partialErr, &other)
+		require.NoError(t, err)
+		require.EqualValues(t, combined, sig[:])
+		require.Equal(t, map[string]interface{}{
+			"2": partialErr.Signature,
+			"3": other.Signature,
+		}, combineData["partial_signatures"])
+
+		combined = partial
+		_, err = admin.CombinePartialSignatures(context.Background(), partialErr, &other)
+		require.True(t, keymanager.IsSignatureVerificationError(err))
+
+		other.SigningRoot = hex.EncodeToString(make([]byte, 32))
+		_, err = admin.CombinePartialSignatures(context.Background(), partialErr, &other)
+		require.True(t, keymanager.IsGenericError(err))
+	})
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"bytes"
 	"os"
 	"strings"
 
 	vault "github.com/bloxapp/eth2-key-manager"
+	"github.com/bloxapp/eth2-key-manager/encryptor/keystorev4"
 	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/sdk/plugin"
 	"github.com/sirupsen/logrus"
@@ -26,16 +28,20 @@ func main() {
 	// Create plugin meta API
 	var logOpts logex.Options
 	var logLevels string
+	var storagePasswordFile string
 	apiClientMeta := &api.PluginAPIClientMeta{}
 	flags := apiClientMeta.FlagSet()
 	flags.StringVar(&logOpts.Format, "log-format", "", "logs format")
 	flags.StringVar(&logLevels, "log-levels", "", "logs levels separated by comma")
 	flags.StringVar(&logOpts.DSN, "log-dsn", "", "external DSN to send logs")
+	flags.StringVar(&logOpts.Environment, "log-env", "", "environment reported with the external logs")
+	flags.StringVar(&storagePasswordFile, "storage-password-file", "", "file with the password of the accounts encryption at rest")
 	if err := flags.Parse(os.Args[1:]); err != nil {
 		logrus.WithError(err).Fatal("failed to parse flags")
 	}
 
 	logOpts.Levels = strings.Split(logLevels, ",")
+	logOpts.Version = Version
 
 	// Init logger for development proposes
 	logger, err := logex.Init(logOpts)
@@ -43,13 +49,27 @@ func main() {
 		logrus.Fatal(err)
 	}
 
+	// Enable accounts encryption at rest
+	var backendOpts []backend.Option
+	if len(storagePasswordFile) > 0 {
+		password, err := os.ReadFile(storagePasswordFile)
+		if err != nil {
+			logger.WithError(err).Fatal("failed to read storage password file")
+		}
+		password = bytes.TrimSpace(password)
+		if len(password) == 0 {
+			logger.Fatal("storage password file is empty")
+		}
+		backendOpts = append(backendOpts, backend.WithStorageEncryption(keystorev4.New(), password))
+	}
+
 	// Create TLS configuration
 	tlsConfig := apiClientMeta.GetTLSConfig()
 	tlsProviderFunc := api.VaultPluginTLSProvider(tlsConfig)
 
 	// Serve plugin
 	if err := plugin.Serve(&plugin.ServeOpts{
-		BackendFactoryFunc: backend.Factory(Version, logger),
+		BackendFactoryFunc: backend.Factory(Version, logger, backendOpts...),
 		TLSProviderFunc:    tlsProviderFunc,
 	}); err != nil {
 		logrus.Fatal(err)
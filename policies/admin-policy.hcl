@@ -13,6 +13,11 @@ path "ethereum/+/accounts/sign" {
   capabilities = ["create"]
 }
 
+# Ability to check sign requests without signing ("create")
+path "ethereum/+/accounts/sign/check" {
+  capabilities = ["create"]
+}
+
 # Ability to get version ("read")
 path "ethereum/+/version" {
   capabilities = ["read"]
@@ -28,12 +33,78 @@ path "ethereum/+/storage/slashing" {
   capabilities = ["read"]
 }
 
+# Ability to override slashing watermarks and to read their audit records ("create", "read")
+path "ethereum/+/storage/slashing/watermarks" {
+  capabilities = ["create", "read"]
+}
+
+# Ability to re-encrypt storage ("create")
+path "ethereum/+/storage/encryption" {
+  capabilities = ["create"]
+}
+
+# Ability to read and run storage migrations ("create", "read")
+path "ethereum/+/storage/migrations" {
+  capabilities = ["create", "read"]
+}
+
+# Ability to run, read and abort import sessions ("create", "read", "delete")
+path "ethereum/+/storage/import" {
+  capabilities = ["create"]
+}
+
+path "ethereum/+/storage/import/*" {
+  capabilities = ["create", "read", "delete"]
+}
+
 # Ability to create/update/read config
 path "ethereum/+/config" {
   capabilities = ["create", "update", "read"]
 }
 
+# Ability to read and set the log level ("create", "update", "read")
+path "ethereum/+/log-level" {
+  capabilities = ["create", "update", "read"]
+}
+
 # Ability to sign voluntary exit ("create")
 path "ethereum/+/accounts/sign-voluntary-exit" {
   capabilities = ["create"]
-}
\ No newline at end of file
+}
+
+# Ability to release the doppelganger protection window of accounts ("delete")
+path "ethereum/+/accounts/doppelganger/*" {
+  capabilities = ["delete"]
+}
+
+# Ability to register and read validator indexes ("create", "update", "read")
+path "ethereum/+/accounts/validator-indexes" {
+  capabilities = ["create", "update", "read"]
+}
+
+# Ability to list, read, approve and reject voluntary exit requests ("list", "read", "create")
+path "ethereum/+/accounts/exits" {
+  capabilities = ["list"]
+}
+
+path "ethereum/+/accounts/exits/*" {
+  capabilities = ["create", "read"]
+}
+
+# Ability to escrow, list and retrieve voluntary exits, and to read their retrievals ("create", "read", "list")
+path "ethereum/+/accounts/exit-escrow" {
+  capabilities = ["create", "read", "list"]
+}
+
+path "ethereum/+/accounts/exit-escrow/*" {
+  capabilities = ["read"]
+}
+
+# Ability to list, register, read and delete key shares, and to combine partial signatures ("list", "create", "update", "read", "delete")
+path "ethereum/+/accounts/shares" {
+  capabilities = ["list"]
+}
+
+path "ethereum/+/accounts/shares/*" {
+  capabilities = ["create", "update", "read", "delete"]
+}
@@ -13,6 +13,11 @@ path "ethereum/+/accounts/sign" {
   capabilities = ["create"]
 }
 
+# Ability to check sign requests without signing ("create")
+path "ethereum/+/accounts/sign/check" {
+  capabilities = ["create"]
+}
+
 # Ability to get version ("read")
 path "ethereum/+/version" {
   capabilities = ["read"]
@@ -0,0 +1,103 @@
+package errorex
+
+import (
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/pkg/errors"
+)
+
+// ErrorCode is the machine-readable code of a backend error.
+type ErrorCode string
+
+// Error codes
+const (
+	CodeBadRequest      ErrorCode = "bad_request"
+	CodeUnknownAccount  ErrorCode = "unknown_account"
+	CodeSlashable       ErrorCode = "slashable"
+	CodePolicyViolation ErrorCode = "policy_violation"
+	CodeNotConfigured   ErrorCode = "not_configured"
+	CodeStorageFailure  ErrorCode = "storage_failure"
+	CodeRateLimited     ErrorCode = "rate_limited"
+	// CodeExitPending isn't an error, the voluntary exit waits for approvals and the request is accepted.
+	CodeExitPending ErrorCode = "exit_pending"
+)
+
+// StatusCode returns the HTTP status code of the error code.
+// Policy violations use 422 instead of 403 so that clients don't mistake them for Vault permission errors.
+func (c ErrorCode) StatusCode() int {
+	switch c {
+	case CodeBadRequest:
+		return http.StatusBadRequest
+	case CodeUnknownAccount:
+		return http.StatusNotFound
+	case CodeSlashable:
+		return http.StatusConflict
+	case CodePolicyViolation:
+		return http.StatusUnprocessableEntity
+	case CodeNotConfigured:
+		return http.StatusPreconditionFailed
+	case CodeRateLimited:
+		return http.StatusTooManyRequests
+	case CodeExitPending:
+		return http.StatusAccepted
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// CodedError represents a backend error with a machine-readable code
+type CodedError struct {
+	Code     ErrorCode `json:"code"`
+	ErrorMsg string    `json:"message"`
+
+	cause error
+}
+
+// NewCodedError is the constructor of CodedError
+func NewCodedError(code ErrorCode, errorMsg string) *CodedError {
+	return &CodedError{
+		Code:     code,
+		ErrorMsg: errorMsg,
+	}
+}
+
+// Wrap returns a CodedError annotating err with the given message, err stays in the chain of the returned error
+func Wrap(code ErrorCode, err error, msg string) *CodedError {
+	return &CodedError{
+		Code:     code,
+		ErrorMsg: errors.Wrap(err, msg).Error(),
+		cause:    err,
+	}
+}
+
+// AsCodedError returns the first CodedError in the chain of err
+func AsCodedError(err error) (*CodedError, bool) {
+	var codedErr *CodedError
+	if errors.As(err, &codedErr) {
+		return codedErr, true
+	}
+	return nil, false
+}
+
+// Error implements error interface
+func (e *CodedError) Error() string {
+	return e.ErrorMsg
+}
+
+// Unwrap returns the wrapped error, if any
+func (e *CodedError) Unwrap() error {
+	return e.cause
+}
+
+// ToLogicalResponse converts error to logical response model
+func (e *CodedError) ToLogicalResponse() (*logical.Response, error) {
+	statusCode := e.Code.StatusCode()
+	return logical.RespondWithStatusCode(&logical.Response{
+		Data: map[string]interface{}{
+			"code":        e.Code,
+			"message":     e.ErrorMsg,
+			"status_code": statusCode,
+		},
+	}, nil, statusCode)
+}
@@ -20,8 +20,26 @@ const (
 	clientTimeout   = time.Minute
 )
 
+// ClientOption customizes the HTTP client created by CreateClient.
+type ClientOption func(client *retryablehttp.Client)
+
+// WithRetryMax overrides the maximum number of retries of a single request.
+func WithRetryMax(retryMax int) ClientOption {
+	return func(client *retryablehttp.Client) {
+		client.RetryMax = retryMax
+	}
+}
+
+// WithCheckRedirect sets the redirect policy of the client.
+func WithCheckRedirect(checkRedirect func(req *http.Request, via []*http.Request) error) ClientOption {
+	return func(client *retryablehttp.Client) {
+		client.HTTPClient.CheckRedirect = checkRedirect
+	}
+}
+
 // CreateClient creates a new HTTP client.
-func CreateClient(logger *logrus.Entry, errorHandler retryablehttp.ErrorHandler) *http.Client {
+// If tlsConfig is nil the server certificate is verified against the system roots.
+func CreateClient(logger *logrus.Entry, errorHandler retryablehttp.ErrorHandler, tlsConfig *tls.Config, opts ...ClientOption) *http.Client {
 	retryClient := retryablehttp.NewClient()
 	retryClient.RetryMax = attempts
 	retryClient.RetryWaitMin = attemptsWaitMin
@@ -29,18 +47,28 @@ func CreateClient(logger *logrus.Entry, errorHandler retryablehttp.ErrorHandler)
 	retryClient.Logger = logger
 	retryClient.ErrorHandler = errorHandler
 
-	// Override transport to support non-authorized HTTPS connections
-	transport := cleanhttp.DefaultPooledTransport()
-	transport.TLSClientConfig = &tls.Config{
-		InsecureSkipVerify: true,
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
 	}
+
+	transport := cleanhttp.DefaultPooledTransport()
+	transport.TLSClientConfig = tlsConfig
 	retryClient.HTTPClient = &http.Client{
 		Transport: transport,
 	}
+	for _, opt := range opts {
+		opt(retryClient)
+	}
 	client := &http.Client{
 		Transport: &retryablehttp.RoundTripper{
 			Client: retryClient,
 		},
+		// The redirects are followed by the retrying client, with its redirect policy.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
 	}
 	client.Timeout = clientTimeout
 
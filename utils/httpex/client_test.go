@@ -1,16 +1,35 @@
 package httpex
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 )
 
 func TestCreateClient(t *testing.T) {
-	t.Run("accepts untrusted HTTPS connection", func(t *testing.T) {
+	t.Run("rejects untrusted HTTPS connection", func(t *testing.T) {
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		}))
+		defer srv.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+
+		client := CreateClient(logrus.NewEntry(logrus.New()), nil, nil)
+		_, err = client.Do(req)
+		require.Error(t, err)
+	})
+
+	t.Run("accepts untrusted HTTPS connection when verification is disabled", func(t *testing.T) {
 		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		}))
@@ -19,7 +38,10 @@ func TestCreateClient(t *testing.T) {
 		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
 		require.NoError(t, err)
 
-		client := CreateClient(logrus.NewEntry(logrus.New()), nil)
+		tlsConfig, err := NewTLSConfig(&TLSOptions{InsecureSkipVerify: true})
+		require.NoError(t, err)
+
+		client := CreateClient(logrus.NewEntry(logrus.New()), nil, tlsConfig)
 		resp, err := client.Do(req)
 		require.NoError(t, err)
 		require.Equal(t, http.StatusOK, resp.StatusCode)
@@ -34,7 +56,7 @@ func TestCreateClient(t *testing.T) {
 		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
 		require.NoError(t, err)
 
-		client := CreateClient(logrus.NewEntry(logrus.New()), nil)
+		client := CreateClient(logrus.NewEntry(logrus.New()), nil, nil)
 		resp, err := client.Do(req)
 		require.NoError(t, err)
 		require.Equal(t, http.StatusOK, resp.StatusCode)
@@ -0,0 +1,102 @@
+package httpex
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// TLSOptions contains TLS settings of the HTTP client.
+// The zero value verifies the server certificate against the system roots.
+type TLSOptions struct {
+	// CACertFile is the path to a PEM encoded CA bundle used to verify the server certificate.
+	CACertFile string `json:"ca_cert_file,omitempty"`
+
+	// ServerName overrides the name used to verify the server certificate.
+	ServerName string `json:"server_name,omitempty"`
+
+	// ClientCertFile and ClientKeyFile are the paths to a PEM encoded
+	// certificate and private key presented to the server (mTLS).
+	ClientCertFile string `json:"client_cert_file,omitempty"`
+	ClientKeyFile  string `json:"client_key_file,omitempty"`
+
+	// PinnedPublicKeys is the list of base64 encoded SHA-256 hashes of the
+	// server SubjectPublicKeyInfo. If set, the server leaf certificate must match one of them.
+	PinnedPublicKeys []string `json:"pinned_public_keys,omitempty"`
+
+	// InsecureSkipVerify disables the server certificate verification.
+	// Public key pinning is still enforced if configured.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// NewTLSConfig builds TLS configuration based on the given options.
+func NewTLSConfig(opts *TLSOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if opts == nil {
+		return tlsConfig, nil
+	}
+
+	tlsConfig.ServerName = opts.ServerName
+	tlsConfig.InsecureSkipVerify = opts.InsecureSkipVerify
+
+	if len(opts.CACertFile) > 0 {
+		caCert, err := os.ReadFile(opts.CACertFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read CA certificate '%s'", opts.CACertFile)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, errors.Errorf("no valid certificates found in '%s'", opts.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(opts.ClientCertFile) > 0 || len(opts.ClientKeyFile) > 0 {
+		if len(opts.ClientCertFile) == 0 || len(opts.ClientKeyFile) == 0 {
+			return nil, errors.New("both client certificate and client key are required")
+		}
+
+		clientCert, err := tls.LoadX509KeyPair(opts.ClientCertFile, opts.ClientKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	if len(opts.PinnedPublicKeys) > 0 {
+		pins := make(map[string]struct{}, len(opts.PinnedPublicKeys))
+		for _, pin := range opts.PinnedPublicKeys {
+			decoded, err := base64.StdEncoding.DecodeString(pin)
+			if err != nil || len(decoded) != sha256.Size {
+				return nil, errors.Errorf("invalid pinned public key '%s'", pin)
+			}
+			pins[string(decoded)] = struct{}{}
+		}
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("no peer certificate presented")
+			}
+
+			hash := sha256.Sum256(state.PeerCertificates[0].RawSubjectPublicKeyInfo)
+			if _, ok := pins[string(hash[:])]; !ok {
+				return errors.New("server public key does not match any pinned public key")
+			}
+			return nil
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// PublicKeyPin returns the base64 encoded SHA-256 hash of the certificate SubjectPublicKeyInfo.
+func PublicKeyPin(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
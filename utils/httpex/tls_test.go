@@ -0,0 +1,225 @@
+package httpex
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{
+		Certificate: [][]byte{c.cert.Raw},
+		PrivateKey:  c.key,
+	}
+}
+
+func newTestCert(t *testing.T, name string, parent *testCert, template *x509.Certificate) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template.SerialNumber = serial
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	signerCert, signerKey := template, key
+	if parent != nil {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+
+	return &testCert{
+		cert:     cert,
+		key:      key,
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+}
+
+func newTestCA(t *testing.T, name string) *testCert {
+	return newTestCert(t, name, nil, &x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	})
+}
+
+func newTestServerCert(t *testing.T, ca *testCert) *testCert {
+	return newTestCert(t, "server", ca, &x509.Certificate{
+		DNSNames:    []string{"vault.local"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+}
+
+func newTestClientCert(t *testing.T, ca *testCert) *testCert {
+	return newTestCert(t, "client", ca, &x509.Certificate{
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+}
+
+func newTestTLSServer(t *testing.T, serverCert *testCert, clientCA *testCert) *httptest.Server {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert.tlsCertificate()},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCA != nil {
+		pool := x509.NewCertPool()
+		pool.AddCert(clientCA.cert)
+		srv.TLS.ClientCAs = pool
+		srv.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func doTLSRequest(t *testing.T, url string, opts *TLSOptions) error {
+	tlsConfig, err := NewTLSConfig(opts)
+	require.NoError(t, err)
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	return nil
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	otherCA := newTestCA(t, "other-ca")
+	serverCert := newTestServerCert(t, ca)
+	clientCert := newTestClientCert(t, ca)
+	otherClientCert := newTestClientCert(t, otherCA)
+
+	t.Run("verifies server certificate by default", func(t *testing.T) {
+		srv := newTestTLSServer(t, serverCert, nil)
+		require.Error(t, doTLSRequest(t, srv.URL, nil))
+	})
+
+	t.Run("accepts server certificate signed by the configured CA", func(t *testing.T) {
+		srv := newTestTLSServer(t, serverCert, nil)
+		require.NoError(t, doTLSRequest(t, srv.URL, &TLSOptions{
+			CACertFile: ca.certFile,
+		}))
+	})
+
+	t.Run("rejects server certificate signed by another CA", func(t *testing.T) {
+		srv := newTestTLSServer(t, serverCert, nil)
+		require.Error(t, doTLSRequest(t, srv.URL, &TLSOptions{
+			CACertFile: otherCA.certFile,
+		}))
+	})
+
+	t.Run("verifies the overridden server name", func(t *testing.T) {
+		srv := newTestTLSServer(t, serverCert, nil)
+		require.NoError(t, doTLSRequest(t, srv.URL, &TLSOptions{
+			CACertFile: ca.certFile,
+			ServerName: "vault.local",
+		}))
+		require.Error(t, doTLSRequest(t, srv.URL, &TLSOptions{
+			CACertFile: ca.certFile,
+			ServerName: "other.local",
+		}))
+	})
+
+	t.Run("presents client certificate", func(t *testing.T) {
+		srv := newTestTLSServer(t, serverCert, ca)
+		require.NoError(t, doTLSRequest(t, srv.URL, &TLSOptions{
+			CACertFile:     ca.certFile,
+			ClientCertFile: clientCert.certFile,
+			ClientKeyFile:  clientCert.keyFile,
+		}))
+	})
+
+	t.Run("rejected without valid client certificate", func(t *testing.T) {
+		srv := newTestTLSServer(t, serverCert, ca)
+		require.Error(t, doTLSRequest(t, srv.URL, &TLSOptions{
+			CACertFile: ca.certFile,
+		}))
+		require.Error(t, doTLSRequest(t, srv.URL, &TLSOptions{
+			CACertFile:     ca.certFile,
+			ClientCertFile: otherClientCert.certFile,
+			ClientKeyFile:  otherClientCert.keyFile,
+		}))
+	})
+
+	t.Run("accepts pinned public key", func(t *testing.T) {
+		srv := newTestTLSServer(t, serverCert, nil)
+		require.NoError(t, doTLSRequest(t, srv.URL, &TLSOptions{
+			CACertFile:       ca.certFile,
+			PinnedPublicKeys: []string{PublicKeyPin(serverCert.cert)},
+		}))
+	})
+
+	t.Run("rejects not pinned public key", func(t *testing.T) {
+		srv := newTestTLSServer(t, serverCert, nil)
+		require.Error(t, doTLSRequest(t, srv.URL, &TLSOptions{
+			CACertFile:       ca.certFile,
+			PinnedPublicKeys: []string{PublicKeyPin(ca.cert)},
+		}))
+		require.Error(t, doTLSRequest(t, srv.URL, &TLSOptions{
+			InsecureSkipVerify: true,
+			PinnedPublicKeys:   []string{PublicKeyPin(ca.cert)},
+		}))
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		_, err := NewTLSConfig(&TLSOptions{CACertFile: filepath.Join(t.TempDir(), "missing.crt")})
+		require.Error(t, err)
+
+		_, err = NewTLSConfig(&TLSOptions{CACertFile: ca.keyFile})
+		require.Error(t, err)
+
+		_, err = NewTLSConfig(&TLSOptions{ClientCertFile: clientCert.certFile})
+		require.Error(t, err)
+
+		_, err = NewTLSConfig(&TLSOptions{PinnedPublicKeys: []string{"invalid"}})
+		require.Error(t, err)
+	})
+}
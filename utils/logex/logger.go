@@ -25,6 +25,12 @@ type Options struct {
 
 	// DSN is the DSN of a external logs store.
 	DSN string
+
+	// Version is the version of the plugin, reported to the external logs store.
+	Version string
+
+	// Environment is the environment reported to the external logs store, derived from the version if empty.
+	Environment string
 }
 
 // Init creates a new logger and sets up its options.
@@ -50,7 +56,11 @@ func Init(opts Options) (*logrus.Logger, error) {
 	// Prepare external logs store configuration.
 	if opts.DSN != "" {
 		// Init Sentry first
-		if err := sentry.Init(opts.DSN); err != nil {
+		if err := sentry.Init(sentry.Options{
+			DSN:         opts.DSN,
+			Version:     opts.Version,
+			Environment: opts.Environment,
+		}); err != nil {
 			return nil, errors.Wrap(err, "failed to init Sentry")
 		}
 
@@ -76,7 +86,7 @@ func Init(opts Options) (*logrus.Logger, error) {
 		}
 
 		// Add Sentry log hook
-		logger.Hooks.Add(sentryhook.New(logLevels))
+		logger.Hooks.Add(sentryhook.New(logLevels, sentryhook.WithConverter(sentry.Converter)))
 	}
 
 	return logger, nil
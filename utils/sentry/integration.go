@@ -1,6 +1,11 @@
 package sentry
 
-import gosentry "github.com/getsentry/sentry-go"
+import (
+	"regexp"
+	"strings"
+
+	gosentry "github.com/getsentry/sentry-go"
+)
 
 // ExtractExtra implements sentry.Integration interface.
 // The logic of this structure is integrated with Sentry.
@@ -50,3 +55,83 @@ func (ee EventFormatter) SetupOnce(client *gosentry.Client) {
 		return event
 	})
 }
+
+// scrubbedValue replaces the scrubbed values.
+const scrubbedValue = "[scrubbed]"
+
+var (
+	// sensitiveKeyParts are the parts of the names of the extra values holding secrets.
+	sensitiveKeyParts = []string{"password", "secret", "token", "private", "seed", "mnemonic", "passphrase"}
+
+	// payloadKeys are the names of the extra values holding request payloads.
+	payloadKeys = map[string]bool{"sign_req": true, "data": true, "payload": true, "body": true, "request": true}
+
+	// sensitiveHeaders are the request headers holding credentials.
+	sensitiveHeaders = map[string]bool{"authorization": true, "cookie": true, "x-vault-token": true}
+
+	// longHexPattern matches the hex strings of 32 bytes or more, keys, roots and encoded payloads.
+	longHexPattern = regexp.MustCompile(`(0x)?[0-9a-fA-F]{64,}`)
+)
+
+// Scrubber implements sentry.Integration interface.
+// It drops the secrets and the request payloads from the events before they are sent.
+type Scrubber struct{}
+
+// Name implements sentry.Integration interface.
+func (s Scrubber) Name() string {
+	return "Scrubber"
+}
+
+// SetupOnce implements sentry.Integration interface.
+func (s Scrubber) SetupOnce(client *gosentry.Client) {
+	client.AddEventProcessor(func(event *gosentry.Event, hint *gosentry.EventHint) *gosentry.Event {
+		return ScrubEvent(event)
+	})
+}
+
+// ScrubEvent drops the secrets and the request payloads from the given event.
+func ScrubEvent(event *gosentry.Event) *gosentry.Event {
+	for key := range event.Extra {
+		if sensitiveKey(key) {
+			event.Extra[key] = scrubbedValue
+		} else if str, ok := event.Extra[key].(string); ok {
+			event.Extra[key] = longHexPattern.ReplaceAllString(str, scrubbedValue)
+		}
+	}
+	for key := range event.Tags {
+		if sensitiveKey(key) {
+			event.Tags[key] = scrubbedValue
+		}
+	}
+
+	event.Message = longHexPattern.ReplaceAllString(event.Message, scrubbedValue)
+	for i := range event.Exception {
+		event.Exception[i].Value = longHexPattern.ReplaceAllString(event.Exception[i].Value, scrubbedValue)
+	}
+
+	if event.Request != nil {
+		event.Request.Data = ""
+		event.Request.Cookies = ""
+		event.Request.QueryString = ""
+		for key := range event.Request.Headers {
+			if sensitiveHeaders[strings.ToLower(key)] {
+				delete(event.Request.Headers, key)
+			}
+		}
+	}
+	return event
+}
+
+// sensitiveKey returns true if the given name of a value is a secret or a request payload.
+func sensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	if payloadKeys[key] {
+		return true
+	}
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package sentry
+
+import (
+	"sync"
+	"time"
+
+	gosentry "github.com/getsentry/sentry-go"
+)
+
+// maxSampledKinds bounds the kinds of events counted before the expired ones are pruned.
+const maxSampledKinds = 1024
+
+// Sampler implements sentry.Integration interface.
+// It sends the first events of a kind in each interval and drops the others,
+// the number of dropped events is reported with the next sent one.
+// The events are of the same kind when they have the same level, message and code tag.
+type Sampler struct {
+	burst    int
+	interval time.Duration
+	now      func() time.Time
+
+	mu    sync.Mutex
+	kinds map[string]*sampledKind
+}
+
+// sampledKind counts the events of a kind in the current interval.
+type sampledKind struct {
+	start   time.Time
+	sent    int
+	dropped int
+}
+
+// NewSampler returns a sampler sending at most burst events of a kind per interval.
+func NewSampler(burst int, interval time.Duration) *Sampler {
+	return &Sampler{
+		burst:    burst,
+		interval: interval,
+		now:      time.Now,
+		kinds:    make(map[string]*sampledKind),
+	}
+}
+
+// Name implements sentry.Integration interface.
+func (s *Sampler) Name() string {
+	return "Sampler"
+}
+
+// SetupOnce implements sentry.Integration interface.
+func (s *Sampler) SetupOnce(client *gosentry.Client) {
+	client.AddEventProcessor(func(event *gosentry.Event, hint *gosentry.EventHint) *gosentry.Event {
+		return s.Sample(event)
+	})
+}
+
+// Sample returns the given event if it is sent, nil if it is dropped.
+func (s *Sampler) Sample(event *gosentry.Event) *gosentry.Event {
+	key := string(event.Level) + "|" + event.Message + "|" + event.Tags[TagCode]
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kind, ok := s.kinds[key]
+	if !ok || now.Sub(kind.start) >= s.interval {
+		dropped := 0
+		if ok {
+			dropped = kind.dropped
+		}
+		if !ok && len(s.kinds) >= maxSampledKinds {
+			s.prune(now)
+		}
+		kind = &sampledKind{start: now, dropped: dropped}
+		s.kinds[key] = kind
+	}
+	if kind.sent >= s.burst {
+		kind.dropped++
+		return nil
+	}
+
+	kind.sent++
+	if kind.dropped > 0 {
+		if event.Extra == nil {
+			event.Extra = make(map[string]interface{})
+		}
+		event.Extra["sampled_dropped"] = kind.dropped
+		kind.dropped = 0
+	}
+	return event
+}
+
+// prune drops the kinds of events whose interval is over.
+func (s *Sampler) prune(now time.Time) {
+	for key, kind := range s.kinds {
+		if now.Sub(kind.start) >= s.interval {
+			delete(s.kinds, key)
+		}
+	}
+}
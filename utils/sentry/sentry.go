@@ -1,17 +1,75 @@
 package sentry
 
 import (
+	"time"
+
 	gosentry "github.com/getsentry/sentry-go"
 )
 
+// Release prefix and environments
+const (
+	releasePrefix = "key-vault@"
+
+	EnvironmentDevelopment = "development"
+	EnvironmentProduction  = "production"
+)
+
+// Options contains the options to initialize Sentry.
+type Options struct {
+	// DSN is the DSN of the Sentry project.
+	DSN string
+
+	// Version is the version of the plugin, reported as the release.
+	Version string
+
+	// Environment is the reported environment, derived from the version if empty.
+	Environment string
+
+	// Debug prints the Sentry client logs.
+	Debug bool
+}
+
 // Init initializes Sentry package.
-func Init(dsn string) error {
+func Init(opts Options) error {
 	return gosentry.Init(gosentry.ClientOptions{
-		Debug:            true,
-		Dsn:              dsn,
+		Debug:            opts.Debug,
+		Dsn:              opts.DSN,
+		Release:          Release(opts.Version),
+		Environment:      Environment(opts.Environment, opts.Version),
 		AttachStacktrace: true,
 		Integrations: func(integrations []gosentry.Integration) []gosentry.Integration {
-			return append(integrations, new(ExtractExtra), new(EventFormatter))
+			return append(integrations,
+				new(ExtractExtra),
+				new(EventFormatter),
+				new(Scrubber),
+				NewSampler(defaultSamplerBurst, defaultSamplerInterval),
+			)
 		},
 	})
 }
+
+// Release returns the release of the given plugin version, empty if unknown.
+func Release(version string) string {
+	if version == "" {
+		return ""
+	}
+	return releasePrefix + version
+}
+
+// Environment returns the given environment, or the one of the given plugin version:
+// the builds without a version are development builds.
+func Environment(environment, version string) string {
+	if environment != "" {
+		return environment
+	}
+	if version == "" || version == "latest" {
+		return EnvironmentDevelopment
+	}
+	return EnvironmentProduction
+}
+
+// Sampling of the repeated events, see Sampler.
+const (
+	defaultSamplerBurst    = 10
+	defaultSamplerInterval = time.Minute
+)
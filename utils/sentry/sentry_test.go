@@ -0,0 +1,115 @@
+package sentry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	gosentry "github.com/getsentry/sentry-go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseAndEnvironment(t *testing.T) {
+	require.Equal(t, "key-vault@1a2b3c", Release("1a2b3c"))
+	require.Equal(t, "", Release(""))
+
+	require.Equal(t, EnvironmentProduction, Environment("", "1a2b3c"))
+	require.Equal(t, EnvironmentDevelopment, Environment("", "latest"))
+	require.Equal(t, EnvironmentDevelopment, Environment("", ""))
+	require.Equal(t, "staging", Environment("staging", "1a2b3c"))
+}
+
+func TestConverter(t *testing.T) {
+	pubKey := "95087182937f6982ae99f9b06bd116f463f414513032e33a3d175d9662eddf162101fcf6ca2a9fedaded74b8047c5dcf"
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+		TagNetwork:   "prater",
+		TagSlot:      uint64(284115),
+		TagPublicKey: pubKey,
+		"attempt":    2,
+	}).WithError(errors.New("slashable attestation"))
+	entry.Level = logrus.WarnLevel
+	entry.Message = "sign request failed"
+
+	event := gosentry.NewEvent()
+	Converter(entry, event, gosentry.CurrentHub())
+	require.Equal(t, gosentry.LevelWarning, event.Level)
+	require.Equal(t, map[string]string{
+		TagNetwork:   "prater",
+		TagSlot:      "284115",
+		TagPublicKey: "95087182937f...",
+	}, event.Tags)
+	require.Equal(t, 2, event.Extra["attempt"])
+	require.NotContains(t, event.Extra, TagPublicKey)
+	require.Len(t, event.Exception, 1)
+}
+
+func TestScrubEvent(t *testing.T) {
+	signature := "a8c2e2f4d1b9e5a6c3f7d8e9b0a1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d3"
+	event := gosentry.NewEvent()
+	event.Message = "failed to sign 0x" + signature
+	event.Exception = []gosentry.Exception{{Value: "invalid root " + signature}}
+	event.Extra = map[string]interface{}{
+		"sign_req":           "0a0b0c",
+		"storage_password":   "pass",
+		"client_token":       "s.abc",
+		"reason":             "wrong root " + signature,
+		"attempt":            2,
+		"encryption_enabled": true,
+	}
+	event.Tags = map[string]string{"seed_id": "1", TagNetwork: "prater"}
+	event.Request = &gosentry.Request{
+		Data:    `{"sign_req":"0a0b0c"}`,
+		Cookies: "session=1",
+		Headers: map[string]string{"X-Vault-Token": "s.abc", "Content-Type": "application/json"},
+	}
+
+	event = ScrubEvent(event)
+	require.Equal(t, "failed to sign [scrubbed]", event.Message)
+	require.Equal(t, "invalid root [scrubbed]", event.Exception[0].Value)
+	require.Equal(t, map[string]interface{}{
+		"sign_req":           scrubbedValue,
+		"storage_password":   scrubbedValue,
+		"client_token":       scrubbedValue,
+		"reason":             "wrong root [scrubbed]",
+		"attempt":            2,
+		"encryption_enabled": true,
+	}, event.Extra)
+	require.Equal(t, map[string]string{"seed_id": scrubbedValue, TagNetwork: "prater"}, event.Tags)
+	require.Empty(t, event.Request.Data)
+	require.Empty(t, event.Request.Cookies)
+	require.Equal(t, map[string]string{"Content-Type": "application/json"}, event.Request.Headers)
+}
+
+func TestSampler(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	sampler := NewSampler(2, time.Minute)
+	sampler.now = func() time.Time {
+		return now
+	}
+	event := func(message, code string) *gosentry.Event {
+		event := gosentry.NewEvent()
+		event.Level = gosentry.LevelWarning
+		event.Message = message
+		event.Tags[TagCode] = code
+		return event
+	}
+
+	require.NotNil(t, sampler.Sample(event("sign request failed", "slashable")))
+	require.NotNil(t, sampler.Sample(event("sign request failed", "slashable")))
+	require.Nil(t, sampler.Sample(event("sign request failed", "slashable")))
+	require.Nil(t, sampler.Sample(event("sign request failed", "slashable")))
+
+	// other kinds are sampled apart
+	require.NotNil(t, sampler.Sample(event("sign request failed", "policy_violation")))
+	require.NotNil(t, sampler.Sample(event("request failed", "slashable")))
+
+	// the dropped events are reported with the first event of the next interval
+	now = now.Add(time.Minute)
+	sent := sampler.Sample(event("sign request failed", "slashable"))
+	require.NotNil(t, sent)
+	require.Equal(t, 2, sent.Extra["sampled_dropped"])
+	sent = sampler.Sample(event("sign request failed", "slashable"))
+	require.NotNil(t, sent)
+	require.NotContains(t, sent.Extra, "sampled_dropped")
+}
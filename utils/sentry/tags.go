@@ -0,0 +1,58 @@
+package sentry
+
+import (
+	"fmt"
+	"strings"
+
+	gosentry "github.com/getsentry/sentry-go"
+	"github.com/makasim/sentryhook"
+	"github.com/sirupsen/logrus"
+)
+
+// Names of the log fields reported as tags.
+const (
+	TagMount      = "mount"
+	TagNetwork    = "network"
+	TagPath       = "path"
+	TagOperation  = "operation"
+	TagCode       = "code"
+	TagObjectType = "object_type"
+	TagSlot       = "slot"
+	TagEpoch      = "epoch"
+	TagPublicKey  = "public_key"
+)
+
+// tagKeys are the log fields reported as tags, the other ones are reported as extra values.
+var tagKeys = []string{TagMount, TagNetwork, TagPath, TagOperation, TagCode, TagObjectType, TagSlot, TagEpoch, TagPublicKey}
+
+// publicKeyPrefixLength is the number of hex characters of the public keys reported.
+const publicKeyPrefixLength = 12
+
+// Converter converts the log entries to Sentry events, the known fields are reported as tags.
+// The public keys are truncated, they identify the validator in the logs.
+func Converter(entry *logrus.Entry, event *gosentry.Event, hub *gosentry.Hub) {
+	sentryhook.DefaultConverter(entry, event, hub)
+
+	for _, key := range tagKeys {
+		value, ok := event.Extra[key]
+		if !ok {
+			continue
+		}
+		delete(event.Extra, key)
+
+		tag := fmt.Sprint(value)
+		if key == TagPublicKey {
+			tag = TruncatePublicKey(tag)
+		}
+		event.Tags[key] = tag
+	}
+}
+
+// TruncatePublicKey returns the prefix of the given hex encoded public key.
+func TruncatePublicKey(pubKey string) string {
+	pubKey = strings.TrimPrefix(pubKey, "0x")
+	if len(pubKey) <= publicKeyPrefixLength {
+		return pubKey
+	}
+	return pubKey[:publicKeyPrefixLength] + "..."
+}
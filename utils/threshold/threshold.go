@@ -0,0 +1,197 @@
+package threshold
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/pkg/errors"
+)
+
+// Threshold errors
+var (
+	// ErrNotEnoughShares is returned when less shares than the threshold are given.
+	ErrNotEnoughShares = errors.New("not enough shares")
+	// ErrGroupKeyMismatch is returned when the shares don't recover the group public key.
+	ErrGroupKeyMismatch = errors.New("shares don't match the group public key")
+	// ErrInvalidPartialSignature is returned when a partial signature doesn't match its share public key.
+	ErrInvalidPartialSignature = errors.New("invalid partial signature")
+	// ErrUnknownShare is returned when a partial signature is given for a share index without a public key.
+	ErrUnknownShare = errors.New("unknown share")
+)
+
+// Group is a BLS key split in shares, any Threshold of them sign for the group public key.
+// The shares are indexed from 1, the index is the point of the share on the secret polynomial.
+type Group struct {
+	PublicKey       []byte
+	Threshold       uint64
+	SharePublicKeys map[uint64][]byte
+}
+
+// shareID returns the BLS ID of the share of the given index.
+func shareID(index uint64) (bls.ID, error) {
+	var id bls.ID
+	if index == 0 {
+		return id, errors.New("share index must be positive")
+	}
+	if err := id.SetDecString(strconv.FormatUint(index, 10)); err != nil {
+		return id, errors.Wrapf(err, "failed to set share id %d", index)
+	}
+	return id, nil
+}
+
+// publicKey deserializes the given public key.
+func publicKey(key []byte) (bls.PublicKey, error) {
+	// The key is copied as cgo rejects slices of structs holding Go pointers.
+	var pubKey bls.PublicKey
+	if err := pubKey.Deserialize(append([]byte(nil), key...)); err != nil {
+		return pubKey, errors.Wrap(err, "failed to deserialize public key")
+	}
+	return pubKey, nil
+}
+
+// sortIndexes sorts the given share indexes in ascending order.
+func sortIndexes(indexes []uint64) []uint64 {
+	sort.Slice(indexes, func(i, j int) bool {
+		return indexes[i] < indexes[j]
+	})
+	return indexes
+}
+
+// recoverPublicKey interpolates the public key of the polynomial at 0 from the shares of the given indexes.
+func recoverPublicKey(shares map[uint64]bls.PublicKey, indexes []uint64) (bls.PublicKey, error) {
+	pubKeys := make([]bls.PublicKey, len(indexes))
+	ids := make([]bls.ID, len(indexes))
+	for i, index := range indexes {
+		id, err := shareID(index)
+		if err != nil {
+			return bls.PublicKey{}, err
+		}
+		pubKeys[i] = shares[index]
+		ids[i] = id
+	}
+
+	var pubKey bls.PublicKey
+	if err := pubKey.Recover(pubKeys, ids); err != nil {
+		return bls.PublicKey{}, errors.Wrap(err, "failed to recover public key")
+	}
+	return pubKey, nil
+}
+
+// Verify checks the share public keys of the group are the points of a single polynomial of degree Threshold-1
+// whose value at 0 is the group public key: each share recovers the group key with the Threshold-1 first shares.
+func (g *Group) Verify() error {
+	if err := core.InitBLS(); err != nil {
+		return errors.Wrap(err, "failed to init BLS")
+	}
+	if g.Threshold == 0 {
+		return errors.New("threshold must be positive")
+	}
+	if uint64(len(g.SharePublicKeys)) < g.Threshold {
+		return errors.Wrapf(ErrNotEnoughShares, "%d shares for a threshold of %d", len(g.SharePublicKeys), g.Threshold)
+	}
+
+	groupPubKey, err := publicKey(g.PublicKey)
+	if err != nil {
+		return errors.Wrap(err, "invalid group public key")
+	}
+	shares := make(map[uint64]bls.PublicKey, len(g.SharePublicKeys))
+	indexes := make([]uint64, 0, len(g.SharePublicKeys))
+	for index, key := range g.SharePublicKeys {
+		if shares[index], err = publicKey(key); err != nil {
+			return errors.Wrapf(err, "invalid public key of share %d", index)
+		}
+		indexes = append(indexes, index)
+	}
+
+	sortIndexes(indexes)
+	base := indexes[:g.Threshold-1]
+	for _, index := range indexes[g.Threshold-1:] {
+		pubKey, err := recoverPublicKey(shares, append(append([]uint64(nil), base...), index))
+		if err != nil {
+			return err
+		}
+		if !pubKey.IsEqual(&groupPubKey) {
+			return errors.Wrapf(ErrGroupKeyMismatch, "share %d", index)
+		}
+	}
+	return nil
+}
+
+// Combine recovers the group signature of the given signing root from the partial signatures by share index,
+// and verifies it against the group public key.
+// The partial signatures of unknown shares are rejected, as they can't be verified. The others are verified first,
+// the invalid ones are ignored as long as Threshold valid ones remain. The lowest Threshold shares are interpolated.
+func (g *Group) Combine(signingRoot phase0.Root, partials map[uint64][]byte) ([]byte, error) {
+	if err := core.InitBLS(); err != nil {
+		return nil, errors.Wrap(err, "failed to init BLS")
+	}
+	if g.Threshold == 0 {
+		return nil, errors.New("threshold must be positive")
+	}
+
+	partialIndexes := make([]uint64, 0, len(partials))
+	for index := range partials {
+		if _, ok := g.SharePublicKeys[index]; !ok {
+			return nil, errors.Wrapf(ErrUnknownShare, "share %d", index)
+		}
+		partialIndexes = append(partialIndexes, index)
+	}
+
+	var (
+		invalid error
+		indexes []uint64
+		sigs    = make(map[uint64]bls.Sign, len(partials))
+	)
+	for _, index := range sortIndexes(partialIndexes) {
+		var sig bls.Sign
+		if err := sig.Deserialize(partials[index]); err != nil {
+			invalid = errors.Wrapf(ErrInvalidPartialSignature, "share %d: %s", index, err)
+			continue
+		}
+		pubKey, err := publicKey(g.SharePublicKeys[index])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid public key of share %d", index)
+		}
+		if !sig.VerifyByte(&pubKey, signingRoot[:]) {
+			invalid = errors.Wrapf(ErrInvalidPartialSignature, "share %d", index)
+			continue
+		}
+		sigs[index] = sig
+		indexes = append(indexes, index)
+	}
+	if uint64(len(sigs)) < g.Threshold {
+		if invalid != nil {
+			return nil, errors.Wrapf(invalid, "%d valid partial signatures for a threshold of %d", len(sigs), g.Threshold)
+		}
+		return nil, errors.Wrapf(ErrNotEnoughShares, "%d partial signatures for a threshold of %d", len(sigs), g.Threshold)
+	}
+
+	indexes = indexes[:g.Threshold]
+	sigVec := make([]bls.Sign, len(indexes))
+	ids := make([]bls.ID, len(indexes))
+	for i, index := range indexes {
+		id, err := shareID(index)
+		if err != nil {
+			return nil, err
+		}
+		sigVec[i] = sigs[index]
+		ids[i] = id
+	}
+
+	var sig bls.Sign
+	if err := sig.Recover(sigVec, ids); err != nil {
+		return nil, errors.Wrap(err, "failed to recover signature")
+	}
+
+	groupPubKey, err := publicKey(g.PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid group public key")
+	}
+	if !sig.VerifyByte(&groupPubKey, signingRoot[:]) {
+		return nil, errors.Wrap(ErrGroupKeyMismatch, "recovered signature does not match the group public key")
+	}
+	return sig.Serialize(), nil
+}
@@ -0,0 +1,117 @@
+package threshold
+
+import (
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bloxapp/eth2-key-manager/core"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// testShares splits a new key in n shares of the given threshold.
+func testShares(t *testing.T, threshold, n uint64) (*bls.SecretKey, map[uint64]*bls.SecretKey) {
+	require.NoError(t, core.InitBLS())
+
+	var sk bls.SecretKey
+	sk.SetByCSPRNG()
+	msk := sk.GetMasterSecretKey(int(threshold))
+
+	shares := make(map[uint64]*bls.SecretKey)
+	for index := uint64(1); index <= n; index++ {
+		id, err := shareID(index)
+		require.NoError(t, err)
+		var share bls.SecretKey
+		require.NoError(t, share.Set(msk, &id))
+		shares[index] = &share
+	}
+	return &sk, shares
+}
+
+func testGroup(sk *bls.SecretKey, threshold uint64, shares map[uint64]*bls.SecretKey) *Group {
+	group := &Group{
+		PublicKey:       sk.GetPublicKey().Serialize(),
+		Threshold:       threshold,
+		SharePublicKeys: make(map[uint64][]byte),
+	}
+	for index, share := range shares {
+		group.SharePublicKeys[index] = share.GetPublicKey().Serialize()
+	}
+	return group
+}
+
+func TestGroupVerify(t *testing.T) {
+	sk, shares := testShares(t, 3, 4)
+
+	t.Run("valid", func(t *testing.T) {
+		require.NoError(t, testGroup(sk, 3, shares).Verify())
+	})
+
+	t.Run("other group key", func(t *testing.T) {
+		var other bls.SecretKey
+		other.SetByCSPRNG()
+		err := testGroup(&other, 3, shares).Verify()
+		require.True(t, errors.Is(err, ErrGroupKeyMismatch))
+	})
+
+	t.Run("share of another polynomial", func(t *testing.T) {
+		_, otherShares := testShares(t, 3, 4)
+		group := testGroup(sk, 3, shares)
+		group.SharePublicKeys[4] = otherShares[4].GetPublicKey().Serialize()
+		require.EqualError(t, group.Verify(), "share 4: shares don't match the group public key")
+	})
+
+	t.Run("not enough shares", func(t *testing.T) {
+		err := testGroup(sk, 3, map[uint64]*bls.SecretKey{1: shares[1], 2: shares[2]}).Verify()
+		require.EqualError(t, err, "2 shares for a threshold of 3: not enough shares")
+	})
+}
+
+func TestGroupCombine(t *testing.T) {
+	sk, shares := testShares(t, 2, 3)
+	group := testGroup(sk, 2, shares)
+	root := phase0.Root{1, 2, 3}
+	partial := func(index uint64) []byte {
+		return shares[index].SignByte(root[:]).Serialize()
+	}
+
+	t.Run("any threshold shares", func(t *testing.T) {
+		expected := sk.SignByte(root[:]).Serialize()
+		for _, partials := range []map[uint64][]byte{
+			{1: partial(1), 2: partial(2)},
+			{2: partial(2), 3: partial(3)},
+			{1: partial(1), 2: partial(2), 3: partial(3)},
+		} {
+			sig, err := group.Combine(root, partials)
+			require.NoError(t, err)
+			require.Equal(t, expected, sig)
+		}
+	})
+
+	t.Run("invalid partial signature", func(t *testing.T) {
+		// the invalid one is ignored
+		sig, err := group.Combine(root, map[uint64][]byte{1: partial(2), 2: partial(2), 3: partial(3)})
+		require.NoError(t, err)
+		require.Equal(t, sk.SignByte(root[:]).Serialize(), sig)
+
+		_, err = group.Combine(root, map[uint64][]byte{1: partial(2), 2: partial(2)})
+		require.EqualError(t, err, "1 valid partial signatures for a threshold of 2: share 1: invalid partial signature")
+	})
+
+	t.Run("unknown share", func(t *testing.T) {
+		// the partial signature of an unknown share can't be verified, even if it would recover the group signature
+		unknown := &Group{PublicKey: group.PublicKey, Threshold: 2, SharePublicKeys: map[uint64][]byte{1: group.SharePublicKeys[1]}}
+		_, err := unknown.Combine(root, map[uint64][]byte{1: partial(1), 3: partial(3)})
+		require.True(t, errors.Is(err, ErrUnknownShare))
+		require.EqualError(t, err, "share 3: unknown share")
+
+		_, err = group.Combine(root, map[uint64][]byte{1: partial(1), 4: partial(2)})
+		require.True(t, errors.Is(err, ErrUnknownShare))
+	})
+
+	t.Run("not enough partial signatures", func(t *testing.T) {
+		_, err := group.Combine(root, map[uint64][]byte{1: partial(1)})
+		require.EqualError(t, err, "1 partial signatures for a threshold of 2: not enough shares")
+	})
+}